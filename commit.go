@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/mr-tron/base58/base58"
 )
 
 // Commit encapsulates information about changes to a dataset in
@@ -48,6 +50,27 @@ func (cm *Commit) SignableBytes() []byte {
 	return []byte(fmt.Sprintf("%s\n%s", cm.Timestamp.Format(time.RFC3339), cm.Title))
 }
 
+// Verify checks the commit signature was created by the private key
+// matching pubKey. Verify errors if the commit is unsigned or the
+// signature doesn't match the commit's SignableBytes
+func (cm *Commit) Verify(pubKey crypto.PubKey) error {
+	if pubKey == nil {
+		return fmt.Errorf("public key is required to verify a commit")
+	}
+	if cm.Signature == "" {
+		return fmt.Errorf("commit is not signed")
+	}
+	sig, err := base58.Decode(cm.Signature)
+	if err != nil {
+		return fmt.Errorf("commit signature isn't valid base58")
+	}
+	// some key types report a mismatched signature as an error
+	if ok, err := pubKey.Verify(cm.SignableBytes(), sig); err != nil || !ok {
+		return fmt.Errorf("commit signature doesn't match")
+	}
+	return nil
+}
+
 // SetPath sets the internal path property of a commit
 // Use with caution. most callers should never need to call SetPath
 func (cm *Commit) SetPath(path string) {
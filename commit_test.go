@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/mr-tron/base58/base58"
 )
 
 func TestCommit(t *testing.T) {
@@ -116,6 +118,46 @@ func TestCommitSignableBytes(t *testing.T) {
 	}
 }
 
+func TestCommitVerify(t *testing.T) {
+	privKey, pubKey, err := crypto.GenerateKeyPair(crypto.RSA, 1024)
+	if err != nil {
+		t.Fatalf("error generating key pair: %s", err.Error())
+	}
+	_, otherPubKey, err := crypto.GenerateKeyPair(crypto.RSA, 1024)
+	if err != nil {
+		t.Fatalf("error generating key pair: %s", err.Error())
+	}
+
+	ts := time.Date(2001, 01, 01, 01, 01, 01, 0, time.UTC)
+	signed := &Commit{Title: "initial commit", Timestamp: ts}
+	sig, err := privKey.Sign(signed.SignableBytes())
+	if err != nil {
+		t.Fatalf("error signing commit: %s", err.Error())
+	}
+	signed.Signature = base58.Encode(sig)
+
+	cases := []struct {
+		cm  *Commit
+		key crypto.PubKey
+		err string
+	}{
+		{signed, pubKey, ""},
+		{signed, nil, "public key is required to verify a commit"},
+		{signed, otherPubKey, "commit signature doesn't match"},
+		{&Commit{Title: "initial commit", Timestamp: ts}, pubKey, "commit is not signed"},
+		{&Commit{Title: "changed title", Timestamp: ts, Signature: signed.Signature}, pubKey, "commit signature doesn't match"},
+		{&Commit{Title: "initial commit", Timestamp: ts.Add(time.Hour), Signature: signed.Signature}, pubKey, "commit signature doesn't match"},
+		{&Commit{Title: "initial commit", Timestamp: ts, Signature: "0OIl"}, pubKey, "commit signature isn't valid base58"},
+	}
+
+	for i, c := range cases {
+		err := c.cm.Verify(c.key)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+		}
+	}
+}
+
 func TestCommitMarshalJSON(t *testing.T) {
 	ts := time.Date(2001, 01, 01, 01, 01, 01, 0, time.UTC)
 	cases := []struct {
@@ -29,6 +29,9 @@ func CompareDatasets(a, b *Dataset) error {
 	if a.DataPath != b.DataPath {
 		return fmt.Errorf("DataPath: %s != %s", a.DataPath, b.DataPath)
 	}
+	if a.OffsetIndexPath != b.OffsetIndexPath {
+		return fmt.Errorf("OffsetIndexPath: %s != %s", a.OffsetIndexPath, b.OffsetIndexPath)
+	}
 
 	if err := CompareMetas(a.Meta, b.Meta); err != nil {
 		return fmt.Errorf("Meta: %s", err.Error())
@@ -36,6 +39,9 @@ func CompareDatasets(a, b *Dataset) error {
 	if err := CompareStructures(a.Structure, b.Structure); err != nil {
 		return fmt.Errorf("Structure: %s", err.Error())
 	}
+	if err := CompareStats(a.Stats, b.Stats); err != nil {
+		return fmt.Errorf("Stats: %s", err.Error())
+	}
 	if err := CompareDatasets(a.Abstract, b.Abstract); err != nil {
 		return fmt.Errorf("Abstract: %s", err.Error())
 	}
@@ -191,6 +197,31 @@ func CompareVisConfigs(a, b *VisConfig) error {
 	return nil
 }
 
+// CompareStats checks if all fields of two Stats pointers are equal,
+// returning an error on the first, nil if equal
+// Note that comparison does not examine the internal path property
+func CompareStats(a, b *Stats) error {
+	if a == nil && b == nil {
+		return nil
+	} else if a == nil && b != nil {
+		return fmt.Errorf("nil: <nil> != <not nil>")
+	} else if a != nil && b == nil {
+		return fmt.Errorf("nil: <not nil> != <nil>")
+	}
+	if a.Qri != b.Qri {
+		return fmt.Errorf("Qri: %s != %s", a.Qri, b.Qri)
+	}
+	if len(a.Columns) != len(b.Columns) {
+		return fmt.Errorf("Columns: %d != %d", len(a.Columns), len(b.Columns))
+	}
+	for i, ac := range a.Columns {
+		if !reflect.DeepEqual(ac, b.Columns[i]) {
+			return fmt.Errorf("Columns: column %d not equal", i)
+		}
+	}
+	return nil
+}
+
 // CompareSchemas checks if all fields of two Schema pointers are equal,
 // returning an error on the first, nil if equal
 // Note that comparison does not examine the internal path property
@@ -19,7 +19,10 @@ func TestCompareDatasets(t *testing.T) {
 		{&Dataset{Qri: "a"}, &Dataset{Qri: "b"}, "Qri: a != b"},
 		{&Dataset{PreviousPath: "a"}, &Dataset{PreviousPath: "b"}, "PreviousPath: a != b"},
 		{&Dataset{DataPath: "a"}, &Dataset{DataPath: "b"}, "DataPath: a != b"},
+		{&Dataset{OffsetIndexPath: "a"}, &Dataset{OffsetIndexPath: "b"}, "OffsetIndexPath: a != b"},
 		{&Dataset{}, &Dataset{Structure: &Structure{}}, "Structure: nil: <nil> != <not nil>"},
+		{&Dataset{}, &Dataset{Stats: &Stats{}}, "Stats: nil: <nil> != <not nil>"},
+		{&Dataset{Stats: &Stats{Columns: []*ColumnStats{{Count: 1}}}}, &Dataset{Stats: &Stats{Columns: []*ColumnStats{{Count: 2}}}}, "Stats: Columns: column 0 not equal"},
 		{&Dataset{}, &Dataset{Transform: &Transform{}}, "Transform: nil: <nil> != <not nil>"},
 		{&Dataset{}, &Dataset{AbstractTransform: &Transform{}}, "AbstractTransform: nil: <nil> != <not nil>"},
 		{&Dataset{}, &Dataset{Commit: &Commit{}}, "Commit: nil: <nil> != <not nil>"},
@@ -149,6 +152,28 @@ func TestCompareVisConfigs(t *testing.T) {
 	}
 }
 
+func TestCompareStats(t *testing.T) {
+	cases := []struct {
+		a, b *Stats
+		err  string
+	}{
+		{nil, nil, ""},
+		{&Stats{Qri: KindStats, Columns: []*ColumnStats{{Title: "a", Count: 1}}}, &Stats{Qri: KindStats, Columns: []*ColumnStats{{Title: "a", Count: 1}}}, ""},
+		{&Stats{}, nil, "nil: <not nil> != <nil>"},
+		{nil, &Stats{}, "nil: <nil> != <not nil>"},
+		{&Stats{Qri: "a"}, &Stats{Qri: "b"}, "Qri: a != b"},
+		{&Stats{Columns: []*ColumnStats{{}}}, &Stats{}, "Columns: 1 != 0"},
+		{&Stats{Columns: []*ColumnStats{{Count: 1}}}, &Stats{Columns: []*ColumnStats{{Count: 2}}}, "Columns: column 0 not equal"},
+	}
+
+	for i, c := range cases {
+		err := CompareStats(c.a, c.b)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error: expected: '%s', got: '%s'", i, c.err, err)
+		}
+	}
+}
+
 // 	cases := []struct {
 // 		a, b *Field
 // 		err  string
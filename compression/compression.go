@@ -0,0 +1,134 @@
+package compression
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// MagicLen is the number of leading bytes Detect needs to identify
+// a compression type
+const MagicLen = 4
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+)
+
+// Detect infers a compression type from the leading bytes of a stream,
+// returning None if no known compression signature is found
+func Detect(header []byte) Type {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return Gzip
+	case bytes.HasPrefix(header, zstdMagic):
+		return Zstd
+	case bytes.HasPrefix(header, bzip2Magic) && len(header) > 3 && header[3] >= '1' && header[3] <= '9':
+		// bzip2 magic is followed by a block size digit
+		return Bzip2
+	default:
+		return None
+	}
+}
+
+// NewReader wraps r in a reader that decompresses data compressed
+// with type t. Decompression starts on the first call to Read, so creating
+// a reader never consumes from r. Closing the returned reader does not
+// close r
+func NewReader(t Type, r io.Reader) (io.ReadCloser, error) {
+	switch t {
+	case None:
+		return ioutil.NopCloser(r), nil
+	case Gzip, Zstd, Bzip2:
+		return &reader{t: t, src: r}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression type for reading: %s", t)
+	}
+}
+
+// reader lazily opens a decompressor on the first call to Read
+type reader struct {
+	t     Type
+	src   io.Reader
+	r     io.Reader
+	close func() error
+}
+
+// Read implements the io.Reader interface
+func (r *reader) Read(p []byte) (int, error) {
+	if r.r == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	return r.r.Read(p)
+}
+
+func (r *reader) open() error {
+	switch r.t {
+	case Gzip:
+		gz, err := gzip.NewReader(r.src)
+		if err != nil {
+			if err == io.EOF {
+				return err
+			}
+			return fmt.Errorf("error reading gzip data: %s", err.Error())
+		}
+		r.r, r.close = gz, gz.Close
+	case Zstd:
+		// decode synchronously so readers that are never closed don't
+		// leak decoding goroutines
+		zr, err := zstd.NewReader(r.src, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return fmt.Errorf("error reading zstd data: %s", err.Error())
+		}
+		r.r = zr
+		r.close = func() error {
+			zr.Close()
+			return nil
+		}
+	case Bzip2:
+		r.r = bzip2.NewReader(r.src)
+	}
+	return nil
+}
+
+// Close releases any resources held by the decompressor
+func (r *reader) Close() error {
+	if r.close != nil {
+		return r.close()
+	}
+	return nil
+}
+
+// NewWriter wraps w in a writer that compresses data with type t.
+// Close must be called to flush any buffered data. Closing the returned
+// writer does not close w
+func NewWriter(t Type, w io.Writer) (io.WriteCloser, error) {
+	switch t {
+	case None:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported compression type for writing: %s", t)
+	}
+}
+
+// nopWriteCloser adds a no-op Close method to an io.Writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close implements the io.Closer interface
+func (nopWriteCloser) Close() error {
+	return nil
+}
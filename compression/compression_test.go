@@ -0,0 +1,225 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseTypeString(t *testing.T) {
+	cases := []struct {
+		in     string
+		expect Type
+		err    string
+	}{
+		{"", None, ""},
+		{"gzip", Gzip, ""},
+		{"tar", Tar, ""},
+		{"zstd", Zstd, ""},
+		{"bzip2", Bzip2, ""},
+		{"lzma", None, `invalid compression type "lzma"`},
+	}
+
+	for i, c := range cases {
+		got, err := ParseTypeString(c.in)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+			continue
+		}
+		if got != c.expect {
+			t.Errorf("case %d result mismatch. expected: %s, got: %s", i, c.expect, got)
+		}
+	}
+}
+
+func TestTypeJSON(t *testing.T) {
+	for _, typ := range []Type{None, Gzip, Tar, Zstd, Bzip2} {
+		data, err := json.Marshal(typ)
+		if err != nil {
+			t.Errorf("%s marshal error: %s", typ, err.Error())
+			continue
+		}
+		got := Type(-1)
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Errorf("%s unmarshal error: %s", typ, err.Error())
+			continue
+		}
+		if got != typ {
+			t.Errorf("round trip mismatch. expected: %s, got: %s", typ, got)
+		}
+	}
+}
+
+func TestExtensionType(t *testing.T) {
+	cases := []struct {
+		path   string
+		expect Type
+	}{
+		{"", None},
+		{"data.csv", None},
+		{"data.csv.gz", Gzip},
+		{"data.json.zst", Zstd},
+		{"path/to/data.csv.bz2", Bzip2},
+		{"data.tar", None},
+	}
+
+	for i, c := range cases {
+		if got := ExtensionType(c.path); got != c.expect {
+			t.Errorf("case %d result mismatch. expected: %s, got: %s", i, c.expect, got)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	gz, err := ioutil.ReadFile("testdata/cities.csv.gz")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	bz, err := ioutil.ReadFile("testdata/cities.csv.bz2")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cases := []struct {
+		header []byte
+		expect Type
+	}{
+		{nil, None},
+		{[]byte("city,pop"), None},
+		{gz[:MagicLen], Gzip},
+		{bz[:MagicLen], Bzip2},
+		{[]byte{0x28, 0xb5, 0x2f, 0xfd}, Zstd},
+		{[]byte{0x28, 0xb5}, None},
+		{[]byte("BZh,"), None},
+	}
+
+	for i, c := range cases {
+		if got := Detect(c.header); got != c.expect {
+			t.Errorf("case %d result mismatch. expected: %s, got: %s", i, c.expect, got)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	expect, err := ioutil.ReadFile("testdata/cities.csv")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cases := []struct {
+		typ  Type
+		path string
+		err  string
+	}{
+		{None, "testdata/cities.csv", ""},
+		{Gzip, "testdata/cities.csv.gz", ""},
+		{Bzip2, "testdata/cities.csv.bz2", ""},
+		{Tar, "testdata/cities.csv", "unsupported compression type for reading: tar"},
+	}
+
+	for i, c := range cases {
+		data, err := ioutil.ReadFile(c.path)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		r, err := NewReader(c.typ, bytes.NewReader(data))
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("case %d read error: %s", i, err.Error())
+			continue
+		}
+		if err := r.Close(); err != nil {
+			t.Errorf("case %d close error: %s", i, err.Error())
+		}
+		if !bytes.Equal(expect, got) {
+			t.Errorf("case %d result mismatch. expected:\n%s\ngot:\n%s", i, string(expect), string(got))
+		}
+	}
+}
+
+func TestNewReaderLazy(t *testing.T) {
+	// creating a reader over an empty source must not error, reads
+	// should give an empty result
+	r, err := NewReader(Gzip, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Errorf("unexpected read error: %s", err.Error())
+	}
+	if len(got) != 0 {
+		t.Errorf("expected empty read, got: %q", string(got))
+	}
+
+	r, err = NewReader(Gzip, bytes.NewBufferString("city,pop\ntoronto,40000000\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	expect := "error reading gzip data: gzip: invalid header"
+	if _, err := ioutil.ReadAll(r); err == nil || err.Error() != expect {
+		t.Errorf("error mismatch. expected: %s, got: %s", expect, err)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	data := []byte("city,pop\ntoronto,40000000\nnew york,8500000\n")
+
+	cases := []struct {
+		typ Type
+		err string
+	}{
+		{None, ""},
+		{Gzip, ""},
+		{Zstd, ""},
+		{Bzip2, "unsupported compression type for writing: bzip2"},
+		{Tar, "unsupported compression type for writing: tar"},
+	}
+
+	for i, c := range cases {
+		buf := &bytes.Buffer{}
+		w, err := NewWriter(c.typ, buf)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		if _, err := w.Write(data); err != nil {
+			t.Errorf("case %d write error: %s", i, err.Error())
+			continue
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("case %d close error: %s", i, err.Error())
+			continue
+		}
+
+		if got := Detect(buf.Bytes()); got != c.typ {
+			t.Errorf("case %d detected type mismatch. expected: %s, got: %s", i, c.typ, got)
+		}
+
+		r, err := NewReader(c.typ, buf)
+		if err != nil {
+			t.Errorf("case %d reader error: %s", i, err.Error())
+			continue
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("case %d read error: %s", i, err.Error())
+			continue
+		}
+		if !bytes.Equal(data, got) {
+			t.Errorf("case %d result mismatch. expected:\n%s\ngot:\n%s", i, string(data), string(got))
+		}
+	}
+}
@@ -1,10 +1,11 @@
-// Package compression is a horrible hack & should be replaced
-// as soon as humanly possible
+// Package compression defines the byte compression types a dataset body
+// can be stored with, and readers & writers for working with them
 package compression
 
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 )
 
 // Type represents a type of byte compression
@@ -15,20 +16,39 @@ const (
 	None Type = iota
 	// Gzip specifies Gzip compression
 	Gzip
-	// Tar specifies tar compression
+	// Tar specifies tar compression. tar is an archive format, not a
+	// compression, and isn't supported by readers or writers
 	Tar
+	// Zstd specifies Zstandard compression
+	Zstd
+	// Bzip2 specifies bzip2 compression. the standard library only
+	// decompresses bzip2, so bzip2 data can be read but not stored
+	Bzip2
 )
 
 // Names maps the name of a hash to codes
 var Names = map[Type]string{
-	None: "",
-	Gzip: "gzip",
-	Tar:  "tar",
+	None:  "",
+	Gzip:  "gzip",
+	Tar:   "tar",
+	Zstd:  "zstd",
+	Bzip2: "bzip2",
 }
 
 // Codes maps a hash code to it's name
 var Codes = map[string]Type{
-	"": None,
+	"":      None,
+	"gzip":  Gzip,
+	"tar":   Tar,
+	"zstd":  Zstd,
+	"bzip2": Bzip2,
+}
+
+// Extensions maps compression types to their conventional file extension
+var Extensions = map[Type]string{
+	Gzip:  ".gz",
+	Zstd:  ".zst",
+	Bzip2: ".bz2",
 }
 
 // ParseTypeString returns a compression type for a given string
@@ -42,6 +62,19 @@ func ParseTypeString(s string) (t Type, err error) {
 	return
 }
 
+// ExtensionType returns the compression type for the extension of a
+// given filepath, returning None if the extension isn't a recognized
+// compression extension
+func ExtensionType(path string) Type {
+	ext := filepath.Ext(path)
+	for t, e := range Extensions {
+		if ext == e {
+			return t
+		}
+	}
+	return None
+}
+
 // String satisfies the stringer interface
 func (t Type) String() string {
 	return Names[t]
@@ -28,11 +28,16 @@ const (
 	// read more at cbor.io
 	CBORDataFormat
 	// XMLDataFormat specifies eXtensible Markup Language-formatted data
-	// currently not supported.
 	XMLDataFormat
-	// XLSDataFormat specifies microsoft excel formatted data
-	// currently not supported.
+	// XLSDataFormat specifies microsoft excel formatted data.
+	// only reading office open xml workbooks (.xlsx files) is supported
 	XLSDataFormat
+	// NDJSONDataFormat specifies newline-delimited JSON-formatted data,
+	// where each line is a single JSON value. read more at ndjson.org
+	NDJSONDataFormat
+	// ParquetDataFormat specifies Apache Parquet columnar data.
+	// read more at parquet.apache.org
+	ParquetDataFormat
 )
 
 // SupportedDataFormats gives a slice of data formats that are
@@ -44,6 +49,9 @@ func SupportedDataFormats() []DataFormat {
 		CBORDataFormat,
 		JSONDataFormat,
 		CSVDataFormat,
+		XMLDataFormat,
+		NDJSONDataFormat,
+		ParquetDataFormat,
 	}
 }
 
@@ -56,6 +64,8 @@ func (f DataFormat) String() string {
 		XMLDataFormat:     "xml",
 		XLSDataFormat:     "xls",
 		CBORDataFormat:    "cbor",
+		NDJSONDataFormat:  "ndjson",
+		ParquetDataFormat: "parquet",
 	}[f]
 
 	if !ok {
@@ -68,17 +78,25 @@ func (f DataFormat) String() string {
 // ParseDataFormatString takes a string representation of a data format
 func ParseDataFormatString(s string) (df DataFormat, err error) {
 	df, ok := map[string]DataFormat{
-		"":      UnknownDataFormat,
-		".csv":  CSVDataFormat,
-		"csv":   CSVDataFormat,
-		".json": JSONDataFormat,
-		"json":  JSONDataFormat,
-		".xml":  XMLDataFormat,
-		"xml":   XMLDataFormat,
-		".xls":  XLSDataFormat,
-		"xls":   XLSDataFormat,
-		"cbor":  CBORDataFormat,
-		".cbor": CBORDataFormat,
+		"":         UnknownDataFormat,
+		".csv":     CSVDataFormat,
+		"csv":      CSVDataFormat,
+		".json":    JSONDataFormat,
+		"json":     JSONDataFormat,
+		".xml":     XMLDataFormat,
+		"xml":      XMLDataFormat,
+		".xls":     XLSDataFormat,
+		"xls":      XLSDataFormat,
+		".xlsx":    XLSDataFormat,
+		"xlsx":     XLSDataFormat,
+		"cbor":     CBORDataFormat,
+		".cbor":    CBORDataFormat,
+		"ndjson":   NDJSONDataFormat,
+		".ndjson":  NDJSONDataFormat,
+		"jsonl":    NDJSONDataFormat,
+		".jsonl":   NDJSONDataFormat,
+		"parquet":  ParquetDataFormat,
+		".parquet": ParquetDataFormat,
 	}[s]
 	if !ok {
 		err = fmt.Errorf("invalid data format: `%s`", s)
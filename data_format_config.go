@@ -2,6 +2,7 @@ package dataset
 
 import (
 	"fmt"
+	"unicode/utf8"
 )
 
 // FormatConfig is the interface for data format configurations
@@ -20,6 +21,12 @@ func ParseFormatConfigMap(f DataFormat, opts map[string]interface{}) (FormatConf
 		return NewCSVOptions(opts)
 	case JSONDataFormat:
 		return NewJSONOptions(opts)
+	case XMLDataFormat:
+		return NewXMLOptions(opts)
+	case XLSDataFormat:
+		return NewXLSOptions(opts)
+	case ParquetDataFormat:
+		return NewParquetOptions(opts)
 	default:
 		return nil, fmt.Errorf("cannot parse configuration for format: %s", f.String())
 	}
@@ -38,15 +45,117 @@ func NewCSVOptions(opts map[string]interface{}) (FormatConfig, error) {
 			return nil, fmt.Errorf("invalid headerRow value: %s", opts["headerRow"])
 		}
 	}
+	if opts["delimiter"] != nil {
+		r, ok := optionRune(opts["delimiter"])
+		if !ok || r == '\r' || r == '\n' {
+			return nil, fmt.Errorf("invalid delimiter value: %v", opts["delimiter"])
+		}
+		o.Delimiter = r
+	}
+	if opts["quote"] != nil {
+		// quote characters must be a single byte
+		r, ok := optionRune(opts["quote"])
+		if !ok || r >= utf8.RuneSelf || r == '\r' || r == '\n' {
+			return nil, fmt.Errorf("invalid quote value: %v", opts["quote"])
+		}
+		o.Quote = r
+	}
+	if opts["lazyQuotes"] != nil {
+		if lazyQuotes, ok := opts["lazyQuotes"].(bool); ok {
+			o.LazyQuotes = lazyQuotes
+		} else {
+			return nil, fmt.Errorf("invalid lazyQuotes value: %v", opts["lazyQuotes"])
+		}
+	}
+	if opts["comment"] != nil {
+		r, ok := optionRune(opts["comment"])
+		if !ok || r == '\r' || r == '\n' {
+			return nil, fmt.Errorf("invalid comment value: %v", opts["comment"])
+		}
+		o.Comment = r
+	}
+	if opts["nullStrings"] != nil {
+		switch nulls := opts["nullStrings"].(type) {
+		case []string:
+			o.NullStrings = nulls
+		case []interface{}:
+			o.NullStrings = make([]string, len(nulls))
+			for i, n := range nulls {
+				str, ok := n.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid nullStrings value: %v", opts["nullStrings"])
+				}
+				o.NullStrings[i] = str
+			}
+		default:
+			return nil, fmt.Errorf("invalid nullStrings value: %v", opts["nullStrings"])
+		}
+	}
+	if opts["variadicFields"] != nil {
+		if variadicFields, ok := opts["variadicFields"].(bool); ok {
+			o.VariadicFields = variadicFields
+		} else {
+			return nil, fmt.Errorf("invalid variadicFields value: %v", opts["variadicFields"])
+		}
+	}
+
+	// double quotes are reserved by the csv parser, even if another quote
+	// character is used
+	quote := o.Quote
+	if quote == 0 {
+		quote = '"'
+	}
+	if o.Delimiter == '"' || o.Delimiter == quote {
+		return nil, fmt.Errorf("delimiter cannot be a quote character")
+	}
+	if o.Comment == '"' || o.Comment == quote {
+		return nil, fmt.Errorf("comment cannot be a quote character")
+	}
+	delim := o.Delimiter
+	if delim == 0 {
+		delim = ','
+	}
+	if o.Comment == delim {
+		return nil, fmt.Errorf("delimiter and comment characters must differ")
+	}
 
 	return o, nil
 }
 
+// optionRune reads a single-character string option as a rune
+func optionRune(v interface{}) (rune, bool) {
+	str, ok := v.(string)
+	if !ok || utf8.RuneCountInString(str) != 1 {
+		return 0, false
+	}
+	r, _ := utf8.DecodeRuneInString(str)
+	return r, r != utf8.RuneError
+}
+
 // CSVOptions specifies configuration details for csv files
 // This'll expand in the future to interoperate with okfn csv spec
 type CSVOptions struct {
 	// HeaderRow specifies weather this csv file has a header row or not
 	HeaderRow bool `json:"headerRow"`
+	// Delimiter is the field delimiter, defaults to a comma.
+	// Set to '\t' for tab-separated values
+	Delimiter rune `json:"delimiter,omitempty"`
+	// Quote is the character used to quote fields, defaults to a double quote
+	// quote characters must be a single byte
+	Quote rune `json:"quote,omitempty"`
+	// LazyQuotes allows quotes to appear in unquoted fields, and
+	// non-doubled quotes to appear in quoted fields
+	LazyQuotes bool `json:"lazyQuotes,omitempty"`
+	// Comment is a character that, when at the start of a line, marks the
+	// line as a comment to be skipped. comments are disabled by default
+	Comment rune `json:"comment,omitempty"`
+	// NullStrings are field values that are read as null. When writing, null
+	// values are written as the first null string, or an empty field if
+	// no null strings are specified
+	NullStrings []string `json:"nullStrings,omitempty"`
+	// VariadicFields allows rows to have differing numbers of fields.
+	// by default all rows must have as many fields as the first row
+	VariadicFields bool `json:"variadicFields,omitempty"`
 }
 
 // Format announces the CSV Data Format for the FormatConfig interface
@@ -59,9 +168,28 @@ func (o *CSVOptions) Map() map[string]interface{} {
 	if o == nil {
 		return nil
 	}
-	return map[string]interface{}{
+	opt := map[string]interface{}{
 		"headerRow": o.HeaderRow,
 	}
+	if o.Delimiter != 0 {
+		opt["delimiter"] = string(o.Delimiter)
+	}
+	if o.Quote != 0 {
+		opt["quote"] = string(o.Quote)
+	}
+	if o.LazyQuotes {
+		opt["lazyQuotes"] = o.LazyQuotes
+	}
+	if o.Comment != 0 {
+		opt["comment"] = string(o.Comment)
+	}
+	if len(o.NullStrings) > 0 {
+		opt["nullStrings"] = o.NullStrings
+	}
+	if o.VariadicFields {
+		opt["variadicFields"] = o.VariadicFields
+	}
+	return opt
 }
 
 // NewJSONOptions creates a JSONOptions pointer from a map
@@ -91,3 +219,245 @@ func (o *JSONOptions) Map() map[string]interface{} {
 	}
 	return map[string]interface{}{}
 }
+
+// NewXMLOptions creates a XMLOptions pointer from a map
+func NewXMLOptions(opts map[string]interface{}) (FormatConfig, error) {
+	o := &XMLOptions{}
+	if opts == nil {
+		return o, nil
+	}
+
+	if opts["rootElement"] != nil {
+		if root, ok := opts["rootElement"].(string); ok {
+			o.RootElement = root
+		} else {
+			return nil, fmt.Errorf("invalid rootElement value: %v", opts["rootElement"])
+		}
+	}
+	if opts["entryElement"] != nil {
+		if entry, ok := opts["entryElement"].(string); ok {
+			o.EntryElement = entry
+		} else {
+			return nil, fmt.Errorf("invalid entryElement value: %v", opts["entryElement"])
+		}
+	}
+	if opts["attributePrefix"] != nil {
+		if prefix, ok := opts["attributePrefix"].(string); ok {
+			o.AttributePrefix = prefix
+		} else {
+			return nil, fmt.Errorf("invalid attributePrefix value: %v", opts["attributePrefix"])
+		}
+	}
+	if opts["ignoreAttributes"] != nil {
+		if ignore, ok := opts["ignoreAttributes"].(bool); ok {
+			o.IgnoreAttributes = ignore
+		} else {
+			return nil, fmt.Errorf("invalid ignoreAttributes value: %v", opts["ignoreAttributes"])
+		}
+	}
+
+	return o, nil
+}
+
+// XMLOptions specifies configuration details for xml files. XML datasets
+// are a single root element containing repeated child elements, with each
+// child element constituting an entry
+type XMLOptions struct {
+	// RootElement is the name of the element that encloses all entries.
+	// readers accept any root element name, writers default to "data"
+	RootElement string `json:"rootElement,omitempty"`
+	// EntryElement is the name of the repeated child element that forms an entry.
+	// If empty, readers treat every child of the root element as an entry,
+	// and writers default to "entry"
+	EntryElement string `json:"entryElement,omitempty"`
+	// AttributePrefix is prepended to attribute names when attributes are
+	// mapped to object keys. defaults to "@"
+	AttributePrefix string `json:"attributePrefix,omitempty"`
+	// IgnoreAttributes drops all element attributes when reading
+	IgnoreAttributes bool `json:"ignoreAttributes,omitempty"`
+}
+
+// Format announces the XML Data Format for the FormatConfig interface
+func (*XMLOptions) Format() DataFormat {
+	return XMLDataFormat
+}
+
+// Map returns a map[string]interface representation of the configuration
+func (o *XMLOptions) Map() map[string]interface{} {
+	if o == nil {
+		return nil
+	}
+	opt := map[string]interface{}{}
+	if o.RootElement != "" {
+		opt["rootElement"] = o.RootElement
+	}
+	if o.EntryElement != "" {
+		opt["entryElement"] = o.EntryElement
+	}
+	if o.AttributePrefix != "" {
+		opt["attributePrefix"] = o.AttributePrefix
+	}
+	if o.IgnoreAttributes {
+		opt["ignoreAttributes"] = o.IgnoreAttributes
+	}
+	return opt
+}
+
+// NewXLSOptions creates a XLSOptions pointer from a map
+func NewXLSOptions(opts map[string]interface{}) (FormatConfig, error) {
+	o := &XLSOptions{}
+	if opts == nil {
+		return o, nil
+	}
+
+	if opts["sheetName"] != nil {
+		if name, ok := opts["sheetName"].(string); ok {
+			o.SheetName = name
+		} else {
+			return nil, fmt.Errorf("invalid sheetName value: %v", opts["sheetName"])
+		}
+	}
+	if opts["sheetIndex"] != nil {
+		// numbers decoded from json are float64
+		switch idx := opts["sheetIndex"].(type) {
+		case int:
+			o.SheetIndex = idx
+		case float64:
+			if idx != float64(int(idx)) {
+				return nil, fmt.Errorf("invalid sheetIndex value: %v", opts["sheetIndex"])
+			}
+			o.SheetIndex = int(idx)
+		default:
+			return nil, fmt.Errorf("invalid sheetIndex value: %v", opts["sheetIndex"])
+		}
+		if o.SheetIndex < 0 {
+			return nil, fmt.Errorf("invalid sheetIndex value: %v", opts["sheetIndex"])
+		}
+	}
+	if opts["headerRow"] != nil {
+		if headerRow, ok := opts["headerRow"].(bool); ok {
+			o.HeaderRow = headerRow
+		} else {
+			return nil, fmt.Errorf("invalid headerRow value: %v", opts["headerRow"])
+		}
+	}
+
+	return o, nil
+}
+
+// XLSOptions specifies configuration details for excel workbooks
+type XLSOptions struct {
+	// SheetName is the name of the worksheet to read. If set, SheetName
+	// takes precedence over SheetIndex
+	SheetName string `json:"sheetName,omitempty"`
+	// SheetIndex is the zero-based position of the worksheet to read within
+	// the workbook, defaults to the first sheet
+	SheetIndex int `json:"sheetIndex,omitempty"`
+	// HeaderRow specifies weather the first row of the sheet
+	// is a header row that should be skipped when reading
+	HeaderRow bool `json:"headerRow,omitempty"`
+}
+
+// Format announces the XLS Data Format for the FormatConfig interface
+func (*XLSOptions) Format() DataFormat {
+	return XLSDataFormat
+}
+
+// Map returns a map[string]interface representation of the configuration
+func (o *XLSOptions) Map() map[string]interface{} {
+	if o == nil {
+		return nil
+	}
+	opt := map[string]interface{}{}
+	if o.SheetName != "" {
+		opt["sheetName"] = o.SheetName
+	}
+	if o.SheetIndex != 0 {
+		opt["sheetIndex"] = o.SheetIndex
+	}
+	if o.HeaderRow {
+		opt["headerRow"] = o.HeaderRow
+	}
+	return opt
+}
+
+// ParquetCodecs lists the column compression codecs supported for
+// parquet data
+var ParquetCodecs = []string{"uncompressed", "snappy", "gzip", "zstd"}
+
+// NewParquetOptions creates a ParquetOptions pointer from a map
+func NewParquetOptions(opts map[string]interface{}) (FormatConfig, error) {
+	o := &ParquetOptions{}
+	if opts == nil {
+		return o, nil
+	}
+
+	if opts["rowGroupSize"] != nil {
+		// numbers decoded from json are float64
+		switch size := opts["rowGroupSize"].(type) {
+		case int:
+			o.RowGroupSize = size
+		case float64:
+			if size != float64(int(size)) {
+				return nil, fmt.Errorf("invalid rowGroupSize value: %v", opts["rowGroupSize"])
+			}
+			o.RowGroupSize = int(size)
+		default:
+			return nil, fmt.Errorf("invalid rowGroupSize value: %v", opts["rowGroupSize"])
+		}
+		if o.RowGroupSize < 0 {
+			return nil, fmt.Errorf("invalid rowGroupSize value: %v", opts["rowGroupSize"])
+		}
+	}
+	if opts["codec"] != nil {
+		codec, ok := opts["codec"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid codec value: %v", opts["codec"])
+		}
+		valid := false
+		for _, c := range ParquetCodecs {
+			if codec == c {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid codec value: %v", opts["codec"])
+		}
+		o.Codec = codec
+	}
+
+	return o, nil
+}
+
+// ParquetOptions specifies configuration details for writing parquet data.
+// Parquet files describe their own layout, so options have no effect
+// on reading
+type ParquetOptions struct {
+	// RowGroupSize is the maximum number of rows written to each
+	// row group, defaults to 10000
+	RowGroupSize int `json:"rowGroupSize,omitempty"`
+	// Codec is the compression codec for column data, one of
+	// "uncompressed", "snappy", "gzip" or "zstd". defaults to snappy
+	Codec string `json:"codec,omitempty"`
+}
+
+// Format announces the Parquet Data Format for the FormatConfig interface
+func (*ParquetOptions) Format() DataFormat {
+	return ParquetDataFormat
+}
+
+// Map returns a map[string]interface representation of the configuration
+func (o *ParquetOptions) Map() map[string]interface{} {
+	if o == nil {
+		return nil
+	}
+	opt := map[string]interface{}{}
+	if o.RowGroupSize != 0 {
+		opt["rowGroupSize"] = o.RowGroupSize
+	}
+	if o.Codec != "" {
+		opt["codec"] = o.Codec
+	}
+	return opt
+}
@@ -1,7 +1,9 @@
 package dataset
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -30,7 +32,10 @@ func TestParseFormatConfigMap(t *testing.T) {
 	}{
 		{CSVDataFormat, map[string]interface{}{}, &CSVOptions{}, ""},
 		{JSONDataFormat, map[string]interface{}{}, &JSONOptions{}, ""},
-		{XLSDataFormat, map[string]interface{}{}, nil, "cannot parse configuration for format: xls"},
+		{XMLDataFormat, map[string]interface{}{}, &XMLOptions{}, ""},
+		{XLSDataFormat, map[string]interface{}{}, &XLSOptions{}, ""},
+		{ParquetDataFormat, map[string]interface{}{}, &ParquetOptions{}, ""},
+		{CBORDataFormat, map[string]interface{}{}, nil, "cannot parse configuration for format: cbor"},
 	}
 
 	for i, c := range cases {
@@ -56,6 +61,24 @@ func TestNewCSVOptions(t *testing.T) {
 		{map[string]interface{}{}, &CSVOptions{}, ""},
 		{map[string]interface{}{"headerRow": true}, &CSVOptions{HeaderRow: true}, ""},
 		{map[string]interface{}{"headerRow": "foo"}, nil, "invalid headerRow value: foo"},
+		{map[string]interface{}{"delimiter": "\t", "quote": "'", "comment": "#"}, &CSVOptions{Delimiter: '\t', Quote: '\'', Comment: '#'}, ""},
+		{map[string]interface{}{"lazyQuotes": true, "variadicFields": true}, &CSVOptions{LazyQuotes: true, VariadicFields: true}, ""},
+		{map[string]interface{}{"nullStrings": []interface{}{"NA", ""}}, &CSVOptions{NullStrings: []string{"NA", ""}}, ""},
+		{map[string]interface{}{"nullStrings": []string{"NULL"}}, &CSVOptions{NullStrings: []string{"NULL"}}, ""},
+		{map[string]interface{}{"delimiter": ";;"}, nil, "invalid delimiter value: ;;"},
+		{map[string]interface{}{"delimiter": "\n"}, nil, "invalid delimiter value: \n"},
+		{map[string]interface{}{"delimiter": 5}, nil, "invalid delimiter value: 5"},
+		{map[string]interface{}{"quote": "é"}, nil, "invalid quote value: é"},
+		{map[string]interface{}{"lazyQuotes": "yes"}, nil, "invalid lazyQuotes value: yes"},
+		{map[string]interface{}{"comment": ""}, nil, "invalid comment value: "},
+		{map[string]interface{}{"nullStrings": "NA"}, nil, "invalid nullStrings value: NA"},
+		{map[string]interface{}{"nullStrings": []interface{}{1}}, nil, "invalid nullStrings value: [1]"},
+		{map[string]interface{}{"variadicFields": 1}, nil, "invalid variadicFields value: 1"},
+		{map[string]interface{}{"delimiter": "\""}, nil, "delimiter cannot be a quote character"},
+		{map[string]interface{}{"delimiter": "'", "quote": "'"}, nil, "delimiter cannot be a quote character"},
+		{map[string]interface{}{"comment": "'", "quote": "'"}, nil, "comment cannot be a quote character"},
+		{map[string]interface{}{"comment": ","}, nil, "delimiter and comment characters must differ"},
+		{map[string]interface{}{"comment": ";", "delimiter": ";"}, nil, "delimiter and comment characters must differ"},
 	}
 
 	for i, c := range cases {
@@ -75,6 +98,10 @@ func TestNewCSVOptions(t *testing.T) {
 				fmt.Errorf("case %d HeaderRow expected: %t, got: %t", i, csvo.HeaderRow, c.res.HeaderRow)
 				continue
 			}
+			if !reflect.DeepEqual(csvo, c.res) {
+				t.Errorf("case %d result mismatch. expected: %v, got: %v", i, c.res, csvo)
+				continue
+			}
 		}
 	}
 }
@@ -86,10 +113,22 @@ func TestCSVOptionsMap(t *testing.T) {
 	}{
 		{nil, nil},
 		{&CSVOptions{HeaderRow: true}, map[string]interface{}{"headerRow": true}},
+		{&CSVOptions{Delimiter: '\t', Quote: '\'', LazyQuotes: true, Comment: '#', VariadicFields: true}, map[string]interface{}{
+			"headerRow":      false,
+			"delimiter":      "\t",
+			"quote":          "'",
+			"lazyQuotes":     true,
+			"comment":        "#",
+			"variadicFields": true,
+		}},
 	}
 
 	for i, c := range cases {
 		got := c.opt.Map()
+		if len(got) != len(c.res) {
+			t.Errorf("case %d length mismatch. expected: %d, got: %d", i, len(c.res), len(got))
+			continue
+		}
 		for key, val := range c.res {
 			if got[key] != val {
 				t.Errorf("case %d, key '%s' expected: '%s' got:'%s'", i, key, val, got[key])
@@ -142,3 +181,210 @@ func TestJSONOptionsMap(t *testing.T) {
 		}
 	}
 }
+
+func TestNewXMLOptions(t *testing.T) {
+	cases := []struct {
+		opts map[string]interface{}
+		res  *XMLOptions
+		err  string
+	}{
+		{nil, &XMLOptions{}, ""},
+		{map[string]interface{}{}, &XMLOptions{}, ""},
+		{map[string]interface{}{"rootElement": "cities", "entryElement": "city"}, &XMLOptions{RootElement: "cities", EntryElement: "city"}, ""},
+		{map[string]interface{}{"attributePrefix": "_", "ignoreAttributes": true}, &XMLOptions{AttributePrefix: "_", IgnoreAttributes: true}, ""},
+		{map[string]interface{}{"rootElement": 5}, nil, "invalid rootElement value: 5"},
+		{map[string]interface{}{"entryElement": false}, nil, "invalid entryElement value: false"},
+		{map[string]interface{}{"attributePrefix": 1}, nil, "invalid attributePrefix value: 1"},
+		{map[string]interface{}{"ignoreAttributes": "yes"}, nil, "invalid ignoreAttributes value: yes"},
+	}
+
+	for i, c := range cases {
+		got, err := NewXMLOptions(c.opts)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error expected: '%s', got: '%s'", i, c.err, err)
+			continue
+		}
+		if c.err == "" {
+			xmlo, ok := got.(*XMLOptions)
+			if !ok {
+				t.Errorf("case %d didn't return a XMLOptions pointer", i)
+				continue
+			}
+			if *xmlo != *c.res {
+				t.Errorf("case %d result mismatch. expected: %v, got: %v", i, c.res, xmlo)
+				continue
+			}
+		}
+	}
+}
+
+func TestXMLOptionsMap(t *testing.T) {
+	cases := []struct {
+		opt *XMLOptions
+		res map[string]interface{}
+	}{
+		{nil, nil},
+		{&XMLOptions{}, map[string]interface{}{}},
+		{&XMLOptions{RootElement: "cities", IgnoreAttributes: true}, map[string]interface{}{"rootElement": "cities", "ignoreAttributes": true}},
+	}
+
+	for i, c := range cases {
+		got := c.opt.Map()
+		if len(got) != len(c.res) {
+			t.Errorf("case %d length mismatch. expected: %d, got: %d", i, len(c.res), len(got))
+			continue
+		}
+		for key, val := range c.res {
+			if got[key] != val {
+				t.Errorf("case %d, key '%s' expected: '%s' got:'%s'", i, key, val, got[key])
+			}
+		}
+	}
+}
+
+func TestNewXLSOptions(t *testing.T) {
+	cases := []struct {
+		opts map[string]interface{}
+		res  *XLSOptions
+		err  string
+	}{
+		{nil, &XLSOptions{}, ""},
+		{map[string]interface{}{}, &XLSOptions{}, ""},
+		{map[string]interface{}{"sheetName": "cities", "headerRow": true}, &XLSOptions{SheetName: "cities", HeaderRow: true}, ""},
+		{map[string]interface{}{"sheetIndex": 2}, &XLSOptions{SheetIndex: 2}, ""},
+		{map[string]interface{}{"sheetIndex": float64(1)}, &XLSOptions{SheetIndex: 1}, ""},
+		{map[string]interface{}{"sheetName": 5}, nil, "invalid sheetName value: 5"},
+		{map[string]interface{}{"sheetIndex": 1.5}, nil, "invalid sheetIndex value: 1.5"},
+		{map[string]interface{}{"sheetIndex": -1}, nil, "invalid sheetIndex value: -1"},
+		{map[string]interface{}{"sheetIndex": "one"}, nil, "invalid sheetIndex value: one"},
+		{map[string]interface{}{"headerRow": "foo"}, nil, "invalid headerRow value: foo"},
+	}
+
+	for i, c := range cases {
+		got, err := NewXLSOptions(c.opts)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error expected: '%s', got: '%s'", i, c.err, err)
+			continue
+		}
+		if c.err == "" {
+			xlso, ok := got.(*XLSOptions)
+			if !ok {
+				t.Errorf("case %d didn't return a XLSOptions pointer", i)
+				continue
+			}
+			if *xlso != *c.res {
+				t.Errorf("case %d result mismatch. expected: %v, got: %v", i, c.res, xlso)
+				continue
+			}
+		}
+	}
+}
+
+func TestXLSOptionsMap(t *testing.T) {
+	cases := []struct {
+		opt *XLSOptions
+		res map[string]interface{}
+	}{
+		{nil, nil},
+		{&XLSOptions{}, map[string]interface{}{}},
+		{&XLSOptions{SheetName: "cities", SheetIndex: 1, HeaderRow: true}, map[string]interface{}{"sheetName": "cities", "sheetIndex": 1, "headerRow": true}},
+	}
+
+	for i, c := range cases {
+		got := c.opt.Map()
+		if len(got) != len(c.res) {
+			t.Errorf("case %d length mismatch. expected: %d, got: %d", i, len(c.res), len(got))
+			continue
+		}
+		for key, val := range c.res {
+			if got[key] != val {
+				t.Errorf("case %d, key '%s' expected: '%s' got:'%s'", i, key, val, got[key])
+			}
+		}
+	}
+}
+
+func TestCSVOptionsRoundTrip(t *testing.T) {
+	opts := &CSVOptions{
+		HeaderRow:      true,
+		Delimiter:      ';',
+		Quote:          '\'',
+		LazyQuotes:     true,
+		Comment:        '#',
+		NullStrings:    []string{"NA", "-"},
+		VariadicFields: true,
+	}
+
+	data, err := json.Marshal(opts.Map())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	got, err := NewCSVOptions(m)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(opts, got) {
+		t.Errorf("round trip mismatch. expected: %v, got: %v", opts, got)
+	}
+}
+
+func TestNewParquetOptions(t *testing.T) {
+	cases := []struct {
+		opts map[string]interface{}
+		res  *ParquetOptions
+		err  string
+	}{
+		{nil, &ParquetOptions{}, ""},
+		{map[string]interface{}{}, &ParquetOptions{}, ""},
+		{map[string]interface{}{"rowGroupSize": 500, "codec": "gzip"}, &ParquetOptions{RowGroupSize: 500, Codec: "gzip"}, ""},
+		{map[string]interface{}{"rowGroupSize": float64(1000)}, &ParquetOptions{RowGroupSize: 1000}, ""},
+		{map[string]interface{}{"codec": "uncompressed"}, &ParquetOptions{Codec: "uncompressed"}, ""},
+		{map[string]interface{}{"rowGroupSize": 1.5}, nil, "invalid rowGroupSize value: 1.5"},
+		{map[string]interface{}{"rowGroupSize": -1}, nil, "invalid rowGroupSize value: -1"},
+		{map[string]interface{}{"rowGroupSize": "big"}, nil, "invalid rowGroupSize value: big"},
+		{map[string]interface{}{"codec": "lzo"}, nil, "invalid codec value: lzo"},
+		{map[string]interface{}{"codec": 1}, nil, "invalid codec value: 1"},
+	}
+
+	for i, c := range cases {
+		got, err := NewParquetOptions(c.opts)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error expected: '%s', got: '%s'", i, c.err, err)
+			continue
+		}
+		if c.err == "" {
+			po, ok := got.(*ParquetOptions)
+			if !ok {
+				t.Errorf("case %d didn't return a ParquetOptions pointer", i)
+				continue
+			}
+			if *po != *c.res {
+				t.Errorf("case %d result mismatch. expected: %v, got: %v", i, c.res, po)
+				continue
+			}
+		}
+	}
+}
+
+func TestParquetOptionsMap(t *testing.T) {
+	cases := []struct {
+		opt *ParquetOptions
+		res map[string]interface{}
+	}{
+		{nil, nil},
+		{&ParquetOptions{}, map[string]interface{}{}},
+		{&ParquetOptions{RowGroupSize: 100, Codec: "zstd"}, map[string]interface{}{"rowGroupSize": 100, "codec": "zstd"}},
+	}
+
+	for i, c := range cases {
+		got := c.opt.Map()
+		if !reflect.DeepEqual(c.res, got) {
+			t.Errorf("case %d result mismatch. expected: %v, got: %v", i, c.res, got)
+		}
+	}
+}
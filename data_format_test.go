@@ -10,6 +10,9 @@ func TestSupportedDataFormats(t *testing.T) {
 		CBORDataFormat,
 		JSONDataFormat,
 		CSVDataFormat,
+		XMLDataFormat,
+		NDJSONDataFormat,
+		ParquetDataFormat,
 	}
 
 	for i, f := range SupportedDataFormats() {
@@ -30,6 +33,8 @@ func TestDataFormatString(t *testing.T) {
 		{XMLDataFormat, "xml"},
 		{XLSDataFormat, "xls"},
 		{CBORDataFormat, "cbor"},
+		{NDJSONDataFormat, "ndjson"},
+		{ParquetDataFormat, "parquet"},
 	}
 
 	for i, c := range cases {
@@ -55,8 +60,16 @@ func TestParseDataFormatString(t *testing.T) {
 		{"xml", XMLDataFormat, ""},
 		{".xls", XLSDataFormat, ""},
 		{"xls", XLSDataFormat, ""},
+		{".xlsx", XLSDataFormat, ""},
+		{"xlsx", XLSDataFormat, ""},
 		{"cbor", CBORDataFormat, ""},
 		{".cbor", CBORDataFormat, ""},
+		{"ndjson", NDJSONDataFormat, ""},
+		{".ndjson", NDJSONDataFormat, ""},
+		{"jsonl", NDJSONDataFormat, ""},
+		{".jsonl", NDJSONDataFormat, ""},
+		{"parquet", ParquetDataFormat, ""},
+		{".parquet", ParquetDataFormat, ""},
 	}
 
 	for i, c := range cases {
@@ -83,6 +96,8 @@ func TestDataFormatMarshalJSON(t *testing.T) {
 		{XMLDataFormat, []byte(`"xml"`), ""},
 		{XLSDataFormat, []byte(`"xls"`), ""},
 		{CBORDataFormat, []byte(`"cbor"`), ""},
+		{NDJSONDataFormat, []byte(`"ndjson"`), ""},
+		{ParquetDataFormat, []byte(`"parquet"`), ""},
 	}
 	for i, c := range cases {
 		got, err := c.format.MarshalJSON()
@@ -108,6 +123,8 @@ func TestDataFormatUnmarshalJSON(t *testing.T) {
 		{[]byte(`"xml"`), XMLDataFormat, ""},
 		{[]byte(`"xls"`), XLSDataFormat, ""},
 		{[]byte(`"cbor"`), CBORDataFormat, ""},
+		{[]byte(`"ndjson"`), NDJSONDataFormat, ""},
+		{[]byte(`"parquet"`), ParquetDataFormat, ""},
 	}
 
 	for i, c := range cases {
@@ -40,10 +40,15 @@ type Dataset struct {
 	DataPath string `json:"dataPath,omitempty"`
 	// Meta contains all human-readable meta about this dataset
 	Meta *Meta `json:"meta,omitempty"`
+	// OffsetIndexPath is the path to an index of byte offsets of entries in
+	// the dataset's data. The index is optional, & only used to speed up reads
+	OffsetIndexPath string `json:"offsetIndexPath,omitempty"`
 	// PreviousPath connects datasets to form a historical DAG
 	PreviousPath string `json:"previousPath,omitempty"`
 	// Qri is required, must be ds:[version]
 	Qri Kind `json:"qri"`
+	// Stats summarizes the values of this dataset's data
+	Stats *Stats `json:"stats,omitempty"`
 	// Structure of this dataset
 	Structure *Structure `json:"structure"`
 	// Transform is a path to the transformation that generated this resource
@@ -60,7 +65,9 @@ func (ds *Dataset) IsEmpty() bool {
 		ds.Structure == nil &&
 		ds.DataPath == "" &&
 		ds.Meta == nil &&
+		ds.OffsetIndexPath == "" &&
 		ds.PreviousPath == "" &&
+		ds.Stats == nil &&
 		ds.Transform == nil &&
 		ds.VisConfig == nil
 }
@@ -139,6 +146,11 @@ func (ds *Dataset) Assign(datasets ...*Dataset) {
 		} else if ds.Commit != nil {
 			ds.Commit.Assign(d.Commit)
 		}
+		if ds.Stats == nil && d.Stats != nil {
+			ds.Stats = d.Stats
+		} else if ds.Stats != nil {
+			ds.Stats.Assign(d.Stats)
+		}
 		if ds.VisConfig == nil && d.VisConfig != nil {
 			ds.VisConfig = d.VisConfig
 		} else if ds.VisConfig != nil {
@@ -148,6 +160,9 @@ func (ds *Dataset) Assign(datasets ...*Dataset) {
 		if d.DataPath != "" {
 			ds.DataPath = d.DataPath
 		}
+		if d.OffsetIndexPath != "" {
+			ds.OffsetIndexPath = d.OffsetIndexPath
+		}
 		if d.PreviousPath != "" {
 			ds.PreviousPath = d.PreviousPath
 		}
@@ -41,7 +41,9 @@ func TestDatasetAssign(t *testing.T) {
 		{&Dataset{Commit: &Commit{Title: "foo"}}},
 		{&Dataset{DataPath: "foo"}},
 		{&Dataset{PreviousPath: "stuff"}},
+		{&Dataset{OffsetIndexPath: "index"}},
 		{&Dataset{Meta: &Meta{Title: "foo"}}},
+		{&Dataset{Stats: &Stats{Qri: KindStats}}},
 		{&Dataset{VisConfig: &VisConfig{Qri: KindVisConfig}}},
 	}
 
@@ -180,7 +182,9 @@ func TestDatasetIsEmpty(t *testing.T) {
 		{&Dataset{Commit: &Commit{}}},
 		{&Dataset{DataPath: "foo"}},
 		{&Dataset{Meta: &Meta{}}},
+		{&Dataset{OffsetIndexPath: "index"}},
 		{&Dataset{PreviousPath: "nope"}},
+		{&Dataset{Stats: &Stats{}}},
 		{&Dataset{Structure: &Structure{}}},
 		{&Dataset{Transform: &Transform{}}},
 		{&Dataset{VisConfig: &VisConfig{}}},
@@ -1,15 +1,21 @@
 package detect
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"unicode/utf8"
 
 	logger "github.com/ipfs/go-log"
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
+	"github.com/qri-io/dataset/dsio"
 )
 
 var (
@@ -28,13 +34,104 @@ func FromFile(path string) (ds *dataset.Structure, err error) {
 	return FromReader(path, f)
 }
 
-// FromReader is a shorthand for a path/filename and reader
+// FromReader is a shorthand for a path/filename and reader. Compressed data
+// is recognized by a compression extension (eg: data.csv.gz) or by sniffing
+// the leading bytes of data, and is decompressed for detection. The data
+// format is taken from the extension that precedes any compression extension.
+// The character encoding of text data is guessed from a sample of leading bytes
 func FromReader(path string, data io.Reader) (ds *dataset.Structure, err error) {
+	ct := compression.ExtensionType(path)
+	if ct != compression.None {
+		path = strings.TrimSuffix(path, filepath.Ext(path))
+	}
+
 	format, err := ExtensionDataFormat(path)
 	if err != nil {
 		return nil, err
 	}
-	return Structure(format, data)
+
+	br := bufio.NewReader(data)
+	if ct == compression.None {
+		// short reads are fine here, any error will resurface when reading
+		header, _ := br.Peek(compression.MagicLen)
+		ct = compression.Detect(header)
+	}
+
+	r, err := compression.NewReader(ct, br)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	defer r.Close()
+
+	data = r
+	enc := ""
+	if format != dataset.CBORDataFormat && format != dataset.XLSDataFormat && format != dataset.ParquetDataFormat {
+		tr := bufio.NewReaderSize(r, encodingSampleSize)
+		// short reads are fine here, any error will resurface when reading
+		sample, _ := tr.Peek(encodingSampleSize)
+		enc = Encoding(sample)
+		if data, err = dsio.NewCharsetReader(enc, tr); err != nil {
+			return nil, err
+		}
+	}
+
+	ds, err = Structure(format, data)
+	if err != nil {
+		return nil, err
+	}
+	ds.Compression = ct
+	ds.Encoding = enc
+	return ds, nil
+}
+
+// encodingSampleSize is the number of leading bytes FromReader examines
+// to guess the character encoding of text data
+const encodingSampleSize = 64 * 1024
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16beBOM = []byte{0xfe, 0xff}
+	utf16leBOM = []byte{0xff, 0xfe}
+)
+
+// Encoding guesses the character encoding of a sample of text data. A byte
+// order mark gives the encoding it marks. utf-8 text without a byte order mark
+// gives an empty string, as utf-8 is assumed when no encoding is specified.
+// Otherwise text is assumed to be windows-1252 if it uses any of the printable
+// characters windows-1252 adds to iso-8859-1, and iso-8859-1 if not
+func Encoding(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		return "utf-8"
+	case bytes.HasPrefix(sample, utf16beBOM):
+		return "utf-16be"
+	case bytes.HasPrefix(sample, utf16leBOM):
+		return "utf-16le"
+	}
+
+	// samples can cut a multi-byte character short
+	for i := 1; i < utf8.UTFMax && i <= len(sample); i++ {
+		if utf8.RuneStart(sample[len(sample)-i]) {
+			if !utf8.FullRune(sample[len(sample)-i:]) {
+				sample = sample[:len(sample)-i]
+			}
+			break
+		}
+	}
+	if utf8.Valid(sample) {
+		return ""
+	}
+
+	for _, b := range sample {
+		switch {
+		case b == 0x81 || b == 0x8d || b == 0x8f || b == 0x90 || b == 0x9d:
+			// unassigned in windows-1252
+		case b >= 0x80 && b <= 0x9f:
+			return "windows-1252"
+		}
+	}
+	return "iso-8859-1"
 }
 
 // Structure attemptes to extract a structure based on a given format and data reader
@@ -65,8 +162,12 @@ func ExtensionDataFormat(path string) (format dataset.DataFormat, err error) {
 		return dataset.CSVDataFormat, nil
 	case ".xml":
 		return dataset.XMLDataFormat, nil
-	case ".xls":
+	case ".xls", ".xlsx":
 		return dataset.XLSDataFormat, nil
+	case ".ndjson", ".jsonl":
+		return dataset.NDJSONDataFormat, nil
+	case ".parquet":
+		return dataset.ParquetDataFormat, nil
 	case "":
 		return dataset.UnknownDataFormat, errors.New("no file extension provided")
 	default:
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
 )
 
 func TestFromFile(t *testing.T) {
@@ -27,6 +28,15 @@ func TestFromFile(t *testing.T) {
 		{"testdata/invalid.cbor", "", "invalid top-level type for CBOR data. cbor datasets must begin with either an array or map"},
 		{"testdata/cbor_object.cbor", "testdata/cbor_object.structure.json", ""},
 		{"testdata/cbor_array.cbor", "testdata/cbor_array.structure.json", ""},
+		{"testdata/cities.xlsx", "testdata/cities.structure.json", ""},
+		{"testdata/log.ndjson", "testdata/log.structure.json", ""},
+		{"testdata/mixed.jsonl", "testdata/mixed.structure.json", ""},
+		{"testdata/hours.csv.gz", "testdata/hours-gzip.structure.json", ""},
+		{"testdata/hours.csv.bz2", "testdata/hours-bzip2.structure.json", ""},
+		{"testdata/cities-latin1.csv", "testdata/cities-latin1.structure.json", ""},
+		{"testdata/cities-windows1252.csv", "testdata/cities-windows1252.structure.json", ""},
+		{"testdata/cities-bom.csv", "testdata/cities-bom.structure.json", ""},
+		{"testdata/cities.parquet", "testdata/cities-parquet.structure.json", ""},
 	}
 
 	for i, c := range cases {
@@ -73,6 +83,55 @@ func TestFromFile(t *testing.T) {
 	}
 }
 
+func TestFromReaderSniffCompression(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/hours.csv.gz")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// no compression extension, compression is detected from the data
+	st, err := FromReader("hours.csv", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if st.Compression != compression.Gzip {
+		t.Errorf("compression mismatch. expected: %s, got: %s", compression.Gzip, st.Compression)
+	}
+	if st.Format != dataset.CSVDataFormat {
+		t.Errorf("format mismatch. expected: %s, got: %s", dataset.CSVDataFormat, st.Format)
+	}
+
+	expect := "unsupported file type: '.txt'"
+	if _, err := FromReader("hours.txt.gz", bytes.NewReader(data)); err == nil || err.Error() != expect {
+		t.Errorf("error mismatch. expected: %s, got: %s", expect, err)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	cases := []struct {
+		sample []byte
+		expect string
+	}{
+		{nil, ""},
+		{[]byte("city,pop\n"), ""},
+		{[]byte("montr\xc3\xa9al"), ""},
+		// multi-byte character cut short by the end of the sample
+		{[]byte("montr\xc3"), ""},
+		{[]byte("\xef\xbb\xbfcity"), "utf-8"},
+		{[]byte("\xfe\xff\x00c"), "utf-16be"},
+		{[]byte("\xff\xfec\x00"), "utf-16le"},
+		{[]byte("montr\xe9al"), "iso-8859-1"},
+		{[]byte("\x93montr\xe9al\x94"), "windows-1252"},
+		{[]byte("\x81montr\xe9al"), "iso-8859-1"},
+	}
+
+	for i, c := range cases {
+		if got := Encoding(c.sample); got != c.expect {
+			t.Errorf("case %d result mismatch. expected: %q, got: %q", i, c.expect, got)
+		}
+	}
+}
+
 func TestExtensionDataFormat(t *testing.T) {
 	cases := []struct {
 		path   string
@@ -83,7 +142,11 @@ func TestExtensionDataFormat(t *testing.T) {
 		{"foo/bar/baz.json", dataset.JSONDataFormat, ""},
 		{"foo/bar/baz.xml", dataset.XMLDataFormat, ""},
 		{"foo/bar/baz.xls", dataset.XLSDataFormat, ""},
+		{"foo/bar/baz.xlsx", dataset.XLSDataFormat, ""},
+		{"foo/bar/baz.ndjson", dataset.NDJSONDataFormat, ""},
+		{"foo/bar/baz.jsonl", dataset.NDJSONDataFormat, ""},
 		{"foo/bar/baz.cbor", dataset.CBORDataFormat, ""},
+		{"foo/bar/baz.parquet", dataset.ParquetDataFormat, ""},
 		{"foo/bar/baz", dataset.UnknownDataFormat, "no file extension provided"},
 		{"foo/bar/baz.jpg", dataset.UnknownDataFormat, "unsupported file type: '.jpg'"},
 	}
@@ -1,7 +1,6 @@
 package detect
 
 import (
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -34,6 +33,12 @@ func Schema(r *dataset.Structure, data io.Reader) (schema *jsonschema.RootSchema
 		return JSONSchema(r, data)
 	case dataset.CSVDataFormat:
 		return CSVSchema(r, data)
+	case dataset.XLSDataFormat:
+		return XLSSchema(r, data)
+	case dataset.NDJSONDataFormat:
+		return NDJSONSchema(r, data)
+	case dataset.ParquetDataFormat:
+		return ParquetSchema(r, data)
 	default:
 		return nil, fmt.Errorf("'%s' is not supported for field detection", r.Format.String())
 	}
@@ -44,9 +49,19 @@ type field struct {
 	Type  vals.Type `json:"type,omitempty"`
 }
 
-// CSVSchema determines the field names and types of an io.Reader of CSV-formatted data, returning a json schema
+// CSVSchema determines the field names and types of an io.Reader of CSV-formatted data, returning a json schema.
+// If resource has CSVOptions, they're used to read data & are preserved in the resulting structure
 func CSVSchema(resource *dataset.Structure, data io.Reader) (schema *jsonschema.RootSchema, err error) {
-	r := csv.NewReader(dsio.ReplaceSoloCarriageReturns(data))
+	opts := &dataset.CSVOptions{}
+	if o, ok := resource.FormatConfig.(*dataset.CSVOptions); ok && o != nil {
+		*opts = *o
+	}
+	nulls := map[string]bool{}
+	for _, n := range opts.NullStrings {
+		nulls[n] = true
+	}
+
+	r := dsio.NewCSVRecordReader(data, opts)
 	r.FieldsPerRecord = -1
 	r.TrimLeadingSpace = true
 	header, err := r.Read()
@@ -54,7 +69,36 @@ func CSVSchema(resource *dataset.Structure, data io.Reader) (schema *jsonschema.
 		return nil, err
 	}
 
-	fields := make([]*field, len(header))
+	fields, headerRow, err := tabularFields(header, func() ([]string, error) {
+		rec, err := r.Read()
+		if err != nil && err.Error() != "EOF" {
+			return nil, fmt.Errorf("error reading csv file: %s", err.Error())
+		}
+		for i, cell := range rec {
+			if nulls[cell] {
+				rec[i] = "null"
+			}
+		}
+		return rec, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if headerRow {
+		opts.HeaderRow = true
+		resource.FormatConfig = opts
+		// ds.HeaderRow = true
+	}
+
+	return tabularSchema(fields)
+}
+
+// tabularFields determines field names and types for rows of string values.
+// header is the first row of data, next reads subsequent rows, returning an
+// EOF error when no rows remain. headerRow reports weather header was
+// interpreted as a row of column titles
+func tabularFields(header []string, next func() ([]string, error)) (fields []*field, headerRow bool, err error) {
+	fields = make([]*field, len(header))
 	types := make([]map[vals.Type]int, len(header))
 
 	for i := range fields {
@@ -70,10 +114,7 @@ func CSVSchema(resource *dataset.Structure, data io.Reader) (schema *jsonschema.
 			f.Title = varName.CreateVarNameFromString(header[i])
 			f.Type = vals.TypeUnknown
 		}
-		resource.FormatConfig = &dataset.CSVOptions{
-			HeaderRow: true,
-		}
-		// ds.HeaderRow = true
+		headerRow = true
 	} else {
 		for i, cell := range header {
 			types[i][vals.ParseType([]byte(cell))]++
@@ -82,7 +123,7 @@ func CSVSchema(resource *dataset.Structure, data io.Reader) (schema *jsonschema.
 
 	count := 0
 	for {
-		rec, err := r.Read()
+		rec, err := next()
 		// max out at 2000 reads
 		if count > 2000 {
 			break
@@ -91,7 +132,7 @@ func CSVSchema(resource *dataset.Structure, data io.Reader) (schema *jsonschema.
 			if err.Error() == "EOF" {
 				break
 			}
-			return nil, fmt.Errorf("error reading csv file: %s", err.Error())
+			return nil, false, err
 		}
 
 		if len(rec) == len(types) {
@@ -111,10 +152,15 @@ func CSVSchema(resource *dataset.Structure, data io.Reader) (schema *jsonschema.
 		}
 	}
 
+	return fields, headerRow, nil
+}
+
+// tabularSchema creates a json schema for an array of rows with the given fields
+func tabularSchema(fields []*field) (*jsonschema.RootSchema, error) {
 	// TODO - lol what a hack. fix everything, put it in jsonschema.
 	items, err := json.Marshal(fields)
 	if err != nil {
-		return nil, fmt.Errorf("error marshaling csv fields to json: %s", err.Error())
+		return nil, fmt.Errorf("error marshaling fields to json: %s", err.Error())
 	}
 	schstr := fmt.Sprintf(`{"type":"array","items":{"type":"array","items":%s}}`, string(items))
 
@@ -1,5 +1,14 @@
 package detect
 
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/dataset"
+)
+
 var (
 	egCorruptCsvData = []byte(`
 		"""fhkajslfnakjlcdnajcl ashklj asdhcjklads ch,,,\dagfd
@@ -104,3 +113,50 @@ not,possible,to,tell,if,this,csv,data,has,a,header
 // 		}
 // 	}
 // }
+
+func TestCSVSchemaOptions(t *testing.T) {
+	cases := []struct {
+		opts   *dataset.CSVOptions
+		data   string
+		expect string
+		config *dataset.CSVOptions
+	}{
+		{nil, "name,count\na,1\nb,2\n",
+			`{"items":{"items":[{"title":"name","type":"string"},{"title":"count","type":"integer"}],"type":"array"},"type":"array"}`,
+			&dataset.CSVOptions{HeaderRow: true}},
+		{&dataset.CSVOptions{Delimiter: '\t'}, "name\tcount\na,b\t1\nc\t2\n",
+			`{"items":{"items":[{"title":"name","type":"string"},{"title":"count","type":"integer"}],"type":"array"},"type":"array"}`,
+			&dataset.CSVOptions{HeaderRow: true, Delimiter: '\t'}},
+		{&dataset.CSVOptions{Comment: '#', NullStrings: []string{"NA"}}, "# comment\nname,count\na,1\nb,NA\nc,NA\nd,NA\n",
+			`{"items":{"items":[{"title":"name","type":"string"},{"title":"count","type":"null"}],"type":"array"},"type":"array"}`,
+			&dataset.CSVOptions{HeaderRow: true, Comment: '#', NullStrings: []string{"NA"}}},
+		{&dataset.CSVOptions{Quote: '\''}, "'a,b',1\n'c',2\n",
+			`{"items":{"items":[{"title":"field_1","type":"string"},{"title":"field_2","type":"integer"}],"type":"array"},"type":"array"}`,
+			&dataset.CSVOptions{Quote: '\''}},
+	}
+
+	for i, c := range cases {
+		st := &dataset.Structure{Format: dataset.CSVDataFormat}
+		if c.opts != nil {
+			st.FormatConfig = c.opts
+		}
+		sch, err := CSVSchema(st, strings.NewReader(c.data))
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+
+		got, err := json.Marshal(sch)
+		if err != nil {
+			t.Errorf("case %d error marshaling schema: %s", i, err.Error())
+			continue
+		}
+		if string(got) != c.expect {
+			t.Errorf("case %d schema mismatch. expected: %s, got: %s", i, c.expect, string(got))
+		}
+
+		if !reflect.DeepEqual(st.FormatConfig, c.config) {
+			t.Errorf("case %d format config mismatch. expected: %v, got: %v", i, c.config, st.FormatConfig)
+		}
+	}
+}
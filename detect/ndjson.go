@@ -0,0 +1,114 @@
+package detect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/vals"
+	"github.com/qri-io/jsonschema"
+)
+
+// NDJSONSchema determines the schema of an io.Reader of newline-delimited
+// JSON data. NDJSON data is always an array of entries. If every sampled
+// entry is the same type, that type is used for the items schema, and
+// when all entries are objects the types of their properties are detected
+// as well
+func NDJSONSchema(resource *dataset.Structure, data io.Reader) (schema *jsonschema.RootSchema, err error) {
+	rd := bufio.NewReader(data)
+	entryTypes := map[vals.Type]int{}
+	propTypes := map[string]map[vals.Type]int{}
+
+	count, line := 0, 0
+	// max out at 2000 reads
+	for count <= 2000 {
+		raw, err := rd.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			log.Debug(err.Error())
+			return nil, fmt.Errorf("error reading data: %s", err.Error())
+		}
+		line++
+
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 {
+			if !json.Valid(raw) {
+				err := fmt.Errorf("error reading ndjson data: invalid json on line %d", line)
+				log.Debug(err.Error())
+				return nil, err
+			}
+
+			typ := vals.ParseType(raw)
+			entryTypes[typ]++
+			if typ == vals.TypeObject {
+				props := map[string]json.RawMessage{}
+				if err := json.Unmarshal(raw, &props); err != nil {
+					log.Debug(err.Error())
+					return nil, fmt.Errorf("error reading ndjson data: %s", err.Error())
+				}
+				for key, val := range props {
+					if propTypes[key] == nil {
+						propTypes[key] = map[vals.Type]int{}
+					}
+					propTypes[key][vals.ParseType(val)]++
+				}
+			}
+			count++
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if len(entryTypes) != 1 {
+		// either no data or mixed entry types, all we know is this is an array
+		return dataset.BaseSchemaArray, nil
+	}
+
+	items := map[string]interface{}{}
+	for typ := range entryTypes {
+		items["type"] = typ.String()
+	}
+	if entryTypes[vals.TypeObject] > 0 {
+		props := map[string]interface{}{}
+		for key, tally := range propTypes {
+			prop := map[string]interface{}{}
+			if typ := tallyType(tally); typ != vals.TypeUnknown {
+				prop["type"] = typ.String()
+			}
+			props[key] = prop
+		}
+		items["properties"] = props
+	}
+
+	sch, err := json.Marshal(map[string]interface{}{
+		"type":  "array",
+		"items": items,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling ndjson schema to json: %s", err.Error())
+	}
+
+	rs := &jsonschema.RootSchema{}
+	if err := rs.UnmarshalJSON(sch); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// tallyType gives the single type that describes all tallied values,
+// widening integers to numbers where the two are mixed. mixed types
+// that can't be reconciled are TypeUnknown
+func tallyType(tally map[vals.Type]int) vals.Type {
+	if len(tally) == 1 {
+		for typ := range tally {
+			return typ
+		}
+	}
+	if len(tally) == 2 && tally[vals.TypeInteger] > 0 && tally[vals.TypeNumber] > 0 {
+		return vals.TypeNumber
+	}
+	return vals.TypeUnknown
+}
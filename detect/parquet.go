@@ -0,0 +1,35 @@
+package detect
+
+import (
+	"io"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/dataset/vals"
+	"github.com/qri-io/jsonschema"
+)
+
+// ParquetSchema determines the field names and types of parquet data from
+// file metadata, returning a json schema
+func ParquetSchema(resource *dataset.Structure, data io.Reader) (schema *jsonschema.RootSchema, err error) {
+	r, err := dsio.NewParquetReader(&dataset.Structure{
+		Format: dataset.ParquetDataFormat,
+		Schema: dataset.BaseSchemaArray,
+	}, data)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	titles, types, err := r.Columns()
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	fields := make([]*field, len(titles))
+	for i, title := range titles {
+		fields[i] = &field{Title: title, Type: vals.TypeFromString(types[i])}
+	}
+	return tabularSchema(fields)
+}
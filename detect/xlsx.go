@@ -0,0 +1,63 @@
+package detect
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+// XLSSchema determines the field names and types of a worksheet within an
+// excel workbook, returning a json schema. If resource has XLSOptions,
+// the specified sheet is used, otherwise fields are drawn from the first sheet
+func XLSSchema(resource *dataset.Structure, data io.Reader) (schema *jsonschema.RootSchema, err error) {
+	opts := &dataset.XLSOptions{}
+	if o, ok := resource.FormatConfig.(*dataset.XLSOptions); ok && o != nil {
+		*opts = *o
+	}
+	// header rows are detected from the data, so the reader must return
+	// all rows regardless of configuration
+	opts.HeaderRow = false
+
+	r, err := dsio.NewXLSXReader(&dataset.Structure{
+		Format:       dataset.XLSDataFormat,
+		FormatConfig: opts,
+		Schema:       dataset.BaseSchemaArray,
+	}, data)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	header, err := r.ReadRow()
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error reading worksheet: %s", err.Error())
+	}
+
+	fields, headerRow, err := tabularFields(header, func() ([]string, error) {
+		row, err := r.ReadRow()
+		if err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return nil, fmt.Errorf("error reading worksheet: %s", err.Error())
+		}
+		// rows omit trailing empty cells
+		for len(row) < len(header) {
+			row = append(row, "")
+		}
+		return row, nil
+	})
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	opts.HeaderRow = headerRow
+	resource.FormatConfig = opts
+
+	return tabularSchema(fields)
+}
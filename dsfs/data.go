@@ -1,8 +1,10 @@
 package dsfs
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/qri-io/cafs"
@@ -15,8 +17,25 @@ func LoadData(store cafs.Filestore, ds *dataset.Dataset) (cafs.File, error) {
 	return store.Get(datastore.NewKey(ds.DataPath))
 }
 
-// LoadRows loads a slice of raw bytes inside a limit/offset row range
-func LoadRows(store cafs.Filestore, ds *dataset.Dataset, limit, offset int) ([]byte, error) {
+// LoadRowsOpts configures optional behaviour of LoadRows
+type LoadRowsOpts struct {
+	// Columns selects & orders the columns of returned rows by schema title.
+	// The returned data is encoded with the projected structure
+	Columns []string
+	// Where is a filter expression rows must match, see dsio.FilterReader
+	// for syntax. Limit & offset count matching rows
+	Where string
+}
+
+// LoadRows loads a slice of raw bytes inside a limit/offset row range.
+// If the dataset has an offset index, reading starts at the closest indexed
+// entry before offset instead of the start of the data. Filtered reads
+// always start from the beginning
+func LoadRows(store cafs.Filestore, ds *dataset.Dataset, limit, offset int, opts ...func(*LoadRowsOpts)) ([]byte, error) {
+	opt := &LoadRowsOpts{}
+	for _, o := range opts {
+		o(opt)
+	}
 
 	datafile, err := LoadData(store, ds)
 	if err != nil {
@@ -24,25 +43,46 @@ func LoadRows(store cafs.Filestore, ds *dataset.Dataset, limit, offset int) ([]b
 		return nil, fmt.Errorf("error loading dataset data: %s", err.Error())
 	}
 
-	added := 0
-	buf, err := dsio.NewEntryBuffer(ds.Structure)
+	var (
+		start int
+		rr    dsio.EntryReader
+	)
+	if opt.Where != "" {
+		rr, err = dsio.NewEntryReader(ds.Structure, datafile)
+	} else {
+		start, rr, err = offsetEntryReader(store, ds, datafile, offset)
+	}
 	if err != nil {
 		log.Debug(err.Error())
 		return nil, fmt.Errorf("error loading dataset data: %s", err.Error())
 	}
+	if opt.Where != "" {
+		if rr, err = dsio.NewFilterReader(rr, opt.Where); err != nil {
+			log.Debug(err.Error())
+			return nil, fmt.Errorf("invalid filter: %s", err.Error())
+		}
+	}
+	if len(opt.Columns) > 0 {
+		if rr, err = dsio.NewProjectReader(rr, opt.Columns...); err != nil {
+			log.Debug(err.Error())
+			return nil, fmt.Errorf("invalid columns: %s", err.Error())
+		}
+	}
 
-	rr, err := dsio.NewEntryReader(ds.Structure, datafile)
+	added := 0
+	buf, err := dsio.NewEntryBuffer(rr.Structure())
 	if err != nil {
 		log.Debug(err.Error())
 		return nil, fmt.Errorf("error loading dataset data: %s", err.Error())
 	}
+
 	err = dsio.EachEntry(rr, func(i int, ent dsio.Entry, err error) error {
 		if err != nil {
 			log.Debugf("error reading entry: %s", err.Error())
 			return err
 		}
 
-		if i < offset {
+		if start+i < offset {
 			return nil
 		} else if limit > 0 && added == limit {
 			return io.EOF
@@ -59,3 +99,50 @@ func LoadRows(store cafs.Filestore, ds *dataset.Dataset, limit, offset int) ([]b
 	err = buf.Close()
 	return buf.Bytes(), err
 }
+
+// LoadOffsetIndex loads the offset index of a dataset's data. Datasets
+// without an index return a nil index
+func LoadOffsetIndex(store cafs.Filestore, ds *dataset.Dataset) (*dsio.OffsetIndex, error) {
+	if ds.OffsetIndexPath == "" {
+		return nil, nil
+	}
+	data, err := fileBytes(store.Get(datastore.NewKey(ds.OffsetIndexPath)))
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error loading offset index: %s", err.Error())
+	}
+	idx := &dsio.OffsetIndex{}
+	if err := json.Unmarshal(data, idx); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error unmarshaling offset index: %s", err.Error())
+	}
+	return idx, nil
+}
+
+// offsetEntryReader creates a reader positioned as close to entry offset as
+// the dataset's offset index allows, returning the index of the first entry
+// the reader will read
+func offsetEntryReader(store cafs.Filestore, ds *dataset.Dataset, datafile io.Reader, offset int) (int, dsio.EntryReader, error) {
+	idx, err := LoadOffsetIndex(store, ds)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	start, pos := idx.Nearest(offset)
+	if start == 0 {
+		rr, err := dsio.NewEntryReader(ds.Structure, datafile)
+		return 0, rr, err
+	}
+
+	if s, ok := datafile.(io.Seeker); ok {
+		_, err = s.Seek(pos, io.SeekStart)
+	} else {
+		_, err = io.CopyN(ioutil.Discard, datafile, pos)
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("error seeking to entry %d: %s", start, err.Error())
+	}
+
+	rr, err := dsio.NewOffsetEntryReader(ds.Structure, datafile)
+	return start, rr, err
+}
@@ -4,6 +4,11 @@ import (
 	"bytes"
 	"io/ioutil"
 	"testing"
+
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dstest"
 )
 
 func TestLoadData(t *testing.T) {
@@ -81,3 +86,127 @@ chatham,35000,65.25,true
 		}
 	}
 }
+
+func TestLoadRowsOffsetIndex(t *testing.T) {
+	store := cafs.NewMapstore()
+	privKey, err := crypto.UnmarshalPrivateKey(testPk)
+	if err != nil {
+		t.Fatalf("error unmarshaling private key: %s", err.Error())
+	}
+
+	tc, err := dstest.NewTestCaseFromDir("testdata/cities")
+	if err != nil {
+		t.Fatalf("error creating test case: %s", err.Error())
+	}
+	df := cafs.NewMemfileBytes(tc.DataFilename, tc.Data)
+	path, err := CreateDataset(store, tc.Input, df, privKey, false, func(o *CreateDatasetOpts) {
+		o.OffsetIndexInterval = 2
+	})
+	if err != nil {
+		t.Fatalf("error creating dataset: %s", err.Error())
+	}
+
+	ds, err := LoadDataset(store, path)
+	if err != nil {
+		t.Fatalf("error loading dataset: %s", err.Error())
+	}
+	if ds.OffsetIndexPath == "" {
+		t.Fatal("expected dataset to have an offset index path")
+	}
+	idx, err := LoadOffsetIndex(store, ds)
+	if err != nil {
+		t.Fatalf("error loading offset index: %s", err.Error())
+	}
+	if idx.Interval != 2 || len(idx.Offsets) != 3 {
+		t.Errorf("unexpected offset index: %v", idx)
+	}
+
+	// rows read with the index must match rows read without it
+	unindexed := &dataset.Dataset{}
+	unindexed.Assign(ds)
+	unindexed.OffsetIndexPath = ""
+
+	for offset := 0; offset < 6; offset++ {
+		for _, limit := range []int{0, 1, 2} {
+			expect, err := LoadRows(store, unindexed, limit, offset)
+			if err != nil {
+				t.Fatalf("error loading rows: %s", err.Error())
+			}
+			got, err := LoadRows(store, ds, limit, offset)
+			if err != nil {
+				t.Errorf("limit %d offset %d error: %s", limit, offset, err.Error())
+				continue
+			}
+			if !bytes.Equal(expect, got) {
+				t.Errorf("limit %d offset %d data mismatch. expected:\n%s\ngot:\n%s", limit, offset, string(expect), string(got))
+			}
+		}
+	}
+
+	// re-saving without an index drops the index of the previous body
+	next := &dataset.Dataset{}
+	next.Assign(ds)
+	next.Commit = &dataset.Commit{Title: "fewer cities"}
+	next.PreviousPath = path.String()
+	data := "city,pop,avg_age,in_usa\nchicago,300000,44.4,true\nraleigh,250000,50.65,true\nboston,650000,36.1,true\n"
+	path, err = CreateDataset(store, next, cafs.NewMemfileBytes(tc.DataFilename, []byte(data)), privKey, false, func(o *CreateDatasetOpts) {
+		o.OffsetIndexInterval = 0
+	})
+	if err != nil {
+		t.Fatalf("error creating next version: %s", err.Error())
+	}
+	if ds, err = LoadDataset(store, path); err != nil {
+		t.Fatalf("error loading next version: %s", err.Error())
+	}
+	if ds.OffsetIndexPath != "" {
+		t.Errorf("expected next version to have no offset index, got: %s", ds.OffsetIndexPath)
+	}
+	got, err := LoadRows(store, ds, 1, 2)
+	if err != nil {
+		t.Fatalf("error loading rows: %s", err.Error())
+	}
+	if expect := "city,pop,avg_age,in_usa\nboston,650000,36.1,true\n"; string(got) != expect {
+		t.Errorf("data mismatch. expected:\n%s\ngot:\n%s", expect, string(got))
+	}
+}
+
+func TestLoadRowsQuery(t *testing.T) {
+	datasets, store, err := makeFilestore()
+	if err != nil {
+		t.Fatalf("error creating test filestore: %s", err.Error())
+	}
+	ds, err := LoadDataset(store, datasets["cities"])
+	if err != nil {
+		t.Fatalf("error loading dataset: %s", err.Error())
+	}
+
+	cases := []struct {
+		columns       []string
+		where         string
+		limit, offset int
+		expect        string
+		err           string
+	}{
+		{[]string{"pop", "city"}, "", 2, 1, "pop,city\n8500000,new york\n300000,chicago\n", ""},
+		{nil, "avg_age < 50 and in_usa", 0, 0, "city,pop,avg_age,in_usa\nnew york,8500000,44.4,true\nchicago,300000,44.4,true\n", ""},
+		{[]string{"city"}, "pop >= 250000 or city = 'chatham'", 2, 1, "city\nnew york\nchicago\n", ""},
+		{[]string{"city"}, "not in_usa", 0, 0, "city\ntoronto\n", ""},
+		{[]string{"country"}, "", 0, 0, "", `invalid columns: column "country" not found`},
+		{nil, "country = 'usa'", 0, 0, "", `invalid filter: column "country" not found`},
+		{nil, "pop >", 0, 0, "", "invalid filter: filter: unexpected end of expression"},
+	}
+
+	for i, c := range cases {
+		data, err := LoadRows(store, ds, c.limit, c.offset, func(o *LoadRowsOpts) {
+			o.Columns = c.columns
+			o.Where = c.where
+		})
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if string(data) != c.expect {
+			t.Errorf("case %d data mismatch. expected:\n%s\ngot:\n%s", i, c.expect, string(data))
+		}
+	}
+}
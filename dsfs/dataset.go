@@ -1,8 +1,10 @@
 package dsfs
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"strings"
 	"time"
@@ -13,6 +15,7 @@ import (
 	"github.com/multiformats/go-multihash"
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
 	"github.com/qri-io/dataset/dsio"
 	"github.com/qri-io/dataset/validate"
 	"github.com/qri-io/dsdiff"
@@ -80,6 +83,9 @@ func DerefDataset(store cafs.Filestore, ds *dataset.Dataset) error {
 	if err := DerefDatasetTransform(store, ds); err != nil {
 		return err
 	}
+	if err := DerefDatasetStats(store, ds); err != nil {
+		return err
+	}
 	if err := DerefDatasetVisConfig(store, ds); err != nil {
 		return err
 	}
@@ -118,6 +124,22 @@ func DerefDatasetVisConfig(store cafs.Filestore, ds *dataset.Dataset) error {
 	return nil
 }
 
+// DerefDatasetStats derferences a dataset's Stats element if required
+// should be a no-op if ds.Stats is nil or isn't a reference
+func DerefDatasetStats(store cafs.Filestore, ds *dataset.Dataset) error {
+	if ds.Stats != nil && ds.Stats.IsEmpty() && ds.Stats.Path().String() != "" {
+		sa, err := loadStats(store, ds.Stats.Path())
+		if err != nil {
+			log.Debug(err.Error())
+			return fmt.Errorf("error loading dataset stats: %s", err.Error())
+		}
+		// assign path to retain internal reference to path
+		sa.Assign(dataset.NewStatsRef(ds.Stats.Path()))
+		ds.Stats = sa
+	}
+	return nil
+}
+
 // DerefDatasetTransform derferences a dataset's transform element if required
 // should be a no-op if ds.Structure is nil or isn't a reference
 func DerefDatasetTransform(store cafs.Filestore, ds *dataset.Dataset) error {
@@ -166,14 +188,38 @@ func DerefDatasetCommit(store cafs.Filestore, ds *dataset.Dataset) error {
 	return nil
 }
 
+// CreateDatasetOpts configures optional behaviour of CreateDataset
+type CreateDatasetOpts struct {
+	// OffsetIndexInterval, when greater than zero, writes an offset index
+	// package file recording the byte offset of every OffsetIndexInterval-th
+	// entry. LoadRows uses the index to skip straight to a window of rows.
+	// Data that can't be offset-indexed is saved without an index
+	OffsetIndexInterval int
+	// SchemaCompatibility is the compatibility a change to the schema of the
+	// previous version must have, eg: validate.BackwardCompatible. Datasets
+	// with incompatible schema changes aren't created. The default,
+	// validate.CompatibilityNone, accepts any change
+	SchemaCompatibility validate.Compatibility
+	// BodyDiffTitle titles commits without a title by summarizing row-level
+	// changes from the previous version's body, eg: "12 rows added, 3
+	// modified". Summarizing holds all entries of the previous body in
+	// memory, so it's off by default & commits get a generic title
+	BodyDiffTitle bool
+}
+
 // CreateDataset places a new dataset in the store. Admittedly, this isn't a simple process.
 // Store is where we're going to
 // Dataset to be saved
 // Pin the dataset if the underlying store supports the pinning interface
-func CreateDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, pk crypto.PrivKey, pin bool) (path datastore.Key, err error) {
+func CreateDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, pk crypto.PrivKey, pin bool, opts ...func(*CreateDatasetOpts)) (path datastore.Key, err error) {
 	// var diffDescription string
 	log.Info("word")
 
+	opt := &CreateDatasetOpts{}
+	for _, o := range opts {
+		o(opt)
+	}
+
 	if pk == nil {
 		err = fmt.Errorf("private key is required to create a dataset")
 		return
@@ -186,11 +232,36 @@ func CreateDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, pk c
 		log.Debug(err.Error())
 		return
 	}
-	df, _, err = prepareDataset(store, ds, df, pk)
+	if opt.SchemaCompatibility != validate.CompatibilityNone {
+		if err = checkSchemaCompatibility(store, ds, opt.SchemaCompatibility); err != nil {
+			log.Debug(err.Error())
+			return
+		}
+	}
+
+	var indexer *dsio.OffsetIndexer
+	if opt.OffsetIndexInterval > 0 && ds.Structure != nil {
+		if indexer, err = dsio.NewOffsetIndexer(ds.Structure, opt.OffsetIndexInterval); err != nil {
+			// indexes are an optimization, unsupported data is saved without one
+			log.Debug(err.Error())
+			indexer, err = nil, nil
+		}
+	}
+
+	df, _, err = prepareDataset(store, ds, df, pk, indexer, opt.BodyDiffTitle)
 	if err != nil {
 		log.Debug(err.Error())
 		return
 	}
+	defer df.Close()
+
+	var indexFile cafs.File
+	if indexer != nil {
+		if indexFile, err = offsetIndexFile(indexer); err != nil {
+			log.Debug(err.Error())
+			return
+		}
+	}
 
 	// TODO - figure out where we stand on this
 	// if diffDescription == "" {
@@ -204,7 +275,7 @@ func CreateDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, pk c
 	// 	 return
 	// }
 
-	path, err = WriteDataset(store, ds, df, pin)
+	path, err = writeDataset(store, ds, df, indexFile, pin)
 	if err != nil {
 		log.Debug(err.Error())
 		err = fmt.Errorf("error writing dataset: %s", err.Error())
@@ -286,12 +357,27 @@ func cleanTitleAndMessage(sTitle, sMsg *string) {
 	*sMsg = sm
 }
 
+// offsetIndexFile creates an offset index package file from an indexer that
+// has scanned a data file. Scanning errors skip the index, returning a nil file
+func offsetIndexFile(indexer *dsio.OffsetIndexer) (cafs.File, error) {
+	idx, err := indexer.Index()
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling offset index to json: %s", err.Error())
+	}
+	return cafs.NewMemfileBytes(PackageFileOffsetIndex.String(), data), nil
+}
+
 // prepareDataset modifies a dataset in preparation for adding to a dsfs
-// it returns a new data file for use in WriteDataset
-func prepareDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, privKey crypto.PrivKey) (cafs.File, string, error) {
-	// TODO - need a better strategy for huge files. I think that strategy is to split
-	// the reader into multiple consumers that are all performing their task on a stream
-	// of byte slices
+// it returns a new data file for use in WriteDataset. If indexer is non-nil,
+// it's written the raw data as the data is inspected. Column stats are
+// calculated from the same read, replacing any existing dataset stats.
+// bodyDiffTitle titles untitled commits with a summary of body changes
+func prepareDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, privKey crypto.PrivKey, indexer *dsio.OffsetIndexer, bodyDiffTitle bool) (cafs.File, string, error) {
 	var err error
 	if df == nil && ds.PreviousPath == "" {
 		return nil, "", fmt.Errorf("datafile or dataset PreviousPath needed")
@@ -310,57 +396,197 @@ func prepareDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, pri
 		}
 	}
 
-	data, err := ioutil.ReadAll(df)
+	// stats describe the body being written, never stats of a previous body
+	ds.Stats = nil
+	stats := dsio.NewStatsAccumulator(ds.Structure)
+	df, err = inspectData(ds.Structure, df, indexer, stats)
 	if err != nil {
 		log.Debug(err.Error())
-		return nil, "", fmt.Errorf("error reading file: %s", err.Error())
+		return nil, "", err
+	}
+	if sa := stats.Stats(); !sa.IsEmpty() {
+		ds.Stats = sa
 	}
-	ds.Structure.Length = len(data)
 
-	// set error count
+	bodyDiff := ""
+	if bodyDiffTitle && ds.PreviousPath != "" {
+		bodyDiff = diffBody(store, ds, df)
+	}
 
-	er, err := dsio.NewEntryReader(ds.Structure, cafs.NewMemfileBytes("data", data))
+	diffDescription, err := prepareCommit(store, ds, bodyDiff, privKey)
+	if err != nil {
+		df.Close()
+		return nil, "", err
+	}
+
+	return df, diffDescription, nil
+}
+
+// diffBody summarizes row-level changes between a dataset's data & the data
+// of it's previous version, eg: "12 rows added, 3 modified". Entries are
+// matched by object key or value. df must be seekable, and is rewound
+// after reading. Body diffs only inform commit titles, so errors are logged
+// & give an empty summary
+func diffBody(store cafs.Filestore, ds *dataset.Dataset, df cafs.File) string {
+	s, ok := df.(io.Seeker)
+	if !ok || ds.Structure == nil {
+		return ""
+	}
+	defer func() {
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			log.Debug(err.Error())
+		}
+	}()
+
+	prev, err := LoadDataset(store, datastore.NewKey(ds.PreviousPath))
+	if err != nil || prev.Structure == nil || prev.DataPath == "" {
+		return ""
+	}
+	prevData, err := LoadData(store, prev)
 	if err != nil {
 		log.Debug(err.Error())
-		return nil, "", fmt.Errorf("error reading data values: %s", err.Error())
+		return ""
 	}
+	defer prevData.Close()
 
-	validationErrors, err := validate.EntryReader(er)
+	before, err := dsio.NewEntryReader(prev.Structure, prevData)
 	if err != nil {
 		log.Debug(err.Error())
-		return nil, "", fmt.Errorf("error validating data: %s", err.Error())
+		return ""
+	}
+	after, err := dsio.NewEntryReader(ds.Structure, df)
+	if err != nil {
+		log.Debug(err.Error())
+		return ""
 	}
-	ds.Structure.ErrCount = len(validationErrors)
 
-	// TODO - add a dsio.RowCount function that avoids actually arranging data into rows
-	er, err = dsio.NewEntryReader(ds.Structure, cafs.NewMemfileBytes("data", data))
+	stats, err := dsio.DiffEntries(before, after, "", nil)
 	if err != nil {
 		log.Debug(err.Error())
-		return nil, "", fmt.Errorf("error reading data values: %s", err.Error())
+		return ""
 	}
+	return stats.String()
+}
 
-	entries := 0
-	for {
-		if _, err = er.ReadEntry(); err != nil {
-			log.Debug(err.Error())
-			break
+// checkSchemaCompatibility errors if the schema of a dataset changes from
+// the schema of it's previous version in a way that doesn't meet a required
+// compatibility
+func checkSchemaCompatibility(store cafs.Filestore, ds *dataset.Dataset, required validate.Compatibility) error {
+	if ds.PreviousPath == "" || ds.Structure == nil || ds.Structure.Schema == nil {
+		return nil
+	}
+	prev, err := LoadDataset(store, datastore.NewKey(ds.PreviousPath))
+	if err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error loading previous dataset: %s", err.Error())
+	}
+	if prev.Structure == nil || prev.Structure.Schema == nil {
+		return nil
+	}
+
+	sc, err := validate.CompareSchemas(prev.Structure.Schema, ds.Structure.Schema)
+	if err != nil {
+		return err
+	}
+	return sc.Error(required)
+}
+
+// inspectData reads a data file exactly once, setting the Length, Checksum,
+// Entries & ErrCount fields of a structure while spooling raw bytes to a
+// temporary file. Length counting, hashing, entry counting & validation all
+// consume the same stream, so the data file is never held in memory.
+// Compressed data is stored as-is: Length & Checksum describe the compressed
+// bytes, while entries are read & validated through a decompressor.
+// The returned file reads from the spool, and removes it when closed.
+// If indexer is non-nil it's also written the raw data, if stats is non-nil
+// it's written each entry
+func inspectData(st *dataset.Structure, df cafs.File, indexer *dsio.OffsetIndexer, stats *dsio.StatsAccumulator) (cafs.File, error) {
+	tmp, err := ioutil.TempFile("", "dsfs_data_")
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error creating temp file: %s", err.Error())
+	}
+	spool := &tempFile{File: tmp, name: "data." + st.Format.String() + compression.Extensions[st.Compression]}
+
+	hash := sha256.New()
+	length := new(byteCounter)
+	writers := []io.Writer{tmp, hash, length}
+	if indexer != nil {
+		writers = append(writers, indexer)
+	}
+	r := io.TeeReader(df, io.MultiWriter(writers...))
+
+	er, err := dsio.NewEntryReader(st, r)
+	if err != nil {
+		log.Debug(err.Error())
+		spool.Close()
+		return nil, fmt.Errorf("error reading data values: %s", err.Error())
+	}
+
+	validator, err := validate.NewEntryValidator(st)
+	if err != nil {
+		log.Debug(err.Error())
+		spool.Close()
+		return nil, fmt.Errorf("error validating data: %s", err.Error())
+	}
+
+	entries, errCount := 0, 0
+	err = dsio.EachEntry(er, func(i int, ent dsio.Entry, err error) error {
+		if err != nil {
+			return err
 		}
+		valErrs, err := validator.ValidateEntry(i, ent)
+		if err != nil {
+			return err
+		}
+		errCount += len(valErrs)
 		entries++
+		if stats != nil {
+			return stats.WriteEntry(ent)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Debug(err.Error())
+		spool.Close()
+		return nil, fmt.Errorf("error reading values: %s", err.Error())
 	}
-	if err.Error() != "EOF" {
-		return nil, "", fmt.Errorf("error reading values: %s", err.Error())
-	}
+	errCount += len(validator.DatasetErrors())
 
-	ds.Structure.Entries = entries
+	// entry readers can stop short of the end of the stream (trailing whitespace,
+	// closing brackets, etc). drain any remaining bytes so length and checksum
+	// cover the entire file
+	if _, err = io.Copy(ioutil.Discard, r); err != nil {
+		log.Debug(err.Error())
+		spool.Close()
+		return nil, fmt.Errorf("error reading file: %s", err.Error())
+	}
 
-	// TODO - set hash
-	shasum, err := multihash.Sum(data, multihash.SHA2_256, -1)
+	mh, err := multihash.Encode(hash.Sum(nil), multihash.SHA2_256)
 	if err != nil {
 		log.Debug(err.Error())
-		return nil, "", fmt.Errorf("error calculating hash: %s", err.Error())
+		spool.Close()
+		return nil, fmt.Errorf("error calculating hash: %s", err.Error())
 	}
-	ds.Structure.Checksum = shasum.B58String()
 
+	st.Length = int(*length)
+	st.Entries = entries
+	st.ErrCount = errCount
+	st.Checksum = multihash.Multihash(mh).B58String()
+
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		log.Debug(err.Error())
+		spool.Close()
+		return nil, fmt.Errorf("error rewinding data file: %s", err.Error())
+	}
+
+	return spool, nil
+}
+
+// prepareCommit generates a commit title if none is provided, timestamps and
+// signs the commit of a dataset. Generated titles use a summary of body
+// changes if bodyDiff is non-empty
+func prepareCommit(store cafs.Filestore, ds *dataset.Dataset, bodyDiff string, privKey crypto.PrivKey) (string, error) {
 	// generate abstract form of dataset
 	// ds.Abstract = dataset.Abstract(ds)
 
@@ -368,10 +594,10 @@ func prepareDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, pri
 	diffDescription, err := generateCommitMsg(store, ds)
 	if err != nil {
 		log.Debug(err.Error())
-		return nil, "", fmt.Errorf("%s", err.Error())
+		return "", fmt.Errorf("%s", err.Error())
 	}
 	if diffDescription == "" {
-		return nil, "", fmt.Errorf("error saving: no changes detected")
+		return "", fmt.Errorf("error saving: no changes detected")
 	}
 
 	if ds.Commit.Title == "" && ds.Commit.Message != "" {
@@ -379,7 +605,9 @@ func prepareDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, pri
 		ds.Commit.Message = ""
 	}
 
-	if ds.Commit.Title == "" {
+	if ds.Commit.Title == "" && bodyDiff != "" {
+		ds.Commit.Title = bodyDiff
+	} else if ds.Commit.Title == "" {
 		ds.Commit.Title = diffDescription
 	}
 	cleanTitleAndMessage(&ds.Commit.Title, &ds.Commit.Message)
@@ -388,11 +616,11 @@ func prepareDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, pri
 	signedBytes, err := privKey.Sign(ds.Commit.SignableBytes())
 	if err != nil {
 		log.Debug(err.Error())
-		return nil, "", fmt.Errorf("error signing commit title: %s", err.Error())
+		return "", fmt.Errorf("error signing commit title: %s", err.Error())
 	}
 	ds.Commit.Signature = base58.Encode(signedBytes)
 
-	return cafs.NewMemfileBytes("data."+ds.Structure.Format.String(), data), diffDescription, nil
+	return diffDescription, nil
 }
 
 // WriteDataset writes a dataset to a cafs, replacing subcomponents of a dataset with path references
@@ -400,6 +628,11 @@ func prepareDataset(store cafs.Filestore, ds *dataset.Dataset, df cafs.File, pri
 // This method is currently exported, but 99% of use cases should use CreateDataset instead of this
 // lower-level function
 func WriteDataset(store cafs.Filestore, ds *dataset.Dataset, dataFile cafs.File, pin bool) (datastore.Key, error) {
+	return writeDataset(store, ds, dataFile, nil, pin)
+}
+
+// writeDataset writes a dataset & an optional offset index file
+func writeDataset(store cafs.Filestore, ds *dataset.Dataset, dataFile, indexFile cafs.File, pin bool) (datastore.Key, error) {
 
 	// assign to a new dataset instance to avoid clobbering input dataset
 	cp := &dataset.Dataset{}
@@ -409,6 +642,10 @@ func WriteDataset(store cafs.Filestore, ds *dataset.Dataset, dataFile cafs.File,
 	if ds.IsEmpty() {
 		return datastore.NewKey(""), fmt.Errorf("cannot save empty dataset")
 	}
+	// an index from another version describes offsets in a different body
+	if indexFile == nil {
+		ds.OffsetIndexPath = ""
+	}
 
 	fileTasks := 0
 	addedDataset := false
@@ -448,6 +685,11 @@ func WriteDataset(store cafs.Filestore, ds *dataset.Dataset, dataFile cafs.File,
 	fileTasks++
 	adder.AddFile(dataFile)
 
+	if indexFile != nil {
+		fileTasks++
+		adder.AddFile(indexFile)
+	}
+
 	if ds.Transform != nil {
 		// all resources must be references
 		for key, r := range ds.Transform.Resources {
@@ -493,6 +735,15 @@ func WriteDataset(store cafs.Filestore, ds *dataset.Dataset, dataFile cafs.File,
 		adder.AddFile(abf)
 	}
 
+	if ds.Stats != nil {
+		saf, err := JSONFile(PackageFileStats.String(), ds.Stats)
+		if err != nil {
+			return datastore.NewKey(""), fmt.Errorf("error marshaling dataset stats to json: %s", err.Error())
+		}
+		fileTasks++
+		adder.AddFile(saf)
+	}
+
 	if ds.VisConfig != nil {
 		vc, err := JSONFile(PackageFileVisConfig.String(), ds.VisConfig)
 		if err != nil {
@@ -522,6 +773,10 @@ func WriteDataset(store cafs.Filestore, ds *dataset.Dataset, dataFile cafs.File,
 				ds.Commit = dataset.NewCommitRef(ao.Path)
 			case PackageFileVisConfig.String():
 				ds.VisConfig = dataset.NewVisConfigRef(ao.Path)
+			case PackageFileStats.String():
+				ds.Stats = dataset.NewStatsRef(ao.Path)
+			case PackageFileOffsetIndex.String():
+				ds.OffsetIndexPath = ao.Path.String()
 			case dataFile.FileName():
 				ds.DataPath = ao.Path.String()
 			default:
@@ -1,8 +1,10 @@
 package dsfs
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
@@ -10,9 +12,14 @@ import (
 
 	"github.com/ipfs/go-datastore"
 	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/multiformats/go-multihash"
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
+	"github.com/qri-io/dataset/dsio"
 	"github.com/qri-io/dataset/dstest"
+	"github.com/qri-io/dataset/validate"
+	"github.com/qri-io/jsonschema"
 )
 
 // Test Private Key. peerId: QmZePf5LeXow3RW5U1AgEiNbW46YnRGhZ7HPvm1UmPFPwt
@@ -146,13 +153,13 @@ func TestCreateDataset(t *testing.T) {
 		{"invalid",
 			"", 0, "commit is required"},
 		{"cities",
-			"/map/QmViLcZ6Yu4Yi4bQe7zcfntHNdDes5xwQp3fbxq3iUouWS", 6, ""},
+			"/map/QmUWRQU4HQxEExp9CxewaKhurtQ7cL3Fg9Z1PMMUMvjXZE", 7, ""},
 		{"complete",
-			"/map/QmRSc1VTBQnoWdH8dV3M9cLt6e1639SVN1L8CMZ7ipCc8F", 15, ""},
+			"/map/QmToXxdsHPiP3kruwErRUbNFseJfsHtUAwJTQHmth7thoF", 17, ""},
 		{"cities_no_commit_title",
-			"/map/QmPHmSFoxBn73t61M3E9SYNZvqD1BdFriTn3fVWjfG4seN", 17, ""},
+			"/map/QmYF1EpffU4BfTt3zMugaJPozUokJp6MhVrRHsJVUWjkhn", 19, ""},
 		{"craigslist",
-			"/map/QmdV6TqbjvwDqZrqPDyXeMYujEZaiaG18YjXVRhD66VFfZ", 20, ""},
+			"/map/QmRSDgGpMpS7kSaBTkyd5b6hfC3vS631DbsDaA1HuBQTmB", 23, ""},
 	}
 
 	for _, c := range cases {
@@ -222,8 +229,8 @@ func TestCreateDataset(t *testing.T) {
 	if err.Error() != expectedErr {
 		t.Errorf("case nil datafile and no PreviousPath, error mismatch: expected '%s', got '%s'", expectedErr, err.Error())
 	}
-	if len(store.(cafs.MapStore)) != 20 {
-		t.Errorf("case nil datafile and PreviousPath, expected invalid number of entries: %d != %d", 20, len(store.(cafs.MapStore)))
+	if len(store.(cafs.MapStore)) != 23 {
+		t.Errorf("case nil datafile and PreviousPath, expected invalid number of entries: %d != %d", 23, len(store.(cafs.MapStore)))
 		_, err := store.(cafs.MapStore).Print()
 		if err != nil {
 			panic(err)
@@ -231,6 +238,115 @@ func TestCreateDataset(t *testing.T) {
 	}
 }
 
+func TestCreateDatasetBodyDiffTitle(t *testing.T) {
+	store := cafs.NewMapstore()
+	privKey, err := crypto.UnmarshalPrivateKey(testPk)
+	if err != nil {
+		t.Fatalf("error unmarshaling private key: %s", err.Error())
+	}
+
+	tc, err := dstest.NewTestCaseFromDir("testdata/cities")
+	if err != nil {
+		t.Fatalf("error creating test case: %s", err.Error())
+	}
+	path, err := CreateDataset(store, tc.Input, cafs.NewMemfileBytes(tc.DataFilename, tc.Data), privKey, false)
+	if err != nil {
+		t.Fatalf("error creating dataset: %s", err.Error())
+	}
+
+	cases := []struct {
+		title    string
+		bodyDiff bool
+		data     string
+		expect   string
+	}{
+		{"", true, "city,pop,avg_age,in_usa\ntoronto,40000001,55.5,false\nnew york,8500000,44.4,true\nchicago,300000,44.4,true\nchatham,35000,65.25,true\nraleigh,250000,50.65,true\nboston,700000,36.1,true\n", "1 row added, 1 modified"},
+		{"", true, "city,pop,avg_age,in_usa\nsalem,40000,40,true\ntoronto,40000001,55.5,false\n", "4 rows removed, 1 modified"},
+		{"", false, "city,pop,avg_age,in_usa\ntoronto,40000001,55.5,false\n", "Structure Changed"},
+		{"user title", true, "city,pop,avg_age,in_usa\n", "user title"},
+	}
+
+	for i, c := range cases {
+		ds := &dataset.Dataset{
+			PreviousPath: path.String(),
+			Commit:       &dataset.Commit{Title: c.title},
+			Structure:    &dataset.Structure{},
+		}
+		ds.Structure.Assign(tc.Input.Structure)
+
+		path, err = CreateDataset(store, ds, cafs.NewMemfileBytes("data.csv", []byte(c.data)), privKey, false, func(o *CreateDatasetOpts) {
+			o.BodyDiffTitle = c.bodyDiff
+		})
+		if err != nil {
+			t.Fatalf("case %d error creating dataset: %s", i, err.Error())
+		}
+		got, err := LoadDataset(store, path)
+		if err != nil {
+			t.Fatalf("case %d error loading dataset: %s", i, err.Error())
+		}
+		if got.Commit.Title != c.expect {
+			t.Errorf("case %d commit title mismatch. expected: %q, got: %q", i, c.expect, got.Commit.Title)
+		}
+	}
+}
+
+func TestCreateDatasetSchemaCompatibility(t *testing.T) {
+	store := cafs.NewMapstore()
+	privKey, err := crypto.UnmarshalPrivateKey(testPk)
+	if err != nil {
+		t.Fatalf("error unmarshaling private key: %s", err.Error())
+	}
+
+	tc, err := dstest.NewTestCaseFromDir("testdata/cities")
+	if err != nil {
+		t.Fatalf("error creating test case: %s", err.Error())
+	}
+	path, err := CreateDataset(store, tc.Input, cafs.NewMemfileBytes(tc.DataFilename, tc.Data), privKey, false)
+	if err != nil {
+		t.Fatalf("error creating dataset: %s", err.Error())
+	}
+
+	// pop changes from integer to number, a backward compatible widening
+	widened := jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type": "array",
+			"items": [
+				{ "title": "city", "type": "string" },
+				{ "title": "pop", "type": "number" },
+				{ "title": "avg_age", "type": "number" },
+				{ "title": "in_usa", "type": "boolean" }
+			]
+		}
+	}`)
+
+	cases := []struct {
+		required validate.Compatibility
+		err      string
+	}{
+		{validate.CompatibilityNone, ""},
+		{validate.BackwardCompatible, ""},
+		{validate.ForwardCompatible, `schema change doesn't meet forward compatibility: type widened "pop": integer -> number`},
+	}
+
+	for i, c := range cases {
+		ds := &dataset.Dataset{
+			PreviousPath: path.String(),
+			Commit:       &dataset.Commit{Title: fmt.Sprintf("widen pop %d", i)},
+			Structure:    &dataset.Structure{},
+		}
+		ds.Structure.Assign(tc.Input.Structure)
+		ds.Structure.Schema = widened
+
+		_, err := CreateDataset(store, ds, cafs.NewMemfileBytes(tc.DataFilename, tc.Data), privKey, false, func(o *CreateDatasetOpts) {
+			o.SchemaCompatibility = c.required
+		})
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+		}
+	}
+}
+
 func TestWriteDataset(t *testing.T) {
 	store := cafs.NewMapstore()
 	prev := Timestamp
@@ -371,3 +487,171 @@ func TestWriteDataset(t *testing.T) {
 		}
 	}
 }
+
+func TestInspectData(t *testing.T) {
+	tc, err := dstest.NewTestCaseFromDir("testdata/cities")
+	if err != nil {
+		t.Errorf("error creating test case: %s", err)
+		return
+	}
+
+	st := &dataset.Structure{}
+	st.Assign(tc.Input.Structure)
+
+	df, err := inspectData(st, cafs.NewMemfileBytes(tc.DataFilename, tc.Data), nil, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	expect := tc.Expect.Structure
+	if st.Length != expect.Length {
+		t.Errorf("length mismatch. expected: %d, got: %d", expect.Length, st.Length)
+	}
+	if st.Entries != expect.Entries {
+		t.Errorf("entries mismatch. expected: %d, got: %d", expect.Entries, st.Entries)
+	}
+	// single-pass inspection should count the same validation errors as
+	// validating the whole body at once
+	er, err := dsio.NewEntryReader(tc.Input.Structure, bytes.NewReader(tc.Data))
+	if err != nil {
+		t.Fatalf("error creating entry reader: %s", err.Error())
+	}
+	valErrs, err := validate.EntryReader(er)
+	if err != nil {
+		t.Fatalf("error validating data: %s", err.Error())
+	}
+	if st.ErrCount != len(valErrs) {
+		t.Errorf("errCount mismatch. expected: %d, got: %d", len(valErrs), st.ErrCount)
+	}
+	if st.Checksum != expect.Checksum {
+		t.Errorf("checksum mismatch. expected: %s, got: %s", expect.Checksum, st.Checksum)
+	}
+
+	data, err := ioutil.ReadAll(df)
+	if err != nil {
+		t.Errorf("error reading spooled data: %s", err.Error())
+		return
+	}
+	if !bytes.Equal(data, tc.Data) {
+		t.Errorf("spooled data mismatch. expected: %s, got: %s", string(tc.Data), string(data))
+	}
+
+	if err := df.Close(); err != nil {
+		t.Errorf("error closing spooled data: %s", err.Error())
+	}
+}
+
+func TestInspectDataRootSchema(t *testing.T) {
+	cases := []struct {
+		schema   string
+		data     string
+		errCount int
+	}{
+		// object datasets resolve $ref & check required keys
+		{`{
+			"type": "object",
+			"definitions": {"city": {"type": "object", "required": ["pop"], "properties": {"pop": {"type": "integer"}}}},
+			"required": ["toronto", "chicago"],
+			"additionalProperties": {"$ref": "#/definitions/city"}
+		}`, `{"toronto": {"pop": 40000000}, "new york": {"pop": "lots"}, "boston": {}}`, 3},
+		// array datasets check item counts & uniqueness
+		{`{
+			"type": "array",
+			"definitions": {"pop": {"type": "integer"}},
+			"minItems": 5,
+			"uniqueItems": true,
+			"items": {"type": "array", "items": [{"type": "string"}, {"$ref": "#/definitions/pop"}]}
+		}`, `[["toronto", 40000000], ["chicago", "lots"], ["toronto", 40000000]]`, 3},
+	}
+
+	for i, c := range cases {
+		sch := &jsonschema.RootSchema{}
+		if err := sch.UnmarshalJSON([]byte(c.schema)); err != nil {
+			t.Fatalf("case %d error parsing schema: %s", i, err.Error())
+		}
+		st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch}
+		df, err := inspectData(st, cafs.NewMemfileBytes("data.json", []byte(c.data)), nil, nil)
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		df.Close()
+
+		// streaming validation must count the same errors as validating the
+		// whole body at once
+		er, err := dsio.NewEntryReader(st, bytes.NewReader([]byte(c.data)))
+		if err != nil {
+			t.Fatalf("case %d error creating entry reader: %s", i, err.Error())
+		}
+		valErrs, err := validate.EntryReader(er)
+		if err != nil {
+			t.Fatalf("case %d error validating data: %s", i, err.Error())
+		}
+		if st.ErrCount != c.errCount || len(valErrs) != c.errCount {
+			t.Errorf("case %d errCount mismatch. expected: %d, got: %d, validating the whole body gives: %d", i, c.errCount, st.ErrCount, len(valErrs))
+		}
+	}
+}
+
+func TestInspectDataCompressed(t *testing.T) {
+	tc, err := dstest.NewTestCaseFromDir("testdata/cities")
+	if err != nil {
+		t.Errorf("error creating test case: %s", err)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := compression.NewWriter(compression.Gzip, buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := w.Write(tc.Data); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	gzdata := buf.Bytes()
+
+	st := &dataset.Structure{}
+	st.Assign(tc.Input.Structure)
+	st.Compression = compression.Gzip
+
+	df, err := inspectData(st, cafs.NewMemfileBytes(tc.DataFilename+".gz", gzdata), nil, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	// length & checksum describe the stored, compressed bytes
+	sum, err := multihash.Sum(gzdata, multihash.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if st.Length != len(gzdata) {
+		t.Errorf("length mismatch. expected: %d, got: %d", len(gzdata), st.Length)
+	}
+	if st.Checksum != sum.B58String() {
+		t.Errorf("checksum mismatch. expected: %s, got: %s", sum.B58String(), st.Checksum)
+	}
+	if st.Entries != tc.Expect.Structure.Entries {
+		t.Errorf("entries mismatch. expected: %d, got: %d", tc.Expect.Structure.Entries, st.Entries)
+	}
+
+	if df.FileName() != "data.csv.gz" {
+		t.Errorf("filename mismatch. expected: data.csv.gz, got: %s", df.FileName())
+	}
+	data, err := ioutil.ReadAll(df)
+	if err != nil {
+		t.Errorf("error reading spooled data: %s", err.Error())
+		return
+	}
+	if !bytes.Equal(data, gzdata) {
+		t.Errorf("spooled data mismatch")
+	}
+
+	if err := df.Close(); err != nil {
+		t.Errorf("error closing spooled data: %s", err.Error())
+	}
+}
@@ -2,7 +2,9 @@ package dsfs
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"os"
 
 	"github.com/qri-io/cafs"
 )
@@ -24,3 +26,45 @@ func fileBytes(file cafs.File, err error) ([]byte, error) {
 	}
 	return ioutil.ReadAll(file)
 }
+
+// tempFile is a cafs.File backed by a temporary file on the local
+// filesystem. Closing a tempFile removes it
+type tempFile struct {
+	*os.File
+	name string
+}
+
+// FileName implements the cafs.File interface
+func (f *tempFile) FileName() string {
+	return f.name
+}
+
+// FullPath implements the cafs.File interface
+func (f *tempFile) FullPath() string {
+	return f.name
+}
+
+// IsDirectory implements the cafs.File interface
+func (f *tempFile) IsDirectory() bool {
+	return false
+}
+
+// NextFile implements the cafs.File interface
+func (f *tempFile) NextFile() (cafs.File, error) {
+	return nil, fmt.Errorf("tempFile is not a directory")
+}
+
+// Close closes & removes the underlying file
+func (f *tempFile) Close() error {
+	f.File.Close()
+	return os.Remove(f.File.Name())
+}
+
+// byteCounter is an io.Writer that counts bytes written to it
+type byteCounter int64
+
+// Write implements the io.Writer interface
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,123 @@
+package dsfs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+)
+
+// HistoryOpts configures optional behaviour of History & Log
+type HistoryOpts struct {
+	// Limit caps the number of versions visited, zero means no limit
+	Limit int
+	// RefsOnly loads each version with LoadDatasetRefs, dereferencing only
+	// the commit needed to summarize it
+	RefsOnly bool
+	// AllowMissing ends the walk without error when an ancestor can't be
+	// loaded, which happens when a store only holds part of a history
+	AllowMissing bool
+}
+
+// LogEntry summarizes a single version of a dataset
+type LogEntry struct {
+	// Path of the version
+	Path datastore.Key
+	// PreviousPath of the version, empty for the first version
+	PreviousPath string
+	// Author of the version commit, if any
+	Author *dataset.User
+	// Title & Message of the version commit
+	Title   string
+	Message string
+	// Timestamp of the version commit
+	Timestamp time.Time
+	// Dataset is the loaded version
+	Dataset *dataset.Dataset
+}
+
+// History walks the versions of a dataset by following PreviousPath, calling
+// fn with each version starting from path. Returning an error from fn stops
+// the walk, returning that error. History errors if an ancestor is missing
+// (unless opts allow it) or if the chain of PreviousPath links forms a cycle
+func History(store cafs.Filestore, path datastore.Key, fn func(i int, le LogEntry) error, opts ...func(*HistoryOpts)) error {
+	opt := &HistoryOpts{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	seen := map[string]bool{}
+	for i := 0; opt.Limit <= 0 || i < opt.Limit; i++ {
+		key := PackageKeypath(store, path, PackageFileDataset).String()
+		if seen[key] {
+			err := fmt.Errorf("history cycle: %s references an earlier version", key)
+			log.Debug(err.Error())
+			return err
+		}
+		seen[key] = true
+
+		ds, err := loadHistoryDataset(store, path, opt.RefsOnly)
+		if err != nil {
+			log.Debug(err.Error())
+			if i > 0 && opt.AllowMissing {
+				return nil
+			}
+			if i > 0 {
+				return fmt.Errorf("error loading ancestor %d (%s): %s", i, path.String(), err.Error())
+			}
+			return err
+		}
+
+		if err := fn(i, newLogEntry(ds)); err != nil {
+			return err
+		}
+		if ds.PreviousPath == "" {
+			break
+		}
+		path = datastore.NewKey(ds.PreviousPath)
+	}
+	return nil
+}
+
+// Log gives a summary of each version of a dataset, starting from path
+func Log(store cafs.Filestore, path datastore.Key, opts ...func(*HistoryOpts)) ([]LogEntry, error) {
+	entries := []LogEntry{}
+	err := History(store, path, func(i int, le LogEntry) error {
+		entries = append(entries, le)
+		return nil
+	}, opts...)
+	return entries, err
+}
+
+// loadHistoryDataset loads a single version for History
+func loadHistoryDataset(store cafs.Filestore, path datastore.Key, refsOnly bool) (*dataset.Dataset, error) {
+	if !refsOnly {
+		return LoadDataset(store, path)
+	}
+	ds, err := LoadDatasetRefs(store, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := DerefDatasetCommit(store, ds); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// newLogEntry summarizes a loaded dataset
+func newLogEntry(ds *dataset.Dataset) LogEntry {
+	le := LogEntry{
+		Path:         ds.Path(),
+		PreviousPath: ds.PreviousPath,
+		Dataset:      ds,
+	}
+	if ds.Commit != nil {
+		le.Author = ds.Commit.Author
+		le.Title = ds.Commit.Title
+		le.Message = ds.Commit.Message
+		le.Timestamp = ds.Commit.Timestamp
+	}
+	return le
+}
@@ -0,0 +1,142 @@
+package dsfs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dstest"
+)
+
+// makeHistory creates a dataset with n versions, returning the path of the
+// latest version
+func makeHistory(store cafs.Filestore, n int) (datastore.Key, error) {
+	privKey, err := crypto.UnmarshalPrivateKey(testPk)
+	if err != nil {
+		return datastore.NewKey(""), err
+	}
+	tc, err := dstest.NewTestCaseFromDir("testdata/cities")
+	if err != nil {
+		return datastore.NewKey(""), err
+	}
+
+	var path datastore.Key
+	prev := ""
+	data := "city,pop,avg_age,in_usa\n"
+	for i := 0; i < n; i++ {
+		data += fmt.Sprintf("city_%d,%d,50.5,true\n", i, i*1000)
+		ds := &dataset.Dataset{
+			PreviousPath: prev,
+			Commit:       &dataset.Commit{Title: fmt.Sprintf("version %d", i)},
+			Structure:    &dataset.Structure{},
+		}
+		ds.Structure.Assign(tc.Input.Structure)
+		path, err = CreateDataset(store, ds, cafs.NewMemfileBytes("data.csv", []byte(data)), privKey, false)
+		if err != nil {
+			return datastore.NewKey(""), err
+		}
+		prev = path.String()
+	}
+	return path, nil
+}
+
+func TestLog(t *testing.T) {
+	store := cafs.NewMapstore()
+	path, err := makeHistory(store, 3)
+	if err != nil {
+		t.Fatalf("error creating history: %s", err.Error())
+	}
+
+	cases := []struct {
+		opts   HistoryOpts
+		titles []string
+		err    string
+	}{
+		{HistoryOpts{}, []string{"version 2", "version 1", "version 0"}, ""},
+		{HistoryOpts{Limit: 2}, []string{"version 2", "version 1"}, ""},
+		{HistoryOpts{RefsOnly: true}, []string{"version 2", "version 1", "version 0"}, ""},
+	}
+
+	for i, c := range cases {
+		log, err := Log(store, path, func(o *HistoryOpts) { *o = c.opts })
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+			continue
+		}
+		if len(log) != len(c.titles) {
+			t.Errorf("case %d length mismatch. expected: %d, got: %d", i, len(c.titles), len(log))
+			continue
+		}
+		for j, le := range log {
+			if le.Title != c.titles[j] {
+				t.Errorf("case %d entry %d title mismatch. expected: %q, got: %q", i, j, c.titles[j], le.Title)
+			}
+			if le.Timestamp.IsZero() {
+				t.Errorf("case %d entry %d expected a timestamp", i, j)
+			}
+			if c.opts.RefsOnly && le.Dataset.Structure != nil && !le.Dataset.Structure.IsEmpty() {
+				t.Errorf("case %d entry %d expected structure to be a reference", i, j)
+			}
+		}
+		if last := log[len(log)-1]; c.opts.Limit == 0 && last.PreviousPath != "" {
+			t.Errorf("case %d expected first version to have no previous path. got: %s", i, last.PreviousPath)
+		}
+	}
+}
+
+func TestHistoryMissingAncestor(t *testing.T) {
+	store := cafs.NewMapstore()
+	tc, err := dstest.NewTestCaseFromDir("testdata/cities")
+	if err != nil {
+		t.Fatalf("error creating test case: %s", err.Error())
+	}
+
+	ds := &dataset.Dataset{
+		PreviousPath: "/map/QmMissing",
+		Commit:       &dataset.Commit{Title: "orphan"},
+		Structure:    &dataset.Structure{},
+	}
+	ds.Structure.Assign(tc.Input.Structure)
+	path, err := WriteDataset(store, ds, cafs.NewMemfileBytes(tc.DataFilename, tc.Data), false)
+	if err != nil {
+		t.Fatalf("error writing dataset: %s", err.Error())
+	}
+
+	if _, err := Log(store, path); err == nil {
+		t.Errorf("expected missing ancestor to error")
+	}
+
+	log, err := Log(store, path, func(o *HistoryOpts) { o.AllowMissing = true })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if len(log) != 1 || log[0].Title != "orphan" {
+		t.Errorf("expected only the orphan version. got: %v", log)
+	}
+}
+
+func TestHistoryStop(t *testing.T) {
+	store := cafs.NewMapstore()
+	path, err := makeHistory(store, 3)
+	if err != nil {
+		t.Fatalf("error creating history: %s", err.Error())
+	}
+
+	visited := 0
+	err = History(store, path, func(i int, le LogEntry) error {
+		visited++
+		if i == 1 {
+			return fmt.Errorf("stop")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "stop" {
+		t.Errorf("expected stop error. got: %v", err)
+	}
+	if visited != 2 {
+		t.Errorf("expected 2 visited versions. got: %d", visited)
+	}
+}
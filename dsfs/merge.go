@@ -0,0 +1,387 @@
+package dsfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+)
+
+// MergeOpts configures optional behaviour of Merge
+type MergeOpts struct {
+	// Key is the primary key field used to match body entries, either a
+	// column title or an object key. An empty key matches entries by object
+	// key, array bodies require a key
+	Key string
+}
+
+// MergeConflict describes a change both sides of a merge made differently
+type MergeConflict struct {
+	// Component is the conflicting part of the dataset: "meta", "structure",
+	// or "body"
+	Component string
+	// Key identifies the conflicting body entry
+	Key string
+	// Field is the conflicting field. Meta & structure fields are
+	// dot-separated paths, eg: "schema.items.items"
+	Field string
+	// Base, Ours and Theirs are the conflicting values, nil if a value isn't
+	// present on that side
+	Base   interface{}
+	Ours   interface{}
+	Theirs interface{}
+}
+
+// MergeResult is the outcome of a merge. If Conflicts is empty, Dataset &
+// Body are ready to be passed to CreateDataset
+type MergeResult struct {
+	Dataset   *dataset.Dataset
+	Body      cafs.File
+	Conflicts []MergeConflict
+}
+
+// structure fields that CreateDataset calculates, which aren't merged
+var mergeSkipStructureFields = map[string]bool{
+	"checksum": true,
+	"entries":  true,
+	"errCount": true,
+	"length":   true,
+}
+
+// Merge performs a three-way merge of two versions of a dataset, ours and
+// theirs, that share a common ancestor base. Meta & structure are merged
+// field by field, schema columns are matched by title. Body entries are
+// matched by key & merged with dsio.MergeEntries, which holds all three
+// bodies in memory. Rows of array bodies are rearranged to fit the merged
+// columns before merging. Transform &
+// VisConfig are taken from ours. The merged dataset's PreviousPath is ours
+func Merge(store cafs.Filestore, base, ours, theirs datastore.Key, opts ...func(*MergeOpts)) (*MergeResult, error) {
+	opt := &MergeOpts{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	var dss [3]*dataset.Dataset
+	for i, path := range []datastore.Key{base, ours, theirs} {
+		ds, err := LoadDataset(store, path)
+		if err != nil {
+			log.Debug(err.Error())
+			return nil, fmt.Errorf("error loading %s: %s", path.String(), err.Error())
+		}
+		dss[i] = ds
+	}
+
+	res := &MergeResult{}
+	addConflicts := func(component string, conflicts []MergeConflict) {
+		for _, c := range conflicts {
+			c.Component = component
+			res.Conflicts = append(res.Conflicts, c)
+		}
+	}
+
+	var err error
+	var metas [3]map[string]interface{}
+	for i, ds := range dss {
+		if metas[i], err = componentMap(ds.Meta); err != nil {
+			return nil, err
+		}
+	}
+	meta, conflicts := mergeFields("", metas[0], metas[1], metas[2])
+	addConflicts("meta", conflicts)
+
+	var sts [3]map[string]interface{}
+	for i, ds := range dss {
+		if ds.Structure == nil {
+			return nil, fmt.Errorf("%s has no structure", ds.Path().String())
+		}
+		if sts[i], err = componentMap(ds.Structure); err != nil {
+			return nil, err
+		}
+		for key := range mergeSkipStructureFields {
+			delete(sts[i], key)
+		}
+	}
+	st, conflicts := mergeFields("", sts[0], sts[1], sts[2])
+	addConflicts("structure", conflicts)
+
+	merged := &dataset.Dataset{}
+	merged.Assign(dss[1])
+	merged.PreviousPath = ours.String()
+	// data path, offset index & stats all describe our body, not the merged one
+	merged.DataPath = ""
+	merged.OffsetIndexPath = ""
+	merged.Stats = nil
+	merged.Commit = &dataset.Commit{Title: fmt.Sprintf("merge %s", theirs.String())}
+
+	if merged.Meta, err = mergedMeta(meta); err != nil {
+		return nil, err
+	}
+	if merged.Structure, err = mergedStructure(st); err != nil {
+		return nil, err
+	}
+
+	body, conflicts, err := mergeBody(store, dss, merged.Structure, opt.Key)
+	if err != nil {
+		return nil, err
+	}
+	addConflicts("body", conflicts)
+
+	if len(res.Conflicts) > 0 {
+		return res, nil
+	}
+	res.Dataset = merged
+	res.Body = body
+	return res, nil
+}
+
+// componentMap gives the json object form of a dataset component
+func componentMap(c interface {
+	MarshalJSONObject() ([]byte, error)
+}) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if reflect.ValueOf(c).IsNil() {
+		return m, nil
+	}
+	data, err := c.MarshalJSONObject()
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error marshaling component: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error unmarshaling component: %s", err.Error())
+	}
+	return m, nil
+}
+
+// mergeFields merges the changes made to a base json object by ours and
+// theirs, recursing into objects. Arrays of titled objects, like the columns
+// of a schema, are merged by title. Other arrays & values are replaced whole,
+// conflicting if both sides change them differently. Conflicts are resolved
+// in favour of ours
+func mergeFields(prefix string, base, ours, theirs map[string]interface{}) (map[string]interface{}, []MergeConflict) {
+	keys := []string{}
+	for _, m := range []map[string]interface{}{base, ours, theirs} {
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var conflicts []MergeConflict
+	merged := map[string]interface{}{}
+	for i, k := range keys {
+		if i > 0 && keys[i-1] == k {
+			continue
+		}
+		field := strings.TrimPrefix(prefix+"."+k, ".")
+		b, bp := base[k]
+		o, op := ours[k]
+		t, tp := theirs[k]
+
+		switch {
+		case op == tp && reflect.DeepEqual(o, t), bp == tp && reflect.DeepEqual(b, t):
+			if op {
+				merged[k] = o
+			}
+		case bp == op && reflect.DeepEqual(b, o):
+			if tp {
+				merged[k] = t
+			}
+		default:
+			bm, bok := b.(map[string]interface{})
+			om, ook := o.(map[string]interface{})
+			tm, tok := t.(map[string]interface{})
+			if bok && ook && tok {
+				val, c := mergeFields(field, bm, om, tm)
+				merged[k] = val
+				conflicts = append(conflicts, c...)
+				continue
+			}
+			if val, c, ok := mergeTitled(field, b, o, t); ok {
+				merged[k] = val
+				conflicts = append(conflicts, c...)
+				continue
+			}
+			conflicts = append(conflicts, MergeConflict{Field: field, Base: b, Ours: o, Theirs: t})
+			if op {
+				merged[k] = o
+			}
+		}
+	}
+	return merged, conflicts
+}
+
+// mergeTitled merges arrays of objects with unique titles, matching
+// elements by title. Merged elements are ordered as they are in ours,
+// followed by elements only theirs adds. ok is false if any of base, ours or
+// theirs isn't an array of titled objects
+func mergeTitled(prefix string, base, ours, theirs interface{}) (merged []interface{}, conflicts []MergeConflict, ok bool) {
+	var maps [3]map[string]interface{}
+	var orders [3][]string
+	for i, v := range []interface{}{base, ours, theirs} {
+		if maps[i], orders[i], ok = titledElements(v); !ok {
+			return nil, nil, false
+		}
+	}
+
+	fields, conflicts := mergeFields(prefix, maps[0], maps[1], maps[2])
+	merged = []interface{}{}
+	for _, title := range append(orders[1], orders[2]...) {
+		if el, ok := fields[title]; ok {
+			merged = append(merged, el)
+			delete(fields, title)
+		}
+	}
+	return merged, conflicts, true
+}
+
+// titledElements maps the elements of an array of objects by their "title"
+// field, ok is false if v isn't an array of objects with unique titles
+func titledElements(v interface{}) (elements map[string]interface{}, order []string, ok bool) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, nil, false
+	}
+	elements = map[string]interface{}{}
+	for _, el := range arr {
+		obj, _ := el.(map[string]interface{})
+		title, _ := obj["title"].(string)
+		if _, dup := elements[title]; title == "" || dup {
+			return nil, nil, false
+		}
+		elements[title] = obj
+		order = append(order, title)
+	}
+	return elements, order, true
+}
+
+// mergedMeta creates a meta from merged fields
+func mergedMeta(fields map[string]interface{}) (*dataset.Meta, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error marshaling merged meta: %s", err.Error())
+	}
+	md := &dataset.Meta{}
+	if err := json.Unmarshal(data, md); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error unmarshaling merged meta: %s", err.Error())
+	}
+	return md, nil
+}
+
+// mergedStructure creates a structure from merged fields
+func mergedStructure(fields map[string]interface{}) (*dataset.Structure, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error marshaling merged structure: %s", err.Error())
+	}
+	st := &dataset.Structure{}
+	if err := json.Unmarshal(data, st); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error unmarshaling merged structure: %s", err.Error())
+	}
+	return st, nil
+}
+
+// mergeBody merges the bodies of base, ours & theirs datasets, writing
+// merged entries with the merged structure
+func mergeBody(store cafs.Filestore, dss [3]*dataset.Dataset, st *dataset.Structure, key string) (cafs.File, []MergeConflict, error) {
+	titles, err := columnTitles(st)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var readers [3]dsio.EntryReader
+	for i, ds := range dss {
+		f, err := LoadData(store, ds)
+		if err != nil {
+			log.Debug(err.Error())
+			return nil, nil, fmt.Errorf("error loading data for %s: %s", ds.Path().String(), err.Error())
+		}
+		defer f.Close()
+		if readers[i], err = dsio.NewEntryReader(ds.Structure, f); err != nil {
+			log.Debug(err.Error())
+			return nil, nil, err
+		}
+
+		dsTitles, err := columnTitles(ds.Structure)
+		if err != nil {
+			return nil, nil, err
+		}
+		if titles != nil && !reflect.DeepEqual(dsTitles, titles) {
+			if readers[i], err = migrateColumns(readers[i], st); err != nil {
+				return nil, nil, fmt.Errorf("error reading data for %s: %s", ds.Path().String(), err.Error())
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := dsio.NewEntryWriter(st, buf)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil, err
+	}
+	entryConflicts, err := dsio.MergeEntries(readers[0], readers[1], readers[2], key, w)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil, fmt.Errorf("error merging body: %s", err.Error())
+	}
+	if err := w.Close(); err != nil {
+		log.Debug(err.Error())
+		return nil, nil, fmt.Errorf("error writing merged body: %s", err.Error())
+	}
+
+	conflicts := make([]MergeConflict, len(entryConflicts))
+	for i, c := range entryConflicts {
+		conflicts[i] = MergeConflict{Key: c.Key, Field: c.Field, Base: c.Base, Ours: c.Ours, Theirs: c.Theirs}
+	}
+	return cafs.NewMemfileBytes(fmt.Sprintf("body.%s", st.Format.String()), buf.Bytes()), conflicts, nil
+}
+
+// columnTitles gives the column titles of a structure's schema, nil if the
+// schema doesn't describe titled columns
+func columnTitles(st *dataset.Structure) ([]string, error) {
+	fields, err := componentMap(st)
+	if err != nil {
+		return nil, err
+	}
+	sch, _ := fields["schema"].(map[string]interface{})
+	items, _ := sch["items"].(map[string]interface{})
+	_, titles, ok := titledElements(items["items"])
+	if !ok {
+		return nil, nil
+	}
+	return titles, nil
+}
+
+// migrateColumns reads all entries of r into memory, rearranging the values
+// of each row to match the columns of st. Columns r doesn't have are null
+func migrateColumns(r dsio.EntryReader, st *dataset.Structure) (dsio.EntryReader, error) {
+	buf, err := dsio.NewEntryBuffer(&dataset.Structure{Format: dataset.JSONDataFormat, Schema: st.Schema})
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	if _, err := dsio.Migrate(r, buf); err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	if err := buf.Close(); err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,159 @@
+package dsfs
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dstest"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestMerge(t *testing.T) {
+	store := cafs.NewMapstore()
+	privKey, err := crypto.UnmarshalPrivateKey(testPk)
+	if err != nil {
+		t.Fatalf("error unmarshaling private key: %s", err.Error())
+	}
+	tc, err := dstest.NewTestCaseFromDir("testdata/cities")
+	if err != nil {
+		t.Fatalf("error creating test case: %s", err.Error())
+	}
+
+	create := func(prev string, md *dataset.Meta, data string, opts ...func(*CreateDatasetOpts)) datastore.Key {
+		ds := &dataset.Dataset{
+			PreviousPath: prev,
+			Commit:       &dataset.Commit{Title: "version"},
+			Meta:         md,
+			Structure:    &dataset.Structure{},
+		}
+		ds.Structure.Assign(tc.Input.Structure)
+		path, err := CreateDataset(store, ds, cafs.NewMemfileBytes("data.csv", []byte(data)), privKey, false, opts...)
+		if err != nil {
+			t.Fatalf("error creating dataset: %s", err.Error())
+		}
+		return path
+	}
+
+	header := "city,pop,avg_age,in_usa\n"
+	base := create("", &dataset.Meta{Title: "cities"},
+		header+"toronto,40000000,55.5,false\nchicago,300000,44.4,true\n")
+	ours := create(base.String(), &dataset.Meta{Title: "cities of north america"},
+		header+"toronto,40000001,55.5,false\nchicago,300000,44.4,true\n",
+		func(o *CreateDatasetOpts) { o.OffsetIndexInterval = 1 })
+	theirs := create(base.String(), &dataset.Meta{Title: "cities", Description: "some cities"},
+		header+"toronto,40000000,55.5,false\nchicago,300000,44.4,true\nboston,700000,36.1,true\n")
+	conflicting := create(base.String(), &dataset.Meta{Title: "big cities"},
+		header+"toronto,40000002,55.5,false\nchicago,300000,44.4,true\n")
+
+	res, err := Merge(store, base, ours, theirs, func(o *MergeOpts) { o.Key = "city" })
+	if err != nil {
+		t.Fatalf("error merging: %s", err.Error())
+	}
+	if len(res.Conflicts) != 0 {
+		t.Fatalf("expected no conflicts. got: %v", res.Conflicts)
+	}
+	if res.Dataset.Meta.Title != "cities of north america" || res.Dataset.Meta.Description != "some cities" {
+		t.Errorf("meta fields weren't merged. got title: %q, description: %q", res.Dataset.Meta.Title, res.Dataset.Meta.Description)
+	}
+	if res.Dataset.PreviousPath != ours.String() {
+		t.Errorf("previous path mismatch. expected: %s, got: %s", ours.String(), res.Dataset.PreviousPath)
+	}
+	if res.Dataset.OffsetIndexPath != "" || res.Dataset.Stats != nil {
+		t.Errorf("expected offset index & stats of ours to be dropped. got index: %q, stats: %v", res.Dataset.OffsetIndexPath, res.Dataset.Stats)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("error reading merged body: %s", err.Error())
+	}
+	expect := header + "toronto,40000001,55.5,false\nchicago,300000,44.4,true\nboston,700000,36.1,true\n"
+	if string(data) != expect {
+		t.Errorf("merged body mismatch. expected:\n%s\ngot:\n%s", expect, string(data))
+	}
+	if _, err := CreateDataset(store, res.Dataset, cafs.NewMemfileBytes(res.Body.FileName(), data), privKey, false); err != nil {
+		t.Errorf("error creating merged dataset: %s", err.Error())
+	}
+
+	res, err = Merge(store, base, ours, conflicting, func(o *MergeOpts) { o.Key = "city" })
+	if err != nil {
+		t.Fatalf("error merging: %s", err.Error())
+	}
+	if res.Dataset != nil || res.Body != nil {
+		t.Errorf("expected conflicting merge to give no dataset")
+	}
+	expectConflicts := []MergeConflict{
+		{Component: "meta", Field: "title", Base: "cities", Ours: "cities of north america", Theirs: "big cities"},
+		{Component: "body", Key: "toronto", Field: "pop", Base: int64(40000000), Ours: int64(40000001), Theirs: int64(40000002)},
+	}
+	if len(res.Conflicts) != len(expectConflicts) {
+		t.Fatalf("conflict count mismatch. expected: %d, got: %d: %v", len(expectConflicts), len(res.Conflicts), res.Conflicts)
+	}
+	for i, c := range expectConflicts {
+		if res.Conflicts[i] != c {
+			t.Errorf("conflict %d mismatch. expected: %v, got: %v", i, c, res.Conflicts[i])
+		}
+	}
+}
+
+func TestMergeColumns(t *testing.T) {
+	store := cafs.NewMapstore()
+	privKey, err := crypto.UnmarshalPrivateKey(testPk)
+	if err != nil {
+		t.Fatalf("error unmarshaling private key: %s", err.Error())
+	}
+
+	columns := `{"title": "city", "type": "string"}, {"title": "pop", "type": "integer"}`
+	create := func(prev, extra, data string) datastore.Key {
+		ds := &dataset.Dataset{
+			PreviousPath: prev,
+			Commit:       &dataset.Commit{Title: "version"},
+			Structure: &dataset.Structure{
+				Format:       dataset.CSVDataFormat,
+				FormatConfig: &dataset.CSVOptions{HeaderRow: true},
+				Schema:       jsonschema.Must(`{"type": "array", "items": {"type": "array", "items": [` + columns + extra + `]}}`),
+			},
+		}
+		path, err := CreateDataset(store, ds, cafs.NewMemfileBytes("data.csv", []byte(data)), privKey, false)
+		if err != nil {
+			t.Fatalf("error creating dataset: %s", err.Error())
+		}
+		return path
+	}
+
+	// ours & theirs each add a column, theirs also changes a value
+	base := create("", "", "city,pop\ntoronto,40000000\nchicago,300000\n")
+	ours := create(base.String(), `, {"title": "founded", "type": "integer"}`,
+		"city,pop,founded\ntoronto,40000000,1793\nchicago,300000,1833\n")
+	theirs := create(base.String(), `, {"title": "region", "type": "string"}`,
+		"city,pop,region\ntoronto,40000000,east\nchicago,300001,midwest\n")
+
+	res, err := Merge(store, base, ours, theirs, func(o *MergeOpts) { o.Key = "city" })
+	if err != nil {
+		t.Fatalf("error merging: %s", err.Error())
+	}
+	if len(res.Conflicts) != 0 {
+		t.Fatalf("expected no conflicts. got: %v", res.Conflicts)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("error reading merged body: %s", err.Error())
+	}
+	expect := "city,pop,founded,region\ntoronto,40000000,1793,east\nchicago,300001,1833,midwest\n"
+	if string(data) != expect {
+		t.Errorf("merged body mismatch. expected:\n%s\ngot:\n%s", expect, string(data))
+	}
+
+	// both sides add a column with the same title & different types
+	conflicting := create(base.String(), `, {"title": "founded", "type": "string"}`,
+		"city,pop,founded\ntoronto,40000000,1793\nchicago,300000,1833\n")
+	res, err = Merge(store, base, ours, conflicting, func(o *MergeOpts) { o.Key = "city" })
+	if err != nil {
+		t.Fatalf("error merging: %s", err.Error())
+	}
+	if len(res.Conflicts) == 0 || res.Conflicts[0].Field != "schema.items.items.founded" {
+		t.Errorf("expected a conflict on the founded column. got: %v", res.Conflicts)
+	}
+}
@@ -44,6 +44,11 @@ const (
 	PackageFileMeta
 	// PackageFileVisConfig isolates the data related to representing a dataset as a visualization
 	PackageFileVisConfig
+	// PackageFileOffsetIndex records byte offsets of entries in the
+	// dataset's data, for reading rows without scanning from the start
+	PackageFileOffsetIndex
+	// PackageFileStats summarizes the values of the dataset's data
+	PackageFileStats
 )
 
 // filenames maps PackageFile to their filename counterparts
@@ -58,6 +63,8 @@ var filenames = map[PackageFile]string{
 	PackageFileTransform:         "transform.json",
 	PackageFileMeta:              "meta.json",
 	PackageFileVisConfig:         "vis_config.json",
+	PackageFileOffsetIndex:       "offset_index.json",
+	PackageFileStats:             "stats.json",
 }
 
 // String implements the io.Stringer interface for PackageFile
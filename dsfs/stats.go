@@ -0,0 +1,35 @@
+package dsfs
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+)
+
+// SaveStats saves dataset stats to a given store
+func SaveStats(store cafs.Filestore, s *dataset.Stats, pin bool) (path datastore.Key, err error) {
+	file, err := JSONFile(PackageFileStats.String(), s)
+	if err != nil {
+		log.Debug(err.Error())
+		return datastore.NewKey(""), fmt.Errorf("error saving json stats file: %s", err.Error())
+	}
+	return store.Put(file, pin)
+}
+
+// LoadStats loads dataset stats from a given path in a store
+func LoadStats(store cafs.Filestore, path datastore.Key) (s *dataset.Stats, err error) {
+	path = PackageKeypath(store, path, PackageFileStats)
+	return loadStats(store, path)
+}
+
+// loadStats assumes the provided path is valid
+func loadStats(store cafs.Filestore, path datastore.Key) (s *dataset.Stats, err error) {
+	data, err := fileBytes(store.Get(path))
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error loading stats file: %s", err.Error())
+	}
+	return dataset.UnmarshalStats(data)
+}
@@ -0,0 +1,83 @@
+package dsfs
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dstest"
+)
+
+func TestLoadStats(t *testing.T) {
+	store := cafs.NewMapstore()
+	sa := &dataset.Stats{Qri: dataset.KindStats, Columns: []*dataset.ColumnStats{{Title: "a", Count: 1}}}
+	a, err := SaveStats(store, sa, true)
+	if err != nil {
+		t.Errorf("%s", err)
+		return
+	}
+
+	got, err := LoadStats(store, a)
+	if err != nil {
+		t.Errorf("%s", err)
+		return
+	}
+	if err := dataset.CompareStats(sa, got); err != nil {
+		t.Errorf("stats mismatch: %s", err.Error())
+	}
+}
+
+func TestCreateDatasetStats(t *testing.T) {
+	store := cafs.NewMapstore()
+	privKey, err := crypto.UnmarshalPrivateKey(testPk)
+	if err != nil {
+		t.Fatalf("error unmarshaling private key: %s", err.Error())
+	}
+	tc, err := dstest.NewTestCaseFromDir("testdata/cities")
+	if err != nil {
+		t.Fatalf("error creating test case: %s", err.Error())
+	}
+
+	path, err := CreateDataset(store, tc.Input, cafs.NewMemfileBytes(tc.DataFilename, tc.Data), privKey, false)
+	if err != nil {
+		t.Fatalf("error creating dataset: %s", err.Error())
+	}
+
+	refs, err := LoadDatasetRefs(store, path)
+	if err != nil {
+		t.Fatalf("error loading dataset refs: %s", err.Error())
+	}
+	if refs.Stats == nil || refs.Stats.Path().String() == "" {
+		t.Fatalf("expected stats to be stored as a reference")
+	}
+
+	ds, err := LoadDataset(store, path)
+	if err != nil {
+		t.Fatalf("error loading dataset: %s", err.Error())
+	}
+	if ds.Stats == nil || len(ds.Stats.Columns) != 4 {
+		t.Fatalf("expected stats for 4 columns. got: %v", ds.Stats)
+	}
+	pop := ds.Stats.Columns[1]
+	if pop.Title != "pop" || pop.Count != ds.Structure.Entries || pop.Min == nil || pop.Histogram == nil {
+		t.Errorf("pop column stats mismatch. got: %#v", pop)
+	}
+
+	// a new version built from the last one doesn't inherit stats that
+	// describe the previous body
+	next := &dataset.Dataset{}
+	next.Assign(ds)
+	next.Commit = &dataset.Commit{Title: "remove all rows"}
+	next.PreviousPath = path.String()
+	path, err = CreateDataset(store, next, cafs.NewMemfileBytes(tc.DataFilename, []byte("city,pop,avg_age,in_usa\n")), privKey, false)
+	if err != nil {
+		t.Fatalf("error creating next version: %s", err.Error())
+	}
+	if ds, err = LoadDataset(store, path); err != nil {
+		t.Fatalf("error loading next version: %s", err.Error())
+	}
+	if ds.Stats != nil {
+		t.Errorf("expected empty body to have no stats. got: %v", ds.Stats)
+	}
+}
@@ -3,10 +3,13 @@ package dsfs
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/dataset/dsql"
 )
 
 // LoadTransform loads a transform from a given path in a store
@@ -104,3 +107,74 @@ func SaveAbstractTransform(store cafs.Filestore, t *dataset.Transform, pin bool)
 
 	return store.Put(cafs.NewMemfileBytes(PackageFileAbstractTransform.String(), data), pin)
 }
+
+// ExecTransform runs a transform against the datasets its Resources
+// reference, returning a reader of the resulting data & the structure that
+// describes it. Only transforms with "sql" syntax can be run, the query is
+// loaded from the store path in the transform's Data field. Results use the
+// data format of the transform's structure if it specifies one, JSON otherwise
+func ExecTransform(store cafs.Filestore, t *dataset.Transform) (dsio.EntryReader, *dataset.Structure, error) {
+	if t.Syntax != "sql" {
+		return nil, nil, fmt.Errorf("can't execute transform with syntax '%s'", t.Syntax)
+	}
+	if t.Data == "" {
+		return nil, nil, fmt.Errorf("transform has no query to execute")
+	}
+	query, err := loadQuery(store, t.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resources := map[string]dsio.EntryReader{}
+	for name, ref := range t.Resources {
+		if ref == nil {
+			return nil, nil, fmt.Errorf("resource '%s' is empty", name)
+		}
+		ds := ref
+		if ds.IsEmpty() || ds.Structure == nil || ds.DataPath == "" {
+			loaded, err := LoadDataset(store, ref.Path())
+			if err != nil {
+				log.Debug(err.Error())
+				return nil, nil, fmt.Errorf("error loading resource '%s': %s", name, err.Error())
+			}
+			ds = loaded
+		}
+
+		f, err := LoadData(store, ds)
+		if err != nil {
+			log.Debug(err.Error())
+			return nil, nil, fmt.Errorf("error loading resource '%s' data: %s", name, err.Error())
+		}
+		defer f.Close()
+
+		r, err := dsio.NewEntryReader(ds.Structure, f)
+		if err != nil {
+			log.Debug(err.Error())
+			return nil, nil, fmt.Errorf("error reading resource '%s' data: %s", name, err.Error())
+		}
+		resources[name] = r
+	}
+
+	return dsql.Exec(query, resources, func(o *dsql.ExecOpts) {
+		if t.Structure != nil && t.Structure.Format != dataset.UnknownDataFormat {
+			o.Format = t.Structure.Format
+			o.FormatConfig = t.Structure.FormatConfig
+		}
+	})
+}
+
+// loadQuery reads the text of a transform query from a store path
+func loadQuery(store cafs.Filestore, path string) (string, error) {
+	f, err := store.Get(datastore.NewKey(path))
+	if err != nil {
+		log.Debug(err.Error())
+		return "", fmt.Errorf("error loading transform query: %s", err.Error())
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		log.Debug(err.Error())
+		return "", fmt.Errorf("error reading transform query: %s", err.Error())
+	}
+	return string(data), nil
+}
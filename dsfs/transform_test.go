@@ -5,8 +5,10 @@ import (
 	"testing"
 
 	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-crypto"
 	"github.com/qri-io/cafs"
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
 	"github.com/qri-io/jsonschema"
 )
 
@@ -146,3 +148,87 @@ func TestSaveAbstractTransform(t *testing.T) {
 		}
 	}
 }
+
+// putQuery adds the text of a transform query to a store, returning it's path
+func putQuery(t *testing.T, store cafs.Filestore, query string) string {
+	key, err := store.Put(cafs.NewMemfileBytes("query.sql", []byte(query)), false)
+	if err != nil {
+		t.Fatalf("error putting query: %s", err.Error())
+	}
+	return key.String()
+}
+
+func TestExecTransform(t *testing.T) {
+	datasets, store, err := makeFilestore()
+	if err != nil {
+		t.Fatalf("error creating test filestore: %s", err.Error())
+	}
+	privKey, err := crypto.UnmarshalPrivateKey(testPk)
+	if err != nil {
+		t.Fatalf("error unmarshaling private key: %s", err.Error())
+	}
+
+	cases := []struct {
+		transform *dataset.Transform
+		expect    string
+		err       string
+	}{
+		{&dataset.Transform{
+			Syntax:    "sql",
+			Data:      putQuery(t, store, "select city, pop from a where in_usa order by pop desc limit 2"),
+			Structure: &dataset.Structure{Format: dataset.CSVDataFormat},
+			Resources: map[string]*dataset.Dataset{"a": dataset.NewDatasetRef(datasets["cities"])},
+		}, "city,pop\nnew york,8500000\nchicago,300000\n", ""},
+		{&dataset.Transform{
+			Syntax:    "sql",
+			Data:      putQuery(t, store, "select count(*) as n from a"),
+			Resources: map[string]*dataset.Dataset{"a": dataset.NewDatasetRef(datasets["cities"])},
+		}, `[[5]]`, ""},
+		{&dataset.Transform{Syntax: "ql", Data: putQuery(t, store, "select * from a")}, "", "can't execute transform with syntax 'ql'"},
+		{&dataset.Transform{Syntax: "sql"}, "", "transform has no query to execute"},
+		{&dataset.Transform{Syntax: "sql", Data: "/map/missing"}, "", "error loading transform query: datastore: key not found"},
+		{&dataset.Transform{Syntax: "sql", Data: putQuery(t, store, "select * from b")}, "", `unknown table "b"`},
+	}
+
+	for i, c := range cases {
+		r, st, err := ExecTransform(store, c.transform)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		buf, err := dsio.NewEntryBuffer(st)
+		if err != nil {
+			t.Errorf("case %d error creating buffer: %s", i, err.Error())
+			continue
+		}
+		err = dsio.EachEntry(r, func(_ int, ent dsio.Entry, err error) error {
+			return buf.WriteEntry(ent)
+		})
+		if err != nil {
+			t.Errorf("case %d error writing results: %s", i, err.Error())
+			continue
+		}
+		if err := buf.Close(); err != nil {
+			t.Errorf("case %d error closing buffer: %s", i, err.Error())
+			continue
+		}
+		if string(buf.Bytes()) != c.expect {
+			t.Errorf("case %d result mismatch. expected: %s, got: %s", i, c.expect, string(buf.Bytes()))
+			continue
+		}
+
+		// results can be committed as a dataset
+		ds := &dataset.Dataset{
+			Commit:    &dataset.Commit{Title: "run transform"},
+			Structure: st,
+			Transform: c.transform,
+		}
+		if _, err := CreateDataset(store, ds, cafs.NewMemfileBytes("data."+st.Format.String(), buf.Bytes()), privKey, false); err != nil {
+			t.Errorf("case %d error committing results: %s", i, err.Error())
+		}
+	}
+}
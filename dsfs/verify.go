@@ -0,0 +1,63 @@
+package dsfs
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/qri-io/cafs"
+)
+
+// VerifyOpts configures optional behaviour of VerifyDataset
+type VerifyOpts struct {
+	// History verifies every version reachable by following PreviousPath,
+	// not just the version at path
+	History bool
+}
+
+// A VerificationError reports a version of a dataset that failed signature
+// verification
+type VerificationError struct {
+	// Index is the position of the version in history, 0 being the version
+	// VerifyDataset was called with, 1 it's previous version, and so on
+	Index int
+	// Path of the version that failed verification
+	Path datastore.Key
+	// Reason verification failed
+	Reason error
+}
+
+// Error implements the error interface
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("version %d (%s) failed verification: %s", e.Index, e.Path.String(), e.Reason.Error())
+}
+
+// VerifyDataset checks the commit signature of the dataset at path was
+// created by the private key matching pubKey. Versions that fail verification
+// return a *VerificationError
+func VerifyDataset(store cafs.Filestore, path datastore.Key, pubKey crypto.PubKey, opts ...func(*VerifyOpts)) error {
+	opt := &VerifyOpts{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	return History(store, path, func(i int, le LogEntry) error {
+		var reason error
+		if le.Dataset.Commit == nil {
+			reason = fmt.Errorf("dataset has no commit")
+		} else {
+			reason = le.Dataset.Commit.Verify(pubKey)
+		}
+		if reason != nil {
+			err := &VerificationError{Index: i, Path: le.Path, Reason: reason}
+			log.Debug(err.Error())
+			return err
+		}
+		return nil
+	}, func(o *HistoryOpts) {
+		o.RefsOnly = true
+		if !opt.History {
+			o.Limit = 1
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package dsfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dstest"
+)
+
+func TestVerifyDataset(t *testing.T) {
+	store := cafs.NewMapstore()
+	privKey, err := crypto.UnmarshalPrivateKey(testPk)
+	if err != nil {
+		t.Fatalf("error unmarshaling private key: %s", err.Error())
+	}
+	_, otherPubKey, err := crypto.GenerateKeyPair(crypto.RSA, 1024)
+	if err != nil {
+		t.Fatalf("error generating key pair: %s", err.Error())
+	}
+
+	path, err := makeHistory(store, 2)
+	if err != nil {
+		t.Fatalf("error creating history: %s", err.Error())
+	}
+
+	// write an unsigned version, then a signed version on top of it
+	tc, err := dstest.NewTestCaseFromDir("testdata/cities")
+	if err != nil {
+		t.Fatalf("error creating test case: %s", err.Error())
+	}
+	unsigned := &dataset.Dataset{
+		Commit:    &dataset.Commit{Title: "unsigned", Timestamp: time.Date(2001, 01, 01, 01, 01, 01, 0, time.UTC)},
+		Structure: &dataset.Structure{},
+	}
+	unsigned.Structure.Assign(tc.Input.Structure)
+	unsignedPath, err := WriteDataset(store, unsigned, cafs.NewMemfileBytes(tc.DataFilename, tc.Data), false)
+	if err != nil {
+		t.Fatalf("error writing dataset: %s", err.Error())
+	}
+	signed := &dataset.Dataset{
+		PreviousPath: unsignedPath.String(),
+		Commit:       &dataset.Commit{Title: "signed"},
+		Structure:    &dataset.Structure{},
+	}
+	signed.Structure.Assign(tc.Input.Structure)
+	signedPath, err := CreateDataset(store, signed, cafs.NewMemfileBytes("data.csv", []byte("city,pop,avg_age,in_usa\n")), privKey, false)
+	if err != nil {
+		t.Fatalf("error creating dataset: %s", err.Error())
+	}
+
+	cases := []struct {
+		path    string
+		key     crypto.PubKey
+		history bool
+		index   int
+		err     string
+	}{
+		{path.String(), privKey.GetPublic(), false, 0, ""},
+		{path.String(), privKey.GetPublic(), true, 0, ""},
+		{path.String(), otherPubKey, true, 0, "version 0 (" + path.String() + ") failed verification: commit signature doesn't match"},
+		{signedPath.String(), privKey.GetPublic(), false, 0, ""},
+		{signedPath.String(), privKey.GetPublic(), true, 1, "version 1 (" + unsignedPath.String() + ") failed verification: commit is not signed"},
+	}
+
+	for i, c := range cases {
+		err := VerifyDataset(store, datastore.NewKey(c.path), c.key, func(o *VerifyOpts) { o.History = c.history })
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+			continue
+		}
+		if err != nil {
+			verr, ok := err.(*VerificationError)
+			if !ok {
+				t.Errorf("case %d expected a *VerificationError. got: %T", i, err)
+				continue
+			}
+			if verr.Index != c.index {
+				t.Errorf("case %d index mismatch. expected: %d, got: %d", i, c.index, verr.Index)
+			}
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package dsfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/multiformats/go-multihash"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+)
+
+// TransformRunner executes transforms of a syntax. t has its Resources
+// loaded, prev is the version of the dataset the transform produced a new
+// version of, nil for the first version
+type TransformRunner interface {
+	RunTransform(store cafs.Filestore, t *dataset.Transform, prev *dataset.Dataset) (dsio.EntryReader, *dataset.Structure, error)
+}
+
+// TransformRunnerFunc adapts a function to the TransformRunner interface
+type TransformRunnerFunc func(store cafs.Filestore, t *dataset.Transform, prev *dataset.Dataset) (dsio.EntryReader, *dataset.Structure, error)
+
+// RunTransform calls f
+func (f TransformRunnerFunc) RunTransform(store cafs.Filestore, t *dataset.Transform, prev *dataset.Dataset) (dsio.EntryReader, *dataset.Structure, error) {
+	return f(store, t, prev)
+}
+
+// SQLRunner runs "sql" transforms with ExecTransform
+var SQLRunner = TransformRunnerFunc(func(store cafs.Filestore, t *dataset.Transform, prev *dataset.Dataset) (dsio.EntryReader, *dataset.Structure, error) {
+	return ExecTransform(store, t)
+})
+
+// VerifyTransformOpts configures VerifyTransform
+type VerifyTransformOpts struct {
+	// Runners execute transforms, keyed by Transform.Syntax. Defaults to
+	// SQLRunner for "sql"
+	Runners map[string]TransformRunner
+}
+
+// TransformReport describes the result of re-running the transform of a
+// dataset. Expected values are those of the committed body, actual values
+// those of the re-run
+type TransformReport struct {
+	// Path of the dataset verified
+	Path string
+	// Syntax of the transform
+	Syntax string
+	// Resources lists the path each resource was loaded from, by name
+	Resources map[string]string
+	// Match is true when the checksums of the committed & re-run bodies are
+	// the same
+	Match bool
+
+	ExpectedChecksum string
+	ActualChecksum   string
+	ExpectedEntries  int
+	ActualEntries    int
+	ExpectedLength   int
+	ActualLength     int
+
+	// Mismatch is the first entry that differs, nil if every entry matches
+	Mismatch *EntryMismatch
+	// Diffs describes each difference, empty when Match is true
+	Diffs []string
+}
+
+// EntryMismatch is an entry that differs between committed & re-run bodies.
+// Expected or Actual is nil if the body has no entry at Index
+type EntryMismatch struct {
+	Index    int
+	Expected *dsio.Entry
+	Actual   *dsio.Entry
+}
+
+// VerifyTransform checks a dataset can be reproduced by its transform. The
+// transform & its Resources are reloaded at their recorded paths and the
+// transform is re-run. Output is encoded with the committed structure, and
+// its checksum compared to the committed Structure.Checksum. Compressed
+// bodies only match if compressed by the same compressor & settings.
+// VerifyTransform errors if a dataset can't be verified at all, mismatches
+// are described by the returned report
+func VerifyTransform(store cafs.Filestore, path datastore.Key, opts ...func(*VerifyTransformOpts)) (*TransformReport, error) {
+	opt := &VerifyTransformOpts{
+		Runners: map[string]TransformRunner{"sql": SQLRunner},
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	ds, err := LoadDataset(store, path)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	if ds.Transform == nil || ds.Transform.IsEmpty() {
+		return nil, fmt.Errorf("dataset has no transform to verify")
+	}
+	if ds.Structure == nil || ds.Structure.Checksum == "" {
+		return nil, fmt.Errorf("dataset has no checksum to verify against")
+	}
+	runner, ok := opt.Runners[ds.Transform.Syntax]
+	if !ok {
+		return nil, fmt.Errorf("no runner for transform syntax '%s'", ds.Transform.Syntax)
+	}
+
+	report := &TransformReport{
+		Path:             path.String(),
+		Syntax:           ds.Transform.Syntax,
+		Resources:        map[string]string{},
+		ExpectedChecksum: ds.Structure.Checksum,
+		ExpectedEntries:  ds.Structure.Entries,
+		ExpectedLength:   ds.Structure.Length,
+	}
+
+	t := &dataset.Transform{}
+	t.Assign(ds.Transform)
+	t.Resources = map[string]*dataset.Dataset{}
+	for name, ref := range ds.Transform.Resources {
+		if ref == nil || ref.Path().String() == "" {
+			return nil, fmt.Errorf("resource '%s' has no recorded path", name)
+		}
+		res, err := LoadDataset(store, ref.Path())
+		if err != nil {
+			log.Debug(err.Error())
+			return nil, fmt.Errorf("error loading resource '%s': %s", name, err.Error())
+		}
+		t.Resources[name] = res
+		report.Resources[name] = ref.Path().String()
+	}
+
+	var prev *dataset.Dataset
+	if ds.PreviousPath != "" {
+		if prev, err = LoadDataset(store, datastore.NewKey(ds.PreviousPath)); err != nil {
+			log.Debug(err.Error())
+			return nil, fmt.Errorf("error loading previous version: %s", err.Error())
+		}
+	}
+
+	r, _, err := runner.RunTransform(store, t, prev)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error running transform: %s", err.Error())
+	}
+	data, err := encodeBody(ds.Structure, r)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error encoding transform output: %s", err.Error())
+	}
+	if report.ActualChecksum, err = checksum(data); err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	report.ActualLength = len(data)
+	report.Match = report.ActualChecksum == report.ExpectedChecksum
+
+	if err := compareBodies(store, ds, data, report); err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	return report, nil
+}
+
+// encodeBody writes entries as a data file with a structure, including any
+// compression the structure specifies
+func encodeBody(st *dataset.Structure, r dsio.EntryReader) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := dsio.NewEntryWriter(st, buf)
+	if err != nil {
+		return nil, err
+	}
+	err = dsio.EachEntry(r, func(_ int, ent dsio.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		return w.WriteEntry(ent)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checksum calculates the checksum of a data file the way CreateDataset does
+func checksum(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	mh, err := multihash.Encode(sum[:], multihash.SHA2_256)
+	if err != nil {
+		return "", fmt.Errorf("error calculating hash: %s", err.Error())
+	}
+	return multihash.Multihash(mh).B58String(), nil
+}
+
+// compareBodies reads committed & re-run bodies entry-by-entry, filling in
+// entry counts & differences of a report
+func compareBodies(store cafs.Filestore, ds *dataset.Dataset, data []byte, report *TransformReport) error {
+	f, err := LoadData(store, ds)
+	if err != nil {
+		return fmt.Errorf("error loading dataset data: %s", err.Error())
+	}
+	defer f.Close()
+
+	expected, err := dsio.NewEntryReader(ds.Structure, f)
+	if err != nil {
+		return fmt.Errorf("error reading dataset data: %s", err.Error())
+	}
+	actual, err := dsio.NewEntryReader(ds.Structure, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error reading transform output: %s", err.Error())
+	}
+
+	for i := 0; ; i++ {
+		exp, err := nextEntry(expected)
+		if err != nil {
+			return fmt.Errorf("error reading dataset data: %s", err.Error())
+		}
+		act, err := nextEntry(actual)
+		if err != nil {
+			return fmt.Errorf("error reading transform output: %s", err.Error())
+		}
+		if exp == nil && act == nil {
+			break
+		}
+		if act != nil {
+			report.ActualEntries++
+		}
+		if report.Mismatch == nil && (exp == nil || act == nil || exp.Key != act.Key || !reflect.DeepEqual(exp.Value, act.Value)) {
+			report.Mismatch = &EntryMismatch{Index: i, Expected: exp, Actual: act}
+		}
+	}
+
+	if report.ExpectedChecksum != report.ActualChecksum {
+		report.Diffs = append(report.Diffs, fmt.Sprintf("checksum: %s != %s", report.ExpectedChecksum, report.ActualChecksum))
+	}
+	if report.ExpectedEntries != report.ActualEntries {
+		report.Diffs = append(report.Diffs, fmt.Sprintf("entries: %d != %d", report.ExpectedEntries, report.ActualEntries))
+	}
+	if report.ExpectedLength != report.ActualLength {
+		report.Diffs = append(report.Diffs, fmt.Sprintf("length: %d != %d", report.ExpectedLength, report.ActualLength))
+	}
+	if m := report.Mismatch; m != nil {
+		report.Diffs = append(report.Diffs, fmt.Sprintf("entry %d: %s != %s", m.Index, entryString(m.Expected), entryString(m.Actual)))
+	}
+	return nil
+}
+
+// nextEntry reads an entry, returning nil at the end of the body
+func nextEntry(r dsio.EntryReader) (*dsio.Entry, error) {
+	ent, err := r.ReadEntry()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &ent, nil
+}
+
+func entryString(ent *dsio.Entry) string {
+	if ent == nil {
+		return "missing"
+	}
+	data, err := json.Marshal(ent.Value)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%v", ent.Value))
+	}
+	if ent.Key != "" {
+		return fmt.Sprintf("%q: %s", ent.Key, data)
+	}
+	return string(data)
+}
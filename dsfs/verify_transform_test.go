@@ -0,0 +1,136 @@
+package dsfs
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+)
+
+func TestVerifyTransform(t *testing.T) {
+	datasets, store, err := makeFilestore()
+	if err != nil {
+		t.Fatalf("error creating test filestore: %s", err.Error())
+	}
+	privKey, err := crypto.UnmarshalPrivateKey(testPk)
+	if err != nil {
+		t.Fatalf("error unmarshaling private key: %s", err.Error())
+	}
+
+	// commit runs a transform, replacing the output with body if it isn't empty
+	commit := func(tf *dataset.Transform, body string) datastore.Key {
+		r, st, err := ExecTransform(store, tf)
+		if err != nil {
+			t.Fatalf("error executing transform: %s", err.Error())
+		}
+		buf, err := dsio.NewEntryBuffer(st)
+		if err != nil {
+			t.Fatalf("error creating buffer: %s", err.Error())
+		}
+		err = dsio.EachEntry(r, func(_ int, ent dsio.Entry, err error) error {
+			return buf.WriteEntry(ent)
+		})
+		if err != nil {
+			t.Fatalf("error writing results: %s", err.Error())
+		}
+		if err := buf.Close(); err != nil {
+			t.Fatalf("error closing buffer: %s", err.Error())
+		}
+		data := buf.Bytes()
+		if body != "" {
+			data = []byte(body)
+		}
+		ds := &dataset.Dataset{
+			Commit:    &dataset.Commit{Title: "run transform"},
+			Structure: st,
+			Transform: tf,
+		}
+		key, err := CreateDataset(store, ds, cafs.NewMemfileBytes("data."+st.Format.String(), data), privKey, false)
+		if err != nil {
+			t.Fatalf("error committing results: %s", err.Error())
+		}
+		return key
+	}
+
+	newTransform := func() *dataset.Transform {
+		return &dataset.Transform{
+			Syntax:    "sql",
+			Data:      putQuery(t, store, "select city, pop from a where in_usa order by pop desc limit 2"),
+			Config:    map[string]interface{}{"limit": 2},
+			Structure: &dataset.Structure{Format: dataset.CSVDataFormat},
+			Resources: map[string]*dataset.Dataset{"a": dataset.NewDatasetRef(datasets["cities"])},
+		}
+	}
+
+	path := commit(newTransform(), "")
+	report, err := VerifyTransform(store, path)
+	if err != nil {
+		t.Fatalf("error verifying transform: %s", err.Error())
+	}
+	if !report.Match {
+		t.Errorf("expected reproducible transform to match. diffs: %v", report.Diffs)
+	}
+	if len(report.Diffs) != 0 || report.Mismatch != nil {
+		t.Errorf("expected no differences, got: %v", report.Diffs)
+	}
+	if report.Resources["a"] != datasets["cities"].String() {
+		t.Errorf("resource path mismatch. expected: %s, got: %s", datasets["cities"].String(), report.Resources["a"])
+	}
+	if report.ActualEntries != 2 || report.ExpectedEntries != 2 {
+		t.Errorf("entries mismatch. expected: 2, got: %d & %d", report.ExpectedEntries, report.ActualEntries)
+	}
+
+	path = commit(newTransform(), "city,pop\nnew york,8500000\nchicago,300001\nboston,600000\n")
+	report, err = VerifyTransform(store, path)
+	if err != nil {
+		t.Fatalf("error verifying transform: %s", err.Error())
+	}
+	if report.Match {
+		t.Errorf("expected altered body not to match")
+	}
+	if report.Mismatch == nil || report.Mismatch.Index != 1 {
+		t.Fatalf("expected mismatch at entry 1, got: %#v", report.Mismatch)
+	}
+	expect := []string{
+		"checksum: " + report.ExpectedChecksum + " != " + report.ActualChecksum,
+		"entries: 3 != 2",
+		"length: 55 != 41",
+		`entry 1: ["chicago",300001] != ["chicago",300000]`,
+	}
+	if len(report.Diffs) != len(expect) {
+		t.Fatalf("diffs length mismatch. expected: %d, got: %d: %v", len(expect), len(report.Diffs), report.Diffs)
+	}
+	for i, diff := range expect {
+		if report.Diffs[i] != diff {
+			t.Errorf("diff %d mismatch. expected: %s, got: %s", i, diff, report.Diffs[i])
+		}
+	}
+
+	// runners can be swapped out
+	_, err = VerifyTransform(store, path, func(o *VerifyTransformOpts) {
+		o.Runners = map[string]TransformRunner{}
+	})
+	if err == nil || err.Error() != "no runner for transform syntax 'sql'" {
+		t.Errorf("expected missing runner error, got: %v", err)
+	}
+	var ran *dataset.Transform
+	_, err = VerifyTransform(store, path, func(o *VerifyTransformOpts) {
+		o.Runners["sql"] = TransformRunnerFunc(func(store cafs.Filestore, t *dataset.Transform, prev *dataset.Dataset) (dsio.EntryReader, *dataset.Structure, error) {
+			ran = t
+			return ExecTransform(store, t)
+		})
+	})
+	if err != nil {
+		t.Fatalf("error verifying with custom runner: %s", err.Error())
+	}
+	if ran == nil || ran.Resources["a"].Structure == nil {
+		t.Errorf("expected runner to be called with loaded resources")
+	}
+
+	if _, err := VerifyTransform(store, datasets["cities"]); err == nil || err.Error() != "dataset has no transform to verify" {
+		t.Errorf("expected no transform error, got: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package dsgraph
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset/dsfs"
+)
+
+// FromDataset builds the provenance graph of a dataset, returning the node
+// of the dataset. The graph links datasets to their components, their
+// previous version & the datasets their transform reads
+func FromDataset(store cafs.Filestore, path datastore.Key) (*Node, error) {
+	b := newBuilder(store)
+	node := b.dataset(path.String())
+	if err := b.wait(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// FromStore builds a single provenance graph for a number of datasets in a
+// store, returning a namespace node that links to each dataset. Datasets
+// & components shared by more than one dataset are represented by the
+// same node
+func FromStore(store cafs.Filestore, paths ...datastore.Key) (*Node, error) {
+	b := newBuilder(store)
+	ns := &Node{Type: NtNamespace, Path: "/" + store.PathPrefix()}
+	roots := make([]*Node, len(paths))
+	for i, path := range paths {
+		roots[i] = b.dataset(path.String())
+	}
+	if err := b.wait(); err != nil {
+		return nil, err
+	}
+	for _, n := range roots {
+		ns.AddLinks(Link{From: ns, To: n})
+	}
+	return ns, nil
+}
+
+// builder constructs a graph, loading up to walkParallelism datasets at once
+type builder struct {
+	store cafs.Filestore
+	sem   chan struct{}
+	wg    sync.WaitGroup
+
+	// lock protects nodes, err & the links of every node
+	lock  sync.Mutex
+	nodes map[string]*Node
+	err   error
+}
+
+func newBuilder(store cafs.Filestore) *builder {
+	return &builder{
+		store: store,
+		sem:   make(chan struct{}, walkParallelism),
+		nodes: map[string]*Node{},
+	}
+}
+
+// wait blocks until every dataset is loaded, returning the first error
+// encountered
+func (b *builder) wait() error {
+	b.wg.Wait()
+	return b.err
+}
+
+// node gets the node of a path, creating one if it doesn't exist. callers
+// must hold the lock
+func (b *builder) node(t NodeType, path string) (n *Node, created bool) {
+	id := string(t) + ":" + path
+	if n, ok := b.nodes[id]; ok {
+		return n, false
+	}
+	n = &Node{Type: t, Path: path}
+	b.nodes[id] = n
+	return n, true
+}
+
+// link connects from to a node, creating it if required. links to empty
+// paths are ignored. callers must hold the lock
+func (b *builder) link(from *Node, t NodeType, path string) *Node {
+	if path == "" {
+		return nil
+	}
+	to, _ := b.node(t, path)
+	from.AddLinks(Link{From: from, To: to})
+	return to
+}
+
+// dataset gets the node of a dataset, loading the dataset in the background
+// the first time it's seen
+func (b *builder) dataset(path string) *Node {
+	path = dsfs.PackageFilepath(b.store, path, dsfs.PackageFileDataset)
+
+	b.lock.Lock()
+	n, created := b.node(NtDataset, path)
+	b.lock.Unlock()
+	if created {
+		b.wg.Add(1)
+		go b.load(n)
+	}
+	return n
+}
+
+// load reads a dataset & links its node to the rest of the graph. Only the
+// transform is dereferenced to find the datasets it reads, other components
+// are linked by path without being loaded
+func (b *builder) load(n *Node) {
+	defer b.wg.Done()
+
+	b.sem <- struct{}{}
+	ds, err := dsfs.LoadDatasetRefs(b.store, datastore.NewKey(n.Path))
+	if err == nil {
+		err = dsfs.DerefDatasetTransform(b.store, ds)
+	}
+	<-b.sem
+	if err != nil {
+		log.Debug(err.Error())
+		b.fail(fmt.Errorf("error loading dataset '%s': %s", n.Path, err.Error()))
+		return
+	}
+
+	var prev *Node
+	if ds.PreviousPath != "" {
+		prev = b.dataset(ds.PreviousPath)
+	}
+	resources := map[string]*Node{}
+	if ds.Transform != nil {
+		for name, ref := range ds.Transform.Resources {
+			if ref != nil && ref.Path().String() != "" {
+				resources[name] = b.dataset(ref.Path().String())
+			}
+		}
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if ds.Commit != nil {
+		b.link(n, NtCommit, ds.Commit.Path().String())
+	}
+	if ds.Meta != nil {
+		b.link(n, NtMetadata, ds.Meta.Path().String())
+	}
+	if ds.Structure != nil {
+		b.link(n, NtStructure, ds.Structure.Path().String())
+	}
+	if ds.Abstract != nil {
+		b.link(n, NtAbstDataset, ds.Abstract.Path().String())
+	}
+	b.link(n, NtData, ds.DataPath)
+	b.link(n, NtOffsetIndex, ds.OffsetIndexPath)
+	if ds.Stats != nil {
+		b.link(n, NtStats, ds.Stats.Path().String())
+	}
+	if ds.VisConfig != nil {
+		b.link(n, NtVisConfig, ds.VisConfig.Path().String())
+	}
+	if ds.Transform != nil {
+		if tf := b.link(n, NtTransform, ds.Transform.Path().String()); tf != nil {
+			if ds.Transform.Structure != nil {
+				b.link(tf, NtStructure, ds.Transform.Structure.Path().String())
+			}
+			names := make([]string, 0, len(resources))
+			for name := range resources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				tf.AddLinks(Link{From: tf, To: resources[name]})
+			}
+		}
+	}
+	if ds.AbstractTransform != nil {
+		if atf := b.link(n, NtAbstTransform, ds.AbstractTransform.Path().String()); atf != nil {
+			for _, ref := range ds.AbstractTransform.Resources {
+				if ref != nil {
+					b.link(atf, NtAbstDataset, ref.Path().String())
+				}
+			}
+		}
+	}
+	if prev != nil {
+		n.AddLinks(Link{From: n, To: prev})
+	}
+}
+
+// fail records the first error the builder encounters
+func (b *builder) fail(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.err == nil {
+		b.err = err
+	}
+}
@@ -0,0 +1,141 @@
+package dsgraph
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsfs"
+	"github.com/qri-io/jsonschema"
+)
+
+// makeLineage creates a cities dataset, a dataset transformed from cities &
+// a second version of the transformed dataset
+func makeLineage(t *testing.T) (store cafs.Filestore, cities, big, bigV2 datastore.Key) {
+	store = cafs.NewMapstore()
+	pk, _, err := crypto.GenerateKeyPair(crypto.RSA, 1024)
+	if err != nil {
+		t.Fatalf("error generating private key: %s", err.Error())
+	}
+
+	create := func(ds *dataset.Dataset, data string, opts ...func(*dsfs.CreateDatasetOpts)) datastore.Key {
+		key, err := dsfs.CreateDataset(store, ds, cafs.NewMemfileBytes("data.json", []byte(data)), pk, false, opts...)
+		if err != nil {
+			t.Fatalf("error creating dataset: %s", err.Error())
+		}
+		return key
+	}
+	structure := func() *dataset.Structure {
+		return &dataset.Structure{Format: dataset.JSONDataFormat, Schema: jsonschema.Must(`{"type":"array"}`)}
+	}
+
+	cities = create(&dataset.Dataset{
+		Meta:      &dataset.Meta{Title: "cities"},
+		Commit:    &dataset.Commit{Title: "initial commit"},
+		Structure: structure(),
+		VisConfig: &dataset.VisConfig{Format: "template"},
+	}, `[["toronto",40000000],["new york",8500000],["chicago",300000]]`,
+		func(o *dsfs.CreateDatasetOpts) { o.OffsetIndexInterval = 1 })
+
+	query, err := store.Put(cafs.NewMemfileBytes("query.sql", []byte("select * from a where a.pop > 1000000")), false)
+	if err != nil {
+		t.Fatalf("error putting query: %s", err.Error())
+	}
+	tf := &dataset.Transform{
+		Syntax:    "sql",
+		Data:      query.String(),
+		Resources: map[string]*dataset.Dataset{"a": dataset.NewDatasetRef(cities)},
+	}
+	big = create(&dataset.Dataset{
+		Commit:    &dataset.Commit{Title: "big cities"},
+		Structure: structure(),
+		Transform: tf,
+	}, `[["toronto",40000000],["new york",8500000]]`)
+
+	bigV2 = create(&dataset.Dataset{
+		Commit:       &dataset.Commit{Title: "just toronto"},
+		Structure:    structure(),
+		PreviousPath: big.String(),
+	}, `[["toronto",40000000]]`)
+
+	return
+}
+
+// linked gives the nodes a node links to with a type
+func linked(n *Node, t NodeType) (nodes []*Node) {
+	for _, l := range n.Links {
+		if l.To.Type == t {
+			nodes = append(nodes, l.To)
+		}
+	}
+	return
+}
+
+func TestFromDataset(t *testing.T) {
+	store, cities, big, bigV2 := makeLineage(t)
+
+	node, err := FromDataset(store, bigV2)
+	if err != nil {
+		t.Fatalf("error building graph: %s", err.Error())
+	}
+	if node.Type != NtDataset || node.Path != bigV2.String() {
+		t.Errorf("root node mismatch. expected: %s %s, got: %s %s", NtDataset, bigV2.String(), node.Type, node.Path)
+	}
+	for _, nt := range []NodeType{NtCommit, NtStructure, NtData} {
+		if len(linked(node, nt)) != 1 {
+			t.Errorf("expected dataset to link to one %s node, got: %d", nt, len(linked(node, nt)))
+		}
+	}
+
+	prev := linked(node, NtDataset)
+	if len(prev) != 1 || prev[0].Path != big.String() {
+		t.Fatalf("expected dataset to link to previous version %s, got: %v", big.String(), prev)
+	}
+	tfs := linked(prev[0], NtTransform)
+	if len(tfs) != 1 {
+		t.Fatalf("expected previous version to link to a transform, got: %d", len(tfs))
+	}
+	res := linked(tfs[0], NtDataset)
+	if len(res) != 1 || res[0].Path != cities.String() {
+		t.Fatalf("expected transform to link to resource %s, got: %v", cities.String(), res)
+	}
+	for _, nt := range []NodeType{NtMetadata, NtStats, NtVisConfig, NtOffsetIndex} {
+		if len(linked(res[0], nt)) != 1 {
+			t.Errorf("expected resource to link to one %s node, got: %d", nt, len(linked(res[0], nt)))
+		}
+	}
+
+	datasets := FilterNodeTypes(node, NtDataset)
+	if len(datasets) != 3 {
+		t.Errorf("expected 3 dataset nodes, got: %d", len(datasets))
+	}
+
+	if _, err := FromDataset(store, datastore.NewKey("/map/missing")); err == nil {
+		t.Errorf("expected missing dataset to error")
+	}
+}
+
+func TestFromStore(t *testing.T) {
+	store, cities, _, bigV2 := makeLineage(t)
+
+	ns, err := FromStore(store, cities, bigV2)
+	if err != nil {
+		t.Fatalf("error building graph: %s", err.Error())
+	}
+	if ns.Type != NtNamespace || len(ns.Links) != 2 {
+		t.Fatalf("expected namespace node linking to 2 datasets, got: %s with %d links", ns.Type, len(ns.Links))
+	}
+
+	// cities is both a root & a transform resource, & should be one node
+	seen := map[*Node]bool{}
+	for _, n := range FilterNodeTypes(ns, NtDataset) {
+		if n.Path == cities.String() {
+			seen[n] = true
+		}
+	}
+	if len(seen) != 1 {
+		t.Errorf("expected a single node for a shared dataset, got: %d", len(seen))
+	}
+}
@@ -1,6 +1,8 @@
 // Package dsgraph is a placeholder package for linking
 // queries, resources, and metadata until proper
-// packaging & architectural decisions can be made
+// packaging & architectural decisions can be made.
+// FromDataset & FromStore build provenance graphs from
+// datasets in a store
 package dsgraph
 
 import (
@@ -1,5 +1,6 @@
 package dsgraph
 
+// walkParallelism is the number of datasets graph builders load at once
 var walkParallelism = 4
 
 // NodeType specifies different types of qri nodes
@@ -25,6 +26,12 @@ var (
 	NtStructure = NodeType("structure")
 	// NtAbstStructure is the abstract_structure.json in a dataset
 	NtAbstStructure = NodeType("abst_structure")
+	// NtVisConfig is the visconfig.json in a dataset
+	NtVisConfig = NodeType("visconfig")
+	// NtStats is the stats.json in a dataset
+	NtStats = NodeType("stats")
+	// NtOffsetIndex is the offset index of a dataset's raw data
+	NtOffsetIndex = NodeType("offset_index")
 	// NtNamespace is the namespace of a single qri repository
 	NtNamespace = NodeType("namespace")
 )
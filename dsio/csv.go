@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/dataset/vals"
@@ -16,8 +17,9 @@ import (
 type CSVReader struct {
 	st         *dataset.Structure
 	readHeader bool
-	r          *csv.Reader
+	r          *CSVRecordReader
 	types      []string
+	nulls      map[string]bool
 }
 
 // NewCSVReader creates a reader from a structure and read source
@@ -25,11 +27,27 @@ func NewCSVReader(st *dataset.Structure, r io.Reader) *CSVReader {
 	// TODO - handle error
 	_, types, _ := terribleHackToGetHeaderRowAndTypes(st)
 
+	opts := csvOptions(st)
+	nulls := map[string]bool{}
+	for _, n := range opts.NullStrings {
+		nulls[n] = true
+	}
+
 	return &CSVReader{
 		st:    st,
-		r:     csv.NewReader(ReplaceSoloCarriageReturns(r)),
+		r:     NewCSVRecordReader(r, opts),
 		types: types,
+		nulls: nulls,
+	}
+}
+
+// csvOptions gives the CSVOptions for a structure, defaulting
+// to an empty set of options
+func csvOptions(st *dataset.Structure) *dataset.CSVOptions {
+	if opts, ok := st.FormatConfig.(*dataset.CSVOptions); ok && opts != nil {
+		return opts
 	}
+	return &dataset.CSVOptions{}
 }
 
 // Structure gives this reader's structure
@@ -82,39 +100,48 @@ func (r *CSVReader) decode(strings []string) ([]interface{}, error) {
 		}
 	}
 	for i, str := range strings {
-		vs[i] = str
-
-		switch types[i] {
-		case "number":
-			if num, err := vals.ParseNumber([]byte(str)); err == nil {
-				vs[i] = num
-			}
-		case "integer":
-			if num, err := vals.ParseInteger([]byte(str)); err == nil {
-				vs[i] = num
-			}
-		case "boolean":
-			if b, err := vals.ParseBoolean([]byte(str)); err == nil {
-				vs[i] = b
-			}
-		case "object":
-			v := map[string]interface{}{}
-			if err := json.Unmarshal([]byte(str), &v); err == nil {
-				vs[i] = v
-			}
-		case "array":
-			v := []interface{}{}
-			if err := json.Unmarshal([]byte(str), &v); err == nil {
-				vs[i] = v
-			}
-		case "null":
+		if r.nulls[str] {
 			vs[i] = nil
+			continue
 		}
+		vs[i] = castString(types[i], str)
 	}
 
 	return vs, nil
 }
 
+// castString converts a string to a value of the given json schema type.
+// If casting fails because the string is invalid, it's returned as a string
+func castString(typ, str string) interface{} {
+	switch typ {
+	case "number":
+		if num, err := vals.ParseNumber([]byte(str)); err == nil {
+			return num
+		}
+	case "integer":
+		if num, err := vals.ParseInteger([]byte(str)); err == nil {
+			return num
+		}
+	case "boolean":
+		if b, err := vals.ParseBoolean([]byte(str)); err == nil {
+			return b
+		}
+	case "object":
+		v := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(str), &v); err == nil {
+			return v
+		}
+	case "array":
+		v := []interface{}{}
+		if err := json.Unmarshal([]byte(str), &v); err == nil {
+			return v
+		}
+	case "null":
+		return nil
+	}
+	return str
+}
+
 // HasHeaderRow checks Structure for the presence of the HeaderRow flag
 func HasHeaderRow(st *dataset.Structure) bool {
 	if st.Format == dataset.CSVDataFormat && st.FormatConfig != nil {
@@ -132,6 +159,8 @@ type CSVWriter struct {
 	w           *csv.Writer
 	st          *dataset.Structure
 	types       []string
+	quote       byte
+	null        string
 }
 
 // NewCSVWriter creates a Writer from a structure and write destination
@@ -139,17 +168,28 @@ func NewCSVWriter(st *dataset.Structure, w io.Writer) *CSVWriter {
 	// TODO - capture error
 	titles, types, _ := terribleHackToGetHeaderRowAndTypes(st)
 
+	opts := csvOptions(st)
+	quote := csvQuote(opts)
+	if quote != '"' {
+		w = quoteSwapWriter{w: w, quote: quote}
+	}
+
 	writer := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		writer.Comma = opts.Delimiter
+	}
 	wr := &CSVWriter{
 		st:    st,
 		w:     writer,
 		types: types,
+		quote: quote,
+	}
+	if len(opts.NullStrings) > 0 {
+		wr.null = opts.NullStrings[0]
 	}
 
-	if CSVOpts, ok := st.FormatConfig.(*dataset.CSVOptions); ok {
-		if CSVOpts.HeaderRow {
-			writer.Write(titles)
-		}
+	if opts.HeaderRow {
+		wr.write(titles)
 	}
 
 	return wr
@@ -209,11 +249,27 @@ func (w *CSVWriter) WriteEntry(ent Entry) error {
 			log.Debug(err.Error())
 			return fmt.Errorf("error encoding entry: %s", err.Error())
 		}
-		return w.w.Write(strs)
+		for i, v := range arr {
+			if v == nil {
+				strs[i] = w.null
+			}
+		}
+		return w.write(strs)
 	}
 	return fmt.Errorf("expected array value to write csv row. got: %v", ent)
 }
 
+// write writes a record to the underlying csv writer, accounting for
+// non-standard quote characters
+func (w *CSVWriter) write(record []string) error {
+	if w.quote != '"' {
+		for i, str := range record {
+			record[i] = swapQuotes(str, w.quote)
+		}
+	}
+	return w.w.Write(record)
+}
+
 // encode uses specified types from structure's schema to go values to strings
 func encode(vs []interface{}) ([]string, error) {
 	strings := make([]string, len(vs))
@@ -255,7 +311,117 @@ func encode(vs []interface{}) ([]string, error) {
 // will be written
 func (w *CSVWriter) Close() error {
 	w.w.Flush()
-	return nil
+	return w.w.Error()
+}
+
+// CSVRecordReader reads raw string records from CSV data according to a set
+// of CSVOptions. The embedded csv.Reader can be further configured before
+// reading begins
+type CSVRecordReader struct {
+	*csv.Reader
+	quote byte
+}
+
+// NewCSVRecordReader creates a CSVRecordReader from a read source and
+// options. opts may be nil
+func NewCSVRecordReader(r io.Reader, opts *dataset.CSVOptions) *CSVRecordReader {
+	if opts == nil {
+		opts = &dataset.CSVOptions{}
+	}
+
+	quote := csvQuote(opts)
+	r = ReplaceSoloCarriageReturns(r)
+	if quote != '"' {
+		r = quoteSwapReader{r: r, quote: quote}
+	}
+
+	cr := csv.NewReader(r)
+	if opts.Delimiter != 0 {
+		cr.Comma = opts.Delimiter
+	}
+	cr.Comment = opts.Comment
+	cr.LazyQuotes = opts.LazyQuotes
+	if opts.VariadicFields {
+		cr.FieldsPerRecord = -1
+	}
+
+	return &CSVRecordReader{Reader: cr, quote: quote}
+}
+
+// Read reads one record from r
+func (r *CSVRecordReader) Read() ([]string, error) {
+	record, err := r.Reader.Read()
+	if err != nil {
+		return record, err
+	}
+	if r.quote != '"' {
+		for i, str := range record {
+			record[i] = swapQuotes(str, r.quote)
+		}
+	}
+	return record, nil
+}
+
+// csvQuote gives the quote character specified by a set of options
+func csvQuote(opts *dataset.CSVOptions) byte {
+	if opts.Quote == 0 {
+		return '"'
+	}
+	return byte(opts.Quote)
+}
+
+// the go csv package only supports double quotes as a quote character.
+// Other quote characters are supported by exchanging all occurrences of the
+// quote character with double quotes & vice-versa in raw csv data, and then
+// exchanging them back again in field values. Because the exchange is
+// symmetric, the parser sees standard csv, and field values are unchanged
+
+// swapQuotes exchanges double quotes and a given quote character in a string
+func swapQuotes(str string, quote byte) string {
+	if strings.IndexByte(str, quote) == -1 && strings.IndexByte(str, '"') == -1 {
+		return str
+	}
+	b := []byte(str)
+	swapQuoteBytes(b, quote)
+	return string(b)
+}
+
+// swapQuoteBytes exchanges double quotes and a given quote character in place
+func swapQuoteBytes(b []byte, quote byte) {
+	for i, c := range b {
+		if c == quote {
+			b[i] = '"'
+		} else if c == '"' {
+			b[i] = quote
+		}
+	}
+}
+
+// quoteSwapReader swaps quote characters in data read from a reader
+type quoteSwapReader struct {
+	r     io.Reader
+	quote byte
+}
+
+// Read implements the io.Reader interface
+func (q quoteSwapReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	swapQuoteBytes(p[:n], q.quote)
+	return n, err
+}
+
+// quoteSwapWriter swaps quote characters in data before writing
+type quoteSwapWriter struct {
+	w     io.Writer
+	quote byte
+}
+
+// Write implements the io.Writer interface
+func (q quoteSwapWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	swapQuoteBytes(b, q.quote)
+	return q.w.Write(b)
 }
 
 // ReplaceSoloCarriageReturns wraps an io.Reader, on every call of Read. it looks for
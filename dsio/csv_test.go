@@ -2,6 +2,7 @@ package dsio
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 
 	"github.com/qri-io/dataset"
@@ -125,3 +126,142 @@ func TestReplaceSoloCarriageReturns(t *testing.T) {
 		t.Errorf("byte mismatch. expected:\n%v\ngot:\n%v", expect, got)
 	}
 }
+
+func TestCSVReaderOptions(t *testing.T) {
+	schema := jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type": "array",
+			"items": [
+				{"title": "name", "type": "string"},
+				{"title": "count", "type": "integer"}
+			]
+		}
+	}`)
+
+	cases := []struct {
+		opts   *dataset.CSVOptions
+		data   string
+		expect []interface{}
+		err    string
+	}{
+		{&dataset.CSVOptions{Delimiter: '\t'}, "a,b\t1\nc\t2\n", []interface{}{
+			[]interface{}{"a,b", int64(1)},
+			[]interface{}{"c", int64(2)},
+		}, ""},
+		{&dataset.CSVOptions{Delimiter: ';', HeaderRow: true}, "name;count\na;1\n", []interface{}{
+			[]interface{}{"a", int64(1)},
+		}, ""},
+		{&dataset.CSVOptions{Quote: '\''}, "'a,''b''',1\n\"c\",2\n", []interface{}{
+			[]interface{}{"a,'b'", int64(1)},
+			[]interface{}{`"c"`, int64(2)},
+		}, ""},
+		{&dataset.CSVOptions{Comment: '#'}, "# header comment\na,1\n#b,2\n", []interface{}{
+			[]interface{}{"a", int64(1)},
+		}, ""},
+		{&dataset.CSVOptions{NullStrings: []string{"NA", ""}}, "NA,1\na,\n", []interface{}{
+			[]interface{}{nil, int64(1)},
+			[]interface{}{"a", nil},
+		}, ""},
+		{&dataset.CSVOptions{}, "a,1\nb,2,3\n", nil, "record on line 2: wrong number of fields"},
+		// rows with more fields than the schema are read as strings
+		{&dataset.CSVOptions{VariadicFields: true}, "a,1\nb,2,3\nc\n", []interface{}{
+			[]interface{}{"a", int64(1)},
+			[]interface{}{"b", "2", "3"},
+			[]interface{}{"c"},
+		}, ""},
+		{&dataset.CSVOptions{}, "a \"b\",1\n", nil, "parse error on line 1, column 3: bare \" in non-quoted-field"},
+		{&dataset.CSVOptions{LazyQuotes: true}, "a \"b\",1\n", []interface{}{
+			[]interface{}{`a "b"`, int64(1)},
+		}, ""},
+	}
+
+	for i, c := range cases {
+		st := &dataset.Structure{Format: dataset.CSVDataFormat, FormatConfig: c.opts, Schema: schema}
+		r := NewCSVReader(st, bytes.NewBufferString(c.data))
+
+		got := []interface{}{}
+		err := EachEntry(r, func(j int, ent Entry, err error) error {
+			got = append(got, ent.Value)
+			return err
+		})
+		if !(err == nil && c.err == "" || err != nil && err.Error() == "error reading row: "+c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		if !reflect.DeepEqual(c.expect, got) {
+			t.Errorf("case %d result mismatch. expected:\n%v\ngot:\n%v", i, c.expect, got)
+		}
+	}
+}
+
+func TestCSVWriterOptions(t *testing.T) {
+	schema := jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type": "array",
+			"items": [
+				{"title": "name", "type": "string"},
+				{"title": "count", "type": "integer"}
+			]
+		}
+	}`)
+	rows := []Entry{
+		{Value: []interface{}{"a,b", 1}},
+		{Value: []interface{}{"it's \"c\"", nil}},
+	}
+
+	cases := []struct {
+		opts   *dataset.CSVOptions
+		expect string
+	}{
+		{&dataset.CSVOptions{}, "\"a,b\",1\n\"it's \"\"c\"\"\",\n"},
+		{&dataset.CSVOptions{HeaderRow: true, Delimiter: '\t'}, "name\tcount\na,b\t1\n\"it's \"\"c\"\"\"\t\n"},
+		{&dataset.CSVOptions{Quote: '\''}, "'a,b',1\n'it''s \"c\"',\n"},
+		{&dataset.CSVOptions{NullStrings: []string{"NA"}}, "\"a,b\",1\n\"it's \"\"c\"\"\",NA\n"},
+	}
+
+	for i, c := range cases {
+		st := &dataset.Structure{Format: dataset.CSVDataFormat, FormatConfig: c.opts, Schema: schema}
+		buf := &bytes.Buffer{}
+		w := NewCSVWriter(st, buf)
+		for _, row := range rows {
+			if err := w.WriteEntry(row); err != nil {
+				t.Errorf("case %d write error: %s", i, err.Error())
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("case %d close error: %s", i, err.Error())
+			continue
+		}
+
+		if buf.String() != c.expect {
+			t.Errorf("case %d output mismatch. expected:\n%s\ngot:\n%s", i, c.expect, buf.String())
+			continue
+		}
+
+		// written data should read back to the same values
+		r := NewCSVReader(st, buf)
+		j := 0
+		if err := EachEntry(r, func(_ int, ent Entry, err error) error {
+			expect := []interface{}{rows[j].Value.([]interface{})[0], rows[j].Value.([]interface{})[1]}
+			if expect[1] != nil {
+				expect[1] = int64(expect[1].(int))
+			}
+			if len(c.opts.NullStrings) == 0 && expect[1] == nil {
+				// without null strings, nulls are written as empty strings
+				expect[1] = ""
+			}
+			if !reflect.DeepEqual(expect, ent.Value) {
+				t.Errorf("case %d row %d read mismatch. expected: %v, got: %v", i, j, expect, ent.Value)
+			}
+			j++
+			return err
+		}); err != nil {
+			t.Errorf("case %d read error: %s", i, err.Error())
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package dsio
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EntryDiffType enumerates the kinds of change an EntryDiff can describe
+type EntryDiffType int
+
+const (
+	// EntryAdded is an entry that only exists in the "after" data
+	EntryAdded EntryDiffType = iota + 1
+	// EntryRemoved is an entry that only exists in the "before" data
+	EntryRemoved
+	// EntryModified is an entry that exists in both, with differing values
+	EntryModified
+)
+
+// String implements the fmt.Stringer interface for EntryDiffType
+func (t EntryDiffType) String() string {
+	switch t {
+	case EntryAdded:
+		return "added"
+	case EntryRemoved:
+		return "removed"
+	case EntryModified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// FieldDiff is a change to a single field of a modified entry
+type FieldDiff struct {
+	// Field is the title or key of the field, or it's index if untitled
+	Field  string
+	Before interface{}
+	After  interface{}
+}
+
+// EntryDiff describes an entry that differs between two sets of data
+type EntryDiff struct {
+	Type EntryDiffType
+	// Key identifies the entry, either by primary key value, object key,
+	// or position in the data
+	Key    string
+	Before interface{}
+	After  interface{}
+	// Fields lists changed fields of modified entries that are arrays or
+	// objects
+	Fields []FieldDiff
+}
+
+// DiffStats counts changed entries
+type DiffStats struct {
+	Added    int
+	Removed  int
+	Modified int
+}
+
+// String gives a human-readable summary of changes, eg:
+// "12 rows added, 3 modified". An empty string means no changes
+func (s DiffStats) String() string {
+	var parts []string
+	for _, c := range []struct {
+		n    int
+		verb string
+	}{
+		{s.Added, "added"},
+		{s.Removed, "removed"},
+		{s.Modified, "modified"},
+	} {
+		if c.n == 0 {
+			continue
+		}
+		if len(parts) == 0 {
+			noun := "rows"
+			if c.n == 1 {
+				noun = "row"
+			}
+			parts = append(parts, fmt.Sprintf("%d %s %s", c.n, noun, c.verb))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d %s", c.n, c.verb))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DiffEntries compares the entries of two readers, calling emit with each
+// added, removed or modified entry. Entries are matched by the value of a
+// primary key field, which can be a column title of array entries or a key
+// of object entries. An empty key matches entries by their object key when
+// the data is an object, or by value when the data is an array, see
+// diffByValue.
+// Added & modified entries are emitted in the order they're read from after,
+// followed by removed entries in the order they were read from before.
+// emit may be nil.
+//
+// DiffEntries is not a streaming diff: every entry of before is decoded &
+// held in memory until the diff completes, along with the key of every entry
+// read from after. Diffing without a key also holds entries of after that
+// have no equal in before. Memory use grows with the decoded size of before,
+// so callers should check Structure.Length or Entries before diffing bodies
+// that may not fit in memory
+func DiffEntries(before, after EntryReader, key string, emit func(EntryDiff) error) (DiffStats, error) {
+	stats := DiffStats{}
+	if emit == nil {
+		emit = func(EntryDiff) error { return nil }
+	}
+	if key == "" && arrayData(after) {
+		return diffByValue(before, after, emit)
+	}
+
+	beforeKey := entryKeyFunc(before, key)
+	afterKey := entryKeyFunc(after, key)
+	fields := entryFieldNames(after)
+
+	var order []string
+	prev := map[string]interface{}{}
+	err := EachEntry(before, func(i int, ent Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		k, err := beforeKey(i, ent)
+		if err != nil {
+			return err
+		}
+		if _, ok := prev[k]; ok {
+			return fmt.Errorf("duplicate key %q", k)
+		}
+		prev[k] = ent.Value
+		order = append(order, k)
+		return nil
+	})
+	if err != nil {
+		log.Debug(err.Error())
+		return stats, fmt.Errorf("error reading before entries: %s", err.Error())
+	}
+
+	seen := map[string]bool{}
+	err = EachEntry(after, func(i int, ent Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		k, err := afterKey(i, ent)
+		if err != nil {
+			return err
+		}
+		if seen[k] {
+			return fmt.Errorf("duplicate key %q", k)
+		}
+		seen[k] = true
+
+		val, ok := prev[k]
+		if !ok {
+			stats.Added++
+			return emit(EntryDiff{Type: EntryAdded, Key: k, After: ent.Value})
+		}
+		if valuesEqual(val, ent.Value) {
+			return nil
+		}
+		stats.Modified++
+		return emit(EntryDiff{
+			Type:   EntryModified,
+			Key:    k,
+			Before: val,
+			After:  ent.Value,
+			Fields: fieldDiffs(val, ent.Value, fields),
+		})
+	})
+	if err != nil {
+		log.Debug(err.Error())
+		return stats, fmt.Errorf("error reading after entries: %s", err.Error())
+	}
+
+	for _, k := range order {
+		if seen[k] {
+			continue
+		}
+		stats.Removed++
+		if err := emit(EntryDiff{Type: EntryRemoved, Key: k, Before: prev[k]}); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// diffByValue compares array data without a primary key. Entries with equal
+// values are unchanged wherever they appear, so inserting a row only adds
+// one entry. Remaining entries of before & after are paired in order as
+// modifications, & the rest are added or removed. Keys are entry positions.
+// All entries of before, and unmatched entries of after, are held in memory
+// until after is read
+func diffByValue(before, after EntryReader, emit func(EntryDiff) error) (DiffStats, error) {
+	stats := DiffStats{}
+	fields := entryFieldNames(after)
+
+	type entry struct {
+		i   int
+		val interface{}
+	}
+
+	var prev []entry
+	unmatched := map[string][]int{}
+	err := EachEntry(before, func(i int, ent Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		k := valueKey(ent.Value)
+		unmatched[k] = append(unmatched[k], len(prev))
+		prev = append(prev, entry{i, ent.Value})
+		return nil
+	})
+	if err != nil {
+		log.Debug(err.Error())
+		return stats, fmt.Errorf("error reading before entries: %s", err.Error())
+	}
+
+	matched := make([]bool, len(prev))
+	var added []entry
+	err = EachEntry(after, func(i int, ent Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		k := valueKey(ent.Value)
+		if idx := unmatched[k]; len(idx) > 0 {
+			matched[idx[0]] = true
+			unmatched[k] = idx[1:]
+			return nil
+		}
+		added = append(added, entry{i, ent.Value})
+		return nil
+	})
+	if err != nil {
+		log.Debug(err.Error())
+		return stats, fmt.Errorf("error reading after entries: %s", err.Error())
+	}
+
+	var removed []entry
+	for i, e := range prev {
+		if !matched[i] {
+			removed = append(removed, e)
+		}
+	}
+
+	for i, a := range added {
+		k := strconv.Itoa(a.i)
+		if i < len(removed) {
+			stats.Modified++
+			b := removed[i]
+			if err := emit(EntryDiff{Type: EntryModified, Key: k, Before: b.val, After: a.val, Fields: fieldDiffs(b.val, a.val, fields)}); err != nil {
+				return stats, err
+			}
+			continue
+		}
+		stats.Added++
+		if err := emit(EntryDiff{Type: EntryAdded, Key: k, After: a.val}); err != nil {
+			return stats, err
+		}
+	}
+	for i := len(added); i < len(removed); i++ {
+		stats.Removed++
+		if err := emit(EntryDiff{Type: EntryRemoved, Key: strconv.Itoa(removed[i].i), Before: removed[i].val}); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// arrayData reports weather a reader reads entries of array data
+func arrayData(r EntryReader) bool {
+	st := r.Structure()
+	if st == nil || st.Schema == nil {
+		return false
+	}
+	mode, err := schemaScanMode(st.Schema)
+	return err == nil && mode == smArray
+}
+
+// valueKey gives a string that's equal for entry values valuesEqual
+// considers equal
+func valueKey(v interface{}) string {
+	data, err := json.Marshal(numbersToFloat(v))
+	if err != nil {
+		return fmt.Sprintf("%#v", v)
+	}
+	return string(data)
+}
+
+// numbersToFloat copies a value, converting all numbers to float64
+func numbersToFloat(v interface{}) interface{} {
+	if f, ok := toFloat(v); ok {
+		return f
+	}
+	switch x := v.(type) {
+	case []interface{}:
+		vals := make([]interface{}, len(x))
+		for i, e := range x {
+			vals[i] = numbersToFloat(e)
+		}
+		return vals
+	case map[string]interface{}:
+		vals := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			vals[k] = numbersToFloat(e)
+		}
+		return vals
+	}
+	return v
+}
+
+// entryKeyFunc gives a function that identifies entries read from r
+func entryKeyFunc(r EntryReader, key string) func(i int, ent Entry) (string, error) {
+	if key == "" {
+		return func(i int, ent Entry) (string, error) {
+			if ent.Key != "" {
+				return ent.Key, nil
+			}
+			return strconv.Itoa(i), nil
+		}
+	}
+
+	col := -1
+	for i, title := range entryFieldNames(r) {
+		if title == key {
+			col = i
+			break
+		}
+	}
+
+	return func(i int, ent Entry) (string, error) {
+		switch v := ent.Value.(type) {
+		case map[string]interface{}:
+			if kv, ok := v[key]; ok {
+				return keyString(kv), nil
+			}
+		case []interface{}:
+			if col >= 0 && col < len(v) {
+				return keyString(v[col]), nil
+			}
+		}
+		return "", fmt.Errorf("entry %d has no primary key field %q", i, key)
+	}
+}
+
+// entryFieldNames gives column titles for readers of array entries
+func entryFieldNames(r EntryReader) []string {
+	st := r.Structure()
+	if st == nil || st.Schema == nil {
+		return nil
+	}
+	titles, _, err := terribleHackToGetHeaderRowAndTypes(st)
+	if err != nil {
+		return nil
+	}
+	return titles
+}
+
+// keyString formats a primary key value, writing integral numbers the same
+// way regardless of their go type
+func keyString(v interface{}) string {
+	switch n := v.(type) {
+	case string:
+		return n
+	case int:
+		return strconv.Itoa(n)
+	case int64:
+		return strconv.FormatInt(n, 10)
+	case float64:
+		if n == float64(int64(n)) {
+			return strconv.FormatInt(int64(n), 10)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// fieldDiffs lists differing fields of two arrays or two objects
+func fieldDiffs(before, after interface{}, titles []string) []FieldDiff {
+	var diffs []FieldDiff
+	switch b := before.(type) {
+	case []interface{}:
+		a, ok := after.([]interface{})
+		if !ok {
+			return nil
+		}
+		n := len(b)
+		if len(a) > n {
+			n = len(a)
+		}
+		for i := 0; i < n; i++ {
+			var bv, av interface{}
+			if i < len(b) {
+				bv = b[i]
+			}
+			if i < len(a) {
+				av = a[i]
+			}
+			if valuesEqual(bv, av) {
+				continue
+			}
+			field := strconv.Itoa(i)
+			if i < len(titles) && titles[i] != "" {
+				field = titles[i]
+			}
+			diffs = append(diffs, FieldDiff{Field: field, Before: bv, After: av})
+		}
+	case map[string]interface{}:
+		a, ok := after.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		keys := []string{}
+		for k := range b {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, ok := b[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !valuesEqual(b[k], a[k]) {
+				diffs = append(diffs, FieldDiff{Field: k, Before: b[k], After: a[k]})
+			}
+		}
+	}
+	return diffs
+}
+
+// valuesEqual compares two entry values. Numbers are compared by value, so
+// values read from different data formats compare equal
+func valuesEqual(a, b interface{}) bool {
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		return ok && af == bf
+	}
+
+	switch av := a.(type) {
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !valuesEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if w, ok := bv[k]; !ok || !valuesEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,163 @@
+package dsio
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/dataset"
+)
+
+func TestDiffEntries(t *testing.T) {
+	citiesSt := &dataset.Structure{
+		Format:       dataset.CSVDataFormat,
+		FormatConfig: &dataset.CSVOptions{HeaderRow: true},
+		Schema:       offsetStruct.Schema,
+	}
+	jsonArraySt := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: offsetStruct.Schema}
+	jsonObjectSt := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaObject}
+
+	cases := []struct {
+		beforeSt, afterSt *dataset.Structure
+		before, after     string
+		key               string
+		diffs             []EntryDiff
+		stats             DiffStats
+		err               string
+	}{
+		{citiesSt, citiesSt,
+			"city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\nchicago,300000,44.4,true\n",
+			"city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\nchicago,300000,44.4,true\n",
+			"city", nil, DiffStats{}, ""},
+		{citiesSt, citiesSt,
+			"city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\nchicago,300000,44.4,true\nraleigh,250000,50.65,true\n",
+			"city,pop,avg_age,in_usa\nnew york,8500000,44.4,true\ntoronto,40000001,55.5,false\nchicago,300000,44.4,true\n",
+			"city",
+			[]EntryDiff{
+				{Type: EntryAdded, Key: "new york", After: []interface{}{"new york", int64(8500000), 44.4, true}},
+				{Type: EntryModified, Key: "toronto",
+					Before: []interface{}{"toronto", int64(40000000), 55.5, false},
+					After:  []interface{}{"toronto", int64(40000001), 55.5, false},
+					Fields: []FieldDiff{{Field: "pop", Before: int64(40000000), After: int64(40000001)}},
+				},
+				{Type: EntryRemoved, Key: "raleigh", Before: []interface{}{"raleigh", int64(250000), 50.65, true}},
+			},
+			DiffStats{Added: 1, Removed: 1, Modified: 1}, ""},
+		// numbers compare by value across formats
+		{citiesSt, jsonArraySt,
+			"city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\n",
+			`[["toronto",40000000,55.5,false]]`,
+			"pop", nil, DiffStats{}, ""},
+		// no key matches array entries by value
+		{jsonArraySt, jsonArraySt,
+			`[["a",1,1,true],["b",2,2,true]]`,
+			`[["b",2,2,true]]`,
+			"",
+			[]EntryDiff{
+				{Type: EntryRemoved, Key: "0", Before: []interface{}{"a", float64(1), float64(1), true}},
+			},
+			DiffStats{Removed: 1}, ""},
+		// inserted rows don't modify the rows that follow, unmatched rows are
+		// paired in order
+		{citiesSt, jsonArraySt,
+			"city,pop,avg_age,in_usa\na,1,1,true\nb,2,2,true\nc,3,3,true\n",
+			`[["z",0,0,true],["a",1,1,true],["b",2,2,true],["c",3,3,false],["c",3,3,true]]`,
+			"",
+			[]EntryDiff{
+				{Type: EntryAdded, Key: "0", After: []interface{}{"z", float64(0), float64(0), true}},
+				{Type: EntryAdded, Key: "3", After: []interface{}{"c", float64(3), float64(3), false}},
+			},
+			DiffStats{Added: 2}, ""},
+		{jsonArraySt, jsonArraySt,
+			`[["a",1,1,true],["b",2,2,true]]`,
+			`[["z",0,0,true],["a",1,1,true],["b",2,2,false]]`,
+			"",
+			[]EntryDiff{
+				{Type: EntryModified, Key: "0",
+					Before: []interface{}{"b", float64(2), float64(2), true},
+					After:  []interface{}{"z", float64(0), float64(0), true},
+					Fields: []FieldDiff{
+						{Field: "city", Before: "b", After: "z"},
+						{Field: "pop", Before: float64(2), After: float64(0)},
+						{Field: "avg_age", Before: float64(2), After: float64(0)},
+					},
+				},
+				{Type: EntryAdded, Key: "2", After: []interface{}{"b", float64(2), float64(2), false}},
+			},
+			DiffStats{Added: 1, Modified: 1}, ""},
+		// no key matches object data by entry key
+		{jsonObjectSt, jsonObjectSt,
+			`{"a":{"x":1,"y":2},"b":true}`,
+			`{"b":true,"a":{"x":1,"z":3}}`,
+			"",
+			[]EntryDiff{
+				{Type: EntryModified, Key: "a",
+					Before: map[string]interface{}{"x": float64(1), "y": float64(2)},
+					After:  map[string]interface{}{"x": float64(1), "z": float64(3)},
+					Fields: []FieldDiff{
+						{Field: "y", Before: float64(2)},
+						{Field: "z", After: float64(3)},
+					},
+				},
+			},
+			DiffStats{Modified: 1}, ""},
+		{citiesSt, citiesSt,
+			"city,pop,avg_age,in_usa\ntoronto,1,1,true\ntoronto,2,2,true\n",
+			"city,pop,avg_age,in_usa\n",
+			"city", nil, DiffStats{}, `error reading before entries: duplicate key "toronto"`},
+		{citiesSt, jsonObjectSt,
+			"city,pop,avg_age,in_usa\n",
+			`{"a":1}`,
+			"city", nil, DiffStats{}, `error reading after entries: entry 0 has no primary key field "city"`},
+	}
+
+	for i, c := range cases {
+		before, err := NewEntryReader(c.beforeSt, bytes.NewBufferString(c.before))
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err.Error())
+		}
+		after, err := NewEntryReader(c.afterSt, bytes.NewBufferString(c.after))
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err.Error())
+		}
+
+		var diffs []EntryDiff
+		stats, err := DiffEntries(before, after, c.key, func(d EntryDiff) error {
+			diffs = append(diffs, d)
+			return nil
+		})
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		if stats != c.stats {
+			t.Errorf("case %d stats mismatch. expected: %v, got: %v", i, c.stats, stats)
+		}
+		if !reflect.DeepEqual(c.diffs, diffs) {
+			t.Errorf("case %d diffs mismatch. expected:\n%v\ngot:\n%v", i, c.diffs, diffs)
+		}
+	}
+}
+
+func TestDiffStatsString(t *testing.T) {
+	cases := []struct {
+		stats  DiffStats
+		expect string
+	}{
+		{DiffStats{}, ""},
+		{DiffStats{Added: 1}, "1 row added"},
+		{DiffStats{Added: 12, Modified: 3}, "12 rows added, 3 modified"},
+		{DiffStats{Removed: 2, Modified: 1}, "2 rows removed, 1 modified"},
+		{DiffStats{Modified: 1}, "1 row modified"},
+		{DiffStats{Added: 1, Removed: 1, Modified: 1}, "1 row added, 1 removed, 1 modified"},
+	}
+
+	for i, c := range cases {
+		if got := c.stats.String(); got != c.expect {
+			t.Errorf("case %d mismatch. expected: %q, got: %q", i, c.expect, got)
+		}
+	}
+}
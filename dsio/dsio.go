@@ -1,4 +1,14 @@
 // Package dsio defines writers & readers for operating on "container" data structures (objects and arrays)
+//
+// Parquet support is written by hand instead of using a parquet library.
+// The established go implementations (xitongsys/parquet-go,
+// segmentio/parquet-go & apache/arrow's parquet package) each depend on
+// apache/thrift & several compression libraries, and newer versions require
+// module-aware builds & generics. dsio only needs a small subset of parquet:
+// flat schemas, PLAIN & dictionary/RLE encodings, v1 & v2 data pages, and
+// snappy, gzip & zstd compression. thrift.go is a compact-protocol codec for
+// parquet file metadata, not a general thrift runtime. ParquetReader is
+// checked against files written by parquet-go in testdata
 package dsio
 
 import (
@@ -7,6 +17,7 @@ import (
 
 	logger "github.com/ipfs/go-log"
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
 	"github.com/qri-io/jsonschema"
 )
 
@@ -46,8 +57,27 @@ type EntryReadWriter interface {
 	Bytes() []byte
 }
 
-// NewEntryReader allocates a EntryReader based on a given structure
+// NewEntryReader allocates a EntryReader based on a given structure.
+// If the structure specifies a compression type, data is decompressed
+// as it's read. Text data is transcoded to utf-8 from the structure's
+// character encoding
 func NewEntryReader(st *dataset.Structure, r io.Reader) (EntryReader, error) {
+	if st.Compression != compression.None {
+		dr, err := compression.NewReader(st.Compression, r)
+		if err != nil {
+			log.Debug(err.Error())
+			return nil, err
+		}
+		r = dr
+	}
+	if textDataFormat(st.Format) {
+		cr, err := NewCharsetReader(st.Encoding, r)
+		if err != nil {
+			return nil, err
+		}
+		r = cr
+	}
+
 	switch st.Format {
 	case dataset.CBORDataFormat:
 		return NewCBORReader(st, r)
@@ -55,6 +85,14 @@ func NewEntryReader(st *dataset.Structure, r io.Reader) (EntryReader, error) {
 		return NewJSONReader(st, r)
 	case dataset.CSVDataFormat:
 		return NewCSVReader(st, r), nil
+	case dataset.XMLDataFormat:
+		return NewXMLReader(st, r)
+	case dataset.XLSDataFormat:
+		return NewXLSXReader(st, r)
+	case dataset.NDJSONDataFormat:
+		return NewNDJSONReader(st, r)
+	case dataset.ParquetDataFormat:
+		return NewParquetReader(st, r)
 	case dataset.UnknownDataFormat:
 		err := fmt.Errorf("structure must have a data format")
 		log.Debug(err.Error())
@@ -66,8 +104,67 @@ func NewEntryReader(st *dataset.Structure, r io.Reader) (EntryReader, error) {
 	}
 }
 
-// NewEntryWriter allocates a EntryWriter based on a given structure
+// NewEntryWriter allocates a EntryWriter based on a given structure.
+// If the structure specifies a compression type, data is compressed as it's
+// written. Text data is written in the structure's character encoding.
+// Close must be called to flush compressed & encoded data
 func NewEntryWriter(st *dataset.Structure, w io.Writer) (EntryWriter, error) {
+	if st.Compression == compression.None {
+		return newEncodedEntryWriter(st, w)
+	}
+
+	cw, err := compression.NewWriter(st.Compression, w)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	ew, err := newEncodedEntryWriter(st, cw)
+	if err != nil {
+		cw.Close()
+		return nil, err
+	}
+	return compressedEntryWriter{EntryWriter: ew, cw: cw}, nil
+}
+
+// compressedEntryWriter wraps an EntryWriter that writes to a compression
+// writer, flushing compressed data on close
+type compressedEntryWriter struct {
+	EntryWriter
+	cw io.WriteCloser
+}
+
+// Close finalizes the writer, indicating all entries have been written
+func (w compressedEntryWriter) Close() error {
+	if err := w.EntryWriter.Close(); err != nil {
+		w.cw.Close()
+		return err
+	}
+	if err := w.cw.Close(); err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error compressing data: %s", err.Error())
+	}
+	return nil
+}
+
+// newEncodedEntryWriter creates an EntryWriter that writes text data in the
+// structure's character encoding
+func newEncodedEntryWriter(st *dataset.Structure, w io.Writer) (EntryWriter, error) {
+	if !textDataFormat(st.Format) || st.Encoding == "" {
+		return newEntryWriter(st, w)
+	}
+
+	cw, err := NewCharsetWriter(st.Encoding, w)
+	if err != nil {
+		return nil, err
+	}
+	ew, err := newEntryWriter(st, cw)
+	if err != nil {
+		return nil, err
+	}
+	return charsetEntryWriter{EntryWriter: ew, cw: cw}, nil
+}
+
+func newEntryWriter(st *dataset.Structure, w io.Writer) (EntryWriter, error) {
 	switch st.Format {
 	case dataset.CBORDataFormat:
 		return NewCBORWriter(st, w)
@@ -75,6 +172,12 @@ func NewEntryWriter(st *dataset.Structure, w io.Writer) (EntryWriter, error) {
 		return NewJSONWriter(st, w)
 	case dataset.CSVDataFormat:
 		return NewCSVWriter(st, w), nil
+	case dataset.XMLDataFormat:
+		return NewXMLWriter(st, w)
+	case dataset.NDJSONDataFormat:
+		return NewNDJSONWriter(st, w)
+	case dataset.ParquetDataFormat:
+		return NewParquetWriter(st, w)
 	case dataset.UnknownDataFormat:
 		err := fmt.Errorf("structure must have a data format")
 		log.Debug(err.Error())
@@ -2,9 +2,11 @@ package dsio
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
 )
 
 func TestNewEntryReader(t *testing.T) {
@@ -16,6 +18,11 @@ func TestNewEntryReader(t *testing.T) {
 		{&dataset.Structure{Format: dataset.CBORDataFormat, Schema: dataset.BaseSchemaArray}, ""},
 		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray}, ""},
 		{&dataset.Structure{Format: dataset.CSVDataFormat, Schema: dataset.BaseSchemaArray}, ""},
+		{&dataset.Structure{Format: dataset.XMLDataFormat, Schema: dataset.BaseSchemaArray}, ""},
+		{&dataset.Structure{Format: dataset.XLSDataFormat, Schema: dataset.BaseSchemaArray}, "error opening workbook: zip: not a valid zip file"},
+		{&dataset.Structure{Format: dataset.NDJSONDataFormat, Schema: dataset.BaseSchemaArray}, ""},
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray, Compression: compression.Gzip}, ""},
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray, Compression: compression.Tar}, "unsupported compression type for reading: tar"},
 	}
 
 	for i, c := range cases {
@@ -36,6 +43,9 @@ func TestNewEntryWriter(t *testing.T) {
 		{&dataset.Structure{Format: dataset.CBORDataFormat, Schema: dataset.BaseSchemaArray}, ""},
 		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray}, ""},
 		{&dataset.Structure{Format: dataset.CSVDataFormat, Schema: dataset.BaseSchemaArray}, ""},
+		{&dataset.Structure{Format: dataset.XMLDataFormat, Schema: dataset.BaseSchemaArray}, ""},
+		{&dataset.Structure{Format: dataset.XLSDataFormat, Schema: dataset.BaseSchemaArray}, "invalid format to create writer: xls"},
+		{&dataset.Structure{Format: dataset.NDJSONDataFormat, Schema: dataset.BaseSchemaArray}, ""},
 	}
 
 	for i, c := range cases {
@@ -46,3 +56,97 @@ func TestNewEntryWriter(t *testing.T) {
 		}
 	}
 }
+
+func TestNewEntryReaderCompressed(t *testing.T) {
+	data := []byte(`[1,"two",{"three":3}]`)
+	expect := []Entry{
+		{Value: float64(1)},
+		{Value: "two"},
+		{Value: map[string]interface{}{"three": float64(3)}},
+	}
+
+	for _, typ := range []compression.Type{compression.Gzip, compression.Zstd} {
+		buf := &bytes.Buffer{}
+		w, err := compression.NewWriter(typ, buf)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		w.Write(data)
+		if err := w.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray, Compression: typ}
+		r, err := NewEntryReader(st, buf)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", typ, err.Error())
+			continue
+		}
+
+		got := []Entry{}
+		err = EachEntry(r, func(i int, ent Entry, err error) error {
+			got = append(got, ent)
+			return err
+		})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", typ, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(expect, got) {
+			t.Errorf("%s: entries mismatch. expected:\n%v\ngot:\n%v", typ, expect, got)
+		}
+	}
+}
+
+func TestNewEntryWriterCompressed(t *testing.T) {
+	entries := []Entry{
+		{Value: float64(1)},
+		{Value: "two"},
+		{Value: map[string]interface{}{"three": float64(3)}},
+	}
+
+	for _, typ := range []compression.Type{compression.Gzip, compression.Zstd} {
+		st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray, Compression: typ}
+		buf := &bytes.Buffer{}
+		w, err := NewEntryWriter(st, buf)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", typ, err.Error())
+			continue
+		}
+		for _, ent := range entries {
+			if err := w.WriteEntry(ent); err != nil {
+				t.Fatalf("%s: error writing entry: %s", typ, err.Error())
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: error closing writer: %s", typ, err.Error())
+		}
+
+		if got := compression.Detect(buf.Bytes()); got != typ {
+			t.Errorf("%s: expected written data to be compressed, detected: %s", typ, got)
+		}
+		r, err := NewEntryReader(st, buf)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", typ, err.Error())
+			continue
+		}
+		got := []Entry{}
+		err = EachEntry(r, func(i int, ent Entry, err error) error {
+			got = append(got, ent)
+			return err
+		})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", typ, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(entries, got) {
+			t.Errorf("%s: entries mismatch. expected:\n%v\ngot:\n%v", typ, entries, got)
+		}
+	}
+
+	st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray, Compression: compression.Bzip2}
+	expect := "unsupported compression type for writing: bzip2"
+	if _, err := NewEntryWriter(st, &bytes.Buffer{}); err == nil || err.Error() != expect {
+		t.Errorf("error mismatch. expected: %s, got: %v", expect, err)
+	}
+}
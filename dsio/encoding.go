@@ -0,0 +1,130 @@
+package dsio
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/qri-io/dataset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// encodingNames maps lower-case character encoding names & aliases
+// to their canonical name
+var encodingNames = map[string]string{
+	"utf-8":        "utf-8",
+	"utf8":         "utf-8",
+	"utf-16":       "utf-16",
+	"utf16":        "utf-16",
+	"utf-16le":     "utf-16le",
+	"utf-16be":     "utf-16be",
+	"iso-8859-1":   "iso-8859-1",
+	"iso8859-1":    "iso-8859-1",
+	"iso_8859-1":   "iso-8859-1",
+	"latin1":       "iso-8859-1",
+	"latin-1":      "iso-8859-1",
+	"windows-1252": "windows-1252",
+	"windows1252":  "windows-1252",
+	"cp1252":       "windows-1252",
+}
+
+// encodings maps canonical character encoding names to their implementations
+var encodings = map[string]encoding.Encoding{
+	"utf-8":        unicode.UTF8,
+	"utf-16":       unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	"utf-16le":     unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be":     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"iso-8859-1":   charmap.ISO8859_1,
+	"windows-1252": charmap.Windows1252,
+}
+
+// CanonicalEncodingName gives the canonical name of a character encoding
+// from a case-insensitive name or alias, erroring if the encoding isn't
+// supported. An empty name is utf-8
+func CanonicalEncodingName(name string) (string, error) {
+	if name == "" {
+		return "utf-8", nil
+	}
+	canon, ok := encodingNames[strings.ToLower(name)]
+	if !ok {
+		err := fmt.Errorf("unsupported character encoding: %q", name)
+		log.Debug(err.Error())
+		return "", err
+	}
+	return canon, nil
+}
+
+// NewCharsetReader wraps r in a reader that transcodes text from the named
+// character encoding to utf-8. A leading byte order mark is removed, and takes
+// precedence over the named encoding. utf-8 text is passed through unaltered
+func NewCharsetReader(name string, r io.Reader) (io.Reader, error) {
+	canon, err := CanonicalEncodingName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var t transform.Transformer = transform.Nop
+	if canon != "utf-8" {
+		t = encodings[canon].NewDecoder()
+	}
+	return transform.NewReader(r, unicode.BOMOverride(t)), nil
+}
+
+// NewCharsetWriter wraps w in a writer that transcodes utf-8 text to the named
+// character encoding. Close must be called to flush any buffered data, closing
+// the returned writer does not close w. Writing characters the encoding can't
+// represent is an error
+func NewCharsetWriter(name string, w io.Writer) (io.WriteCloser, error) {
+	canon, err := CanonicalEncodingName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if canon == "utf-8" {
+		return nopWriteCloser{w}, nil
+	}
+	return transform.NewWriter(w, encodings[canon].NewEncoder()), nil
+}
+
+// textDataFormat reports weather a data format is character-encoded text.
+// Structure.Encoding has no effect on binary formats
+func textDataFormat(f dataset.DataFormat) bool {
+	switch f {
+	case dataset.CBORDataFormat, dataset.XLSDataFormat, dataset.ParquetDataFormat:
+		return false
+	default:
+		return true
+	}
+}
+
+// charsetEntryWriter wraps an EntryWriter that writes to a charset writer,
+// flushing the charset writer on close
+type charsetEntryWriter struct {
+	EntryWriter
+	cw io.WriteCloser
+}
+
+// Close finalizes the writer, indicating all entries have been written
+func (w charsetEntryWriter) Close() error {
+	if err := w.EntryWriter.Close(); err != nil {
+		return err
+	}
+	if err := w.cw.Close(); err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error encoding data: %s", err.Error())
+	}
+	return nil
+}
+
+// nopWriteCloser adds a no-op Close method to an io.Writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close implements the io.Closer interface
+func (nopWriteCloser) Close() error {
+	return nil
+}
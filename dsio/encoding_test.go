@@ -0,0 +1,187 @@
+package dsio
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestCanonicalEncodingName(t *testing.T) {
+	cases := []struct {
+		name, expect string
+		err          string
+	}{
+		{"", "utf-8", ""},
+		{"UTF-8", "utf-8", ""},
+		{"utf8", "utf-8", ""},
+		{"UTF-16LE", "utf-16le", ""},
+		{"latin1", "iso-8859-1", ""},
+		{"ISO-8859-1", "iso-8859-1", ""},
+		{"cp1252", "windows-1252", ""},
+		{"ebcdic", "", `unsupported character encoding: "ebcdic"`},
+	}
+
+	for i, c := range cases {
+		got, err := CanonicalEncodingName(c.name)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+			continue
+		}
+		if got != c.expect {
+			t.Errorf("case %d result mismatch. expected: %s, got: %s", i, c.expect, got)
+		}
+	}
+}
+
+func TestNewCharsetReader(t *testing.T) {
+	cases := []struct {
+		encoding string
+		in       []byte
+		expect   string
+		err      string
+	}{
+		{"", []byte("caf\xc3\xa9"), "café", ""},
+		// invalid utf-8 is passed through unaltered
+		{"", []byte("caf\xe9"), "caf\xe9", ""},
+		{"", []byte("\xef\xbb\xbfcaf\xc3\xa9"), "café", ""},
+		{"iso-8859-1", []byte("caf\xe9"), "café", ""},
+		{"windows-1252", []byte("\x93caf\xe9\x94"), "“café”", ""},
+		{"utf-16le", []byte("c\x00a\x00f\x00\xe9\x00"), "café", ""},
+		{"utf-16be", []byte("\x00c\x00a\x00f\x00\xe9"), "café", ""},
+		{"utf-16", []byte("\xff\xfec\x00a\x00f\x00\xe9\x00"), "café", ""},
+		// byte order marks override the named encoding
+		{"iso-8859-1", []byte("\xef\xbb\xbfcaf\xc3\xa9"), "café", ""},
+		{"ebcdic", nil, "", `unsupported character encoding: "ebcdic"`},
+	}
+
+	for i, c := range cases {
+		r, err := NewCharsetReader(c.encoding, bytes.NewReader(c.in))
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("case %d read error: %s", i, err.Error())
+			continue
+		}
+		if string(got) != c.expect {
+			t.Errorf("case %d result mismatch. expected: %q, got: %q", i, c.expect, string(got))
+		}
+	}
+}
+
+func TestNewCharsetWriter(t *testing.T) {
+	cases := []struct {
+		encoding string
+		in       string
+		expect   []byte
+		err      string
+	}{
+		{"", "café", []byte("caf\xc3\xa9"), ""},
+		{"latin1", "café", []byte("caf\xe9"), ""},
+		{"windows-1252", "“café”", []byte("\x93caf\xe9\x94"), ""},
+		{"utf-16le", "café", []byte("c\x00a\x00f\x00\xe9\x00"), ""},
+		{"ebcdic", "", nil, `unsupported character encoding: "ebcdic"`},
+	}
+
+	for i, c := range cases {
+		buf := &bytes.Buffer{}
+		w, err := NewCharsetWriter(c.encoding, buf)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		if _, err := w.Write([]byte(c.in)); err != nil {
+			t.Errorf("case %d write error: %s", i, err.Error())
+			continue
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("case %d close error: %s", i, err.Error())
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), c.expect) {
+			t.Errorf("case %d result mismatch. expected: %q, got: %q", i, c.expect, buf.Bytes())
+		}
+	}
+}
+
+var encodedCSVStruct = &dataset.Structure{
+	Format:       dataset.CSVDataFormat,
+	FormatConfig: &dataset.CSVOptions{HeaderRow: true},
+	Encoding:     "iso-8859-1",
+	Schema: jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type": "array",
+			"items": [
+				{"title": "city", "type": "string"},
+				{"title": "pop", "type": "integer"}
+			]
+		}
+	}`),
+}
+
+func TestEntryReaderEncoding(t *testing.T) {
+	data := []byte("city,pop\nmontr\xe9al,1700000\nqu\xe9bec,540000\n")
+	r, err := NewEntryReader(encodedCSVStruct, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	got := []Entry{}
+	err = EachEntry(r, func(i int, ent Entry, err error) error {
+		got = append(got, ent)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expect := []Entry{
+		{Value: []interface{}{"montréal", int64(1700000)}},
+		{Value: []interface{}{"québec", int64(540000)}},
+	}
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("entries mismatch. expected:\n%v\ngot:\n%v", expect, got)
+	}
+}
+
+func TestEntryWriterEncoding(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewEntryWriter(encodedCSVStruct, buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err := w.WriteEntry(Entry{Value: []interface{}{"montréal", int64(1700000)}}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expect := "city,pop\nmontr\xe9al,1700000\n"
+	if buf.String() != expect {
+		t.Errorf("result mismatch. expected: %q, got: %q", expect, buf.String())
+	}
+
+	// characters the encoding can't represent are an error
+	w, err = NewEntryWriter(encodedCSVStruct, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	w.WriteEntry(Entry{Value: []interface{}{"東京", int64(13500000)}})
+	if err := w.Close(); err == nil {
+		t.Errorf("expected unrepresentable character to error on close")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
 )
 
 // EntryBuffer mimics the behaviour of bytes.Buffer, but with structured Dataa
@@ -21,15 +22,24 @@ type EntryBuffer struct {
 
 // NewEntryBuffer allocates a buffer, buffers should always be created with
 // NewEntryBuffer, which will error if the provided structure is invalid for
-// reading / writing
+// reading / writing. Buffers always hold uncompressed, utf-8 data, ignoring
+// any compression or character encoding the structure specifies
 func NewEntryBuffer(st *dataset.Structure) (*EntryBuffer, error) {
+	bst := st
+	if st.Compression != compression.None || st.Encoding != "" {
+		bst = &dataset.Structure{}
+		*bst = *st
+		bst.Compression = compression.None
+		bst.Encoding = ""
+	}
+
 	buf := &bytes.Buffer{}
-	r, err := NewEntryReader(st, buf)
+	r, err := NewEntryReader(bst, buf)
 	if err != nil {
 		log.Debug(err.Error())
 		return nil, err
 	}
-	w, err := NewEntryWriter(st, buf)
+	w, err := NewEntryWriter(bst, buf)
 	if err != nil {
 		log.Debug(err.Error())
 		return nil, err
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
 	"github.com/qri-io/dataset/dstest"
 )
 
@@ -66,3 +67,33 @@ func TestEntryBuffer(t *testing.T) {
 		return
 	}
 }
+
+func TestEntryBufferCompressed(t *testing.T) {
+	st := &dataset.Structure{
+		Format:      dataset.JSONDataFormat,
+		Schema:      dataset.BaseSchemaArray,
+		Compression: compression.Gzip,
+	}
+
+	buf, err := NewEntryBuffer(st)
+	if err != nil {
+		t.Errorf("error allocating EntryBuffer: %s", err.Error())
+		return
+	}
+	if buf.Structure() != st {
+		t.Errorf("expected buffer to return the provided structure")
+	}
+	if err := buf.WriteEntry(Entry{Value: "a"}); err != nil {
+		t.Errorf("error writing entry: %s", err.Error())
+		return
+	}
+	if err := buf.Close(); err != nil {
+		t.Errorf("error closing buffer: %s", err.Error())
+		return
+	}
+
+	// buffers always hold uncompressed data
+	if string(buf.Bytes()) != `["a"]` {
+		t.Errorf("bytes mismatch. expected: %s, got: %s", `["a"]`, string(buf.Bytes()))
+	}
+}
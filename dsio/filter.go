@@ -0,0 +1,496 @@
+package dsio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/vals"
+)
+
+// FilterReader wraps an EntryReader, only reading entries that match a
+// filter expression. Expressions compare columns & literals, for example:
+//
+//	pop > 100000 and (in_usa = true or city is null)
+//
+// Columns are named by schema title for array entries & by key for object
+// entries. Titles that aren't plain identifiers are quoted with backticks.
+// Supported syntax:
+// * literals: numbers, 'strings' or "strings", true, false & null
+// * comparisons: = (or ==), != (or <>), <, <=, >, >=
+// * null checks: x is null, x is not null
+// * boolean operators: and, or, not & parentheses
+// A column or literal on its own matches if it's true.
+// Numbers & integers compare by value, strings compare lexically. Null and
+// values of different types don't compare: only != matches them, other
+// comparisons are false
+type FilterReader struct {
+	r      EntryReader
+	expr   filterNode
+	titles []string
+}
+
+// NewFilterReader creates a reader that skips entries that don't match
+// expr. It's an error for expr to name a column the schema of array entries
+// doesn't declare
+func NewFilterReader(r EntryReader, expr string) (*FilterReader, error) {
+	node, err := parseFilter(expr)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	fr := &FilterReader{r: r, expr: node}
+	if st := r.Structure(); st != nil && st.Schema != nil {
+		if titles, _, err := terribleHackToGetHeaderRowAndTypes(st); err == nil {
+			fr.titles = titles
+			for _, col := range node.columns(nil) {
+				if indexOf(titles, col) < 0 {
+					return nil, fmt.Errorf("column %q not found", col)
+				}
+			}
+		}
+	}
+	return fr, nil
+}
+
+// Structure gives the structure of the underlying reader
+func (fr *FilterReader) Structure() *dataset.Structure {
+	return fr.r.Structure()
+}
+
+// ReadEntry reads the next matching entry
+func (fr *FilterReader) ReadEntry() (Entry, error) {
+	for {
+		ent, err := fr.r.ReadEntry()
+		if err != nil {
+			return ent, err
+		}
+		if truthy(fr.expr.eval(fr.resolver(ent))) {
+			return ent, nil
+		}
+	}
+}
+
+// resolver looks up column values of an entry
+func (fr *FilterReader) resolver(ent Entry) func(string) vals.Value {
+	return func(col string) vals.Value {
+		var v interface{}
+		switch x := ent.Value.(type) {
+		case []interface{}:
+			if i := indexOf(fr.titles, col); i >= 0 && i < len(x) {
+				v = x[i]
+			}
+		case map[string]interface{}:
+			v = x[col]
+		}
+		val, err := vals.ConvertDecoded(v)
+		if err != nil {
+			return vals.Null(true)
+		}
+		return val
+	}
+}
+
+// filterNode is a parsed filter expression
+type filterNode interface {
+	eval(col func(string) vals.Value) vals.Value
+	// columns appends the columns an expression refers to
+	columns(cols []string) []string
+}
+
+type columnNode string
+
+func (n columnNode) eval(col func(string) vals.Value) vals.Value { return col(string(n)) }
+func (n columnNode) columns(cols []string) []string              { return append(cols, string(n)) }
+
+type literalNode struct {
+	val vals.Value
+}
+
+func (n literalNode) eval(col func(string) vals.Value) vals.Value { return n.val }
+func (n literalNode) columns(cols []string) []string              { return cols }
+
+type notNode struct {
+	x filterNode
+}
+
+func (n notNode) eval(col func(string) vals.Value) vals.Value {
+	return vals.Boolean(!truthy(n.x.eval(col)))
+}
+func (n notNode) columns(cols []string) []string { return n.x.columns(cols) }
+
+type logicNode struct {
+	and  bool
+	l, r filterNode
+}
+
+func (n logicNode) eval(col func(string) vals.Value) vals.Value {
+	l := truthy(n.l.eval(col))
+	if n.and && !l || !n.and && l {
+		return vals.Boolean(l)
+	}
+	return vals.Boolean(truthy(n.r.eval(col)))
+}
+func (n logicNode) columns(cols []string) []string { return n.r.columns(n.l.columns(cols)) }
+
+type nullNode struct {
+	x   filterNode
+	not bool
+}
+
+func (n nullNode) eval(col func(string) vals.Value) vals.Value {
+	return vals.Boolean(n.x.eval(col).IsNull() != n.not)
+}
+func (n nullNode) columns(cols []string) []string { return n.x.columns(cols) }
+
+type compareNode struct {
+	op   string
+	l, r filterNode
+}
+
+func (n compareNode) eval(col func(string) vals.Value) vals.Value {
+	c, ok := compareValues(n.l.eval(col), n.r.eval(col))
+	switch n.op {
+	case "=":
+		return vals.Boolean(ok && c == 0)
+	case "!=":
+		return vals.Boolean(!ok || c != 0)
+	case "<":
+		return vals.Boolean(ok && c < 0)
+	case "<=":
+		return vals.Boolean(ok && c <= 0)
+	case ">":
+		return vals.Boolean(ok && c > 0)
+	case ">=":
+		return vals.Boolean(ok && c >= 0)
+	}
+	return vals.Boolean(false)
+}
+func (n compareNode) columns(cols []string) []string { return n.r.columns(n.l.columns(cols)) }
+
+// compareValues orders two values, returning false if they don't compare
+func compareValues(a, b vals.Value) (int, bool) {
+	if a.IsNull() || b.IsNull() {
+		return 0, false
+	}
+	at, bt := a.Type(), b.Type()
+	switch {
+	case isNumericType(at) && isNumericType(bt):
+		if at == vals.TypeInteger && bt == vals.TypeInteger {
+			return compareInts(a.Integer(), b.Integer()), true
+		}
+		return compareFloats(numberOf(a), numberOf(b)), true
+	case at == vals.TypeString && bt == vals.TypeString:
+		return strings.Compare(a.String(), b.String()), true
+	case at == vals.TypeBoolean && bt == vals.TypeBoolean:
+		if a.Boolean() == b.Boolean() {
+			return 0, true
+		} else if b.Boolean() {
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func isNumericType(t vals.Type) bool {
+	return t == vals.TypeInteger || t == vals.TypeNumber
+}
+
+func numberOf(v vals.Value) float64 {
+	if v.Type() == vals.TypeInteger {
+		return float64(v.Integer())
+	}
+	return v.Number()
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func truthy(v vals.Value) bool {
+	return v.Type() == vals.TypeBoolean && v.Boolean()
+}
+
+// filter expression token kinds
+const (
+	tokEOF = iota
+	tokIdent
+	tokColumn
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type filterToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// lexFilter splits a filter expression into tokens
+func lexFilter(expr string) ([]filterToken, error) {
+	var toks []filterToken
+	rs := []rune(expr)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, filterToken{tokLParen, "(", i})
+			i++
+		case r == ')':
+			toks = append(toks, filterToken{tokRParen, ")", i})
+			i++
+		case r == '\'' || r == '"' || r == '`':
+			str, n, err := lexQuoted(rs[i:])
+			if err != nil {
+				return nil, fmt.Errorf("filter position %d: %s", i, err.Error())
+			}
+			kind := tokString
+			if r == '`' {
+				kind = tokColumn
+			}
+			toks = append(toks, filterToken{kind, str, i})
+			i += n
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if i+1 < len(rs) {
+				switch two := string(rs[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "<>":
+					op = two
+				}
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("filter position %d: unexpected '!'", i)
+			}
+			toks = append(toks, filterToken{tokOp, normalizeOp(op), i})
+			i += len(op)
+		case unicode.IsDigit(r) || r == '-' || r == '.':
+			start := i
+			for i++; i < len(rs); i++ {
+				c := rs[i]
+				if !(unicode.IsDigit(c) || c == '.' || c == 'e' || c == 'E' ||
+					(c == '-' || c == '+') && (rs[i-1] == 'e' || rs[i-1] == 'E')) {
+					break
+				}
+			}
+			toks = append(toks, filterToken{tokNumber, string(rs[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			toks = append(toks, filterToken{tokIdent, string(rs[start:i]), start})
+		default:
+			return nil, fmt.Errorf("filter position %d: unexpected %q", i, r)
+		}
+	}
+	return append(toks, filterToken{tokEOF, "", len(rs)}), nil
+}
+
+// normalizeOp maps comparison operator aliases to a single spelling
+func normalizeOp(op string) string {
+	switch op {
+	case "==":
+		return "="
+	case "<>":
+		return "!="
+	}
+	return op
+}
+
+// lexQuoted reads a quoted string, returning its contents & the number of
+// runes consumed. Backslash escapes the next character
+func lexQuoted(rs []rune) (string, int, error) {
+	quote := rs[0]
+	str := []rune{}
+	for i := 1; i < len(rs); i++ {
+		switch rs[i] {
+		case '\\':
+			if i+1 < len(rs) {
+				i++
+				str = append(str, rs[i])
+			}
+		case quote:
+			return string(str), i + 1, nil
+		default:
+			str = append(str, rs[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated %c", quote)
+}
+
+// filterParser is a recursive descent parser for filter expressions
+type filterParser struct {
+	toks []filterToken
+	i    int
+}
+
+// parseFilter parses a filter expression
+func parseFilter(expr string) (filterNode, error) {
+	toks, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{toks: toks}
+	node, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("filter position %d: unexpected %q", tok.pos, tok.text)
+	}
+	return node, nil
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.toks[p.i]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.toks[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+// keyword consumes the next token if it's a case-insensitive keyword
+func (p *filterParser) keyword(kw string) bool {
+	if tok := p.peek(); tok.kind == tokIdent && strings.EqualFold(tok.text, kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) or() (filterNode, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = logicNode{and: false, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) and() (filterNode, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = logicNode{and: true, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) not() (filterNode, error) {
+	if p.keyword("not") {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *filterParser) comparison() (filterNode, error) {
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.keyword("is") {
+		not := p.keyword("not")
+		if !p.keyword("null") {
+			tok := p.peek()
+			return nil, fmt.Errorf("filter position %d: expected null, got %q", tok.pos, tok.text)
+		}
+		return nullNode{x: l, not: not}, nil
+	}
+
+	if tok := p.peek(); tok.kind == tokOp {
+		p.next()
+		r, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{op: tok.text, l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *filterParser) operand() (filterNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if end := p.next(); end.kind != tokRParen {
+			return nil, fmt.Errorf("filter position %d: expected ')'", end.pos)
+		}
+		return x, nil
+	case tokString:
+		return literalNode{vals.String(tok.text)}, nil
+	case tokColumn:
+		return columnNode(tok.text), nil
+	case tokNumber:
+		if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return literalNode{vals.Integer(i)}, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("filter position %d: invalid number %q", tok.pos, tok.text)
+		}
+		return literalNode{vals.Number(f)}, nil
+	case tokIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return literalNode{vals.Boolean(true)}, nil
+		case "false":
+			return literalNode{vals.Boolean(false)}, nil
+		case "null":
+			return literalNode{vals.Null(true)}, nil
+		case "and", "or", "not", "is":
+			return nil, fmt.Errorf("filter position %d: unexpected %q", tok.pos, tok.text)
+		}
+		return columnNode(tok.text), nil
+	case tokEOF:
+		return nil, fmt.Errorf("filter: unexpected end of expression")
+	}
+	return nil, fmt.Errorf("filter position %d: unexpected %q", tok.pos, tok.text)
+}
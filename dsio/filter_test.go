@@ -0,0 +1,94 @@
+package dsio
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qri-io/dataset"
+)
+
+func TestFilterReader(t *testing.T) {
+	data := "city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\nnew york,8500000,44.4,true\nchicago,300000,44.4,true\nchatham,35000,65.25,true\nraleigh,250000,50.65,true\n"
+
+	cases := []struct {
+		expr   string
+		expect []string
+		err    string
+	}{
+		{"true", []string{"toronto", "new york", "chicago", "chatham", "raleigh"}, ""},
+		{"pop > 300000", []string{"toronto", "new york"}, ""},
+		{"pop >= 300000 AND in_usa", []string{"new york", "chicago"}, ""},
+		{"city == 'chatham' or city = \"raleigh\"", []string{"chatham", "raleigh"}, ""},
+		{"not (avg_age < 50)", []string{"toronto", "chatham", "raleigh"}, ""},
+		{"avg_age = 44.4 and pop <> 300000", []string{"new york"}, ""},
+		{"in_usa is null", nil, ""},
+		{"in_usa is not null and not in_usa", []string{"toronto"}, ""},
+		{"in_usa != true", []string{"toronto"}, ""},
+		{"city > 'n'", []string{"toronto", "new york", "raleigh"}, ""},
+		{"pop = 'chicago'", nil, ""},
+		{"`pop` < 1e5", []string{"chatham"}, ""},
+
+		{"country = 'usa'", nil, `column "country" not found`},
+		{"pop >", nil, "filter: unexpected end of expression"},
+		{"(pop > 1", nil, "filter position 8: expected ')'"},
+		{"pop ! 1", nil, "filter position 4: unexpected '!'"},
+		{"city = 'toronto", nil, "filter position 7: unterminated '"},
+		{"pop is 1", nil, `filter position 7: expected null, got "1"`},
+		{"pop 1", nil, `filter position 4: unexpected "1"`},
+	}
+
+	for i, c := range cases {
+		r, err := NewEntryReader(offsetStruct, strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("case %d error creating reader: %s", i, err.Error())
+		}
+		fr, err := NewFilterReader(r, c.expr)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if fr.Structure() != offsetStruct {
+			t.Errorf("case %d expected filter reader to report source structure", i)
+		}
+
+		var got []string
+		err = EachEntry(fr, func(_ int, ent Entry, err error) error {
+			got = append(got, ent.Value.([]interface{})[0].(string))
+			return nil
+		})
+		if err != nil {
+			t.Errorf("case %d error reading: %s", i, err.Error())
+			continue
+		}
+		if strings.Join(got, ",") != strings.Join(c.expect, ",") {
+			t.Errorf("case %d %q result mismatch. expected: %v, got: %v", i, c.expr, c.expect, got)
+		}
+	}
+}
+
+func TestFilterReaderObjects(t *testing.T) {
+	st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaObject}
+	r, err := NewEntryReader(st, strings.NewReader(`{"a":{"n":1},"b":{"n":2.5},"c":{"m":3}}`))
+	if err != nil {
+		t.Fatalf("error creating reader: %s", err.Error())
+	}
+	fr, err := NewFilterReader(r, "n > 1 or m is not null and n is null")
+	if err != nil {
+		t.Fatalf("error creating filter reader: %s", err.Error())
+	}
+
+	keys := map[string]bool{}
+	err = EachEntry(fr, func(_ int, ent Entry, err error) error {
+		keys[ent.Key] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error reading: %s", err.Error())
+	}
+	if len(keys) != 2 || !keys["b"] || !keys["c"] {
+		t.Errorf("expected keys b & c. got: %v", keys)
+	}
+}
@@ -129,7 +129,10 @@ func (r *JSONReader) scanJSONEntry(data []byte, atEOF bool) (advance int, token
 		if skip > 0 {
 			r.scanMode = sm
 			r.initialized = true
-			data = data[skip:]
+			// continue scanning past the opening closure. bufio.Scanner won't call
+			// split again if data arrived with EOF & no token is returned here
+			adv, tok, err := r.scanJSONEntry(data[skip:], atEOF)
+			return skip + adv, tok, err
 		}
 		return skip, nil, nil
 	}
@@ -158,7 +161,12 @@ func (r *JSONReader) scanObjectEntry(data []byte, atEOF bool) (advance int, toke
 	if key == nil || e != nil {
 		return stradv, key, e
 	}
-	r.objKey = string(key)
+	// keys are scanned as raw json strings, including quotes & escapes.
+	// decode them so entry keys match other readers & writers re-encode
+	// them without double-quoting
+	if e := json.Unmarshal(key, &r.objKey); e != nil {
+		return stradv, nil, e
+	}
 
 	vadv, val, e := scanEntry(data[stradv:], atEOF)
 	if val == nil || e != nil {
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
+	"testing/iotest"
 
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/dataset/dstest"
@@ -123,6 +125,77 @@ func TestJSONReader(t *testing.T) {
 	}
 }
 
+func TestJSONReaderObjectKeys(t *testing.T) {
+	st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaObject}
+	cases := []struct {
+		data   string
+		keys   []string
+		output string
+	}{
+		{`{"a": 1, "b": 2}`, []string{"a", "b"}, `{"a":1,"b":2}`},
+		{`{"b \"c\"": 2}`, []string{`b "c"`}, `{"b \"c\"":2}`},
+		{`{"caf\u00e9": true, "tab\t": null}`, []string{"café", "tab\t"}, `{"café":true,"tab\t":null}`},
+	}
+
+	for i, c := range cases {
+		r, err := NewJSONReader(st, bytes.NewBufferString(c.data))
+		if err != nil {
+			t.Fatalf("case %d unexpected error: %s", i, err.Error())
+		}
+		buf := &bytes.Buffer{}
+		w, err := NewJSONWriter(st, buf)
+		if err != nil {
+			t.Fatalf("case %d unexpected error: %s", i, err.Error())
+		}
+
+		keys := []string{}
+		err = EachEntry(r, func(_ int, ent Entry, err error) error {
+			if err != nil {
+				return err
+			}
+			keys = append(keys, ent.Key)
+			return w.WriteEntry(ent)
+		})
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("case %d error closing writer: %s", i, err.Error())
+			continue
+		}
+
+		if !reflect.DeepEqual(c.keys, keys) {
+			t.Errorf("case %d keys mismatch. expected: %q, got: %q", i, c.keys, keys)
+		}
+		// keys read from json are written back unchanged
+		if buf.String() != c.output {
+			t.Errorf("case %d output mismatch. expected: %s, got: %s", i, c.output, buf.String())
+		}
+	}
+}
+
+func TestJSONReaderDataWithEOF(t *testing.T) {
+	// readers like gzip can return the last chunk of data along with io.EOF
+	st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray}
+	r, err := NewJSONReader(st, iotest.DataErrReader(bytes.NewBufferString(`[1,"two",{"three":3}]`)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	count := 0
+	err = EachEntry(r, func(i int, ent Entry, err error) error {
+		count++
+		return err
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if count != 3 {
+		t.Errorf("entry count mismatch. expected: 3, got: %d", count)
+	}
+}
+
 func TestJSONWriter(t *testing.T) {
 	objst := &dataset.Structure{Schema: dataset.BaseSchemaObject}
 	arrst := &dataset.Structure{Schema: dataset.BaseSchemaArray}
@@ -0,0 +1,213 @@
+package dsio
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// EntryConflict is an entry that was changed in incompatible ways by both
+// sides of a merge
+type EntryConflict struct {
+	// Key identifies the entry by primary key value or object key
+	Key string
+	// Field is the title or key of the conflicting field, empty if the
+	// conflict concerns the entire entry
+	Field string
+	// Base, Ours and Theirs are the conflicting values, nil if the entry
+	// doesn't exist on that side
+	Base   interface{}
+	Ours   interface{}
+	Theirs interface{}
+}
+
+// MergeEntries performs a three-way merge of entries changed from a common
+// base by ours and theirs, writing merged entries to w. Entries are matched
+// by the value of a primary key field, or with an empty key by object key.
+// Array data has no identity besides it's values, so merging array entries
+// requires a key. Changes made by only one side are kept, and changes
+// to different fields of the same entry are combined. Changes both sides
+// make differently are reported as conflicts, and resolved in favour of ours.
+// All entries of base, ours and theirs are read into memory before merging,
+// so merges are limited to data that fits in memory three times over.
+// Merged entries are written in the order they're read from ours, followed
+// by entries only theirs adds. MergeEntries doesn't close w
+func MergeEntries(base, ours, theirs EntryReader, key string, w EntryWriter) ([]EntryConflict, error) {
+	if key == "" && arrayData(ours) {
+		return nil, fmt.Errorf("a key is required to merge array entries")
+	}
+	fields := entryFieldNames(ours)
+
+	_, baseEnts, err := readKeyedEntries(base, key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading base entries: %s", err.Error())
+	}
+	oursOrder, oursEnts, err := readKeyedEntries(ours, key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading our entries: %s", err.Error())
+	}
+	theirsOrder, theirsEnts, err := readKeyedEntries(theirs, key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading their entries: %s", err.Error())
+	}
+
+	var conflicts []EntryConflict
+	write := func(ent Entry) error {
+		if err := w.WriteEntry(ent); err != nil {
+			log.Debug(err.Error())
+			return fmt.Errorf("error writing merged entry: %s", err.Error())
+		}
+		return nil
+	}
+
+	for _, k := range oursOrder {
+		o := oursEnts[k]
+		b, inBase := baseEnts[k]
+		t, inTheirs := theirsEnts[k]
+
+		switch {
+		case !inTheirs && !inBase:
+			// added by ours
+		case !inTheirs:
+			// removed by theirs
+			if valuesEqual(b.Value, o.Value) {
+				continue
+			}
+			conflicts = append(conflicts, EntryConflict{Key: k, Base: b.Value, Ours: o.Value})
+		case !inBase:
+			// added by both
+			if !valuesEqual(o.Value, t.Value) {
+				conflicts = append(conflicts, EntryConflict{Key: k, Ours: o.Value, Theirs: t.Value})
+			}
+		default:
+			val, fieldConflicts := mergeValues(b.Value, o.Value, t.Value, fields)
+			for _, c := range fieldConflicts {
+				c.Key = k
+				conflicts = append(conflicts, c)
+			}
+			o.Value = val
+		}
+
+		if err := write(o); err != nil {
+			return conflicts, err
+		}
+	}
+
+	for _, k := range theirsOrder {
+		if _, ok := oursEnts[k]; ok {
+			continue
+		}
+		t := theirsEnts[k]
+		if b, inBase := baseEnts[k]; inBase {
+			// removed by ours
+			if !valuesEqual(b.Value, t.Value) {
+				conflicts = append(conflicts, EntryConflict{Key: k, Base: b.Value, Theirs: t.Value})
+			}
+			continue
+		}
+		if err := write(t); err != nil {
+			return conflicts, err
+		}
+	}
+
+	return conflicts, nil
+}
+
+// readKeyedEntries reads all entries of r, identifying each with a key
+func readKeyedEntries(r EntryReader, key string) ([]string, map[string]Entry, error) {
+	keyFunc := entryKeyFunc(r, key)
+	order := []string{}
+	ents := map[string]Entry{}
+	err := EachEntry(r, func(i int, ent Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		k, err := keyFunc(i, ent)
+		if err != nil {
+			return err
+		}
+		if _, ok := ents[k]; ok {
+			return fmt.Errorf("duplicate key %q", k)
+		}
+		ents[k] = ent
+		order = append(order, k)
+		return nil
+	})
+	if err != nil {
+		log.Debug(err.Error())
+	}
+	return order, ents, err
+}
+
+// mergeValues merges the changes made to a base value by ours and theirs.
+// Arrays of the same length and objects are merged field by field,
+// other values conflict if both sides change them differently
+func mergeValues(base, ours, theirs interface{}, titles []string) (interface{}, []EntryConflict) {
+	switch {
+	case valuesEqual(ours, theirs), valuesEqual(base, theirs):
+		return ours, nil
+	case valuesEqual(base, ours):
+		return theirs, nil
+	}
+
+	switch b := base.(type) {
+	case []interface{}:
+		o, ok := ours.([]interface{})
+		t, tok := theirs.([]interface{})
+		if !ok || !tok || len(o) != len(b) || len(t) != len(b) {
+			break
+		}
+		var conflicts []EntryConflict
+		merged := make([]interface{}, len(b))
+		for i := range b {
+			val, c := mergeValues(b[i], o[i], t[i], nil)
+			if c != nil {
+				field := strconv.Itoa(i)
+				if i < len(titles) && titles[i] != "" {
+					field = titles[i]
+				}
+				conflicts = append(conflicts, EntryConflict{Field: field, Base: b[i], Ours: o[i], Theirs: t[i]})
+			}
+			merged[i] = val
+		}
+		return merged, conflicts
+	case map[string]interface{}:
+		o, ok := ours.(map[string]interface{})
+		t, tok := theirs.(map[string]interface{})
+		if !ok || !tok {
+			break
+		}
+		keys := []string{}
+		for _, m := range []map[string]interface{}{b, o, t} {
+			for k := range m {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		var conflicts []EntryConflict
+		merged := map[string]interface{}{}
+		for i, k := range keys {
+			if i > 0 && keys[i-1] == k {
+				continue
+			}
+			val, c := mergeValues(b[k], o[k], t[k], nil)
+			if c != nil {
+				conflicts = append(conflicts, EntryConflict{Field: k, Base: b[k], Ours: o[k], Theirs: t[k]})
+			}
+			// merge the presence of a key the same way as it's value
+			_, bp := b[k]
+			_, op := o[k]
+			_, tp := t[k]
+			if op == tp || op != bp {
+				tp = op
+			}
+			if tp {
+				merged[k] = val
+			}
+		}
+		return merged, conflicts
+	}
+
+	return ours, []EntryConflict{{Base: base, Ours: ours, Theirs: theirs}}
+}
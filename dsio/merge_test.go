@@ -0,0 +1,118 @@
+package dsio
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/dataset"
+)
+
+func TestMergeEntries(t *testing.T) {
+	citiesSt := &dataset.Structure{
+		Format:       dataset.CSVDataFormat,
+		FormatConfig: &dataset.CSVOptions{HeaderRow: true},
+		Schema:       offsetStruct.Schema,
+	}
+	jsonObjectSt := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaObject}
+
+	cases := []struct {
+		st                 *dataset.Structure
+		base, ours, theirs string
+		key                string
+		expect             string
+		conflicts          []EntryConflict
+		err                string
+	}{
+		// each side changes different fields of toronto, theirs adds boston,
+		// ours removes chicago
+		{citiesSt,
+			"city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\nchicago,300000,44.4,true\n",
+			"city,pop,avg_age,in_usa\ntoronto,40000001,55.5,false\n",
+			"city,pop,avg_age,in_usa\ntoronto,40000000,60,false\nchicago,300000,44.4,true\nboston,700000,36.1,true\n",
+			"city",
+			"city,pop,avg_age,in_usa\ntoronto,40000001,60,false\nboston,700000,36.1,true\n",
+			nil, ""},
+		// both sides change the same field
+		{citiesSt,
+			"city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\n",
+			"city,pop,avg_age,in_usa\ntoronto,40000001,55.5,false\n",
+			"city,pop,avg_age,in_usa\ntoronto,40000002,55.5,false\n",
+			"city",
+			"city,pop,avg_age,in_usa\ntoronto,40000001,55.5,false\n",
+			[]EntryConflict{{Key: "toronto", Field: "pop", Base: int64(40000000), Ours: int64(40000001), Theirs: int64(40000002)}},
+			""},
+		// theirs modifies an entry ours removes
+		{citiesSt,
+			"city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\n",
+			"city,pop,avg_age,in_usa\n",
+			"city,pop,avg_age,in_usa\ntoronto,40000002,55.5,false\n",
+			"city",
+			"city,pop,avg_age,in_usa\n",
+			[]EntryConflict{{Key: "toronto",
+				Base:   []interface{}{"toronto", int64(40000000), 55.5, false},
+				Theirs: []interface{}{"toronto", int64(40000002), 55.5, false}}},
+			""},
+		// object entries are matched by key and merged field by field
+		{jsonObjectSt,
+			`{"a":{"x":1,"y":1},"b":{"x":1}}`,
+			`{"a":{"x":2,"y":1},"b":{"x":1}}`,
+			`{"a":{"x":1},"b":{"x":1},"c":{"x":3}}`,
+			"",
+			`{"a":{"x":2},"b":{"x":1},"c":{"x":3}}`,
+			nil, ""},
+		// array entries can't be matched without a key
+		{citiesSt,
+			"city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\n",
+			"city,pop,avg_age,in_usa\n",
+			"city,pop,avg_age,in_usa\n",
+			"", "", nil,
+			"a key is required to merge array entries"},
+		{citiesSt,
+			"city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\ntoronto,40000000,55.5,false\n",
+			"city,pop,avg_age,in_usa\n",
+			"city,pop,avg_age,in_usa\n",
+			"city", "", nil,
+			`error reading base entries: duplicate key "toronto"`},
+	}
+
+	for i, c := range cases {
+		base, err := NewEntryReader(c.st, bytes.NewBufferString(c.base))
+		if err != nil {
+			t.Fatalf("case %d error creating reader: %s", i, err.Error())
+		}
+		ours, err := NewEntryReader(c.st, bytes.NewBufferString(c.ours))
+		if err != nil {
+			t.Fatalf("case %d error creating reader: %s", i, err.Error())
+		}
+		theirs, err := NewEntryReader(c.st, bytes.NewBufferString(c.theirs))
+		if err != nil {
+			t.Fatalf("case %d error creating reader: %s", i, err.Error())
+		}
+
+		buf := &bytes.Buffer{}
+		w, err := NewEntryWriter(c.st, buf)
+		if err != nil {
+			t.Fatalf("case %d error creating writer: %s", i, err.Error())
+		}
+
+		conflicts, err := MergeEntries(base, ours, theirs, c.key, w)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+			continue
+		}
+		if c.err != "" {
+			continue
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("case %d error closing writer: %s", i, err.Error())
+		}
+
+		if buf.String() != c.expect {
+			t.Errorf("case %d merged data mismatch. expected:\n%s\ngot:\n%s", i, c.expect, buf.String())
+		}
+		if !reflect.DeepEqual(conflicts, c.conflicts) {
+			t.Errorf("case %d conflicts mismatch. expected:\n%#v\ngot:\n%#v", i, c.conflicts, conflicts)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package dsio
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/vals"
+)
+
+// MigrateOpts configures how Migrate converts entries
+type MigrateOpts struct {
+	// Renames maps source column titles or object keys to target titles
+	// or keys
+	Renames map[string]string
+	// Casts sets the type values of a target column are converted to,
+	// overriding the type given by the target schema
+	Casts map[string]vals.Type
+	// Defaults gives values for target columns that are missing or null
+	// in the source
+	Defaults map[string]interface{}
+}
+
+// LossyConversion is a value that couldn't be migrated exactly
+type LossyConversion struct {
+	// Index is the position of the entry in the source
+	Index int
+	// Key is the key of the entry, if the source is an object
+	Key string
+	// Column is the target column title or key
+	Column string
+	// From is the source value, To is the value written
+	From interface{}
+	To   interface{}
+	// Reason describes what was lost
+	Reason string
+}
+
+// MigrationReport summarizes a migration
+type MigrationReport struct {
+	// Entries is the number of entries migrated
+	Entries int
+	// Dropped lists source columns or keys with no target column, in the
+	// order they were first seen
+	Dropped []string
+	// Lossy lists values that weren't converted exactly
+	Lossy []LossyConversion
+}
+
+// Migrate reads all entries from r, converting each to the structure of w
+// & writing it. Source values are matched to target columns by title or
+// key, after applying renames. Values are cast to the type of their target
+// column using vals.Type.Parse, empty strings cast to a non-string type
+// become null. Targets without titled columns or properties keep every
+// source field. r & w may use different data formats, Migrate doesn't
+// close w
+func Migrate(r EntryReader, w EntryWriter, opts ...func(*MigrateOpts)) (*MigrationReport, error) {
+	opt := &MigrateOpts{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	m := newMigrator(r.Structure(), w.Structure(), opt)
+	err := EachEntry(r, func(i int, ent Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		migrated := m.migrate(i, ent)
+		if err := w.WriteEntry(migrated); err != nil {
+			log.Debug(err.Error())
+			return fmt.Errorf("error writing entry %d: %s", i, err.Error())
+		}
+		m.report.Entries++
+		return nil
+	})
+	if err != nil {
+		return m.report, err
+	}
+	return m.report, nil
+}
+
+// migrator holds state for converting entries between structures
+type migrator struct {
+	opt *MigrateOpts
+	// source column titles, for array entries
+	srcTitles []string
+	// target columns & their types. tabular targets write array entries
+	titles  []string
+	types   map[string]vals.Type
+	tabular bool
+	// inverse of renames, target title to source title
+	sources map[string]string
+	dropped map[string]bool
+	report  *MigrationReport
+}
+
+func newMigrator(src, dst *dataset.Structure, opt *MigrateOpts) *migrator {
+	m := &migrator{
+		opt:     opt,
+		types:   map[string]vals.Type{},
+		sources: map[string]string{},
+		dropped: map[string]bool{},
+		report:  &MigrationReport{},
+	}
+
+	if src != nil && src.Schema != nil {
+		m.srcTitles, _, _ = terribleHackToGetHeaderRowAndTypes(src)
+	}
+	if dst != nil && dst.Schema != nil {
+		titles, types, err := terribleHackToGetHeaderRowAndTypes(dst)
+		if err == nil {
+			m.tabular = true
+			m.titles = titles
+			for i, title := range titles {
+				m.types[title] = vals.TypeFromString(types[i])
+			}
+		} else {
+			m.titles, m.types = schemaProperties(dst)
+		}
+	}
+	for title, t := range opt.Casts {
+		m.types[title] = t
+	}
+
+	for from, to := range opt.Renames {
+		m.sources[to] = from
+	}
+	return m
+}
+
+// schemaProperties gives the property keys & types of object entries
+func schemaProperties(st *dataset.Structure) ([]string, map[string]vals.Type) {
+	types := map[string]vals.Type{}
+	data, err := st.Schema.MarshalJSON()
+	if err != nil {
+		return nil, types
+	}
+	sch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &sch); err != nil {
+		return nil, types
+	}
+	items, _ := sch["items"].(map[string]interface{})
+	props, _ := items["properties"].(map[string]interface{})
+
+	keys := make([]string, 0, len(props))
+	for key, p := range props {
+		keys = append(keys, key)
+		if prop, ok := p.(map[string]interface{}); ok {
+			if t, ok := prop["type"].(string); ok {
+				types[key] = vals.TypeFromString(t)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, types
+}
+
+// migrate converts a single entry
+func (m *migrator) migrate(i int, ent Entry) Entry {
+	fields, order := m.fields(ent.Value)
+	if fields == nil {
+		// scalar entries are passed through
+		return ent
+	}
+
+	// targets without known columns keep all fields, renamed
+	titles := m.titles
+	if titles == nil {
+		titles = make([]string, len(order))
+		for j, name := range order {
+			titles[j] = m.target(name)
+		}
+	}
+
+	used := map[string]bool{}
+	values := make([]interface{}, len(titles))
+	for j, title := range titles {
+		src := title
+		if from, ok := m.sources[title]; ok {
+			src = from
+		}
+		v, ok := fields[src]
+		if ok {
+			used[src] = true
+		}
+		if v == nil {
+			if def, ok := m.opt.Defaults[title]; ok {
+				v = def
+			}
+		}
+		values[j] = m.cast(i, ent.Key, title, v)
+	}
+
+	for _, name := range order {
+		if !used[name] && !m.dropped[name] {
+			m.dropped[name] = true
+			m.report.Dropped = append(m.report.Dropped, name)
+		}
+	}
+
+	out := Entry{Index: ent.Index, Key: ent.Key}
+	if m.tabular {
+		out.Value = values
+	} else {
+		obj := make(map[string]interface{}, len(titles))
+		for j, title := range titles {
+			if values[j] != nil || hasField(fields, m.source(title)) {
+				obj[title] = values[j]
+			}
+		}
+		out.Value = obj
+	}
+	return out
+}
+
+// fields gives the named fields of an entry value, in order
+func (m *migrator) fields(v interface{}) (map[string]interface{}, []string) {
+	switch x := v.(type) {
+	case []interface{}:
+		fields := make(map[string]interface{}, len(x))
+		order := make([]string, len(x))
+		for i, val := range x {
+			name := strconv.Itoa(i)
+			if i < len(m.srcTitles) && m.srcTitles[i] != "" {
+				name = m.srcTitles[i]
+			}
+			fields[name] = val
+			order[i] = name
+		}
+		return fields, order
+	case map[string]interface{}:
+		order := make([]string, 0, len(x))
+		for key := range x {
+			order = append(order, key)
+		}
+		sort.Strings(order)
+		return x, order
+	}
+	return nil, nil
+}
+
+// target gives the target name of a source field
+func (m *migrator) target(name string) string {
+	if to, ok := m.opt.Renames[name]; ok {
+		return to
+	}
+	return name
+}
+
+// source gives the source name of a target field
+func (m *migrator) source(title string) string {
+	if from, ok := m.sources[title]; ok {
+		return from
+	}
+	return title
+}
+
+func hasField(fields map[string]interface{}, name string) bool {
+	_, ok := fields[name]
+	return ok
+}
+
+// cast converts a value to the type of a target column, recording lossy
+// conversions
+func (m *migrator) cast(i int, key, title string, v interface{}) interface{} {
+	t, ok := m.types[title]
+	if !ok || t == vals.TypeUnknown || t == vals.TypeNull || v == nil {
+		return v
+	}
+
+	lossy := func(to interface{}, reason string) interface{} {
+		m.report.Lossy = append(m.report.Lossy, LossyConversion{
+			Index:  i,
+			Key:    key,
+			Column: title,
+			From:   v,
+			To:     to,
+			Reason: reason,
+		})
+		return to
+	}
+
+	switch x := v.(type) {
+	case string:
+		if t == vals.TypeString {
+			return x
+		}
+		if x == "" {
+			return nil
+		}
+	case int:
+		v = int64(x)
+	}
+
+	switch t {
+	case vals.TypeString:
+		if str, ok := v.(string); ok {
+			return str
+		}
+		return string(cellBytes(v))
+	case vals.TypeInteger:
+		switch n := v.(type) {
+		case int64:
+			return n
+		case float64:
+			if n != math.Trunc(n) || math.IsInf(n, 0) || math.IsNaN(n) {
+				return lossy(int64(n), "fractional part dropped")
+			}
+			return int64(n)
+		case string:
+			if parsed, err := vals.TypeInteger.Parse([]byte(n)); err == nil {
+				return parsed
+			}
+			if parsed, err := vals.TypeNumber.Parse([]byte(n)); err == nil {
+				f := parsed.(float64)
+				if f != math.Trunc(f) {
+					return lossy(int64(f), "fractional part dropped")
+				}
+				return int64(f)
+			}
+		}
+	case vals.TypeNumber:
+		switch n := v.(type) {
+		case float64:
+			return n
+		case int64:
+			f := float64(n)
+			if int64(f) != n {
+				return lossy(f, "precision lost")
+			}
+			return f
+		}
+	case vals.TypeBoolean:
+		if b, ok := v.(bool); ok {
+			return b
+		}
+	case vals.TypeArray:
+		if arr, ok := v.([]interface{}); ok {
+			return arr
+		}
+	case vals.TypeObject:
+		if obj, ok := v.(map[string]interface{}); ok {
+			return obj
+		}
+	}
+
+	parsed, err := t.Parse(cellBytes(v))
+	if err != nil {
+		return lossy(nil, fmt.Sprintf("can't cast %v to %s", v, t))
+	}
+	return parsed
+}
@@ -0,0 +1,151 @@
+package dsio
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/vals"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestMigrate(t *testing.T) {
+	data := "city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\nchicago,300000,44,true\n"
+	r, err := NewEntryReader(offsetStruct, strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("error creating reader: %s", err.Error())
+	}
+
+	target := &dataset.Structure{
+		Format: dataset.CBORDataFormat,
+		Schema: jsonschema.Must(`{
+			"type": "array",
+			"items": {
+				"type": "array",
+				"items": [
+					{"title": "name", "type": "string"},
+					{"title": "country", "type": "string"},
+					{"title": "pop", "type": "number"},
+					{"title": "avg_age", "type": "integer"}
+				]
+			}
+		}`),
+	}
+	buf, err := NewEntryBuffer(target)
+	if err != nil {
+		t.Fatalf("error allocating buffer: %s", err.Error())
+	}
+
+	report, err := Migrate(r, buf, func(o *MigrateOpts) {
+		o.Renames = map[string]string{"city": "name"}
+		o.Defaults = map[string]interface{}{"country": "unknown"}
+	})
+	if err != nil {
+		t.Fatalf("error migrating: %s", err.Error())
+	}
+	if err := buf.Close(); err != nil {
+		t.Fatalf("error closing buffer: %s", err.Error())
+	}
+
+	if report.Entries != 2 {
+		t.Errorf("expected 2 entries. got: %d", report.Entries)
+	}
+	if !reflect.DeepEqual(report.Dropped, []string{"in_usa"}) {
+		t.Errorf("dropped mismatch. got: %v", report.Dropped)
+	}
+	expectLossy := []LossyConversion{
+		{Index: 0, Column: "avg_age", From: 55.5, To: int64(55), Reason: "fractional part dropped"},
+	}
+	if !reflect.DeepEqual(report.Lossy, expectLossy) {
+		t.Errorf("lossy mismatch. expected: %v, got: %v", expectLossy, report.Lossy)
+	}
+
+	expect := [][]interface{}{
+		{"toronto", "unknown", float64(40000000), int64(55)},
+		{"chicago", "unknown", float64(300000), int64(44)},
+	}
+	i := 0
+	err = EachEntry(buf, func(_ int, ent Entry, err error) error {
+		got, err := vals.ConvertDecoded(ent.Value)
+		if err != nil {
+			return err
+		}
+		want, _ := vals.ConvertDecoded(expect[i])
+		for j := 0; j < want.Len(); j++ {
+			if !valuesEqual(got.Index(j), want.Index(j)) && !vals.Equal(got.Index(j), want.Index(j)) {
+				t.Errorf("entry %d column %d mismatch. expected: %#v, got: %#v", i, j, want.Index(j), got.Index(j))
+			}
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error reading migrated entries: %s", err.Error())
+	}
+	if i != len(expect) {
+		t.Errorf("expected %d migrated entries. got: %d", len(expect), i)
+	}
+}
+
+func TestMigrateObjects(t *testing.T) {
+	src := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaObject}
+	data := `{"a":{"n":"1","flag":"true","extra":1},"b":{"n":"2.5","flag":"maybe"},"c":{"n":"","flag":1}}`
+	r, err := NewEntryReader(src, strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("error creating reader: %s", err.Error())
+	}
+
+	target := &dataset.Structure{
+		Format: dataset.JSONDataFormat,
+		Schema: jsonschema.Must(`{
+			"type": "object",
+			"items": {
+				"type": "object",
+				"properties": {
+					"count": {"type": "integer"},
+					"flag": {"type": "boolean"}
+				}
+			}
+		}`),
+	}
+	out := &bytes.Buffer{}
+	w, err := NewEntryWriter(target, out)
+	if err != nil {
+		t.Fatalf("error creating writer: %s", err.Error())
+	}
+
+	report, err := Migrate(r, w, func(o *MigrateOpts) {
+		o.Renames = map[string]string{"n": "count"}
+		o.Casts = map[string]vals.Type{"flag": vals.TypeString}
+	})
+	if err != nil {
+		t.Fatalf("error migrating: %s", err.Error())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing writer: %s", err.Error())
+	}
+
+	expect := `{"a":{"count":1,"flag":"true"},"b":{"count":2,"flag":"maybe"},"c":{"count":null,"flag":"1"}}`
+	if out.String() != expect {
+		t.Errorf("output mismatch. expected: %s, got: %s", expect, out.String())
+	}
+	if !reflect.DeepEqual(report.Dropped, []string{"extra"}) {
+		t.Errorf("dropped mismatch. got: %v", report.Dropped)
+	}
+	if len(report.Lossy) != 1 || report.Lossy[0].Key != "b" || report.Lossy[0].Reason != "fractional part dropped" {
+		t.Errorf("lossy mismatch. got: %v", report.Lossy)
+	}
+}
+
+func TestMigrateCastFailure(t *testing.T) {
+	m := newMigrator(nil, nil, &MigrateOpts{Casts: map[string]vals.Type{"a": vals.TypeBoolean}})
+	if v := m.cast(3, "", "a", "nope"); v != nil {
+		t.Errorf("expected failed cast to give nil. got: %v", v)
+	}
+	expect := LossyConversion{Index: 3, Column: "a", From: "nope", Reason: "can't cast nope to boolean"}
+	if len(m.report.Lossy) != 1 || m.report.Lossy[0] != expect {
+		t.Errorf("lossy mismatch. expected: %v, got: %v", expect, m.report.Lossy)
+	}
+}
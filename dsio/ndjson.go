@@ -0,0 +1,138 @@
+package dsio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/qri-io/dataset"
+)
+
+// NDJSONReader implements the EntryReader interface for newline-delimited
+// JSON data. Each non-blank line is read as a single array entry, so
+// NDJSON datasets must have a top-level array schema
+type NDJSONReader struct {
+	st        *dataset.Structure
+	rd        *bufio.Reader
+	linesRead int
+}
+
+// NewNDJSONReader creates a reader from a structure and read source
+func NewNDJSONReader(st *dataset.Structure, r io.Reader) (*NDJSONReader, error) {
+	if st.Schema == nil {
+		err := fmt.Errorf("schema required for NDJSON reader")
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	sm, err := schemaScanMode(st.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if sm != smArray {
+		err := fmt.Errorf("invalid schema. root must be an array for NDJSON data")
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	return &NDJSONReader{
+		st: st,
+		rd: bufio.NewReader(r),
+	}, nil
+}
+
+// Structure gives this reader's structure
+func (r *NDJSONReader) Structure() *dataset.Structure {
+	return r.st
+}
+
+// ReadEntry reads one line of JSON from the reader
+func (r *NDJSONReader) ReadEntry() (Entry, error) {
+	for {
+		line, err := r.rd.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			log.Debug(err.Error())
+			return Entry{}, err
+		}
+		r.linesRead++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err == io.EOF {
+				return Entry{}, err
+			}
+			// skip blank lines
+			continue
+		}
+
+		ent := Entry{}
+		if e := json.Unmarshal(line, &ent.Value); e != nil {
+			log.Debug(e.Error())
+			return ent, fmt.Errorf("error decoding line %d: %s", r.linesRead, e.Error())
+		}
+		return ent, nil
+	}
+}
+
+// NDJSONWriter implements the EntryWriter interface for
+// newline-delimited JSON data
+type NDJSONWriter struct {
+	st *dataset.Structure
+	wr *bufio.Writer
+}
+
+// NewNDJSONWriter creates a Writer from a structure and write destination
+func NewNDJSONWriter(st *dataset.Structure, w io.Writer) (*NDJSONWriter, error) {
+	if st.Schema == nil {
+		err := fmt.Errorf("schema required for NDJSON writer")
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	sm, err := schemaScanMode(st.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if sm != smArray {
+		err := fmt.Errorf("invalid schema. root must be an array for NDJSON data")
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	return &NDJSONWriter{
+		st: st,
+		wr: bufio.NewWriter(w),
+	}, nil
+}
+
+// Structure gives this writer's structure
+func (w *NDJSONWriter) Structure() *dataset.Structure {
+	return w.st
+}
+
+// WriteEntry writes one JSON value as a line to the writer
+func (w *NDJSONWriter) WriteEntry(ent Entry) error {
+	data, err := json.Marshal(ent.Value)
+	if err != nil {
+		log.Debug(err.Error())
+		return err
+	}
+
+	if _, err := w.wr.Write(append(data, '\n')); err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error writing entry: %s", err.Error())
+	}
+	return nil
+}
+
+// Close finalizes the writer, indicating no more records
+// will be written
+func (w *NDJSONWriter) Close() error {
+	if err := w.wr.Flush(); err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error closing writer: %s", err.Error())
+	}
+	return nil
+}
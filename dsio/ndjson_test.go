@@ -0,0 +1,128 @@
+package dsio
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+)
+
+const ndjsonData = `{"city":"toronto","pop":40000000,"in_usa":false}
+{"city":"new york","pop":8500000,"in_usa":true}
+
+["chicago",300000,true]
+"raleigh"
+`
+
+func TestNDJSONReader(t *testing.T) {
+	cases := []struct {
+		structure *dataset.Structure
+		data      string
+		entries   []Entry
+		err       string
+	}{
+		{&dataset.Structure{}, "", nil, "schema required for NDJSON reader"},
+		{&dataset.Structure{Schema: jsonschema.Must(`false`)}, "", nil, "invalid schema. root must be either an array or object type"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaObject}, "", nil, "invalid schema. root must be an array for NDJSON data"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray}, "", []Entry{}, ""},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray}, ndjsonData, []Entry{
+			{Value: map[string]interface{}{"city": "toronto", "pop": float64(40000000), "in_usa": false}},
+			{Value: map[string]interface{}{"city": "new york", "pop": float64(8500000), "in_usa": true}},
+			{Value: []interface{}{"chicago", float64(300000), true}},
+			{Value: "raleigh"},
+		}, ""},
+		// no trailing newline
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray}, "1\n2", []Entry{
+			{Value: float64(1)},
+			{Value: float64(2)},
+		}, ""},
+	}
+
+	for i, c := range cases {
+		r, err := NewNDJSONReader(c.structure, bytes.NewBufferString(c.data))
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s. got: %s", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		got := []Entry{}
+		err = EachEntry(r, func(j int, ent Entry, err error) error {
+			got = append(got, ent)
+			return err
+		})
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+
+		if !reflect.DeepEqual(c.entries, got) {
+			t.Errorf("case %d entries mismatch. expected:\n%v\ngot:\n%v", i, c.entries, got)
+		}
+	}
+}
+
+func TestNDJSONReaderInvalidLine(t *testing.T) {
+	r, err := NewNDJSONReader(&dataset.Structure{Schema: dataset.BaseSchemaArray}, bytes.NewBufferString("1\n\n{\"a\":\n"))
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	if _, err := r.ReadEntry(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	expect := "error decoding line 3: unexpected end of JSON input"
+	if _, err := r.ReadEntry(); err == nil || err.Error() != expect {
+		t.Errorf("error mismatch. expected: %s, got: %s", expect, err)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	arrst := &dataset.Structure{Schema: dataset.BaseSchemaArray}
+
+	cases := []struct {
+		structure *dataset.Structure
+		entries   []Entry
+		out       string
+		err       string
+	}{
+		{&dataset.Structure{}, []Entry{}, "", "schema required for NDJSON writer"},
+		{&dataset.Structure{Schema: jsonschema.Must(`true`)}, []Entry{}, "", "invalid schema. root must be either an array or object type"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaObject}, []Entry{}, "", "invalid schema. root must be an array for NDJSON data"},
+
+		{arrst, []Entry{}, "", ""},
+		{arrst, []Entry{{Value: "a"}, {Value: float64(1.5)}, {Value: nil}}, "\"a\"\n1.5\nnull\n", ""},
+		{arrst, []Entry{{Value: map[string]interface{}{"a": []interface{}{1, 2}}}}, "{\"a\":[1,2]}\n", ""},
+	}
+
+	for i, c := range cases {
+		buf := &bytes.Buffer{}
+		w, err := NewNDJSONWriter(c.structure, buf)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s. got: %s", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		for _, ent := range c.entries {
+			if err := w.WriteEntry(ent); err != nil {
+				t.Errorf("case %d WriteEntry error: %s", i, err.Error())
+				break
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("case %d Close error: %s", i, err.Error())
+		}
+
+		if buf.String() != c.out {
+			t.Errorf("case %d result mismatch. expected:\n%s\ngot:\n%s", i, c.out, buf.String())
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package dsio
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
+)
+
+// OffsetIndex records the byte offsets of entries within raw data, allowing
+// reads to start at an entry without scanning all preceding data.
+// Offsets[i] is the byte offset of entry i*Interval
+type OffsetIndex struct {
+	// Interval is the number of entries between indexed offsets
+	Interval int `json:"interval"`
+	// Offsets lists the byte offset of every Interval-th entry
+	Offsets []int64 `json:"offsets"`
+}
+
+// Nearest gives the closest indexed entry at or before entry, and its
+// byte offset
+func (idx *OffsetIndex) Nearest(entry int) (int, int64) {
+	if idx == nil || idx.Interval <= 0 || len(idx.Offsets) == 0 || entry <= 0 {
+		return 0, 0
+	}
+	i := entry / idx.Interval
+	if i >= len(idx.Offsets) {
+		i = len(idx.Offsets) - 1
+	}
+	return i * idx.Interval, idx.Offsets[i]
+}
+
+// CheckOffsetIndexable errors if the data a structure describes can't be
+// offset-indexed. Indexes are supported for uncompressed, utf-8 encoded
+// CSV, JSON and CBOR data. The CSV indexer scans single bytes, so CSV data
+// must use ASCII delimiter, quote & comment characters without lazy quotes
+func CheckOffsetIndexable(st *dataset.Structure) error {
+	switch st.Format {
+	case dataset.CSVDataFormat, dataset.JSONDataFormat, dataset.CBORDataFormat:
+	default:
+		return fmt.Errorf("offset indexes are not supported for %s data", st.Format.String())
+	}
+	if st.Compression != compression.None {
+		return fmt.Errorf("offset indexes are not supported for compressed data")
+	}
+	if textDataFormat(st.Format) {
+		if enc, err := CanonicalEncodingName(st.Encoding); err != nil || enc != "utf-8" {
+			return fmt.Errorf("offset indexes are only supported for utf-8 encoded data")
+		}
+	}
+	if st.Format == dataset.CSVDataFormat {
+		opts := csvOptions(st)
+		if opts.LazyQuotes {
+			return fmt.Errorf("offset indexes are not supported for csv data with lazy quotes")
+		}
+		for _, c := range []rune{opts.Delimiter, opts.Quote, opts.Comment} {
+			if c >= utf8.RuneSelf {
+				return fmt.Errorf("offset indexes are not supported for csv data with non-ascii character %q", c)
+			}
+		}
+	}
+	return nil
+}
+
+// OffsetIndexer builds an OffsetIndex from raw data written to it. Indexers
+// only scan for entry boundaries without decoding values, so they can be
+// attached to a stream that's consumed elsewhere with an io.MultiWriter.
+// Writes never fail, scanning errors are reported by Index
+type OffsetIndexer struct {
+	index   *OffsetIndex
+	pos     int64
+	entries int
+	err     error
+	scan    func(b byte)
+
+	// csv state
+	headerRow bool
+	quote     byte
+	comment   byte
+	delim     byte
+	csvState  int
+
+	// json state
+	depth    int
+	inString bool
+	escaped  bool
+	expect   bool
+
+	// cbor state
+	frames   []cborFrame
+	headLeft int
+	skip     uint64
+	arg      uint64
+	major    byte
+}
+
+// NewOffsetIndexer creates an indexer that records the offset of every
+// interval-th entry of data described by a structure
+func NewOffsetIndexer(st *dataset.Structure, interval int) (*OffsetIndexer, error) {
+	if interval <= 0 {
+		err := fmt.Errorf("offset index interval must be greater than zero")
+		log.Debug(err.Error())
+		return nil, err
+	}
+	if err := CheckOffsetIndexable(st); err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	ix := &OffsetIndexer{index: &OffsetIndex{Interval: interval, Offsets: []int64{}}}
+	switch st.Format {
+	case dataset.CSVDataFormat:
+		opts := csvOptions(st)
+		ix.headerRow = HasHeaderRow(st)
+		ix.quote = csvQuote(opts)
+		ix.comment = byte(opts.Comment)
+		ix.delim = ','
+		if opts.Delimiter != 0 {
+			ix.delim = byte(opts.Delimiter)
+		}
+		ix.scan = ix.scanCSV
+	case dataset.JSONDataFormat:
+		ix.scan = ix.scanJSON
+	case dataset.CBORDataFormat:
+		ix.scan = ix.scanCBOR
+	}
+	return ix, nil
+}
+
+// Write implements the io.Writer interface
+func (ix *OffsetIndexer) Write(p []byte) (int, error) {
+	if ix.err == nil {
+		for _, b := range p {
+			ix.scan(b)
+			ix.pos++
+		}
+	}
+	return len(p), nil
+}
+
+// Index gives the index of all entries scanned so far
+func (ix *OffsetIndexer) Index() (*OffsetIndex, error) {
+	if ix.err != nil {
+		return nil, ix.err
+	}
+	return ix.index, nil
+}
+
+// entryStart records the start of an entry at the current position
+func (ix *OffsetIndexer) entryStart() {
+	if ix.entries%ix.index.Interval == 0 {
+		ix.index.Offsets = append(ix.index.Offsets, ix.pos)
+	}
+	ix.entries++
+}
+
+// csv scanning states
+const (
+	csvRecordStart = iota
+	csvFieldStart
+	csvUnquoted
+	csvQuoted
+	csvQuoteInQuoted
+	csvComment
+)
+
+// scanCSV tracks record boundaries, skipping empty lines, comments & the
+// header row the same way CSVReader does
+func (ix *OffsetIndexer) scanCSV(b byte) {
+	newline := b == '\n' || b == '\r'
+
+	switch ix.csvState {
+	case csvRecordStart:
+		switch {
+		case newline:
+			return
+		case ix.comment != 0 && b == ix.comment:
+			ix.csvState = csvComment
+			return
+		}
+		if ix.headerRow {
+			ix.headerRow = false
+		} else {
+			ix.entryStart()
+		}
+		ix.csvState = csvFieldStart
+		ix.scanCSV(b)
+	case csvComment:
+		if newline {
+			ix.csvState = csvRecordStart
+		}
+	case csvFieldStart, csvUnquoted, csvQuoteInQuoted:
+		switch {
+		case newline:
+			ix.csvState = csvRecordStart
+		case b == ix.delim:
+			ix.csvState = csvFieldStart
+		case b == ix.quote && ix.csvState == csvFieldStart:
+			ix.csvState = csvQuoted
+		case b == ix.quote && ix.csvState == csvQuoteInQuoted:
+			// escaped quote
+			ix.csvState = csvQuoted
+		default:
+			ix.csvState = csvUnquoted
+		}
+	case csvQuoted:
+		if b == ix.quote {
+			ix.csvState = csvQuoteInQuoted
+		}
+	}
+}
+
+// scanJSON tracks the start of each element of the top level array, or each
+// key of the top level object
+func (ix *OffsetIndexer) scanJSON(b byte) {
+	if ix.inString {
+		switch {
+		case ix.escaped:
+			ix.escaped = false
+		case b == '\\':
+			ix.escaped = true
+		case b == '"':
+			ix.inString = false
+		}
+		return
+	}
+
+	if ix.depth < 0 {
+		// the top level closure has ended
+		return
+	} else if ix.depth == 0 {
+		// skip anything preceding the top level closure, including byte order marks
+		if b == '[' || b == '{' {
+			ix.depth = 1
+			ix.expect = true
+		}
+		return
+	}
+
+	switch b {
+	case ' ', '\t', '\n', '\r':
+		return
+	case ',':
+		if ix.depth == 1 {
+			ix.expect = true
+		}
+		return
+	}
+
+	if ix.depth == 1 && ix.expect && b != ']' && b != '}' {
+		ix.entryStart()
+		ix.expect = false
+	}
+
+	switch b {
+	case '"':
+		ix.inString = true
+	case '[', '{':
+		ix.depth++
+	case ']', '}':
+		ix.depth--
+		if ix.depth == 0 {
+			ix.depth = -1
+		}
+	}
+}
+
+// cborFrame tracks items remaining in a cbor container. Indefinite length
+// containers have a remaining count of -1
+type cborFrame struct {
+	remaining int64
+	seen      int64
+	isMap     bool
+}
+
+// scanCBOR tracks the start of each element of the top level array, or each
+// key of the top level map
+func (ix *OffsetIndexer) scanCBOR(b byte) {
+	if ix.skip > 0 {
+		ix.skip--
+		return
+	}
+	if ix.headLeft > 0 {
+		ix.arg = ix.arg<<8 | uint64(b)
+		ix.headLeft--
+		if ix.headLeft == 0 {
+			ix.cborItem(false)
+		}
+		return
+	}
+
+	if b == cborBdBreak {
+		if len(ix.frames) == 0 || ix.frames[len(ix.frames)-1].remaining != -1 {
+			ix.err = fmt.Errorf("error indexing cbor data: unexpected break at offset %d", ix.pos)
+			return
+		}
+		ix.frames = ix.frames[:len(ix.frames)-1]
+		ix.cborComplete()
+		return
+	}
+
+	if len(ix.frames) == 1 && (!ix.frames[0].isMap || ix.frames[0].seen%2 == 0) {
+		ix.entryStart()
+	}
+
+	ix.major = b >> 5
+	info := b & 0x1f
+	switch {
+	case info < 24:
+		ix.arg = uint64(info)
+		ix.cborItem(false)
+	case info <= 27:
+		ix.arg = 0
+		ix.headLeft = 1 << (info - 24)
+	case info == 31:
+		ix.cborItem(true)
+	default:
+		ix.err = fmt.Errorf("error indexing cbor data: invalid byte descriptor 0x%x at offset %d", b, ix.pos)
+	}
+}
+
+// cborItem handles a complete item head
+func (ix *OffsetIndexer) cborItem(indefinite bool) {
+	switch ix.major {
+	case cborMajorBytes, cborMajorText:
+		if indefinite {
+			ix.frames = append(ix.frames, cborFrame{remaining: -1})
+			return
+		}
+		ix.skip = ix.arg
+		ix.cborComplete()
+	case cborMajorArray, cborMajorMap:
+		isMap := ix.major == cborMajorMap
+		n := int64(ix.arg)
+		if isMap {
+			n *= 2
+		}
+		if indefinite {
+			n = -1
+		}
+		if n == 0 {
+			ix.cborComplete()
+			return
+		}
+		ix.frames = append(ix.frames, cborFrame{remaining: n, isMap: isMap})
+	case cborMajorTag:
+		// a tag wraps a single item
+		ix.frames = append(ix.frames, cborFrame{remaining: 1})
+	default:
+		ix.cborComplete()
+	}
+}
+
+// cborComplete marks the end of an item, closing any containers it completes
+func (ix *OffsetIndexer) cborComplete() {
+	for len(ix.frames) > 0 {
+		f := &ix.frames[len(ix.frames)-1]
+		f.seen++
+		if f.remaining == -1 {
+			return
+		}
+		f.remaining--
+		if f.remaining > 0 {
+			return
+		}
+		ix.frames = ix.frames[:len(ix.frames)-1]
+	}
+}
+
+// NewOffsetEntryReader creates an EntryReader for data that starts at an
+// entry offset recorded in an OffsetIndex. Any header row or opening closure
+// is assumed to have been skipped
+func NewOffsetEntryReader(st *dataset.Structure, r io.Reader) (EntryReader, error) {
+	if err := CheckOffsetIndexable(st); err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	switch st.Format {
+	case dataset.CSVDataFormat:
+		cr := NewCSVReader(st, r)
+		cr.readHeader = true
+		return cr, nil
+	case dataset.JSONDataFormat:
+		jr, err := NewJSONReader(st, r)
+		if err != nil {
+			return nil, err
+		}
+		jr.initialized = true
+		return jr, nil
+	default:
+		cr, err := NewCBORReader(st, r)
+		if err != nil {
+			return nil, err
+		}
+		cr.rowsRead = 1
+		cr.readingMap = cr.sm == smObject
+		return cr, nil
+	}
+}
@@ -0,0 +1,225 @@
+package dsio
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestCheckOffsetIndexable(t *testing.T) {
+	cases := []struct {
+		st  *dataset.Structure
+		err string
+	}{
+		{&dataset.Structure{Format: dataset.CSVDataFormat}, ""},
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Encoding: "UTF-8"}, ""},
+		{&dataset.Structure{Format: dataset.CBORDataFormat, Encoding: "latin1"}, ""},
+		{&dataset.Structure{Format: dataset.XMLDataFormat}, "offset indexes are not supported for xml data"},
+		{&dataset.Structure{Format: dataset.CSVDataFormat, Compression: compression.Gzip}, "offset indexes are not supported for compressed data"},
+		{&dataset.Structure{Format: dataset.CSVDataFormat, Encoding: "utf-16"}, "offset indexes are only supported for utf-8 encoded data"},
+		{&dataset.Structure{Format: dataset.CSVDataFormat, FormatConfig: &dataset.CSVOptions{Delimiter: '\t', Comment: '#'}}, ""},
+		{&dataset.Structure{Format: dataset.CSVDataFormat, FormatConfig: &dataset.CSVOptions{Delimiter: '¦'}}, "offset indexes are not supported for csv data with non-ascii character '¦'"},
+		{&dataset.Structure{Format: dataset.CSVDataFormat, FormatConfig: &dataset.CSVOptions{Comment: '§'}}, "offset indexes are not supported for csv data with non-ascii character '§'"},
+		{&dataset.Structure{Format: dataset.CSVDataFormat, FormatConfig: &dataset.CSVOptions{LazyQuotes: true}}, "offset indexes are not supported for csv data with lazy quotes"},
+	}
+
+	for i, c := range cases {
+		err := CheckOffsetIndexable(c.st)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+		}
+	}
+}
+
+func TestOffsetIndexNearest(t *testing.T) {
+	idx := &OffsetIndex{Interval: 10, Offsets: []int64{5, 100, 200}}
+	cases := []struct {
+		idx    *OffsetIndex
+		entry  int
+		start  int
+		offset int64
+	}{
+		{nil, 15, 0, 0},
+		{idx, 0, 0, 0},
+		{idx, 9, 0, 5},
+		{idx, 10, 10, 100},
+		{idx, 25, 20, 200},
+		{idx, 1000, 20, 200},
+	}
+
+	for i, c := range cases {
+		start, offset := c.idx.Nearest(c.entry)
+		if start != c.start || offset != c.offset {
+			t.Errorf("case %d mismatch. expected: (%d, %d), got: (%d, %d)", i, c.start, c.offset, start, offset)
+		}
+	}
+}
+
+func TestOffsetIndexer(t *testing.T) {
+	cases := []struct {
+		st       *dataset.Structure
+		data     string
+		interval int
+		offsets  []int64
+	}{
+		{
+			&dataset.Structure{Format: dataset.CSVDataFormat, Schema: dataset.BaseSchemaArray},
+			"a,b\n\"c\n\",d\r\ne,\"f\"\"\"\n\ng,h",
+			1, []int64{0, 4, 12, 21},
+		},
+		{
+			&dataset.Structure{
+				Format:       dataset.CSVDataFormat,
+				FormatConfig: &dataset.CSVOptions{HeaderRow: true, Comment: '#'},
+				Schema:       dataset.BaseSchemaArray,
+			},
+			"a,b\n# comment\n1,2\n3,4\n5,6\n",
+			2, []int64{14, 22},
+		},
+		{
+			&dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray},
+			"\xef\xbb\xbf[1, \"a,]\\\"\", {\"b\": [2, 3]}, null ]",
+			1, []int64{4, 7, 16, 31},
+		},
+		{
+			&dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaObject},
+			`{"a": 1, "b": {"c": 2}, "d": [3]}`,
+			1, []int64{1, 9, 24},
+		},
+	}
+
+	for i, c := range cases {
+		ix, err := NewOffsetIndexer(c.st, c.interval)
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		// write one byte at a time to exercise state spanning writes
+		for _, b := range []byte(c.data) {
+			ix.Write([]byte{b})
+		}
+		idx, err := ix.Index()
+		if err != nil {
+			t.Errorf("case %d index error: %s", i, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(c.offsets, idx.Offsets) {
+			t.Errorf("case %d offsets mismatch. expected: %v, got: %v", i, c.offsets, idx.Offsets)
+		}
+	}
+}
+
+var offsetStruct = &dataset.Structure{
+	Format:       dataset.CSVDataFormat,
+	FormatConfig: &dataset.CSVOptions{HeaderRow: true},
+	Schema: jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type": "array",
+			"items": [
+				{"title": "city", "type": "string"},
+				{"title": "pop", "type": "integer"},
+				{"title": "avg_age", "type": "number"},
+				{"title": "in_usa", "type": "boolean"}
+			]
+		}
+	}`),
+}
+
+func TestOffsetEntryReader(t *testing.T) {
+	arrayRows := []interface{}{
+		[]interface{}{"toronto", int64(40000000), 55.5, false},
+		[]interface{}{"new york", int64(8500000), 44.4, true},
+		[]interface{}{"chicago", int64(300000), 44.4, true},
+		[]interface{}{"chatham", int64(35000), 65.25, true},
+		[]interface{}{"raleigh", int64(250000), 50.65, true},
+	}
+	objectRows := []Entry{
+		{Key: "a", Value: []interface{}{"toronto"}},
+		{Key: "b", Value: map[string]interface{}{"city": "new york"}},
+		{Key: "c", Value: "chicago"},
+	}
+
+	cases := []struct {
+		st      *dataset.Structure
+		entries []Entry
+	}{
+		{offsetStruct, nil},
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: offsetStruct.Schema}, nil},
+		{&dataset.Structure{Format: dataset.CBORDataFormat, Schema: offsetStruct.Schema}, nil},
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaObject}, objectRows},
+		{&dataset.Structure{Format: dataset.CBORDataFormat, Schema: dataset.BaseSchemaObject}, objectRows},
+	}
+
+	for i, c := range cases {
+		entries := c.entries
+		if entries == nil {
+			for _, row := range arrayRows {
+				entries = append(entries, Entry{Value: row})
+			}
+		}
+
+		buf := &bytes.Buffer{}
+		w, err := NewEntryWriter(c.st, buf)
+		if err != nil {
+			t.Fatalf("case %d writer error: %s", i, err.Error())
+		}
+		for _, ent := range entries {
+			if err := w.WriteEntry(ent); err != nil {
+				t.Fatalf("case %d write error: %s", i, err.Error())
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("case %d close error: %s", i, err.Error())
+		}
+		data := buf.Bytes()
+
+		ix, err := NewOffsetIndexer(c.st, 2)
+		if err != nil {
+			t.Fatalf("case %d indexer error: %s", i, err.Error())
+		}
+		ix.Write(data)
+		idx, err := ix.Index()
+		if err != nil {
+			t.Errorf("case %d index error: %s", i, err.Error())
+			continue
+		}
+		if expect := (len(entries) + 1) / 2; len(idx.Offsets) != expect {
+			t.Errorf("case %d expected %d offsets, got: %v", i, expect, idx.Offsets)
+			continue
+		}
+
+		// the complete data, read normally
+		expect := readAll(t, c.st, data, NewEntryReader)
+		for j, offset := range idx.Offsets {
+			got := readAll(t, c.st, data[offset:], NewOffsetEntryReader)
+			if !reflect.DeepEqual(expect[j*2:], got) {
+				t.Errorf("case %d offset %d entries mismatch. expected:\n%v\ngot:\n%v", i, offset, expect[j*2:], got)
+			}
+		}
+	}
+}
+
+func readAll(t *testing.T, st *dataset.Structure, data []byte, newReader func(*dataset.Structure, io.Reader) (EntryReader, error)) []Entry {
+	r, err := newReader(st, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	entries := []Entry{}
+	err = EachEntry(r, func(i int, ent Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ent)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return entries
+}
@@ -0,0 +1,749 @@
+package dsio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/qri-io/dataset"
+)
+
+// ParquetReader implements the EntryReader interface for Apache Parquet data.
+// Parquet metadata is stored at the end of a file, so the entire source is read
+// into memory on the first call to ReadEntry. Each parquet row is read as an
+// array entry, one row group at a time. Only flat schemas of columns without
+// repeated or nested fields are supported
+type ParquetReader struct {
+	st        *dataset.Structure
+	src       io.Reader
+	opened    bool
+	data      []byte
+	columns   []*parquetColumn
+	rowGroups []interface{}
+	rowGroup  int
+	values    [][]interface{}
+	row       int
+	numRows   int
+}
+
+// NewParquetReader creates a reader from a structure and read source
+func NewParquetReader(st *dataset.Structure, r io.Reader) (*ParquetReader, error) {
+	if st.Schema == nil {
+		err := fmt.Errorf("schema required for Parquet reader")
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	sm, err := schemaScanMode(st.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if sm != smArray {
+		err := fmt.Errorf("invalid schema. root must be an array for Parquet data")
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	return &ParquetReader{
+		st:  st,
+		src: r,
+	}, nil
+}
+
+// Structure gives this reader's structure
+func (r *ParquetReader) Structure() *dataset.Structure {
+	return r.st
+}
+
+// Columns gives the titles and json schema types of each column in the
+// parquet file
+func (r *ParquetReader) Columns() (titles, types []string, err error) {
+	if err = r.open(); err != nil {
+		return nil, nil, err
+	}
+	for _, c := range r.columns {
+		titles = append(titles, c.name)
+		types = append(types, c.jsonType())
+	}
+	return titles, types, nil
+}
+
+// ReadEntry reads one parquet row as an array of column values
+func (r *ParquetReader) ReadEntry() (Entry, error) {
+	if err := r.open(); err != nil {
+		return Entry{}, err
+	}
+
+	for r.row >= r.numRows {
+		if r.rowGroup >= len(r.rowGroups) {
+			return Entry{}, io.EOF
+		}
+		if err := r.readRowGroup(); err != nil {
+			log.Debug(err.Error())
+			return Entry{}, err
+		}
+	}
+
+	row := make([]interface{}, len(r.columns))
+	for i, col := range r.values {
+		row[i] = col[r.row]
+	}
+	r.row++
+	return Entry{Value: row}, nil
+}
+
+// open reads the source & parses file metadata
+func (r *ParquetReader) open() error {
+	if r.opened {
+		return nil
+	}
+	r.opened = true
+
+	data, err := ioutil.ReadAll(r.src)
+	if err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error reading parquet data: %s", err.Error())
+	}
+	r.data = data
+
+	if len(data) < 12 || string(data[:4]) != parquetMagic || string(data[len(data)-4:]) != parquetMagic {
+		err := fmt.Errorf("error reading parquet data: not a parquet file")
+		log.Debug(err.Error())
+		return err
+	}
+
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footerLen > len(data)-12 {
+		err := fmt.Errorf("error reading parquet data: invalid footer length")
+		log.Debug(err.Error())
+		return err
+	}
+	tr := &thriftReader{data: data[len(data)-8-footerLen : len(data)-8]}
+	meta, err := tr.readStruct()
+	if err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error reading parquet metadata: %s", err.Error())
+	}
+
+	if r.columns, err = parquetColumns(meta.list(2)); err != nil {
+		log.Debug(err.Error())
+		return err
+	}
+	r.rowGroups = meta.list(4)
+	return nil
+}
+
+// readRowGroup decodes all column values of the next row group
+func (r *ParquetReader) readRowGroup() error {
+	rg, ok := r.rowGroups[r.rowGroup].(thriftStruct)
+	if !ok {
+		return fmt.Errorf("error reading parquet metadata: invalid row group")
+	}
+	r.rowGroup++
+
+	chunks := rg.list(1)
+	if len(chunks) != len(r.columns) {
+		return fmt.Errorf("error reading parquet data: row group has %d columns, expected %d", len(chunks), len(r.columns))
+	}
+
+	numRows := int(rg.int(3, 0))
+	values := make([][]interface{}, len(r.columns))
+	for i, chunk := range chunks {
+		cc, ok := chunk.(thriftStruct)
+		if !ok || cc.strct(3) == nil {
+			return fmt.Errorf("error reading parquet data: missing metadata for column %q", r.columns[i].name)
+		}
+		vals, err := r.columns[i].readChunk(r.data, cc.strct(3))
+		if err != nil {
+			return fmt.Errorf("error reading column %q: %s", r.columns[i].name, err.Error())
+		}
+		if len(vals) != numRows {
+			return fmt.Errorf("error reading column %q: expected %d values, got %d", r.columns[i].name, numRows, len(vals))
+		}
+		values[i] = vals
+	}
+
+	r.values = values
+	r.numRows = numRows
+	r.row = 0
+	return nil
+}
+
+// ParquetWriter implements the EntryWriter interface for Apache Parquet data.
+// Structures must have a schema describing an array of arrays with titled
+// columns. Column types are mapped from the schema: integers are written as
+// INT64, numbers as DOUBLE, booleans as BOOLEAN, strings as UTF8 byte arrays,
+// and objects & arrays as JSON byte arrays. All columns are optional, nil
+// values are written as nulls. Rows are buffered in memory & written in row
+// groups, the file footer is written by Close
+type ParquetWriter struct {
+	st        *dataset.Structure
+	w         *countingWriter
+	columns   []*parquetColumn
+	codec     int64
+	groupSize int
+	rows      [][]interface{}
+	rowGroups []interface{}
+	numRows   int64
+}
+
+// NewParquetWriter creates a Writer from a structure and write destination
+func NewParquetWriter(st *dataset.Structure, w io.Writer) (*ParquetWriter, error) {
+	if st.Schema == nil {
+		err := fmt.Errorf("schema required for Parquet writer")
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	titles, types, err := terribleHackToGetHeaderRowAndTypes(st)
+	if err != nil {
+		err := fmt.Errorf("invalid schema. parquet data must be an array of arrays with titled columns")
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	columns := make([]*parquetColumn, len(titles))
+	for i, title := range titles {
+		if title == "" {
+			err := fmt.Errorf("invalid schema. column %d has no title", i)
+			log.Debug(err.Error())
+			return nil, err
+		}
+		columns[i] = newParquetColumn(title, types[i])
+	}
+
+	opts := &dataset.ParquetOptions{}
+	if o, ok := st.FormatConfig.(*dataset.ParquetOptions); ok && o != nil {
+		opts = o
+	}
+	codec, err := parquetCodec(opts.Codec)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	groupSize := opts.RowGroupSize
+	if groupSize == 0 {
+		groupSize = defaultParquetRowGroupSize
+	}
+
+	return &ParquetWriter{
+		st:        st,
+		w:         &countingWriter{w: w},
+		columns:   columns,
+		codec:     codec,
+		groupSize: groupSize,
+	}, nil
+}
+
+// Structure gives this writer's structure
+func (w *ParquetWriter) Structure() *dataset.Structure {
+	return w.st
+}
+
+// WriteEntry buffers one row, writing a row group when the buffer is full
+func (w *ParquetWriter) WriteEntry(ent Entry) error {
+	arr, ok := ent.Value.([]interface{})
+	if !ok || len(arr) != len(w.columns) {
+		return fmt.Errorf("expected array value of %d columns to write parquet row. got: %v", len(w.columns), ent.Value)
+	}
+
+	row := make([]interface{}, len(arr))
+	for i, v := range arr {
+		pv, err := w.columns[i].physicalValue(v)
+		if err != nil {
+			log.Debug(err.Error())
+			return err
+		}
+		row[i] = pv
+	}
+	w.rows = append(w.rows, row)
+
+	if len(w.rows) >= w.groupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the file footer. Close must be
+// called for a valid parquet file to be written
+func (w *ParquetWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+
+	schema := []interface{}{
+		[]thriftField{
+			{4, "schema"},
+			{5, int32(len(w.columns))},
+		},
+	}
+	for _, c := range w.columns {
+		schema = append(schema, c.schemaElement())
+	}
+
+	tw := &thriftWriter{}
+	tw.writeStruct([]thriftField{
+		{1, int32(1)},
+		{2, thriftList{thriftStructType, schema}},
+		{3, w.numRows},
+		{4, thriftList{thriftStructType, w.rowGroups}},
+		{6, "github.com/qri-io/dataset"},
+	})
+
+	footer := make([]byte, 4)
+	binary.LittleEndian.PutUint32(footer, uint32(len(tw.buf)))
+	footer = append(append(tw.buf, footer...), parquetMagic...)
+	if _, err := w.w.Write(footer); err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error writing parquet footer: %s", err.Error())
+	}
+	return nil
+}
+
+// writeMagic writes the leading magic number if nothing has been written
+func (w *ParquetWriter) writeMagic() error {
+	if w.w.n > 0 {
+		return nil
+	}
+	if _, err := w.w.Write([]byte(parquetMagic)); err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error writing parquet data: %s", err.Error())
+	}
+	return nil
+}
+
+// flush writes buffered rows as a row group, with one data page per column
+func (w *ParquetWriter) flush() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+
+	var totalSize int64
+	chunks := make([]interface{}, len(w.columns))
+	for i, col := range w.columns {
+		values := make([]interface{}, len(w.rows))
+		for j, row := range w.rows {
+			values[j] = row[i]
+		}
+
+		offset := w.w.n
+		page, uncompressedSize, err := col.dataPage(values, w.codec)
+		if err != nil {
+			log.Debug(err.Error())
+			return fmt.Errorf("error encoding column %q: %s", col.name, err.Error())
+		}
+		if _, err := w.w.Write(page); err != nil {
+			log.Debug(err.Error())
+			return fmt.Errorf("error writing parquet data: %s", err.Error())
+		}
+		totalSize += uncompressedSize
+
+		chunks[i] = []thriftField{
+			{2, offset},
+			{3, []thriftField{
+				{1, int32(col.typ)},
+				{2, thriftList{thriftI32, []interface{}{int32(parquetEncodingPlain), int32(parquetEncodingRLE)}}},
+				{3, thriftList{thriftBinary, []interface{}{col.name}}},
+				{4, int32(w.codec)},
+				{5, int64(len(values))},
+				{6, uncompressedSize},
+				{7, int64(len(page))},
+				{9, offset},
+			}},
+		}
+	}
+
+	w.rowGroups = append(w.rowGroups, []thriftField{
+		{1, thriftList{thriftStructType, chunks}},
+		{2, totalSize},
+		{3, int64(len(w.rows))},
+	})
+	w.numRows += int64(len(w.rows))
+	w.rows = w.rows[:0]
+	return nil
+}
+
+// countingWriter tracks the number of bytes written, parquet metadata
+// records the file offset of each column chunk
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write implements the io.Writer interface
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// parquetPage is a decoded page header & its payload
+type parquetPage struct {
+	header thriftStruct
+	data   []byte
+}
+
+// readChunk decodes all values in a column chunk
+func (c *parquetColumn) readChunk(data []byte, meta thriftStruct) ([]interface{}, error) {
+	start := meta.int(9, 0)
+	if dict := meta.int(11, 0); dict > 0 && dict < start {
+		start = dict
+	}
+	size := meta.int(7, 0)
+	if start < 4 || size < 0 || start+size > int64(len(data)) {
+		return nil, fmt.Errorf("invalid column chunk offset")
+	}
+	codec := meta.int(4, 0)
+	numValues := int(meta.int(5, 0))
+
+	tr := &thriftReader{data: data[start : start+size]}
+	values := make([]interface{}, 0, numValues)
+	var dict []interface{}
+
+	for len(values) < numValues {
+		header, err := tr.readStruct()
+		if err != nil {
+			return nil, fmt.Errorf("error reading page header: %s", err.Error())
+		}
+		pageSize := int(header.int(3, 0))
+		if pageSize < 0 || tr.pos+pageSize > len(tr.data) {
+			return nil, fmt.Errorf("invalid page size: %d", pageSize)
+		}
+		page := parquetPage{header: header, data: tr.data[tr.pos : tr.pos+pageSize]}
+		tr.pos += pageSize
+
+		switch header.int(1, -1) {
+		case parquetPageDictionary:
+			if dict, err = c.readDictionaryPage(page, codec); err != nil {
+				return nil, err
+			}
+		case parquetPageData:
+			vals, err := c.readDataPage(page, codec, dict)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, vals...)
+		case parquetPageDataV2:
+			vals, err := c.readDataPageV2(page, codec, dict)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, vals...)
+		default:
+			// index pages & unknown page types are skipped
+		}
+	}
+
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		val, err := c.value(v)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+	}
+	return values, nil
+}
+
+func (c *parquetColumn) readDictionaryPage(page parquetPage, codec int64) ([]interface{}, error) {
+	dh := page.header.strct(7)
+	if dh == nil {
+		return nil, fmt.Errorf("missing dictionary page header")
+	}
+	data, err := parquetDecompress(codec, page.data, int(page.header.int(2, 0)))
+	if err != nil {
+		return nil, err
+	}
+	vals, _, err := c.plainDecode(data, int(dh.int(1, 0)))
+	return vals, err
+}
+
+func (c *parquetColumn) readDataPage(page parquetPage, codec int64, dict []interface{}) ([]interface{}, error) {
+	dh := page.header.strct(5)
+	if dh == nil {
+		return nil, fmt.Errorf("missing data page header")
+	}
+	data, err := parquetDecompress(codec, page.data, int(page.header.int(2, 0)))
+	if err != nil {
+		return nil, err
+	}
+	numValues := int(dh.int(1, 0))
+
+	var levels []int
+	if c.optional {
+		if dh.int(3, parquetEncodingRLE) != parquetEncodingRLE {
+			return nil, fmt.Errorf("unsupported definition level encoding: %d", dh.int(3, 0))
+		}
+		if len(data) < 4 {
+			return nil, fmt.Errorf("invalid data page")
+		}
+		size := int(binary.LittleEndian.Uint32(data))
+		if size > len(data)-4 {
+			return nil, fmt.Errorf("invalid definition levels length")
+		}
+		if levels, err = rleDecode(data[4:4+size], 1, numValues); err != nil {
+			return nil, err
+		}
+		data = data[4+size:]
+	}
+
+	return c.decodeValues(data, dh.int(2, 0), numValues, levels, dict)
+}
+
+func (c *parquetColumn) readDataPageV2(page parquetPage, codec int64, dict []interface{}) ([]interface{}, error) {
+	dh := page.header.strct(8)
+	if dh == nil {
+		return nil, fmt.Errorf("missing data page header")
+	}
+	numValues := int(dh.int(1, 0))
+	defLen, repLen := int(dh.int(5, 0)), int(dh.int(6, 0))
+	if defLen < 0 || repLen < 0 || defLen+repLen > len(page.data) {
+		return nil, fmt.Errorf("invalid data page")
+	}
+
+	var (
+		levels []int
+		err    error
+	)
+	if c.optional {
+		if levels, err = rleDecode(page.data[repLen:repLen+defLen], 1, numValues); err != nil {
+			return nil, err
+		}
+	}
+
+	// levels are never compressed in v2 data pages
+	data := page.data[repLen+defLen:]
+	if dh.bool(7, true) {
+		if data, err = parquetDecompress(codec, data, int(page.header.int(2, 0))-repLen-defLen); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.decodeValues(data, dh.int(4, 0), numValues, levels, dict)
+}
+
+// decodeValues decodes the values section of a data page, placing nils
+// wherever definition levels mark a null
+func (c *parquetColumn) decodeValues(data []byte, encoding int64, numValues int, levels []int, dict []interface{}) ([]interface{}, error) {
+	nonNull := numValues
+	if levels != nil {
+		nonNull = 0
+		for _, l := range levels {
+			nonNull += l
+		}
+	}
+
+	var (
+		vals []interface{}
+		err  error
+	)
+	switch encoding {
+	case parquetEncodingPlain:
+		vals, _, err = c.plainDecode(data, nonNull)
+	case parquetEncodingPlainDictionary, parquetEncodingRLEDictionary:
+		if dict == nil {
+			return nil, fmt.Errorf("dictionary encoded page without a dictionary")
+		}
+		if len(data) < 1 {
+			return nil, fmt.Errorf("invalid dictionary encoded page")
+		}
+		var idx []int
+		if idx, err = rleDecode(data[1:], int(data[0]), nonNull); err != nil {
+			return nil, err
+		}
+		vals = make([]interface{}, len(idx))
+		for i, j := range idx {
+			if j < 0 || j >= len(dict) {
+				return nil, fmt.Errorf("dictionary index out of range: %d", j)
+			}
+			vals[i] = dict[j]
+		}
+	case parquetEncodingRLE:
+		if c.typ != parquetBoolean {
+			return nil, fmt.Errorf("unsupported encoding for %s values: RLE", parquetTypeNames[c.typ])
+		}
+		if len(data) < 4 {
+			return nil, fmt.Errorf("invalid RLE encoded page")
+		}
+		size := int(binary.LittleEndian.Uint32(data))
+		if size > len(data)-4 {
+			return nil, fmt.Errorf("invalid RLE encoded page")
+		}
+		var bits []int
+		if bits, err = rleDecode(data[4:4+size], 1, nonNull); err != nil {
+			return nil, err
+		}
+		vals = make([]interface{}, len(bits))
+		for i, b := range bits {
+			vals[i] = b == 1
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %d", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) != nonNull {
+		return nil, fmt.Errorf("expected %d values, got %d", nonNull, len(vals))
+	}
+
+	if levels == nil {
+		return vals, nil
+	}
+	values := make([]interface{}, numValues)
+	j := 0
+	for i, l := range levels {
+		if l == 1 {
+			values[i] = vals[j]
+			j++
+		}
+	}
+	return values, nil
+}
+
+// dataPage encodes values as a v1 data page with PLAIN encoding, returning
+// the page including its header & the uncompressed size of the page
+func (c *parquetColumn) dataPage(values []interface{}, codec int64) ([]byte, int64, error) {
+	levels := make([]int, len(values))
+	nonNull := make([]interface{}, 0, len(values))
+	for i, v := range values {
+		if v != nil {
+			levels[i] = 1
+			nonNull = append(nonNull, v)
+		}
+	}
+
+	rle := rleEncode(levels, 1)
+	buf := make([]byte, 4, 4+len(rle))
+	binary.LittleEndian.PutUint32(buf, uint32(len(rle)))
+	buf = append(buf, rle...)
+	buf = append(buf, c.plainEncode(nonNull)...)
+
+	compressed, err := parquetCompress(codec, buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tw := &thriftWriter{}
+	tw.writeStruct([]thriftField{
+		{1, int32(parquetPageData)},
+		{2, int32(len(buf))},
+		{3, int32(len(compressed))},
+		{5, []thriftField{
+			{1, int32(len(values))},
+			{2, int32(parquetEncodingPlain)},
+			{3, int32(parquetEncodingRLE)},
+			{4, int32(parquetEncodingRLE)},
+		}},
+	})
+
+	uncompressedSize := int64(len(tw.buf) + len(buf))
+	return append(tw.buf, compressed...), uncompressedSize, nil
+}
+
+// physicalValue converts an entry value to the go type used to encode
+// values of the column's physical type
+func (c *parquetColumn) physicalValue(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch c.typ {
+	case parquetBoolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case parquetInt64:
+		switch n := v.(type) {
+		case int:
+			return int64(n), nil
+		case int32:
+			return int64(n), nil
+		case int64:
+			return n, nil
+		case float64:
+			if n == float64(int64(n)) {
+				return int64(n), nil
+			}
+		}
+	case parquetDouble:
+		switch n := v.(type) {
+		case float64:
+			return n, nil
+		case float32:
+			return float64(n), nil
+		case int:
+			return float64(n), nil
+		case int64:
+			return float64(n), nil
+		}
+	case parquetByteArray:
+		if c.kind == parquetKindJSON {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for column %q: %s", c.name, err.Error())
+			}
+			return data, nil
+		}
+		switch s := v.(type) {
+		case string:
+			return []byte(s), nil
+		case []byte:
+			return s, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid value for %s column %q: %v", parquetTypeNames[c.typ], c.name, v)
+}
+
+// parquetCompress compresses page data with a parquet compression codec
+func parquetCompress(codec int64, data []byte) ([]byte, error) {
+	if codec == parquetCodecUncompressed {
+		return data, nil
+	}
+
+	buf := &bytes.Buffer{}
+	cw, err := parquetCodecWriter(codec, buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parquetDecompress decompresses page data compressed with a parquet
+// compression codec
+func parquetDecompress(codec int64, data []byte, size int) ([]byte, error) {
+	if codec == parquetCodecUncompressed {
+		return data, nil
+	}
+
+	r, err := parquetCodecReader(codec, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing page: %s", err.Error())
+	}
+	if len(out) != size {
+		return nil, fmt.Errorf("error decompressing page: expected %d bytes, got %d", size, len(out))
+	}
+	return out, nil
+}
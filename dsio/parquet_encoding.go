@@ -0,0 +1,585 @@
+package dsio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/qri-io/dataset/compression"
+)
+
+// parquetMagic starts & ends every parquet file
+const parquetMagic = "PAR1"
+
+// defaultParquetRowGroupSize is the number of rows written per row group
+// when ParquetOptions doesn't specify one
+const defaultParquetRowGroupSize = 10000
+
+// parquet physical types
+const (
+	parquetBoolean           int64 = 0
+	parquetInt32             int64 = 1
+	parquetInt64             int64 = 2
+	parquetInt96             int64 = 3
+	parquetFloat             int64 = 4
+	parquetDouble            int64 = 5
+	parquetByteArray         int64 = 6
+	parquetFixedLenByteArray int64 = 7
+)
+
+var parquetTypeNames = map[int64]string{
+	parquetBoolean:           "BOOLEAN",
+	parquetInt32:             "INT32",
+	parquetInt64:             "INT64",
+	parquetInt96:             "INT96",
+	parquetFloat:             "FLOAT",
+	parquetDouble:            "DOUBLE",
+	parquetByteArray:         "BYTE_ARRAY",
+	parquetFixedLenByteArray: "FIXED_LEN_BYTE_ARRAY",
+}
+
+// parquet field repetition types
+const (
+	parquetRequired int64 = 0
+	parquetOptional int64 = 1
+	parquetRepeated int64 = 2
+)
+
+// parquet converted types
+const (
+	parquetConvertedUTF8            int64 = 0
+	parquetConvertedEnum            int64 = 4
+	parquetConvertedDecimal         int64 = 5
+	parquetConvertedDate            int64 = 6
+	parquetConvertedTimestampMillis int64 = 9
+	parquetConvertedTimestampMicros int64 = 10
+	parquetConvertedJSON            int64 = 19
+)
+
+// parquet logical type union field ids
+const (
+	parquetLogicalString    int16 = 1
+	parquetLogicalEnum      int16 = 4
+	parquetLogicalDecimal   int16 = 5
+	parquetLogicalDate      int16 = 6
+	parquetLogicalTimestamp int16 = 8
+	parquetLogicalJSON      int16 = 12
+)
+
+// parquet value encodings
+const (
+	parquetEncodingPlain           int64 = 0
+	parquetEncodingPlainDictionary int64 = 2
+	parquetEncodingRLE             int64 = 3
+	parquetEncodingRLEDictionary   int64 = 8
+)
+
+// parquet page types
+const (
+	parquetPageData       int64 = 0
+	parquetPageDictionary int64 = 2
+	parquetPageDataV2     int64 = 3
+)
+
+// parquet compression codecs
+const (
+	parquetCodecUncompressed int64 = 0
+	parquetCodecSnappy       int64 = 1
+	parquetCodecGzip         int64 = 2
+	parquetCodecZstd         int64 = 6
+)
+
+var parquetCodecs = map[string]int64{
+	"uncompressed": parquetCodecUncompressed,
+	"snappy":       parquetCodecSnappy,
+	"gzip":         parquetCodecGzip,
+	"zstd":         parquetCodecZstd,
+}
+
+// parquetCodec gives the codec for a ParquetOptions codec name, defaulting
+// to snappy
+func parquetCodec(name string) (int64, error) {
+	if name == "" {
+		return parquetCodecSnappy, nil
+	}
+	codec, ok := parquetCodecs[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported parquet codec: %q", name)
+	}
+	return codec, nil
+}
+
+// parquet values annotated with a logical type are converted on read
+const (
+	parquetKindPlain = iota
+	parquetKindString
+	parquetKindJSON
+	parquetKindDecimal
+	parquetKindDate
+	parquetKindTimestampMillis
+	parquetKindTimestampMicros
+	parquetKindTimestampNanos
+)
+
+// parquetColumn describes a single leaf column of a flat parquet schema
+type parquetColumn struct {
+	name     string
+	typ      int64
+	typeLen  int
+	optional bool
+	kind     int
+	scale    int
+}
+
+// newParquetColumn creates a column for writing from a json schema type
+func newParquetColumn(name, jsonType string) *parquetColumn {
+	c := &parquetColumn{name: name, optional: true}
+	switch jsonType {
+	case "integer":
+		c.typ = parquetInt64
+	case "number":
+		c.typ = parquetDouble
+	case "boolean":
+		c.typ = parquetBoolean
+	case "object", "array":
+		c.typ, c.kind = parquetByteArray, parquetKindJSON
+	default:
+		c.typ, c.kind = parquetByteArray, parquetKindString
+	}
+	return c
+}
+
+// parquetColumns reads columns from file metadata schema elements. The first
+// element is the schema root, all others must be non-repeated leaf fields
+func parquetColumns(elements []interface{}) ([]*parquetColumn, error) {
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("error reading parquet metadata: missing schema")
+	}
+
+	columns := make([]*parquetColumn, 0, len(elements)-1)
+	for _, el := range elements[1:] {
+		se, ok := el.(thriftStruct)
+		if !ok {
+			return nil, fmt.Errorf("error reading parquet metadata: invalid schema element")
+		}
+		name := se.str(4)
+		if se.int(5, 0) > 0 {
+			return nil, fmt.Errorf("unsupported parquet schema: nested column %q", name)
+		}
+		rep := se.int(3, parquetRequired)
+		if rep == parquetRepeated {
+			return nil, fmt.Errorf("unsupported parquet schema: repeated column %q", name)
+		}
+
+		c := &parquetColumn{
+			name:     name,
+			typ:      se.int(1, parquetByteArray),
+			typeLen:  int(se.int(2, 0)),
+			optional: rep == parquetOptional,
+			scale:    int(se.int(7, 0)),
+		}
+		if c.typ == parquetInt96 {
+			// legacy INT96 values are always nanosecond timestamps
+			c.kind = parquetKindTimestampNanos
+		}
+
+		switch se.int(6, -1) {
+		case parquetConvertedUTF8, parquetConvertedEnum:
+			c.kind = parquetKindString
+		case parquetConvertedJSON:
+			c.kind = parquetKindJSON
+		case parquetConvertedDecimal:
+			c.kind = parquetKindDecimal
+		case parquetConvertedDate:
+			c.kind = parquetKindDate
+		case parquetConvertedTimestampMillis:
+			c.kind = parquetKindTimestampMillis
+		case parquetConvertedTimestampMicros:
+			c.kind = parquetKindTimestampMicros
+		}
+
+		// logical types supersede converted types
+		if lt := se.strct(10); lt != nil {
+			switch {
+			case lt.strct(parquetLogicalString) != nil, lt.strct(parquetLogicalEnum) != nil:
+				c.kind = parquetKindString
+			case lt.strct(parquetLogicalJSON) != nil:
+				c.kind = parquetKindJSON
+			case lt.strct(parquetLogicalDecimal) != nil:
+				c.kind = parquetKindDecimal
+				c.scale = int(lt.strct(parquetLogicalDecimal).int(1, 0))
+			case lt.strct(parquetLogicalDate) != nil:
+				c.kind = parquetKindDate
+			case lt.strct(parquetLogicalTimestamp) != nil:
+				unit := lt.strct(parquetLogicalTimestamp).strct(2)
+				switch {
+				case unit == nil || unit.strct(1) != nil:
+					c.kind = parquetKindTimestampMillis
+				case unit.strct(2) != nil:
+					c.kind = parquetKindTimestampMicros
+				default:
+					c.kind = parquetKindTimestampNanos
+				}
+			}
+		}
+
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// jsonType gives the json schema type of values read from the column
+func (c *parquetColumn) jsonType() string {
+	switch c.kind {
+	case parquetKindString, parquetKindDate, parquetKindTimestampMillis, parquetKindTimestampMicros, parquetKindTimestampNanos:
+		return "string"
+	case parquetKindJSON:
+		return "object"
+	case parquetKindDecimal:
+		return "number"
+	}
+
+	switch c.typ {
+	case parquetBoolean:
+		return "boolean"
+	case parquetInt32, parquetInt64:
+		return "integer"
+	case parquetFloat, parquetDouble:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// schemaElement gives the file metadata schema element for a column
+func (c *parquetColumn) schemaElement() []thriftField {
+	fields := []thriftField{
+		{1, int32(c.typ)},
+		{3, int32(parquetOptional)},
+		{4, c.name},
+	}
+	switch c.kind {
+	case parquetKindString:
+		fields = append(fields,
+			thriftField{6, int32(parquetConvertedUTF8)},
+			thriftField{10, []thriftField{{parquetLogicalString, []thriftField{}}}},
+		)
+	case parquetKindJSON:
+		fields = append(fields,
+			thriftField{6, int32(parquetConvertedJSON)},
+			thriftField{10, []thriftField{{parquetLogicalJSON, []thriftField{}}}},
+		)
+	}
+	return fields
+}
+
+// value converts a decoded physical value to an entry value
+func (c *parquetColumn) value(v interface{}) (interface{}, error) {
+	switch c.kind {
+	case parquetKindString:
+		if b, ok := v.([]byte); ok {
+			return string(b), nil
+		}
+	case parquetKindJSON:
+		if b, ok := v.([]byte); ok {
+			var val interface{}
+			if err := json.Unmarshal(b, &val); err != nil {
+				return nil, fmt.Errorf("invalid JSON value: %s", err.Error())
+			}
+			return val, nil
+		}
+	case parquetKindDecimal:
+		var unscaled *big.Int
+		switch n := v.(type) {
+		case int64:
+			unscaled = big.NewInt(n)
+		case []byte:
+			// big-endian two's complement
+			unscaled = new(big.Int).SetBytes(n)
+			if len(n) > 0 && n[0]&0x80 != 0 {
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(n)*8)))
+			}
+		}
+		if unscaled != nil {
+			f, _ := new(big.Float).Quo(new(big.Float).SetInt(unscaled), big.NewFloat(math.Pow10(c.scale))).Float64()
+			return f, nil
+		}
+	case parquetKindDate:
+		if n, ok := v.(int64); ok {
+			return time.Unix(n*86400, 0).UTC().Format("2006-01-02"), nil
+		}
+	case parquetKindTimestampMillis:
+		if n, ok := v.(int64); ok {
+			return time.Unix(0, n*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano), nil
+		}
+	case parquetKindTimestampMicros:
+		if n, ok := v.(int64); ok {
+			return time.Unix(0, n*int64(time.Microsecond)).UTC().Format(time.RFC3339Nano), nil
+		}
+	case parquetKindTimestampNanos:
+		switch n := v.(type) {
+		case int64:
+			return time.Unix(0, n).UTC().Format(time.RFC3339Nano), nil
+		case [12]byte:
+			// INT96 timestamps are nanoseconds of the day followed by a julian day
+			nanos := int64(binary.LittleEndian.Uint64(n[:8]))
+			days := int64(binary.LittleEndian.Uint32(n[8:])) - 2440588
+			return time.Unix(days*86400, nanos).UTC().Format(time.RFC3339Nano), nil
+		}
+	}
+
+	switch n := v.(type) {
+	case []byte:
+		return string(n), nil
+	case [12]byte:
+		return string(n[:]), nil
+	default:
+		return v, nil
+	}
+}
+
+// plainDecode decodes n PLAIN encoded values, giving the values & number of
+// bytes consumed. INT32 values are widened to int64, FLOAT to float64
+func (c *parquetColumn) plainDecode(data []byte, n int) ([]interface{}, int, error) {
+	if n < 0 {
+		return nil, 0, fmt.Errorf("invalid value count: %d", n)
+	}
+
+	vals := make([]interface{}, n)
+	pos := 0
+	need := func(size int) error {
+		if size < 0 || pos+size > len(data) {
+			return fmt.Errorf("unexpected end of %s data", parquetTypeNames[c.typ])
+		}
+		return nil
+	}
+
+	for i := range vals {
+		switch c.typ {
+		case parquetBoolean:
+			if i/8 >= len(data) {
+				return nil, 0, fmt.Errorf("unexpected end of BOOLEAN data")
+			}
+			vals[i] = data[i/8]>>(uint(i)%8)&1 == 1
+			pos = i/8 + 1
+		case parquetInt32:
+			if err := need(4); err != nil {
+				return nil, 0, err
+			}
+			vals[i] = int64(int32(binary.LittleEndian.Uint32(data[pos:])))
+			pos += 4
+		case parquetInt64:
+			if err := need(8); err != nil {
+				return nil, 0, err
+			}
+			vals[i] = int64(binary.LittleEndian.Uint64(data[pos:]))
+			pos += 8
+		case parquetInt96:
+			if err := need(12); err != nil {
+				return nil, 0, err
+			}
+			var v [12]byte
+			copy(v[:], data[pos:])
+			vals[i] = v
+			pos += 12
+		case parquetFloat:
+			if err := need(4); err != nil {
+				return nil, 0, err
+			}
+			vals[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[pos:])))
+			pos += 4
+		case parquetDouble:
+			if err := need(8); err != nil {
+				return nil, 0, err
+			}
+			vals[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[pos:]))
+			pos += 8
+		case parquetByteArray:
+			if err := need(4); err != nil {
+				return nil, 0, err
+			}
+			size := int(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+			if err := need(size); err != nil {
+				return nil, 0, err
+			}
+			vals[i] = data[pos : pos+size]
+			pos += size
+		case parquetFixedLenByteArray:
+			if err := need(c.typeLen); err != nil {
+				return nil, 0, err
+			}
+			vals[i] = data[pos : pos+c.typeLen]
+			pos += c.typeLen
+		default:
+			return nil, 0, fmt.Errorf("unsupported parquet type: %d", c.typ)
+		}
+	}
+	return vals, pos, nil
+}
+
+// plainEncode PLAIN encodes values of the column's physical type
+func (c *parquetColumn) plainEncode(vals []interface{}) []byte {
+	var buf []byte
+	b8 := make([]byte, 8)
+	for i, v := range vals {
+		switch v := v.(type) {
+		case bool:
+			if i%8 == 0 {
+				buf = append(buf, 0)
+			}
+			if v {
+				buf[len(buf)-1] |= 1 << (uint(i) % 8)
+			}
+		case int64:
+			binary.LittleEndian.PutUint64(b8, uint64(v))
+			buf = append(buf, b8...)
+		case float64:
+			binary.LittleEndian.PutUint64(b8, math.Float64bits(v))
+			buf = append(buf, b8...)
+		case []byte:
+			binary.LittleEndian.PutUint32(b8, uint32(len(v)))
+			buf = append(buf, b8[:4]...)
+			buf = append(buf, v...)
+		}
+	}
+	return buf
+}
+
+// rleDecode reads up to n values from the RLE / bit-packing hybrid encoding
+func rleDecode(data []byte, bitWidth, n int) ([]int, error) {
+	if bitWidth < 0 || bitWidth > 32 {
+		return nil, fmt.Errorf("invalid bit width: %d", bitWidth)
+	}
+
+	vals := make([]int, 0, n)
+	byteWidth := (bitWidth + 7) / 8
+	pos := 0
+	for len(vals) < n {
+		header, size := binary.Uvarint(data[pos:])
+		if size <= 0 {
+			return nil, fmt.Errorf("invalid RLE data")
+		}
+		pos += size
+
+		if header&1 == 0 {
+			// rle run: a repeat count & single value
+			count := int(header >> 1)
+			if pos+byteWidth > len(data) {
+				return nil, fmt.Errorf("unexpected end of RLE data")
+			}
+			var v int
+			for i := 0; i < byteWidth; i++ {
+				v |= int(data[pos+i]) << (8 * uint(i))
+			}
+			pos += byteWidth
+			for i := 0; i < count && len(vals) < n; i++ {
+				vals = append(vals, v)
+			}
+			continue
+		}
+
+		// bit-packed run: groups of 8 values packed LSB first
+		count := int(header>>1) * 8
+		size = int(header>>1) * bitWidth
+		if pos+size > len(data) {
+			// the final run may be truncated when it holds more values than needed
+			size = len(data) - pos
+		}
+		packed := data[pos : pos+size]
+		pos += size
+		for i := 0; i < count && len(vals) < n; i++ {
+			var v int
+			for b := 0; b < bitWidth; b++ {
+				bit := i*bitWidth + b
+				if bit/8 >= len(packed) {
+					return nil, fmt.Errorf("unexpected end of RLE data")
+				}
+				v |= int(packed[bit/8]>>(uint(bit)%8)&1) << uint(b)
+			}
+			vals = append(vals, v)
+		}
+	}
+	return vals, nil
+}
+
+// rleEncode writes values with the RLE / bit-packing hybrid encoding, using
+// only rle runs
+func rleEncode(vals []int, bitWidth int) []byte {
+	var buf []byte
+	byteWidth := (bitWidth + 7) / 8
+	hdr := make([]byte, binary.MaxVarintLen64)
+	for i := 0; i < len(vals); {
+		j := i
+		for j < len(vals) && vals[j] == vals[i] {
+			j++
+		}
+		buf = append(buf, hdr[:binary.PutUvarint(hdr, uint64(j-i)<<1)]...)
+		for b := 0; b < byteWidth; b++ {
+			buf = append(buf, byte(vals[i]>>(8*uint(b))))
+		}
+		i = j
+	}
+	return buf
+}
+
+// parquetCodecReader wraps r in a reader that decompresses a page
+func parquetCodecReader(codec int64, r io.Reader) (io.Reader, error) {
+	switch codec {
+	case parquetCodecSnappy:
+		// parquet uses snappy's block format, without stream framing
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		dec, err := snappy.Decode(nil, data)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing page: %s", err.Error())
+		}
+		return bytes.NewReader(dec), nil
+	case parquetCodecGzip:
+		return compression.NewReader(compression.Gzip, r)
+	case parquetCodecZstd:
+		return compression.NewReader(compression.Zstd, r)
+	default:
+		return nil, fmt.Errorf("unsupported parquet compression codec: %d", codec)
+	}
+}
+
+// parquetCodecWriter wraps w in a writer that compresses a page
+func parquetCodecWriter(codec int64, w io.Writer) (io.WriteCloser, error) {
+	switch codec {
+	case parquetCodecSnappy:
+		return &snappyBlockWriter{w: w}, nil
+	case parquetCodecGzip:
+		return compression.NewWriter(compression.Gzip, w)
+	case parquetCodecZstd:
+		return compression.NewWriter(compression.Zstd, w)
+	default:
+		return nil, fmt.Errorf("unsupported parquet compression codec: %d", codec)
+	}
+}
+
+// snappyBlockWriter buffers writes, encoding a single snappy block on close
+type snappyBlockWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+// Write implements the io.Writer interface
+func (s *snappyBlockWriter) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+// Close encodes & writes buffered data
+func (s *snappyBlockWriter) Close() error {
+	_, err := s.w.Write(snappy.Encode(nil, s.buf.Bytes()))
+	return err
+}
@@ -0,0 +1,378 @@
+package dsio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+)
+
+var parquetStructure = &dataset.Structure{
+	Format: dataset.ParquetDataFormat,
+	Schema: jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type": "array",
+			"items": [
+				{"title": "city", "type": "string"},
+				{"title": "pop", "type": "integer"},
+				{"title": "avg_age", "type": "number"},
+				{"title": "in_usa", "type": "boolean"},
+				{"title": "meta", "type": "object"}
+			]
+		}
+	}`),
+}
+
+var parquetRows = [][]interface{}{
+	{"toronto", int64(40000000), 55.5, false, map[string]interface{}{"a": float64(1)}},
+	{"new york", int64(8500000), 44.4, true, nil},
+	{"chicago", nil, 44.4, true, []interface{}{"b"}},
+	{nil, int64(40000000), nil, nil, nil},
+	{"", int64(0), float64(0), false, "c"},
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	cases := []struct {
+		opts *dataset.ParquetOptions
+	}{
+		{nil},
+		{&dataset.ParquetOptions{Codec: "uncompressed"}},
+		{&dataset.ParquetOptions{Codec: "gzip", RowGroupSize: 2}},
+		{&dataset.ParquetOptions{Codec: "zstd", RowGroupSize: 1}},
+		{&dataset.ParquetOptions{Codec: "snappy", RowGroupSize: 5}},
+	}
+
+	for i, c := range cases {
+		st := &dataset.Structure{
+			Format:       dataset.ParquetDataFormat,
+			FormatConfig: c.opts,
+			Schema:       parquetStructure.Schema,
+		}
+
+		buf := &bytes.Buffer{}
+		w, err := NewEntryWriter(st, buf)
+		if err != nil {
+			t.Errorf("case %d writer error: %s", i, err.Error())
+			continue
+		}
+		for _, row := range parquetRows {
+			if err := w.WriteEntry(Entry{Value: row}); err != nil {
+				t.Errorf("case %d write error: %s", i, err.Error())
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("case %d close error: %s", i, err.Error())
+			continue
+		}
+
+		r, err := NewEntryReader(st, buf)
+		if err != nil {
+			t.Errorf("case %d reader error: %s", i, err.Error())
+			continue
+		}
+		got := [][]interface{}{}
+		err = EachEntry(r, func(j int, ent Entry, err error) error {
+			if err != nil {
+				return err
+			}
+			got = append(got, ent.Value.([]interface{}))
+			return nil
+		})
+		if err != nil {
+			t.Errorf("case %d read error: %s", i, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(parquetRows, got) {
+			t.Errorf("case %d rows mismatch. expected:\n%v\ngot:\n%v", i, parquetRows, got)
+		}
+	}
+}
+
+func TestParquetReaderColumns(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewParquetWriter(parquetStructure, buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	r, err := NewParquetReader(parquetStructure, buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	titles, types, err := r.Columns()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectTitles := []string{"city", "pop", "avg_age", "in_usa", "meta"}
+	expectTypes := []string{"string", "integer", "number", "boolean", "object"}
+	if !reflect.DeepEqual(expectTitles, titles) {
+		t.Errorf("titles mismatch. expected: %v, got: %v", expectTitles, titles)
+	}
+	if !reflect.DeepEqual(expectTypes, types) {
+		t.Errorf("types mismatch. expected: %v, got: %v", expectTypes, types)
+	}
+	if _, err := r.ReadEntry(); err == nil || err.Error() != "EOF" {
+		t.Errorf("expected EOF reading empty file, got: %v", err)
+	}
+}
+
+func TestParquetReaderErrors(t *testing.T) {
+	cases := []struct {
+		structure *dataset.Structure
+		data      string
+		err       string
+	}{
+		{&dataset.Structure{}, "", "schema required for Parquet reader"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaObject}, "", "invalid schema. root must be an array for Parquet data"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray}, "", "error reading parquet data: not a parquet file"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray}, "city,pop\ntoronto,40000000\n", "error reading parquet data: not a parquet file"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray}, "PAR1\xff\xff\x00\x00PAR1", "error reading parquet data: invalid footer length"},
+	}
+
+	for i, c := range cases {
+		r, err := NewParquetReader(c.structure, bytes.NewBufferString(c.data))
+		if err == nil {
+			_, err = r.ReadEntry()
+		}
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+		}
+	}
+}
+
+func TestParquetWriterErrors(t *testing.T) {
+	cases := []struct {
+		structure *dataset.Structure
+		entry     Entry
+		err       string
+	}{
+		{&dataset.Structure{}, Entry{}, "schema required for Parquet writer"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaObject}, Entry{}, "invalid schema. parquet data must be an array of arrays with titled columns"},
+		{&dataset.Structure{Schema: parquetStructure.Schema, FormatConfig: &dataset.ParquetOptions{Codec: "lz4"}}, Entry{}, `unsupported parquet codec: "lz4"`},
+		{parquetStructure, Entry{Value: []interface{}{"a"}}, "expected array value of 5 columns to write parquet row. got: [a]"},
+		{parquetStructure, Entry{Value: []interface{}{"a", 1.5, 1.0, true, nil}}, `invalid value for INT64 column "pop": 1.5`},
+		{parquetStructure, Entry{Value: []interface{}{5, 1, 1.0, true, nil}}, `invalid value for BYTE_ARRAY column "city": 5`},
+	}
+
+	for i, c := range cases {
+		w, err := NewParquetWriter(c.structure, &bytes.Buffer{})
+		if err == nil {
+			err = w.WriteEntry(c.entry)
+		}
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+		}
+	}
+}
+
+func TestParquetReaderDictionaryPageV2(t *testing.T) {
+	// a single required string column, dictionary encoded in a v2 data page
+	col := &parquetColumn{typ: parquetByteArray, kind: parquetKindString}
+	dict := col.plainEncode([]interface{}{[]byte("toronto"), []byte("new york")})
+	indices := append([]byte{1}, rleEncode([]int{0, 1, 1, 0}, 1)...)
+
+	tw := &thriftWriter{}
+	tw.writeStruct([]thriftField{
+		{1, int32(parquetPageDictionary)},
+		{2, int32(len(dict))},
+		{3, int32(len(dict))},
+		{7, []thriftField{{1, int32(2)}, {2, int32(parquetEncodingPlainDictionary)}}},
+	})
+	tw.buf = append(tw.buf, dict...)
+	dataOffset := int64(4 + len(tw.buf))
+	tw.writeStruct([]thriftField{
+		{1, int32(parquetPageDataV2)},
+		{2, int32(len(indices))},
+		{3, int32(len(indices))},
+		{8, []thriftField{
+			{1, int32(4)},
+			{2, int32(0)},
+			{3, int32(4)},
+			{4, int32(parquetEncodingRLEDictionary)},
+			{5, int32(0)},
+			{6, int32(0)},
+			{7, false},
+		}},
+	})
+	tw.buf = append(tw.buf, indices...)
+	chunk := tw.buf
+
+	meta := &thriftWriter{}
+	meta.writeStruct([]thriftField{
+		{1, int32(1)},
+		{2, thriftList{thriftStructType, []interface{}{
+			[]thriftField{{4, "schema"}, {5, int32(1)}},
+			[]thriftField{{1, int32(parquetByteArray)}, {3, int32(parquetRequired)}, {4, "city"}, {6, int32(parquetConvertedUTF8)}},
+		}}},
+		{3, int64(4)},
+		{4, thriftList{thriftStructType, []interface{}{
+			[]thriftField{
+				{1, thriftList{thriftStructType, []interface{}{
+					[]thriftField{
+						{2, int64(4)},
+						{3, []thriftField{
+							{1, int32(parquetByteArray)},
+							{2, thriftList{thriftI32, []interface{}{int32(parquetEncodingRLEDictionary)}}},
+							{3, thriftList{thriftBinary, []interface{}{"city"}}},
+							{4, int32(parquetCodecUncompressed)},
+							{5, int64(4)},
+							{6, int64(len(chunk))},
+							{7, int64(len(chunk))},
+							{9, dataOffset},
+							{11, int64(4)},
+						}},
+					},
+				}}},
+				{2, int64(len(chunk))},
+				{3, int64(4)},
+			},
+		}}},
+	})
+
+	data := append([]byte(parquetMagic), chunk...)
+	data = append(data, meta.buf...)
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(meta.buf)))
+	data = append(append(data, size...), parquetMagic...)
+
+	r, err := NewParquetReader(&dataset.Structure{Schema: dataset.BaseSchemaArray}, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	got := []interface{}{}
+	err = EachEntry(r, func(i int, ent Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		got = append(got, ent.Value.([]interface{})[0])
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expect := []interface{}{"toronto", "new york", "new york", "toronto"}
+	if !reflect.DeepEqual(expect, got) {
+		t.Errorf("values mismatch. expected: %v, got: %v", expect, got)
+	}
+}
+
+func TestParquetReaderForeignFiles(t *testing.T) {
+	// rows of parquet-go's "flat" example
+	flatRows := [][]interface{}{}
+	for i := 0; i < 10; i++ {
+		flatRows = append(flatRows, []interface{}{"StudentName", int64(20 + i%5), int64(i), float64(float32(50.0 + float32(i)*0.1)), i%2 == 0, "2019-05-24"})
+	}
+
+	cases := []struct {
+		file   string
+		titles []string
+		types  []string
+		rows   [][]interface{}
+	}{
+		{"testdata/parquet/parquet-go_flat_snappy.parquet",
+			[]string{"name", "age", "id", "weight", "sex", "day"},
+			[]string{"string", "integer", "integer", "number", "boolean", "string"},
+			flatRows},
+	}
+
+	for _, c := range cases {
+		data, err := ioutil.ReadFile(c.file)
+		if err != nil {
+			t.Fatalf("%s: error reading file: %s", c.file, err.Error())
+		}
+		st := &dataset.Structure{Format: dataset.ParquetDataFormat, Schema: dataset.BaseSchemaArray}
+		r, err := NewParquetReader(st, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", c.file, err.Error())
+		}
+		titles, types, err := r.Columns()
+		if err != nil {
+			t.Errorf("%s: error reading columns: %s", c.file, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(c.titles, titles) || !reflect.DeepEqual(c.types, types) {
+			t.Errorf("%s: columns mismatch. expected: %v %v, got: %v %v", c.file, c.titles, c.types, titles, types)
+		}
+
+		got := [][]interface{}{}
+		err = EachEntry(r, func(i int, ent Entry, err error) error {
+			if err != nil {
+				return err
+			}
+			got = append(got, ent.Value.([]interface{}))
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: error reading entries: %s", c.file, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(c.rows, got) {
+			t.Errorf("%s: rows mismatch. expected:\n%v\ngot:\n%v", c.file, c.rows, got)
+		}
+	}
+}
+
+func TestRLEDecode(t *testing.T) {
+	cases := []struct {
+		data     []byte
+		bitWidth int
+		n        int
+		expect   []int
+		err      string
+	}{
+		{[]byte{0x08, 0x01}, 1, 4, []int{1, 1, 1, 1}, ""},
+		// bit-packed run of 8 values, 3 bits each: 0..7
+		{[]byte{0x03, 0x88, 0xc6, 0xfa}, 3, 8, []int{0, 1, 2, 3, 4, 5, 6, 7}, ""},
+		// bit-packed runs are padded to a multiple of 8 values
+		{[]byte{0x03, 0x88, 0xc6, 0xfa}, 3, 3, []int{0, 1, 2}, ""},
+		{[]byte{0x04, 0x02, 0x02, 0x01}, 8, 3, []int{2, 2, 1}, ""},
+		{[]byte{0x08}, 1, 4, nil, "unexpected end of RLE data"},
+		{[]byte{}, 1, 1, nil, "invalid RLE data"},
+	}
+
+	for i, c := range cases {
+		got, err := rleDecode(c.data, c.bitWidth, c.n)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+			continue
+		}
+		if c.err == "" && !reflect.DeepEqual(c.expect, got) {
+			t.Errorf("case %d result mismatch. expected: %v, got: %v", i, c.expect, got)
+		}
+	}
+}
+
+func TestThriftReaderDepth(t *testing.T) {
+	// each 0x1c byte opens a struct as field 1 of the enclosing struct, each
+	// 0x00 stops a struct
+	nested := func(depth int) []byte {
+		return append(bytes.Repeat([]byte{0x1c}, depth), bytes.Repeat([]byte{0x00}, depth+1)...)
+	}
+
+	cases := []struct {
+		data []byte
+		err  string
+	}{
+		{nested(thriftMaxDepth), ""},
+		{nested(thriftMaxDepth + 1), "thrift data nested more than 64 levels deep"},
+		{nested(100000), "thrift data nested more than 64 levels deep"},
+		// lists of lists count towards depth as well
+		{bytes.Repeat([]byte{0x19, 0x19}, 100000), "thrift data nested more than 64 levels deep"},
+	}
+
+	for i, c := range cases {
+		r := &thriftReader{data: c.data}
+		_, err := r.readStruct()
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s, got: %s", i, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package dsio
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+)
+
+// ProjectReader wraps an EntryReader, selecting & reordering the columns of
+// each entry by schema title. Array entries are projected by the titles of
+// their schema's items, object entries by key. The Structure a ProjectReader
+// reports describes the projected entries
+type ProjectReader struct {
+	r         EntryReader
+	structure *dataset.Structure
+	columns   []string
+	// indexes of columns in source array entries, nil for object entries
+	indexes []int
+}
+
+// NewProjectReader creates a reader that only reads the named columns, in
+// the order given. It's an error to name a column the source schema doesn't
+// declare. Object entries without declared properties can be projected by
+// any key, missing keys are left out
+func NewProjectReader(r EntryReader, columns ...string) (*ProjectReader, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("at least one column is required")
+	}
+	seen := map[string]bool{}
+	for _, col := range columns {
+		if seen[col] {
+			return nil, fmt.Errorf("column %q is selected more than once", col)
+		}
+		seen[col] = true
+	}
+
+	st := r.Structure()
+	if st == nil || st.Schema == nil {
+		return nil, fmt.Errorf("structure with a schema is required to project columns")
+	}
+	sch, err := schemaMap(st.Schema)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error reading schema: %s", err.Error())
+	}
+	items, _ := sch["items"].(map[string]interface{})
+	if items == nil {
+		return nil, fmt.Errorf("schema doesn't describe entry columns")
+	}
+
+	pr := &ProjectReader{r: r, columns: columns}
+	projected := map[string]interface{}{}
+	for key, val := range items {
+		projected[key] = val
+	}
+
+	if fields, ok := items["items"].([]interface{}); ok {
+		titles, _, err := terribleHackToGetHeaderRowAndTypes(st)
+		if err != nil {
+			return nil, fmt.Errorf("schema doesn't describe entry columns")
+		}
+		pr.indexes = make([]int, len(columns))
+		selected := make([]interface{}, len(columns))
+		for i, col := range columns {
+			idx := indexOf(titles, col)
+			if idx < 0 {
+				return nil, fmt.Errorf("column %q not found", col)
+			}
+			pr.indexes[i] = idx
+			selected[i] = fields[idx]
+		}
+		projected["items"] = selected
+	} else if props, ok := items["properties"].(map[string]interface{}); ok {
+		selected := map[string]interface{}{}
+		for _, col := range columns {
+			prop, ok := props[col]
+			if !ok {
+				return nil, fmt.Errorf("column %q not found", col)
+			}
+			selected[col] = prop
+		}
+		projected["properties"] = selected
+		if req, ok := items["required"].([]interface{}); ok {
+			required := []interface{}{}
+			for _, r := range req {
+				if key, ok := r.(string); ok && seen[key] {
+					required = append(required, key)
+				}
+			}
+			projected["required"] = required
+		}
+	} else if t, _ := items["type"].(string); t != "object" {
+		return nil, fmt.Errorf("schema doesn't describe entry columns")
+	}
+
+	sch["items"] = projected
+	rs, err := rootSchema(sch)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error creating projected schema: %s", err.Error())
+	}
+	pr.structure = derivedStructure(st, rs)
+	pr.structure.Entries = st.Entries
+	return pr, nil
+}
+
+// Structure gives the structure of projected entries
+func (pr *ProjectReader) Structure() *dataset.Structure {
+	return pr.structure
+}
+
+// ReadEntry reads the next entry, keeping only projected columns
+func (pr *ProjectReader) ReadEntry() (Entry, error) {
+	ent, err := pr.r.ReadEntry()
+	if err != nil {
+		return ent, err
+	}
+
+	switch v := ent.Value.(type) {
+	case []interface{}:
+		if pr.indexes == nil {
+			return ent, fmt.Errorf("entry %d: expected an object, got an array", ent.Index)
+		}
+		row := make([]interface{}, len(pr.indexes))
+		for i, idx := range pr.indexes {
+			if idx < len(v) {
+				row[i] = v[idx]
+			}
+		}
+		ent.Value = row
+	case map[string]interface{}:
+		if pr.indexes != nil {
+			return ent, fmt.Errorf("entry %d: expected an array, got an object", ent.Index)
+		}
+		obj := make(map[string]interface{}, len(pr.columns))
+		for _, col := range pr.columns {
+			if val, ok := v[col]; ok {
+				obj[col] = val
+			}
+		}
+		ent.Value = obj
+	default:
+		return ent, fmt.Errorf("entry %d: can't project columns of a %T", ent.Index, ent.Value)
+	}
+	return ent, nil
+}
+
+// schemaMap decodes a schema into generic values
+func schemaMap(rs *jsonschema.RootSchema) (map[string]interface{}, error) {
+	data, err := rs.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	sch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &sch); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// rootSchema encodes generic values as a schema
+func rootSchema(sch map[string]interface{}) (*jsonschema.RootSchema, error) {
+	data, err := json.Marshal(sch)
+	if err != nil {
+		return nil, err
+	}
+	rs := &jsonschema.RootSchema{}
+	if err := rs.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// derivedStructure describes entries derived from a structure, dropping
+// details like checksum & length that no longer hold
+func derivedStructure(st *dataset.Structure, sch *jsonschema.RootSchema) *dataset.Structure {
+	return &dataset.Structure{
+		Qri:          st.Qri,
+		Format:       st.Format,
+		FormatConfig: st.FormatConfig,
+		Compression:  st.Compression,
+		Encoding:     st.Encoding,
+		Schema:       sch,
+	}
+}
+
+func indexOf(strs []string, str string) int {
+	for i, s := range strs {
+		if s == str {
+			return i
+		}
+	}
+	return -1
+}
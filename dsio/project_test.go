@@ -0,0 +1,132 @@
+package dsio
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestProjectReader(t *testing.T) {
+	data := "city,pop,avg_age,in_usa\ntoronto,40000000,55.5,false\nchicago,300000,44.4,true\n"
+
+	cases := []struct {
+		columns []string
+		titles  []string
+		types   []string
+		expect  [][]interface{}
+		err     string
+	}{
+		{[]string{"in_usa", "city"}, []string{"in_usa", "city"}, []string{"boolean", "string"}, [][]interface{}{
+			{false, "toronto"},
+			{true, "chicago"},
+		}, ""},
+		{[]string{"pop"}, []string{"pop"}, []string{"integer"}, [][]interface{}{
+			{int64(40000000)},
+			{int64(300000)},
+		}, ""},
+		{nil, nil, nil, nil, "at least one column is required"},
+		{[]string{"city", "city"}, nil, nil, nil, `column "city" is selected more than once`},
+		{[]string{"country"}, nil, nil, nil, `column "country" not found`},
+	}
+
+	for i, c := range cases {
+		r, err := NewEntryReader(offsetStruct, strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("case %d error creating reader: %s", i, err.Error())
+		}
+		pr, err := NewProjectReader(r, c.columns...)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		titles, types, err := terribleHackToGetHeaderRowAndTypes(pr.Structure())
+		if err != nil {
+			t.Errorf("case %d error reading projected schema: %s", i, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(titles, c.titles) || !reflect.DeepEqual(types, c.types) {
+			t.Errorf("case %d schema mismatch. expected: %v %v, got: %v %v", i, c.titles, c.types, titles, types)
+		}
+		if pr.Structure().Format != offsetStruct.Format {
+			t.Errorf("case %d format mismatch. got: %s", i, pr.Structure().Format)
+		}
+
+		var got [][]interface{}
+		err = EachEntry(pr, func(_ int, ent Entry, err error) error {
+			got = append(got, ent.Value.([]interface{}))
+			return nil
+		})
+		if err != nil {
+			t.Errorf("case %d error reading: %s", i, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Errorf("case %d entries mismatch. expected: %v, got: %v", i, c.expect, got)
+		}
+	}
+}
+
+func TestProjectReaderObjects(t *testing.T) {
+	st := &dataset.Structure{
+		Format: dataset.JSONDataFormat,
+		Schema: jsonschema.Must(`{
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"a": {"type": "integer"},
+					"b": {"type": "string"},
+					"c": {"type": "boolean"}
+				},
+				"required": ["a", "b"]
+			}
+		}`),
+	}
+	r, err := NewEntryReader(st, strings.NewReader(`[{"a":1,"b":"x","c":true},{"a":2,"c":false}]`))
+	if err != nil {
+		t.Fatalf("error creating reader: %s", err.Error())
+	}
+	pr, err := NewProjectReader(r, "c", "b")
+	if err != nil {
+		t.Fatalf("error creating project reader: %s", err.Error())
+	}
+
+	sch, err := schemaMap(pr.Structure().Schema)
+	if err != nil {
+		t.Fatalf("error reading projected schema: %s", err.Error())
+	}
+	items := sch["items"].(map[string]interface{})
+	if props := items["properties"].(map[string]interface{}); len(props) != 2 || props["a"] != nil {
+		t.Errorf("expected properties b & c. got: %v", props)
+	}
+	if req := items["required"]; !reflect.DeepEqual(req, []interface{}{"b"}) {
+		t.Errorf("expected required [b]. got: %v", req)
+	}
+
+	expect := []interface{}{
+		map[string]interface{}{"b": "x", "c": true},
+		map[string]interface{}{"c": false},
+	}
+	var got []interface{}
+	err = EachEntry(pr, func(_ int, ent Entry, err error) error {
+		got = append(got, ent.Value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error reading: %s", err.Error())
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("entries mismatch. expected: %v, got: %v", expect, got)
+	}
+
+	if _, err := NewProjectReader(r, "d"); err == nil || err.Error() != `column "d" not found` {
+		t.Errorf("expected missing column error. got: %v", err)
+	}
+}
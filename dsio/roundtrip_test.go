@@ -0,0 +1,95 @@
+package dsio_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/dataset/dstest/roundtrip"
+	"github.com/qri-io/dataset/generate"
+	"github.com/qri-io/jsonschema"
+)
+
+// entryCollector is an EntryWriter that keeps entries in memory
+type entryCollector struct {
+	st      *dataset.Structure
+	entries []dsio.Entry
+}
+
+func (c *entryCollector) Structure() *dataset.Structure { return c.st }
+func (c *entryCollector) Close() error                  { return nil }
+func (c *entryCollector) WriteEntry(ent dsio.Entry) error {
+	c.entries = append(c.entries, ent)
+	return nil
+}
+
+func TestRoundTripTabular(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		ds, body, err := generate.Dataset(func(o *generate.RandomDatasetOpts) {
+			o.Seed = seed
+			o.NumEntries = 30
+		})
+		if err != nil {
+			t.Fatalf("seed %d: error generating dataset: %s", seed, err.Error())
+		}
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Fatalf("seed %d: error reading body: %s", seed, err.Error())
+		}
+
+		r, err := dsio.NewEntryReader(ds.Structure, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("seed %d: error creating reader: %s", seed, err.Error())
+		}
+		var entries []dsio.Entry
+		dsio.EachEntry(r, func(i int, ent dsio.Entry, err error) error {
+			entries = append(entries, ent)
+			return err
+		})
+
+		for _, err := range roundtrip.Check(ds.Structure, entries) {
+			t.Errorf("seed %d: %s", seed, err.Error())
+		}
+	}
+}
+
+func TestRoundTripNested(t *testing.T) {
+	schemas := []string{
+		`{
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["id", "tags"],
+				"properties": {
+					"id": { "type": "integer", "minimum": 0 },
+					"tags": { "type": "array", "items": { "type": "string", "pattern": "^[a-z]{1,8}$" } },
+					"score": { "type": "number" },
+					"nested": { "type": "object", "properties": { "ok": { "type": "boolean" } } }
+				}
+			}
+		}`,
+		`{
+			"type": "object",
+			"additionalProperties": {
+				"type": "array",
+				"items": { "type": ["string", "integer", "null"] }
+			}
+		}`,
+	}
+
+	for i, s := range schemas {
+		st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: jsonschema.Must(s)}
+		for seed := int64(1); seed <= 10; seed++ {
+			c := &entryCollector{st: st}
+			g := generate.NewGenerator(func(o *generate.GeneratorOpts) { o.Seed = seed })
+			if err := g.WriteEntries(c, 15); err != nil {
+				t.Fatalf("schema %d seed %d: error generating entries: %s", i, seed, err.Error())
+			}
+			for _, err := range roundtrip.Check(st, c.entries) {
+				t.Errorf("schema %d seed %d: %s", i, seed, err.Error())
+			}
+		}
+	}
+}
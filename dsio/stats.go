@@ -0,0 +1,458 @@
+package dsio
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/qri-io/dataset"
+)
+
+const (
+	// statsExactDistinct is the number of unique values counted exactly
+	// before switching to a distinct-count estimate
+	statsExactDistinct = 1024
+	// statsTopValues is the number of most frequent strings reported
+	statsTopValues = 10
+	// statsTopValuesCapacity is the number of strings tracked when finding
+	// the most frequent values
+	statsTopValuesCapacity = 100
+	// histogramBins is the number of bins in a histogram
+	histogramBins = 10
+	// histogramExactValues is the number of values a histogram buffers
+	// before fixing it's bins
+	histogramExactValues = 1024
+	// hllPrecision is the number of bits of each hash used to pick a
+	// hyperloglog register
+	hllPrecision = 10
+)
+
+// StatsAccumulator calculates column statistics of entries written to it.
+// Array entries are split into columns by position, using schema titles
+// where available. Object entries are split into columns by key, and scalar
+// entries are treated as a single column. Memory use is bounded regardless of
+// the number of entries, so distinct counts, top values & histograms are
+// approximate for large data
+type StatsAccumulator struct {
+	st      *dataset.Structure
+	titles  []string
+	types   []string
+	entries int
+	cols    []*columnAccumulator
+	keys    map[string]int
+}
+
+// NewStatsAccumulator creates a stats accumulator for entries of data
+// described by a structure
+func NewStatsAccumulator(st *dataset.Structure) *StatsAccumulator {
+	acc := &StatsAccumulator{st: st, keys: map[string]int{}}
+	if st != nil && st.Schema != nil {
+		acc.titles, acc.types, _ = terribleHackToGetHeaderRowAndTypes(st)
+	}
+	return acc
+}
+
+// Structure gives the structure being accumulated
+func (acc *StatsAccumulator) Structure() *dataset.Structure {
+	return acc.st
+}
+
+// WriteEntry adds the values of an entry to stats
+func (acc *StatsAccumulator) WriteEntry(ent Entry) error {
+	acc.entries++
+	switch v := ent.Value.(type) {
+	case []interface{}:
+		for i, val := range v {
+			acc.column(i, "").add(val)
+		}
+	case map[string]interface{}:
+		for key, val := range v {
+			i, ok := acc.keys[key]
+			if !ok {
+				i = len(acc.cols)
+				acc.keys[key] = i
+			}
+			acc.column(i, key).add(val)
+		}
+	default:
+		acc.column(0, "").add(v)
+	}
+	return nil
+}
+
+// Close implements the EntryWriter interface
+func (acc *StatsAccumulator) Close() error {
+	return nil
+}
+
+// column gets the accumulator for column i, creating any missing columns
+func (acc *StatsAccumulator) column(i int, title string) *columnAccumulator {
+	for len(acc.cols) <= i {
+		n := len(acc.cols)
+		col := newColumnAccumulator()
+		col.title = title
+		if n < len(acc.titles) && title == "" {
+			col.title = acc.titles[n]
+		}
+		if n < len(acc.types) && title == "" {
+			col.typ = acc.types[n]
+		}
+		acc.cols = append(acc.cols, col)
+	}
+	return acc.cols[i]
+}
+
+// Stats gives statistics of all entries written so far
+func (acc *StatsAccumulator) Stats() *dataset.Stats {
+	stats := &dataset.Stats{Qri: dataset.KindStats}
+	for _, i := range acc.columnOrder() {
+		stats.Columns = append(stats.Columns, acc.cols[i].stats(acc.entries))
+	}
+	return stats
+}
+
+// columnOrder gives the order columns are reported in: positional columns
+// in position order, object keys sorted by key
+func (acc *StatsAccumulator) columnOrder() []int {
+	order := make([]int, len(acc.cols))
+	for i := range order {
+		order[i] = i
+	}
+	if len(acc.keys) > 0 {
+		sort.Slice(order, func(a, b int) bool {
+			return acc.cols[order[a]].title < acc.cols[order[b]].title
+		})
+	}
+	return order
+}
+
+// columnAccumulator calculates stats for a single column
+type columnAccumulator struct {
+	title string
+	typ   string
+	seen  map[string]bool
+
+	count    int
+	distinct map[string]bool
+	hll      []uint8
+
+	numbers        int
+	mean, m2       float64
+	minNum, maxNum float64
+	hist           histogram
+
+	strings        int
+	minStr, maxStr string
+	top            map[string]int
+}
+
+func newColumnAccumulator() *columnAccumulator {
+	return &columnAccumulator{
+		seen:     map[string]bool{},
+		distinct: map[string]bool{},
+		hll:      make([]uint8, 1<<hllPrecision),
+		top:      map[string]int{},
+	}
+}
+
+func (c *columnAccumulator) add(v interface{}) {
+	if v == nil {
+		return
+	}
+	c.count++
+
+	var key string
+	switch val := v.(type) {
+	case string:
+		c.seen["string"] = true
+		key = "s:" + val
+		c.addString(val)
+	case bool:
+		c.seen["boolean"] = true
+		key = "b:" + keyString(val)
+	default:
+		if f, ok := toFloat(v); ok {
+			if f == math.Trunc(f) {
+				c.seen["integer"] = true
+			} else {
+				c.seen["number"] = true
+			}
+			key = "n:" + keyString(v)
+			c.addNumber(f)
+			break
+		}
+		switch v.(type) {
+		case []interface{}:
+			c.seen["array"] = true
+		case map[string]interface{}:
+			c.seen["object"] = true
+		}
+		data, _ := json.Marshal(v)
+		key = "j:" + string(data)
+	}
+	c.addDistinct(key)
+}
+
+func (c *columnAccumulator) addNumber(f float64) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return
+	}
+	c.numbers++
+	if c.numbers == 1 || f < c.minNum {
+		c.minNum = f
+	}
+	if c.numbers == 1 || f > c.maxNum {
+		c.maxNum = f
+	}
+	// welford's online algorithm
+	delta := f - c.mean
+	c.mean += delta / float64(c.numbers)
+	c.m2 += delta * (f - c.mean)
+	c.hist.add(f)
+}
+
+func (c *columnAccumulator) addString(s string) {
+	c.strings++
+	if c.strings == 1 || s < c.minStr {
+		c.minStr = s
+	}
+	if c.strings == 1 || s > c.maxStr {
+		c.maxStr = s
+	}
+
+	// the space-saving algorithm: when all slots are taken, replace the least
+	// frequent value, inheriting it's count
+	if _, ok := c.top[s]; !ok && len(c.top) >= statsTopValuesCapacity {
+		minKey, minCount := "", -1
+		for k, n := range c.top {
+			if minCount == -1 || n < minCount || n == minCount && k > minKey {
+				minKey, minCount = k, n
+			}
+		}
+		delete(c.top, minKey)
+		c.top[s] = minCount
+	}
+	c.top[s]++
+}
+
+func (c *columnAccumulator) addDistinct(key string) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := mix64(h.Sum64())
+	i := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > c.hll[i] {
+		c.hll[i] = rank
+	}
+
+	if c.distinct != nil {
+		c.distinct[key] = true
+		if len(c.distinct) > statsExactDistinct {
+			c.distinct = nil
+		}
+	}
+}
+
+// distinctCount gives the number of unique values, estimated with
+// hyperloglog once there are too many values to count exactly
+func (c *columnAccumulator) distinctCount() int {
+	if c.distinct != nil {
+		return len(c.distinct)
+	}
+	m := float64(len(c.hll))
+	sum, zeros := 0.0, 0
+	for _, r := range c.hll {
+		sum += math.Pow(2, -float64(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	est := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+	return int(est + 0.5)
+}
+
+func (c *columnAccumulator) stats(entries int) *dataset.ColumnStats {
+	cs := &dataset.ColumnStats{
+		Title:     c.title,
+		Type:      c.typ,
+		Count:     c.count,
+		NullCount: entries - c.count,
+		Distinct:  c.distinctCount(),
+	}
+	if cs.Type == "" {
+		cs.Type = c.observedType()
+	}
+
+	if c.numbers > 0 && c.strings == 0 {
+		cs.Min, cs.Max = c.minNum, c.maxNum
+	} else if c.strings > 0 && c.numbers == 0 {
+		cs.Min, cs.Max = c.minStr, c.maxStr
+	}
+
+	if c.numbers > 0 {
+		mean := c.mean
+		stddev := math.Sqrt(c.m2 / float64(c.numbers))
+		cs.Mean, cs.StdDev = &mean, &stddev
+		cs.Histogram = c.hist.histogram()
+	}
+
+	if c.strings > 0 {
+		for v, n := range c.top {
+			cs.TopValues = append(cs.TopValues, &dataset.ValueCount{Value: v, Count: n})
+		}
+		sort.Slice(cs.TopValues, func(i, j int) bool {
+			a, b := cs.TopValues[i], cs.TopValues[j]
+			return a.Count > b.Count || a.Count == b.Count && a.Value < b.Value
+		})
+		if len(cs.TopValues) > statsTopValues {
+			cs.TopValues = cs.TopValues[:statsTopValues]
+		}
+	}
+	return cs
+}
+
+// observedType gives the json schema type of all values seen, empty if
+// values are of mixed types
+func (c *columnAccumulator) observedType() string {
+	if c.seen["integer"] && c.seen["number"] {
+		delete(c.seen, "integer")
+	}
+	if len(c.seen) != 1 {
+		return ""
+	}
+	for t := range c.seen {
+		return t
+	}
+	return ""
+}
+
+// histogram counts number values in equal-width bins. The first values are
+// buffered to pick bins, after which bins double in width whenever a value
+// falls outside their range
+type histogram struct {
+	buf    []float64
+	lo     float64
+	width  float64
+	counts []int
+}
+
+func (h *histogram) add(v float64) {
+	if h.counts != nil {
+		h.insert(v)
+		return
+	}
+
+	h.buf = append(h.buf, v)
+	if len(h.buf) < histogramExactValues {
+		return
+	}
+
+	min, max := bounds(h.buf)
+	h.reset(min, max)
+	for _, v := range h.buf {
+		h.insert(v)
+	}
+	h.buf = nil
+}
+
+// reset sets empty bins spanning min to max
+func (h *histogram) reset(min, max float64) {
+	h.lo, h.width = min, (max-min)/histogramBins
+	if h.width == 0 {
+		h.width = 1
+	}
+	// guard against rounding leaving max outside the last bin
+	for h.lo+h.width*histogramBins < max {
+		h.width = math.Nextafter(h.width, math.Inf(1))
+	}
+	h.counts = make([]int, histogramBins)
+}
+
+func (h *histogram) insert(v float64) {
+	for v < h.lo {
+		// double bin widths, keeping the upper bound fixed
+		hi := h.lo + h.width*histogramBins
+		merged := make([]int, histogramBins)
+		for i, n := range h.counts {
+			merged[histogramBins/2+i/2] += n
+		}
+		h.counts = merged
+		h.width *= 2
+		h.lo = hi - h.width*histogramBins
+	}
+	for v > h.lo+h.width*histogramBins {
+		// double bin widths, keeping the lower bound fixed
+		merged := make([]int, histogramBins)
+		for i, n := range h.counts {
+			merged[i/2] += n
+		}
+		h.counts = merged
+		h.width *= 2
+	}
+
+	i := int((v - h.lo) / h.width)
+	if i >= histogramBins {
+		i = histogramBins - 1
+	}
+	h.counts[i]++
+}
+
+func (h *histogram) histogram() *dataset.Histogram {
+	if h.counts == nil {
+		if len(h.buf) == 0 {
+			return nil
+		}
+		min, max := bounds(h.buf)
+		if min == max {
+			return &dataset.Histogram{Bins: []float64{min, max}, Counts: []int{len(h.buf)}}
+		}
+		exact := &histogram{}
+		exact.reset(min, max)
+		for _, v := range h.buf {
+			exact.insert(v)
+		}
+		return exact.histogram()
+	}
+
+	// trim empty bins from either end
+	start, end := 0, len(h.counts)
+	for start < end && h.counts[start] == 0 {
+		start++
+	}
+	for end > start && h.counts[end-1] == 0 {
+		end--
+	}
+	hist := &dataset.Histogram{Counts: append([]int{}, h.counts[start:end]...)}
+	for i := start; i <= end; i++ {
+		hist.Bins = append(hist.Bins, h.lo+float64(i)*h.width)
+	}
+	return hist
+}
+
+// bounds gives the smallest & largest of a slice of numbers
+func bounds(vals []float64) (min, max float64) {
+	for i, v := range vals {
+		if i == 0 || v < min {
+			min = v
+		}
+		if i == 0 || v > max {
+			max = v
+		}
+	}
+	return
+}
+
+// mix64 is the splitmix64 finalizer, spreading hash bits evenly
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,148 @@
+package dsio
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/qri-io/dataset"
+)
+
+func TestStatsAccumulator(t *testing.T) {
+	acc := NewStatsAccumulator(offsetStruct)
+	rows := []interface{}{
+		[]interface{}{"toronto", int64(40000000), 55.5, false},
+		[]interface{}{"new york", int64(8500000), 44.4, true},
+		[]interface{}{"chicago", int64(300000), 44.4, true},
+		[]interface{}{"chatham", int64(35000), 65.25, true},
+		[]interface{}{"raleigh", int64(250000), nil},
+	}
+	for _, row := range rows {
+		if err := acc.WriteEntry(Entry{Value: row}); err != nil {
+			t.Fatalf("unexpected error writing entry: %s", err.Error())
+		}
+	}
+
+	stats := acc.Stats()
+	if stats.Qri != dataset.KindStats {
+		t.Errorf("expected kind %s. got: %s", dataset.KindStats, stats.Qri)
+	}
+	if len(stats.Columns) != 4 {
+		t.Fatalf("expected 4 columns. got: %d", len(stats.Columns))
+	}
+
+	cases := []struct {
+		title     string
+		typ       string
+		count     int
+		nullCount int
+		distinct  int
+		min, max  interface{}
+		mean      float64
+		top       string
+	}{
+		{"city", "string", 5, 0, 5, "chatham", "toronto", 0, "chatham"},
+		{"pop", "integer", 5, 0, 5, float64(35000), float64(40000000), 9817000, ""},
+		{"avg_age", "number", 4, 1, 3, 44.4, 65.25, 52.3875, ""},
+		{"in_usa", "boolean", 4, 1, 2, nil, nil, 0, ""},
+	}
+
+	for i, c := range cases {
+		col := stats.Columns[i]
+		if col.Title != c.title {
+			t.Errorf("case %d title mismatch. expected: %s, got: %s", i, c.title, col.Title)
+		}
+		if col.Type != c.typ {
+			t.Errorf("case %d type mismatch. expected: %s, got: %s", i, c.typ, col.Type)
+		}
+		if col.Count != c.count || col.NullCount != c.nullCount || col.Distinct != c.distinct {
+			t.Errorf("case %d count mismatch. expected: %d/%d/%d, got: %d/%d/%d", i, c.count, c.nullCount, c.distinct, col.Count, col.NullCount, col.Distinct)
+		}
+		if col.Min != c.min || col.Max != c.max {
+			t.Errorf("case %d min/max mismatch. expected: %v/%v, got: %v/%v", i, c.min, c.max, col.Min, col.Max)
+		}
+		if c.mean != 0 {
+			if col.Mean == nil || math.Abs(*col.Mean-c.mean) > 1e-9 {
+				t.Errorf("case %d mean mismatch. expected: %f, got: %v", i, c.mean, col.Mean)
+			}
+			if col.StdDev == nil || col.Histogram == nil {
+				t.Errorf("case %d expected stddev & histogram", i)
+			} else if sum := sumCounts(col.Histogram.Counts); sum != c.count {
+				t.Errorf("case %d histogram count mismatch. expected: %d, got: %d", i, c.count, sum)
+			}
+		} else if col.Mean != nil || col.Histogram != nil {
+			t.Errorf("case %d expected no number stats", i)
+		}
+		if c.top != "" && (len(col.TopValues) == 0 || col.TopValues[0].Value != c.top) {
+			t.Errorf("case %d top value mismatch. expected: %s, got: %v", i, c.top, col.TopValues)
+		}
+	}
+
+	if sd := *stats.Columns[2].StdDev; math.Abs(sd-8.6996) > 1e-4 {
+		t.Errorf("stddev mismatch. expected: 8.6996, got: %f", sd)
+	}
+	hist := stats.Columns[2].Histogram
+	if len(hist.Bins) != 11 || hist.Bins[0] != 44.4 || hist.Bins[10] != 65.25 {
+		t.Errorf("histogram bins mismatch. got: %v", hist.Bins)
+	}
+}
+
+func TestStatsAccumulatorObjects(t *testing.T) {
+	acc := NewStatsAccumulator(&dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray})
+	for _, v := range []interface{}{
+		map[string]interface{}{"b": "x", "a": float64(1)},
+		map[string]interface{}{"b": "y"},
+		map[string]interface{}{"b": "x", "a": float64(3)},
+	} {
+		acc.WriteEntry(Entry{Value: v})
+	}
+
+	stats := acc.Stats()
+	if len(stats.Columns) != 2 {
+		t.Fatalf("expected 2 columns. got: %d", len(stats.Columns))
+	}
+	a, b := stats.Columns[0], stats.Columns[1]
+	if a.Title != "a" || a.Count != 2 || a.NullCount != 1 || a.Type != "integer" {
+		t.Errorf("column a mismatch. got: %#v", a)
+	}
+	if b.Title != "b" || b.Count != 3 || b.Distinct != 2 || b.Type != "string" {
+		t.Errorf("column b mismatch. got: %#v", b)
+	}
+	if len(b.TopValues) != 2 || *b.TopValues[0] != (dataset.ValueCount{Value: "x", Count: 2}) {
+		t.Errorf("column b top values mismatch. got: %v", b.TopValues)
+	}
+}
+
+func TestStatsAccumulatorLarge(t *testing.T) {
+	acc := NewStatsAccumulator(&dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray})
+	n := 20000
+	for i := 0; i < n; i++ {
+		str := "common"
+		if i%2 == 0 {
+			str = fmt.Sprintf("rare_%d", i)
+		}
+		acc.WriteEntry(Entry{Value: []interface{}{float64(i), str}})
+	}
+
+	stats := acc.Stats()
+	num, str := stats.Columns[0], stats.Columns[1]
+	if math.Abs(float64(num.Distinct-n))/float64(n) > 0.1 {
+		t.Errorf("distinct estimate too far off. expected ~%d, got: %d", n, num.Distinct)
+	}
+	if sum := sumCounts(num.Histogram.Counts); sum != n {
+		t.Errorf("histogram count mismatch. expected: %d, got: %d", n, sum)
+	}
+	if first, last := num.Histogram.Bins[0], num.Histogram.Bins[len(num.Histogram.Bins)-1]; first > 0 || last < float64(n-1) {
+		t.Errorf("histogram bins should span all values. got: %v", num.Histogram.Bins)
+	}
+	if len(str.TopValues) != statsTopValues || str.TopValues[0].Value != "common" || str.TopValues[0].Count < n/2 {
+		t.Errorf("top values mismatch. got: %v", str.TopValues[0])
+	}
+}
+
+func sumCounts(counts []int) (sum int) {
+	for _, n := range counts {
+		sum += n
+	}
+	return
+}
@@ -1,194 +1,568 @@
 package dsio
 
-// import (
-// 	"bytes"
-// 	"fmt"
-// 	"sort"
-
-// 	"github.com/qri-io/dataset"
-// )
-
-// // StructuredRowBuffer is the full-featured version of StructuredBuffer
-// // While incurring additional overhead & programmatic completexity, it brings
-// // the capactity to do things like sort data by fields, filter duplicate
-// // rows, etc.
-// type StructuredRowBuffer struct {
-// 	st     *dataset.Structure
-// 	rows   [][][]byte
-// 	less   *func(i, j int) bool
-// 	unique bool
-// 	err    error
-// }
-
-// // StructuredRowBufferCfg encapsulates configuration for StructuredRowBuffer
-// type StructuredRowBufferCfg struct {
-// 	// TODO - restore
-// 	// OrderBy gives a list of orders
-// 	// OrderBy []*dataset.Field
-// 	// OrderByDesc reverses the order given
-// 	// OrderByDesc bool
-
-// 	// Unique silently rejects writing rows
-// 	// already present in the buffer
-// 	Unique bool
-// 	// TODO - FilterFunc only allows rows that pass a given test function
-// 	// FilterFunc func(row [][]byte) bool
-// }
-
-// // NewStructuredRowBuffer allocates a StructuredRowBuffer from a statement
-// func NewStructuredRowBuffer(st *dataset.Structure, configs ...func(o *StructuredRowBufferCfg)) (*StructuredRowBuffer, error) {
-// 	cfg := &StructuredRowBufferCfg{}
-// 	for _, config := range configs {
-// 		config(cfg)
-// 	}
-
-// 	rb := &StructuredRowBuffer{
-// 		st:     st,
-// 		unique: cfg.Unique,
-// 	}
-// 	// rb.less, rb.err = rb.makeLessFunc(st, cfg)
-
-// 	return rb, nil
-// }
-
-// // Structure gives the underlying structure this buffer is using
-// func (rb *StructuredRowBuffer) Structure() *dataset.Structure {
-// 	return rb.st
-// }
-
-// // ReadRow reads one row from the buffer
-// func (rb *StructuredRowBuffer) ReadRow() ([][]byte, error) {
-// 	return nil, fmt.Errorf("cannot read rows from a *StructuredRowBuffer, call Close() then Bytes() instead")
-// }
-
-// // WriteRow writes one row to the buffer
-// func (rb *StructuredRowBuffer) WriteRow(row [][]byte) error {
-// 	if rb.unique && rb.HasRow(row) {
-// 		return nil
-// 	}
-// 	rb.rows = append(rb.rows, row)
-// 	return nil
-// }
-
-// // Close closes the writer portion of the buffer, which will affect
-// // underlying contents.
-// func (rb *StructuredRowBuffer) Close() error {
-// 	if rb.err != nil {
-// 		return rb.err
-// 	}
-// 	if rb.less != nil {
-// 		sort.Sort(rb)
-// 	}
-// 	return nil
-// }
-
-// // Bytes gives the raw contents of the underlying buffer
-// func (rb *StructuredRowBuffer) Bytes() []byte {
-// 	buf, err := NewValueBuffer(rb.Structure())
-// 	if err != nil {
-// 		// shouldn't be possible
-// 		panic(err)
-// 	}
-// 	for _, row := range rb.rows {
-// 		if err := buf.WriteRow(row); err != nil {
-// 			return nil
-// 		}
-// 	}
-// 	if err := buf.Close(); err != nil {
-// 		return nil
-// 	}
-// 	return buf.Bytes()
-// }
-
-// // HasRow checks if a row is in the buffer or not
-// func (rb *StructuredRowBuffer) HasRow(row [][]byte) bool {
-// ROWS:
-// 	for _, r := range rb.rows {
-// 		if len(r) != len(row) {
-// 			return false
-// 		}
-// 		for i, cell := range row {
-// 			if !bytes.Equal(r[i], cell) {
-// 				continue ROWS
-// 			}
-// 		}
-// 		return true
-// 	}
-// 	return false
-// }
-
-// // Len is the number of elements in the collection.
-// func (rb *StructuredRowBuffer) Len() int {
-// 	return len(rb.rows)
-// }
-
-// // Less reports whether the element with
-// // index i should sort before the element with index j.
-// func (rb *StructuredRowBuffer) Less(i, j int) bool {
-// 	less := *rb.less
-// 	return less(i, j)
-// }
-
-// // Swap swaps the elements with indexes i and j.
-// func (rb *StructuredRowBuffer) Swap(i, j int) {
-// 	rb.rows[i], rb.rows[j] = rb.rows[j], rb.rows[i]
-// }
-
-// // func (rb *StructuredRowBuffer) makeLessFunc(st *dataset.Structure, cfg *StructuredRowBufferCfg) (*func(i, j int) bool, error) {
-// // 	if len(cfg.OrderBy) == 0 {
-// // 		return nil, nil
-// // 	}
-
-// // 	type order struct {
-// // 		idx  int
-// // 		desc bool
-// // 		dt   datatypes.Type
-// // 	}
-
-// // 	if st.Schema == nil {
-// // 		return nil, fmt.Errorf("structure has no schema")
-// // 	}
-
-// // 	orders := []order{}
-// // 	for _, o := range cfg.OrderBy {
-// // 		idx := -1
-// // 		for i, f := range st.Schema.Fields {
-// // 			if f == o || f.Name == o.Name {
-// // 				idx = i
-// // 				break
-// // 			}
-// // 		}
-
-// // 		if idx < 0 {
-// // 			return nil, fmt.Errorf("couldn't find sort field: %s", o.Name)
-// // 		}
-
-// // 		orders = append(orders, order{
-// // 			idx:  idx,
-// // 			desc: cfg.OrderByDesc,
-// // 			dt:   st.Schema.Fields[idx].Type,
-// // 		})
-// // 	}
-
-// // 	less := func(i, j int) bool {
-// // 		for _, o := range orders {
-// // 			l, err := datatypes.CompareTypeBytes(rb.rows[i][o.idx], rb.rows[j][o.idx], o.dt)
-// // 			if err != nil {
-// // 				// TODO - wut
-// // 				continue
-// // 			}
-// // 			if l == 0 {
-// // 				continue
-// // 			}
-// // 			return l < 0
-// // 		}
-// // 		return false
-// // 	}
-
-// // 	if cfg.OrderByDesc {
-// // 		opposite := func(i, j int) bool { return !less(i, j) }
-// // 		return &opposite, nil
-// // 	}
-
-// // 	return &less, nil
-// // }
+import (
+	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
+	"github.com/qri-io/dataset/vals"
+)
+
+// DefaultRowBufferMemLimit is the approximate number of bytes of entries a
+// StructuredRowBuffer holds in memory before spilling to disk
+const DefaultRowBufferMemLimit = 64 << 20
+
+func init() {
+	// register container types so entry values can be spilled to disk
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+// StructuredRowBuffer is the full-featured version of EntryBuffer
+// While incurring additional overhead & programmatic complexity, it brings
+// the capacity to do things like sort data by fields, filter duplicate
+// rows, etc. Entries are written to the buffer, and once the buffer is
+// closed, read back in order. Buffers that grow past their memory limit
+// spill sorted runs of entries to temporary files, which are merged as
+// entries are read
+type StructuredRowBuffer struct {
+	st       *dataset.Structure
+	orders   []rowOrder
+	desc     bool
+	unique   bool
+	filter   func(Entry) bool
+	memLimit int
+
+	rows    []bufferedRow
+	memSize int
+	seq     int
+	seen    map[[sha256.Size]byte]bool
+	runs    []string
+	closed  bool
+	merger  *rowMerger
+}
+
+// StructuredRowBufferCfg encapsulates configuration for StructuredRowBuffer
+type StructuredRowBufferCfg struct {
+	// OrderBy gives a list of column titles or object keys to sort entries
+	// by. Entries that compare equal keep the order they were written in
+	OrderBy []string
+	// OrderByDesc reverses the order given, including the order of entries
+	// that compare equal
+	OrderByDesc bool
+	// Unique silently rejects writing rows
+	// already present in the buffer
+	Unique bool
+	// FilterFunc only allows rows that pass a given test function
+	FilterFunc func(ent Entry) bool
+	// MemLimit is the approximate number of bytes of entries to hold in
+	// memory before spilling to disk, defaults to DefaultRowBufferMemLimit
+	MemLimit int
+}
+
+// rowOrder is a single sorting criteria
+type rowOrder struct {
+	// idx is the position of an array entry column, -1 if not known
+	idx int
+	// key is the column title or object key
+	key string
+	// t is the type values are compared as, TypeUnknown infers from values
+	t vals.Type
+}
+
+// bufferedRow is an entry & the position it was written at
+type bufferedRow struct {
+	Seq   int
+	Entry Entry
+}
+
+// NewStructuredRowBuffer allocates a StructuredRowBuffer from a structure
+func NewStructuredRowBuffer(st *dataset.Structure, configs ...func(o *StructuredRowBufferCfg)) (*StructuredRowBuffer, error) {
+	cfg := &StructuredRowBufferCfg{}
+	for _, config := range configs {
+		config(cfg)
+	}
+
+	rb := &StructuredRowBuffer{
+		st:       st,
+		desc:     cfg.OrderByDesc,
+		unique:   cfg.Unique,
+		filter:   cfg.FilterFunc,
+		memLimit: cfg.MemLimit,
+	}
+	if rb.memLimit <= 0 {
+		rb.memLimit = DefaultRowBufferMemLimit
+	}
+	if rb.unique {
+		rb.seen = map[[sha256.Size]byte]bool{}
+	}
+
+	var err error
+	if rb.orders, err = makeRowOrders(st, cfg.OrderBy); err != nil {
+		return nil, err
+	}
+	return rb, nil
+}
+
+// makeRowOrders resolves sort columns against a structure's schema
+func makeRowOrders(st *dataset.Structure, orderBy []string) ([]rowOrder, error) {
+	if len(orderBy) == 0 {
+		return nil, nil
+	}
+	if st == nil || st.Schema == nil {
+		return nil, fmt.Errorf("structure has no schema")
+	}
+
+	titles, types, _ := terribleHackToGetHeaderRowAndTypes(st)
+	orders := make([]rowOrder, len(orderBy))
+	for i, key := range orderBy {
+		o := rowOrder{idx: -1, key: key}
+		for j, title := range titles {
+			if title == key {
+				o.idx = j
+				o.t = vals.TypeFromString(types[j])
+				break
+			}
+		}
+		if titles != nil && o.idx < 0 {
+			return nil, fmt.Errorf("couldn't find sort field: %s", key)
+		}
+		orders[i] = o
+	}
+	return orders, nil
+}
+
+// Structure gives the underlying structure this buffer is using
+func (rb *StructuredRowBuffer) Structure() *dataset.Structure {
+	return rb.st
+}
+
+// ReadEntry reads one entry from the buffer. Entries can only be read once
+// the buffer is closed
+func (rb *StructuredRowBuffer) ReadEntry() (Entry, error) {
+	if !rb.closed {
+		return Entry{}, fmt.Errorf("cannot read entries from a StructuredRowBuffer before calling Close()")
+	}
+	if rb.merger == nil {
+		m, err := rb.newMerger()
+		if err != nil {
+			return Entry{}, err
+		}
+		rb.merger = m
+	}
+
+	row, err := rb.merger.next()
+	if err != nil {
+		return Entry{}, err
+	}
+	row.Entry.Index = rb.merger.read - 1
+	return row.Entry, nil
+}
+
+// WriteEntry writes one entry to the buffer
+func (rb *StructuredRowBuffer) WriteEntry(ent Entry) error {
+	if rb.closed {
+		return fmt.Errorf("cannot write entries to a closed StructuredRowBuffer")
+	}
+	if rb.filter != nil && !rb.filter(ent) {
+		return nil
+	}
+
+	data, err := json.Marshal(struct {
+		Key   string
+		Value interface{}
+	}{ent.Key, ent.Value})
+	if err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error encoding entry: %s", err.Error())
+	}
+	if rb.unique {
+		sum := sha256.Sum256(data)
+		if rb.seen[sum] {
+			return nil
+		}
+		rb.seen[sum] = true
+	}
+
+	rb.rows = append(rb.rows, bufferedRow{Seq: rb.seq, Entry: ent})
+	rb.seq++
+	rb.memSize += len(data)
+	if rb.memSize > rb.memLimit {
+		return rb.spill()
+	}
+	return nil
+}
+
+// HasEntry checks if an entry has been written to the buffer. HasEntry
+// only considers entries held in memory unless the buffer is unique
+func (rb *StructuredRowBuffer) HasEntry(ent Entry) bool {
+	if rb.unique {
+		data, err := json.Marshal(struct {
+			Key   string
+			Value interface{}
+		}{ent.Key, ent.Value})
+		if err != nil {
+			return false
+		}
+		return rb.seen[sha256.Sum256(data)]
+	}
+	for _, row := range rb.rows {
+		if row.Entry.Key == ent.Key && valuesEqual(row.Entry.Value, ent.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the writer portion of the buffer, sorting buffered entries.
+// Entries can be read from the buffer once it's closed
+func (rb *StructuredRowBuffer) Close() error {
+	if rb.closed {
+		return nil
+	}
+	rb.closed = true
+	rb.seen = nil
+	rb.sortRows()
+	return nil
+}
+
+// Bytes gives the contents of the buffer encoded with the buffer's
+// structure. Bytes returns nil if the buffer isn't closed or can't be
+// encoded. Compression & character encoding are ignored
+func (rb *StructuredRowBuffer) Bytes() []byte {
+	if !rb.closed {
+		return nil
+	}
+
+	st := rb.st
+	if st.Compression != compression.None || st.Encoding != "" {
+		st = &dataset.Structure{}
+		*st = *rb.st
+		st.Compression = compression.None
+		st.Encoding = ""
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := NewEntryWriter(st, buf)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil
+	}
+	m, err := rb.newMerger()
+	if err != nil {
+		return nil
+	}
+	defer m.close()
+
+	for {
+		row, err := m.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil
+		}
+		if err := w.WriteEntry(row.Entry); err != nil {
+			log.Debug(err.Error())
+			return nil
+		}
+	}
+	if err := w.Close(); err != nil {
+		log.Debug(err.Error())
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// Cleanup removes any temporary files the buffer has spilled to disk. The
+// buffer can't be read after calling Cleanup
+func (rb *StructuredRowBuffer) Cleanup() error {
+	if rb.merger != nil {
+		rb.merger.close()
+		rb.merger = nil
+	}
+	for _, path := range rb.runs {
+		if err := os.Remove(path); err != nil {
+			log.Debug(err.Error())
+			return fmt.Errorf("error removing buffer file: %s", err.Error())
+		}
+	}
+	rb.runs = nil
+	rb.rows = nil
+	return nil
+}
+
+// Len is the number of entries held in memory
+func (rb *StructuredRowBuffer) Len() int {
+	return len(rb.rows)
+}
+
+// Less reports whether the entry with
+// index i should sort before the entry with index j.
+func (rb *StructuredRowBuffer) Less(i, j int) bool {
+	return rb.less(rb.rows[i], rb.rows[j])
+}
+
+// Swap swaps the entries with indexes i and j.
+func (rb *StructuredRowBuffer) Swap(i, j int) {
+	rb.rows[i], rb.rows[j] = rb.rows[j], rb.rows[i]
+}
+
+// less orders two rows by sort columns, falling back to write order
+func (rb *StructuredRowBuffer) less(a, b bufferedRow) bool {
+	for _, o := range rb.orders {
+		cmp := compareCells(o.cell(a.Entry), o.cell(b.Entry), o.t)
+		if cmp == 0 {
+			continue
+		}
+		if rb.desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+	if rb.desc && len(rb.orders) > 0 {
+		return a.Seq > b.Seq
+	}
+	return a.Seq < b.Seq
+}
+
+// sortRows sorts entries held in memory. rows are always written in order,
+// so there's nothing to do without sort columns
+func (rb *StructuredRowBuffer) sortRows() {
+	if len(rb.orders) > 0 {
+		sort.Sort(rb)
+	}
+}
+
+// spill writes entries held in memory to a temporary file as a sorted run
+func (rb *StructuredRowBuffer) spill() error {
+	rb.sortRows()
+
+	f, err := ioutil.TempFile("", "row_buffer")
+	if err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error creating buffer file: %s", err.Error())
+	}
+	rb.runs = append(rb.runs, f.Name())
+
+	enc := gob.NewEncoder(f)
+	for _, row := range rb.rows {
+		if err := enc.Encode(row); err != nil {
+			f.Close()
+			log.Debug(err.Error())
+			return fmt.Errorf("error writing buffer file: %s", err.Error())
+		}
+	}
+	if err := f.Close(); err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error writing buffer file: %s", err.Error())
+	}
+
+	rb.rows = nil
+	rb.memSize = 0
+	return nil
+}
+
+// cell gives the bytes of the value a row is sorted by
+func (o rowOrder) cell(ent Entry) []byte {
+	switch v := ent.Value.(type) {
+	case []interface{}:
+		if o.idx >= 0 && o.idx < len(v) {
+			return cellBytes(v[o.idx])
+		}
+	case map[string]interface{}:
+		return cellBytes(v[o.key])
+	}
+	return nil
+}
+
+// cellBytes formats a value for comparison with vals.CompareTypeBytes
+func cellBytes(v interface{}) []byte {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return []byte(x)
+	case []byte:
+		return x
+	case int:
+		return []byte(strconv.Itoa(x))
+	case int64:
+		return []byte(strconv.FormatInt(x, 10))
+	case float64:
+		return []byte(strconv.FormatFloat(x, 'f', -1, 64))
+	case bool:
+		return []byte(strconv.FormatBool(x))
+	default:
+		data, _ := json.Marshal(x)
+		return data
+	}
+}
+
+// compareCells compares two cell values as type t, inferring a type from
+// the values if t is unknown. Values that can't be compared as their type
+// are compared as raw bytes
+func compareCells(a, b []byte, t vals.Type) int {
+	if t == vals.TypeUnknown {
+		t = vals.TypeNumber
+		for _, cell := range [][]byte{a, b} {
+			if len(cell) > 0 && vals.ParseType(cell) != vals.TypeNumber && vals.ParseType(cell) != vals.TypeInteger {
+				t = vals.TypeString
+			}
+		}
+	}
+	cmp, err := vals.CompareTypeBytes(a, b, t)
+	if err != nil && t == vals.TypeInteger {
+		cmp, err = vals.CompareNumberBytes(a, b)
+	}
+	if err != nil {
+		return bytes.Compare(a, b)
+	}
+	return cmp
+}
+
+// newMerger creates a merger over all buffered entries
+func (rb *StructuredRowBuffer) newMerger() (*rowMerger, error) {
+	m := &rowMerger{less: rb.less}
+	for _, path := range rb.runs {
+		f, err := os.Open(path)
+		if err != nil {
+			m.close()
+			log.Debug(err.Error())
+			return nil, fmt.Errorf("error opening buffer file: %s", err.Error())
+		}
+		if err := m.add(&runSource{f: f, dec: gob.NewDecoder(f)}); err != nil {
+			m.close()
+			return nil, err
+		}
+	}
+	if err := m.add(&memSource{rows: rb.rows}); err != nil {
+		m.close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// rowSource is a sorted sequence of rows
+type rowSource interface {
+	next() (bufferedRow, error)
+	close()
+}
+
+// memSource reads rows held in memory
+type memSource struct {
+	rows []bufferedRow
+	i    int
+}
+
+func (s *memSource) next() (bufferedRow, error) {
+	if s.i >= len(s.rows) {
+		return bufferedRow{}, io.EOF
+	}
+	s.i++
+	return s.rows[s.i-1], nil
+}
+
+func (s *memSource) close() {}
+
+// runSource reads rows spilled to disk
+type runSource struct {
+	f   *os.File
+	dec *gob.Decoder
+}
+
+func (s *runSource) next() (row bufferedRow, err error) {
+	if err = s.dec.Decode(&row); err != nil && err != io.EOF {
+		log.Debug(err.Error())
+		err = fmt.Errorf("error reading buffer file: %s", err.Error())
+	}
+	return
+}
+
+func (s *runSource) close() {
+	s.f.Close()
+}
+
+// rowMerger merges sorted row sources into a single sorted sequence
+type rowMerger struct {
+	less    func(a, b bufferedRow) bool
+	heads   []bufferedRow
+	sources []rowSource
+	read    int
+}
+
+// add reads the first row of a source, adding it to the merge
+func (m *rowMerger) add(src rowSource) error {
+	row, err := src.next()
+	if err == io.EOF {
+		src.close()
+		return nil
+	} else if err != nil {
+		src.close()
+		return err
+	}
+	heap.Push(m, mergeHead{row, src})
+	return nil
+}
+
+// next gives the next row across all sources
+func (m *rowMerger) next() (bufferedRow, error) {
+	if len(m.heads) == 0 {
+		return bufferedRow{}, io.EOF
+	}
+	head := heap.Pop(m).(mergeHead)
+	m.read++
+	return head.row, m.add(head.src)
+}
+
+// close closes all remaining sources
+func (m *rowMerger) close() {
+	for _, src := range m.sources {
+		src.close()
+	}
+	m.heads = nil
+	m.sources = nil
+}
+
+// mergeHead is the next row of a source
+type mergeHead struct {
+	row bufferedRow
+	src rowSource
+}
+
+// Len implements heap.Interface
+func (m *rowMerger) Len() int { return len(m.heads) }
+
+// Less implements heap.Interface
+func (m *rowMerger) Less(i, j int) bool { return m.less(m.heads[i], m.heads[j]) }
+
+// Swap implements heap.Interface
+func (m *rowMerger) Swap(i, j int) {
+	m.heads[i], m.heads[j] = m.heads[j], m.heads[i]
+	m.sources[i], m.sources[j] = m.sources[j], m.sources[i]
+}
+
+// Push implements heap.Interface
+func (m *rowMerger) Push(x interface{}) {
+	h := x.(mergeHead)
+	m.heads = append(m.heads, h.row)
+	m.sources = append(m.sources, h.src)
+}
+
+// Pop implements heap.Interface
+func (m *rowMerger) Pop() interface{} {
+	n := len(m.heads) - 1
+	h := mergeHead{m.heads[n], m.sources[n]}
+	m.heads = m.heads[:n]
+	m.sources = m.sources[:n]
+	return h
+}
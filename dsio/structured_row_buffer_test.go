@@ -1,138 +1,173 @@
 package dsio
 
-// import (
-// 	"bytes"
-// 	"fmt"
-// 	"io/ioutil"
-// 	"os"
-// 	"testing"
-
-// 	"github.com/qri-io/dataset"
-// 	dmp "github.com/sergi/go-diff/diffmatchpatch"
-// )
-
-// func TestStructuredRowBuffer(t *testing.T) {
-// 	datasets, err := makeTestData()
-// 	if err != nil {
-// 		t.Errorf("error creating filestore", err.Error())
-// 		return
-// 	}
-
-// 	cases := []struct {
-// 		dsName     string
-// 		Cfg        func(cfg *StructuredRowBufferCfg)
-// 		resultPath string
-// 		// newErr     string
-// 	}{
-// 		{"movies", func(cfg *StructuredRowBufferCfg) {}, "testdata/movies.csv"},
-// 		{"movies", func(cfg *StructuredRowBufferCfg) {
-// 			cfg.OrderBy = []*dataset.Field{
-// 				&dataset.Field{Name: "movie_title"},
-// 			}
-// 		}, "testdata/movies_sorted_movie_title.csv"},
-// 		{"movies", func(cfg *StructuredRowBufferCfg) {
-// 			cfg.OrderBy = []*dataset.Field{
-// 				&dataset.Field{Name: "movie_title"},
-// 			}
-// 			cfg.OrderByDesc = true
-// 		}, "testdata/movies_sorted_movie_title_desc.csv"},
-// 		{"movies", func(cfg *StructuredRowBufferCfg) {
-// 			cfg.OrderBy = []*dataset.Field{
-// 				&dataset.Field{Name: "duration"},
-// 				&dataset.Field{Name: "movie_title"},
-// 			}
-// 		}, "testdata/movies_sorted_duration_movie_title.csv"},
-// 		{"movies", func(cfg *StructuredRowBufferCfg) {
-// 			cfg.OrderBy = []*dataset.Field{
-// 				&dataset.Field{Name: "duration"},
-// 			}
-// 			cfg.OrderByDesc = true
-// 		}, "testdata/movies_sorted_duration_desc.csv"},
-// 		{"cities", func(cfg *StructuredRowBufferCfg) {
-// 			cfg.Unique = true
-// 		}, "testdata/cities_unique.csv"},
-// 	}
-
-// 	for i, c := range cases {
-// 		ds := datasets[c.dsName].ds
-// 		// if err != nil {
-// 		// 	t.Errorf("error creating dataset: %s", err.Error())
-// 		// 	return
-// 		// }
-
-// 		// outst := &dataset.Structure{
-// 		// 	Format: dataset.JSONDataFormat,
-// 		// 	FormatConfig: &dataset.JSONOptions{
-// 		// 		ArrayEntries: false,
-// 		// 	},
-// 		// 	Schema: ds.Structure.Schema,
-// 		// }
-
-// 		srbuf, err := NewStructuredRowBuffer(ds.Structure, c.Cfg)
-// 		if err != nil {
-// 			t.Errorf("case %d error allocating StructuredRowBuffer: %s", i, err.Error())
-// 			continue
-// 		}
-
-// 		rr, err := NewRowReader(ds.Structure, bytes.NewBuffer(datasets[c.dsName].data))
-// 		if err != nil {
-// 			t.Errorf("case %d error allocating RowReader: %s", i, err.Error())
-// 			continue
-// 		}
-
-// 		if err = EachRow(rr, func(i int, row [][]byte, err error) error {
-// 			if err != nil {
-// 				return err
-// 			}
-// 			return srbuf.WriteRow(row)
-// 		}); err != nil {
-// 			t.Errorf("error writing rows: %s", err.Error())
-// 			continue
-// 		}
-
-// 		srbufs := srbuf.Structure()
-// 		if err := dataset.CompareStructures(ds.Structure, srbufs); err != nil {
-// 			t.Errorf("case %d buffer structure mismatch: %s", i, err.Error())
-// 			continue
-// 		}
-
-// 		if err := srbuf.Close(); err != nil {
-// 			t.Errorf("case %d error closing buffer: %s", i, err.Error())
-// 			continue
-// 		}
-
-// 		if c.resultPath != "" {
-// 			expectBytes, err := ioutil.ReadFile(c.resultPath)
-// 			if err != nil {
-// 				t.Errorf("case %d error reading result data file: %s", i, err.Error())
-// 				continue
-// 			}
-// 			if !bytes.Equal(expectBytes, srbuf.Bytes()) {
-// 				dmp := dmp.New()
-// 				diffs := dmp.DiffMain(string(expectBytes), string(srbuf.Bytes()), true)
-// 				if len(diffs) == 0 {
-// 					t.Logf("case %d bytes were unequal but computed no difference between results")
-// 					continue
-// 				}
-
-// 				t.Errorf("case %d mismatch:\n%s", i, dmp.DiffPrettyText(diffs))
-// 				path := fmt.Sprintf("%sTestStructuredRowBuffer_case_%d_data.%s", os.TempDir(), i, ds.Structure.Format.String())
-// 				if err := ioutil.WriteFile(path, srbuf.Bytes(), os.ModePerm); err == nil {
-// 					t.Logf("result bytes written to: %s", path)
-// 				}
-// 			}
-// 		}
-
-// 	}
-
-// 	// out := []interface{}{}
-// 	// if err := json.Unmarshal(rbuf.Bytes(), &out); err != nil {
-// 	// 	t.Errorf("error unmarshaling encoded bytes: %s", err.Error())
-// 	// 	return
-// 	// }
-
-// 	// if _, err = json.Marshal(out); err != nil {
-// 	// 	t.Errorf("error marshaling json data: %s", err.Error())
-// 	// 	return
-// 	// }
-// }
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dstest"
+)
+
+func TestStructuredRowBuffer(t *testing.T) {
+	tc, err := dstest.NewTestCaseFromDir("testdata/csv/movies")
+	if err != nil {
+		t.Fatalf("error loading test case: %s", err.Error())
+	}
+	movies := tc.Input.Structure
+
+	cases := []struct {
+		st         *dataset.Structure
+		dataPath   string
+		cfg        func(cfg *StructuredRowBufferCfg)
+		resultPath string
+	}{
+		{movies, "testdata/csv/movies/data.csv", func(cfg *StructuredRowBufferCfg) {}, "testdata/csv/movies/data.csv"},
+		{movies, "testdata/csv/movies/data.csv", func(cfg *StructuredRowBufferCfg) {
+			cfg.OrderBy = []string{"movie_title"}
+		}, "testdata/csv/movies_sorted_movie_title/data.csv"},
+		{movies, "testdata/csv/movies/data.csv", func(cfg *StructuredRowBufferCfg) {
+			cfg.OrderBy = []string{"movie_title"}
+			cfg.OrderByDesc = true
+		}, "testdata/csv/movies_sorted_movie_title_desc/data.csv"},
+		{movies, "testdata/csv/movies/data.csv", func(cfg *StructuredRowBufferCfg) {
+			cfg.OrderBy = []string{"duration", "movie_title"}
+		}, "testdata/csv/movies_sorted_duration_movie_title/data.csv"},
+		{movies, "testdata/csv/movies/data.csv", func(cfg *StructuredRowBufferCfg) {
+			cfg.OrderBy = []string{"duration"}
+			cfg.OrderByDesc = true
+		}, "testdata/csv/movies_sorted_duration_desc/data.csv"},
+		{movies, "testdata/csv/movies/data.csv", func(cfg *StructuredRowBufferCfg) {
+			cfg.OrderBy = []string{"duration", "movie_title"}
+			cfg.MemLimit = 200
+		}, "testdata/csv/movies_sorted_duration_movie_title/data.csv"},
+		{offsetStruct, "testdata/csv/cities/data.csv", func(cfg *StructuredRowBufferCfg) {
+			cfg.Unique = true
+		}, "testdata/csv/cities_unique/cities_unique.csv"},
+		{offsetStruct, "testdata/csv/cities/data.csv", func(cfg *StructuredRowBufferCfg) {
+			cfg.Unique = true
+			cfg.MemLimit = 1
+		}, "testdata/csv/cities_unique/cities_unique.csv"},
+	}
+
+	for i, c := range cases {
+		srbuf, err := NewStructuredRowBuffer(c.st, c.cfg)
+		if err != nil {
+			t.Errorf("case %d error allocating StructuredRowBuffer: %s", i, err.Error())
+			continue
+		}
+
+		f, err := os.Open(c.dataPath)
+		if err != nil {
+			t.Errorf("case %d error opening data: %s", i, err.Error())
+			continue
+		}
+		rr, err := NewEntryReader(c.st, f)
+		if err != nil {
+			t.Errorf("case %d error allocating EntryReader: %s", i, err.Error())
+			continue
+		}
+
+		err = EachEntry(rr, func(i int, ent Entry, err error) error {
+			if err != nil {
+				return err
+			}
+			return srbuf.WriteEntry(ent)
+		})
+		f.Close()
+		if err != nil {
+			t.Errorf("case %d error writing entries: %s", i, err.Error())
+			continue
+		}
+
+		if err := srbuf.Close(); err != nil {
+			t.Errorf("case %d error closing buffer: %s", i, err.Error())
+			continue
+		}
+
+		expectBytes, err := ioutil.ReadFile(c.resultPath)
+		if err != nil {
+			t.Errorf("case %d error reading result data file: %s", i, err.Error())
+			continue
+		}
+		if got := srbuf.Bytes(); !bytes.Equal(expectBytes, got) {
+			t.Errorf("case %d mismatch. expected:\n%s\ngot:\n%s", i, string(expectBytes), string(got))
+		}
+		if err := srbuf.Cleanup(); err != nil {
+			t.Errorf("case %d error cleaning up: %s", i, err.Error())
+		}
+	}
+}
+
+func TestStructuredRowBufferReadEntry(t *testing.T) {
+	st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray}
+	srbuf, err := NewStructuredRowBuffer(st, func(cfg *StructuredRowBufferCfg) {
+		cfg.OrderBy = []string{"n"}
+		cfg.FilterFunc = func(ent Entry) bool {
+			return ent.Value.(map[string]interface{})["n"] != nil
+		}
+		cfg.MemLimit = 40
+	})
+	if err != nil {
+		t.Fatalf("error allocating StructuredRowBuffer: %s", err.Error())
+	}
+
+	for _, n := range []interface{}{int64(3), nil, 1.5, int64(10), nil, int64(-2), int64(3)} {
+		if err := srbuf.WriteEntry(Entry{Value: map[string]interface{}{"n": n}}); err != nil {
+			t.Fatalf("error writing entry: %s", err.Error())
+		}
+	}
+	if _, err := srbuf.ReadEntry(); err == nil {
+		t.Errorf("expected reading an open buffer to error")
+	}
+	if len(srbuf.runs) == 0 {
+		t.Errorf("expected buffer to spill to disk")
+	}
+	if err := srbuf.Close(); err != nil {
+		t.Fatalf("error closing buffer: %s", err.Error())
+	}
+
+	got := []string{}
+	for {
+		ent, err := srbuf.ReadEntry()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("error reading entry: %s", err.Error())
+		}
+		got = append(got, fmt.Sprintf("%d:%v", ent.Index, ent.Value.(map[string]interface{})["n"]))
+	}
+
+	expect := "[0:-2 1:1.5 2:3 3:3 4:10]"
+	if fmt.Sprintf("%v", got) != expect {
+		t.Errorf("entry order mismatch. expected: %s, got: %v", expect, got)
+	}
+	if err := srbuf.Cleanup(); err != nil {
+		t.Errorf("error cleaning up: %s", err.Error())
+	}
+}
+
+func TestNewStructuredRowBuffer(t *testing.T) {
+	cases := []struct {
+		st      *dataset.Structure
+		orderBy []string
+		err     string
+	}{
+		{offsetStruct, nil, ""},
+		{offsetStruct, []string{"pop"}, ""},
+		{offsetStruct, []string{"population"}, "couldn't find sort field: population"},
+		{&dataset.Structure{}, []string{"pop"}, "structure has no schema"},
+	}
+
+	for i, c := range cases {
+		_, err := NewStructuredRowBuffer(c.st, func(cfg *StructuredRowBufferCfg) {
+			cfg.OrderBy = c.orderBy
+		})
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+		}
+	}
+}
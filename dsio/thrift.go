@@ -0,0 +1,343 @@
+package dsio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// thrift compact protocol type identifiers
+const (
+	thriftStop        byte = 0
+	thriftBoolTrue    byte = 1
+	thriftBoolFalse   byte = 2
+	thriftByte        byte = 3
+	thriftI16         byte = 4
+	thriftI32         byte = 5
+	thriftI64         byte = 6
+	thriftDouble      byte = 7
+	thriftBinary      byte = 8
+	thriftListType    byte = 9
+	thriftSetType     byte = 10
+	thriftMapType     byte = 11
+	thriftStructType  byte = 12
+	thriftMaxListSize      = 1 << 24
+	// parquet metadata nests a handful of levels deep, deeper data is
+	// malformed & would otherwise recurse without bound
+	thriftMaxDepth = 64
+)
+
+// thriftStruct is a decoded thrift struct, mapping field ids to values.
+// integer values are always decoded as int64, and lists as []interface{}
+type thriftStruct map[int16]interface{}
+
+// int gives the integer value of field id, or def if the field is missing
+func (s thriftStruct) int(id int16, def int64) int64 {
+	if v, ok := s[id].(int64); ok {
+		return v
+	}
+	return def
+}
+
+// bool gives the boolean value of field id, or def if the field is missing
+func (s thriftStruct) bool(id int16, def bool) bool {
+	if v, ok := s[id].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// str gives the binary value of field id as a string
+func (s thriftStruct) str(id int16) string {
+	if v, ok := s[id].([]byte); ok {
+		return string(v)
+	}
+	return ""
+}
+
+// strct gives the struct value of field id, nil if the field is missing
+func (s thriftStruct) strct(id int16) thriftStruct {
+	if v, ok := s[id].(thriftStruct); ok {
+		return v
+	}
+	return nil
+}
+
+// list gives the list value of field id
+func (s thriftStruct) list(id int16) []interface{} {
+	if v, ok := s[id].([]interface{}); ok {
+		return v
+	}
+	return nil
+}
+
+// thriftReader decodes thrift compact protocol data
+type thriftReader struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (r *thriftReader) readByte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, fmt.Errorf("unexpected end of thrift data")
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *thriftReader) readUvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid thrift varint")
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) readZigzag() (int64, error) {
+	v, err := r.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+// readStruct reads a struct, including the stop field that ends it
+func (r *thriftReader) readStruct() (thriftStruct, error) {
+	s := thriftStruct{}
+	var lastID int16
+	for {
+		h, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		typ := h & 0x0f
+		if typ == thriftStop {
+			return s, nil
+		}
+
+		id := lastID + int16(h>>4)
+		if h>>4 == 0 {
+			v, err := r.readZigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		lastID = id
+
+		switch typ {
+		case thriftBoolTrue:
+			s[id] = true
+		case thriftBoolFalse:
+			s[id] = false
+		default:
+			v, err := r.readValue(typ)
+			if err != nil {
+				return nil, err
+			}
+			s[id] = v
+		}
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) (interface{}, error) {
+	switch typ {
+	case thriftListType, thriftSetType, thriftMapType, thriftStructType:
+		if r.depth >= thriftMaxDepth {
+			return nil, fmt.Errorf("thrift data nested more than %d levels deep", thriftMaxDepth)
+		}
+		r.depth++
+		defer func() { r.depth-- }()
+	}
+
+	switch typ {
+	case thriftBoolTrue, thriftBoolFalse:
+		// booleans outside of struct field headers are encoded as a single byte
+		b, err := r.readByte()
+		return b == thriftBoolTrue, err
+	case thriftByte:
+		b, err := r.readByte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return r.readZigzag()
+	case thriftDouble:
+		if r.pos+8 > len(r.data) {
+			return nil, fmt.Errorf("unexpected end of thrift data")
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos:]))
+		r.pos += 8
+		return v, nil
+	case thriftBinary:
+		size, err := r.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if size > uint64(len(r.data)-r.pos) {
+			return nil, fmt.Errorf("unexpected end of thrift data")
+		}
+		v := r.data[r.pos : r.pos+int(size)]
+		r.pos += int(size)
+		return v, nil
+	case thriftListType, thriftSetType:
+		h, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(h >> 4)
+		if size == 15 {
+			if size, err = r.readUvarint(); err != nil {
+				return nil, err
+			}
+		}
+		if size > thriftMaxListSize {
+			return nil, fmt.Errorf("thrift list too large: %d", size)
+		}
+		list := make([]interface{}, int(size))
+		for i := range list {
+			if list[i], err = r.readValue(h & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case thriftMapType:
+		size, err := r.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		if size > thriftMaxListSize {
+			return nil, fmt.Errorf("thrift map too large: %d", size)
+		}
+		kv, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		// maps aren't used in parquet metadata, read & discard entries
+		for i := uint64(0); i < size; i++ {
+			if _, err := r.readValue(kv >> 4); err != nil {
+				return nil, err
+			}
+			if _, err := r.readValue(kv & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case thriftStructType:
+		return r.readStruct()
+	default:
+		return nil, fmt.Errorf("invalid thrift type: %d", typ)
+	}
+}
+
+// thriftField is a single field to encode. values must be one of bool,
+// int32, int64, []byte, string, float64, []thriftField (a struct) or thriftList
+type thriftField struct {
+	id    int16
+	value interface{}
+}
+
+// thriftList is a list to encode, all items must be of the given type
+type thriftList struct {
+	typ   byte
+	items []interface{}
+}
+
+// thriftWriter encodes values with the thrift compact protocol
+type thriftWriter struct {
+	buf []byte
+}
+
+func (w *thriftWriter) writeUvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf = append(w.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (w *thriftWriter) writeZigzag(v int64) {
+	w.writeUvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+// writeStruct writes fields as a struct. fields must be in ascending id order
+func (w *thriftWriter) writeStruct(fields []thriftField) {
+	var lastID int16
+	for _, f := range fields {
+		typ := thriftType(f.value)
+		if b, ok := f.value.(bool); ok && !b {
+			typ = thriftBoolFalse
+		}
+
+		if delta := f.id - lastID; delta > 0 && delta <= 15 {
+			w.buf = append(w.buf, byte(delta)<<4|typ)
+		} else {
+			w.buf = append(w.buf, typ)
+			w.writeZigzag(int64(f.id))
+		}
+		lastID = f.id
+
+		if _, ok := f.value.(bool); !ok {
+			w.writeValue(f.value)
+		}
+	}
+	w.buf = append(w.buf, thriftStop)
+}
+
+func (w *thriftWriter) writeValue(v interface{}) {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			w.buf = append(w.buf, thriftBoolTrue)
+		} else {
+			w.buf = append(w.buf, thriftBoolFalse)
+		}
+	case int32:
+		w.writeZigzag(int64(v))
+	case int64:
+		w.writeZigzag(v)
+	case float64:
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+		w.buf = append(w.buf, b[:]...)
+	case []byte:
+		w.writeUvarint(uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	case string:
+		w.writeUvarint(uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	case []thriftField:
+		w.writeStruct(v)
+	case thriftList:
+		if len(v.items) < 15 {
+			w.buf = append(w.buf, byte(len(v.items))<<4|v.typ)
+		} else {
+			w.buf = append(w.buf, 0xf0|v.typ)
+			w.writeUvarint(uint64(len(v.items)))
+		}
+		for _, item := range v.items {
+			w.writeValue(item)
+		}
+	}
+}
+
+// thriftType gives the compact protocol type of a value to encode
+func thriftType(v interface{}) byte {
+	switch v.(type) {
+	case bool:
+		return thriftBoolTrue
+	case int32:
+		return thriftI32
+	case int64:
+		return thriftI64
+	case float64:
+		return thriftDouble
+	case []byte, string:
+		return thriftBinary
+	case thriftList:
+		return thriftListType
+	default:
+		return thriftStructType
+	}
+}
@@ -0,0 +1,327 @@
+package dsio
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/qri-io/dataset"
+)
+
+// XLSXReader implements the EntryReader interface for excel workbooks
+// in the office open xml (.xlsx) format. Legacy binary .xls workbooks
+// are not supported. Each row of the selected worksheet is read as an
+// array entry, with cell values cast to the types specified by the
+// structure's schema
+type XLSXReader struct {
+	st         *dataset.Structure
+	readHeader bool
+	types      []string
+	sheet      io.ReadCloser
+	dec        *xml.Decoder
+	strs       []string
+}
+
+// NewXLSXReader creates a reader from a structure and read source.
+// Workbooks are zip archives that require random access to read, so the
+// entire source is buffered in memory, worksheet rows are decoded as
+// they're read
+func NewXLSXReader(st *dataset.Structure, r io.Reader) (*XLSXReader, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error reading workbook: %s", err.Error())
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error opening workbook: %s", err.Error())
+	}
+
+	opts, _ := st.FormatConfig.(*dataset.XLSOptions)
+	if opts == nil {
+		opts = &dataset.XLSOptions{}
+	}
+
+	sheetPath, err := xlsxSheetPath(zr, opts)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	strs, err := xlsxSharedStrings(zr)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	sheet, err := xlsxOpen(zr, sheetPath)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	// TODO - handle error
+	_, types, _ := terribleHackToGetHeaderRowAndTypes(st)
+
+	return &XLSXReader{
+		st:    st,
+		types: types,
+		sheet: sheet,
+		dec:   xml.NewDecoder(sheet),
+		strs:  strs,
+	}, nil
+}
+
+// Structure gives this reader's structure
+func (r *XLSXReader) Structure() *dataset.Structure {
+	return r.st
+}
+
+// ReadEntry reads one worksheet row from the reader
+func (r *XLSXReader) ReadEntry() (Entry, error) {
+	if !r.readHeader {
+		r.readHeader = true
+		if opts, ok := r.st.FormatConfig.(*dataset.XLSOptions); ok && opts.HeaderRow {
+			if _, err := r.ReadRow(); err != nil {
+				if err != io.EOF {
+					log.Debug(err.Error())
+				}
+				return Entry{}, err
+			}
+		}
+	}
+
+	row, err := r.ReadRow()
+	if err != nil {
+		if err != io.EOF {
+			log.Debug(err.Error())
+		}
+		return Entry{}, err
+	}
+
+	vs := make([]interface{}, len(row))
+	for i, str := range row {
+		typ := "string"
+		if i < len(r.types) {
+			typ = r.types[i]
+		}
+		vs[i] = castString(typ, str)
+	}
+
+	return Entry{Value: vs}, nil
+}
+
+// ReadRow reads the string values of the next worksheet row, ignoring
+// the structure's schema. Empty cells that precede a cell with a value
+// are given as empty strings. ReadRow returns io.EOF after the last row
+func (r *XLSXReader) ReadRow() ([]string, error) {
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				r.sheet.Close()
+			}
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "row" {
+			row := &xlsxRow{}
+			if err := r.dec.DecodeElement(row, &start); err != nil {
+				return nil, fmt.Errorf("error decoding worksheet row: %s", err.Error())
+			}
+			return r.rowStrings(row)
+		}
+	}
+}
+
+// rowStrings converts a decoded row to a slice of cell values
+func (r *XLSXReader) rowStrings(row *xlsxRow) ([]string, error) {
+	strs := []string{}
+	for _, c := range row.Cells {
+		col := len(strs)
+		if c.Ref != "" {
+			var err error
+			if col, err = xlsxColumnIndex(c.Ref); err != nil {
+				return nil, err
+			}
+		}
+		for len(strs) < col {
+			strs = append(strs, "")
+		}
+
+		val := c.Value
+		switch c.Type {
+		case "s":
+			idx, err := strconv.Atoi(c.Value)
+			if err != nil || idx < 0 || idx >= len(r.strs) {
+				return nil, fmt.Errorf("invalid shared string index for cell %s: %s", c.Ref, c.Value)
+			}
+			val = r.strs[idx]
+		case "inlineStr":
+			val = c.Inline.String()
+		case "b":
+			if c.Value == "1" {
+				val = "true"
+			} else {
+				val = "false"
+			}
+		}
+		if col < len(strs) {
+			strs[col] = val
+		} else {
+			strs = append(strs, val)
+		}
+	}
+	return strs, nil
+}
+
+// xlsxColumnIndex gives the zero-based column index for a cell reference
+// like "AB12"
+func xlsxColumnIndex(ref string) (int, error) {
+	col := 0
+	for i, ch := range ref {
+		if ch >= 'A' && ch <= 'Z' {
+			col = col*26 + int(ch-'A'+1)
+			continue
+		}
+		if i == 0 {
+			break
+		}
+		return col - 1, nil
+	}
+	return 0, fmt.Errorf("invalid cell reference: %s", ref)
+}
+
+// xlsxRow is a single worksheet row
+type xlsxRow struct {
+	Cells []xlsxCell `xml:"c"`
+}
+
+// xlsxCell is a single worksheet cell
+type xlsxCell struct {
+	Ref    string         `xml:"r,attr"`
+	Type   string         `xml:"t,attr"`
+	Value  string         `xml:"v"`
+	Inline xlsxStringItem `xml:"is"`
+}
+
+// xlsxStringItem is a string that may be broken into multiple formatted runs
+type xlsxStringItem struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+// String joins all text in a string item
+func (si xlsxStringItem) String() string {
+	str := si.Text
+	for _, run := range si.Runs {
+		str += run.Text
+	}
+	return str
+}
+
+// xlsxSheetPath resolves the archive path of the worksheet specified by opts
+func xlsxSheetPath(zr *zip.Reader, opts *dataset.XLSOptions) (string, error) {
+	wb := struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}{}
+	if err := xlsxDecode(zr, "xl/workbook.xml", &wb); err != nil {
+		return "", err
+	}
+
+	rid := ""
+	if opts.SheetName != "" {
+		for _, sh := range wb.Sheets {
+			if sh.Name == opts.SheetName {
+				rid = sh.RID
+			}
+		}
+		if rid == "" {
+			return "", fmt.Errorf("sheet not found: %s", opts.SheetName)
+		}
+	} else {
+		if opts.SheetIndex >= len(wb.Sheets) {
+			return "", fmt.Errorf("sheet index out of range: %d", opts.SheetIndex)
+		}
+		rid = wb.Sheets[opts.SheetIndex].RID
+	}
+
+	rels := struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}{}
+	if err := xlsxDecode(zr, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID == rid {
+			// targets are relative to the xl directory unless absolute
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+	return "", fmt.Errorf("missing worksheet relationship: %s", rid)
+}
+
+// xlsxSharedStrings reads the shared string table of a workbook, which is
+// optional and only present if cells reference shared strings
+func xlsxSharedStrings(zr *zip.Reader) ([]string, error) {
+	sst := struct {
+		Items []xlsxStringItem `xml:"si"`
+	}{}
+	if err := xlsxDecode(zr, "xl/sharedStrings.xml", &sst); err != nil {
+		if err == errXLSXMissingFile {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	strs := make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		strs[i] = si.String()
+	}
+	return strs, nil
+}
+
+// errXLSXMissingFile is returned when a workbook doesn't contain a file
+var errXLSXMissingFile = fmt.Errorf("file not found in workbook")
+
+// xlsxOpen opens a file within a workbook archive
+func xlsxOpen(zr *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f.Open()
+		}
+	}
+	return nil, errXLSXMissingFile
+}
+
+// xlsxDecode decodes an entire xml file within a workbook archive into v
+func xlsxDecode(zr *zip.Reader, name string, v interface{}) error {
+	f, err := xlsxOpen(zr, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := xml.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("error decoding %s: %s", name, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package dsio
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+)
+
+var xlsxStruct = &dataset.Structure{
+	Format: dataset.XLSDataFormat,
+	FormatConfig: &dataset.XLSOptions{
+		HeaderRow: true,
+	},
+	Schema: jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type":"array",
+			"items": [
+				{"title":"city","type":"string"},
+				{"title":"pop","type":"integer"},
+				{"title":"avg_age","type":"number"},
+				{"title":"in_usa","type":"boolean"}
+			]
+		}
+	}`),
+}
+
+func TestXLSXReader(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/xlsx/cities.xlsx")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cases := []struct {
+		structure *dataset.Structure
+		data      []byte
+		entries   []Entry
+		err       string
+	}{
+		{xlsxStruct, []byte("not a workbook"), nil, "error opening workbook: zip: not a valid zip file"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray, FormatConfig: &dataset.XLSOptions{SheetName: "nope"}}, data, nil, "sheet not found: nope"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray, FormatConfig: &dataset.XLSOptions{SheetIndex: 2}}, data, nil, "sheet index out of range: 2"},
+		{xlsxStruct, data, []Entry{
+			{Value: []interface{}{"toronto", int64(40000000), float64(55.5), false}},
+			{Value: []interface{}{"new york", int64(8500000), float64(44.4), true}},
+			{Value: []interface{}{"chicago", int64(300000), float64(44.4), true}},
+			{Value: []interface{}{"chatham", int64(35000), float64(65.25), true}},
+			{Value: []interface{}{"raleigh", int64(250000), float64(50.65), true}},
+		}, ""},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray}, data, []Entry{
+			{Value: []interface{}{"city", "pop", "avg_age", "in_usa"}},
+			{Value: []interface{}{"toronto", "40000000", "55.5", "false"}},
+			{Value: []interface{}{"new york", "8500000", "44.4", "true"}},
+			{Value: []interface{}{"chicago", "300000", "44.4", "true"}},
+			{Value: []interface{}{"chatham", "35000", "65.25", "true"}},
+			{Value: []interface{}{"raleigh", "250000", "50.65", "true"}},
+		}, ""},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray, FormatConfig: &dataset.XLSOptions{SheetName: "sparse"}}, data, []Entry{
+			{Value: []interface{}{"1", "", "foo"}},
+			{Value: []interface{}{"2", "2.5", "bar"}},
+		}, ""},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray, FormatConfig: &dataset.XLSOptions{SheetIndex: 1, HeaderRow: true}}, data, []Entry{
+			{Value: []interface{}{"2", "2.5", "bar"}},
+		}, ""},
+	}
+
+	for i, c := range cases {
+		r, err := NewXLSXReader(c.structure, bytes.NewReader(c.data))
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s. got: %s", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		got := []Entry{}
+		err = EachEntry(r, func(j int, ent Entry, err error) error {
+			got = append(got, ent)
+			return err
+		})
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+
+		if !reflect.DeepEqual(c.entries, got) {
+			t.Errorf("case %d entries mismatch. expected:\n%v\ngot:\n%v", i, c.entries, got)
+		}
+	}
+}
+
+func TestXLSXColumnIndex(t *testing.T) {
+	cases := []struct {
+		ref    string
+		expect int
+		err    string
+	}{
+		{"A1", 0, ""},
+		{"D12", 3, ""},
+		{"Z3", 25, ""},
+		{"AA3", 26, ""},
+		{"AB100", 27, ""},
+		{"1", 0, "invalid cell reference: 1"},
+		{"AB", 0, "invalid cell reference: AB"},
+	}
+
+	for i, c := range cases {
+		got, err := xlsxColumnIndex(c.ref)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s. got: %s", i, c.err, err)
+			continue
+		}
+		if got != c.expect {
+			t.Errorf("case %d result mismatch. expected: %d, got: %d", i, c.expect, got)
+		}
+	}
+}
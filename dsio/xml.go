@@ -0,0 +1,446 @@
+package dsio
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/qri-io/dataset"
+)
+
+const (
+	// xmlTextKey is the object key for character data in elements that also
+	// have attributes or child elements
+	xmlTextKey = "#text"
+	// defaultXMLAttributePrefix is prepended to attribute names when
+	// XMLOptions doesn't specify an attribute prefix
+	defaultXMLAttributePrefix = "@"
+	// defaultXMLRootElement is the root element name XMLWriter uses when
+	// XMLOptions doesn't specify one
+	defaultXMLRootElement = "data"
+	// defaultXMLEntryElement is the entry element name XMLWriter uses when
+	// XMLOptions doesn't specify one
+	defaultXMLEntryElement = "entry"
+)
+
+// xmlOptions gives the XMLOptions for a structure, with defaults applied
+func xmlOptions(st *dataset.Structure) *dataset.XMLOptions {
+	opts := &dataset.XMLOptions{}
+	if o, ok := st.FormatConfig.(*dataset.XMLOptions); ok && o != nil {
+		*opts = *o
+	}
+	if opts.AttributePrefix == "" {
+		opts.AttributePrefix = defaultXMLAttributePrefix
+	}
+	return opts
+}
+
+// XMLReader implements the EntryReader interface for the XML data format.
+// XML data is expected to be a single root element containing repeated
+// child elements, each child element is read as one entry.
+// If the structure's schema describes an array of titled columns, entries
+// are read as arrays with child element values ordered by column title.
+// Otherwise entries are read as objects, with attributes and child elements
+// as keys.
+type XMLReader struct {
+	st     *dataset.Structure
+	opts   *dataset.XMLOptions
+	dec    *xml.Decoder
+	sm     scanMode
+	titles []string
+	types  []string
+	inRoot bool
+}
+
+// NewXMLReader creates a reader from a structure and read source. A character
+// encoding declared in the XML prolog is honored if the structure doesn't
+// specify an encoding
+func NewXMLReader(st *dataset.Structure, r io.Reader) (*XMLReader, error) {
+	if st.Schema == nil {
+		err := fmt.Errorf("schema required for XML reader")
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	sm, err := schemaScanMode(st.Schema)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	// error is ignored b/c not all XML datasets are tabular
+	titles, types, _ := terribleHackToGetHeaderRowAndTypes(st)
+
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		if st.Encoding != "" {
+			// data has already been transcoded from the structure's encoding
+			// by NewEntryReader, which takes precedence over the declaration
+			return input, nil
+		}
+		return NewCharsetReader(label, input)
+	}
+
+	return &XMLReader{
+		st:     st,
+		opts:   xmlOptions(st),
+		dec:    dec,
+		sm:     sm,
+		titles: titles,
+		types:  types,
+	}, nil
+}
+
+// Structure gives this reader's structure
+func (r *XMLReader) Structure() *dataset.Structure {
+	return r.st
+}
+
+// ReadEntry reads one XML element from the reader
+func (r *XMLReader) ReadEntry() (Entry, error) {
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			if err != io.EOF {
+				log.Debug(err.Error())
+			}
+			return Entry{}, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if !r.inRoot {
+				r.inRoot = true
+				continue
+			}
+			if r.opts.EntryElement != "" && t.Name.Local != r.opts.EntryElement {
+				if err := r.dec.Skip(); err != nil {
+					log.Debug(err.Error())
+					return Entry{}, err
+				}
+				continue
+			}
+
+			val, err := r.readElement(t)
+			if err != nil {
+				log.Debug(err.Error())
+				return Entry{}, err
+			}
+			ent := Entry{Value: r.entryValue(val)}
+			if r.sm == smObject {
+				ent.Key = t.Name.Local
+			}
+			return ent, nil
+		case xml.EndElement:
+			// the only end element we can encounter here closes the root element
+			return Entry{}, io.EOF
+		}
+	}
+}
+
+// readElement decodes the contents of an element into either a string
+// or an object, consuming tokens up to & including the closing tag
+func (r *XMLReader) readElement(start xml.StartElement) (interface{}, error) {
+	obj := map[string]interface{}{}
+	if !r.opts.IgnoreAttributes {
+		for _, attr := range start.Attr {
+			obj[r.opts.AttributePrefix+attr.Name.Local] = attr.Value
+		}
+	}
+
+	text := &bytes.Buffer{}
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("unexpected EOF reading element: %s", start.Name.Local)
+			}
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			val, err := r.readElement(t)
+			if err != nil {
+				return nil, err
+			}
+			// repeated child elements are collected into an array. readElement
+			// never returns an array itself, so any array present is a repeat
+			name := t.Name.Local
+			switch prev := obj[name].(type) {
+			case nil:
+				obj[name] = val
+			case []interface{}:
+				obj[name] = append(prev, val)
+			default:
+				obj[name] = []interface{}{prev, val}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			str := strings.TrimSpace(text.String())
+			if len(obj) == 0 {
+				return str, nil
+			}
+			if str != "" {
+				obj[xmlTextKey] = str
+			}
+			return obj, nil
+		}
+	}
+}
+
+// entryValue arranges element values into rows when reading tabular data
+func (r *XMLReader) entryValue(v interface{}) interface{} {
+	obj, ok := v.(map[string]interface{})
+	if !ok || !tabularTitles(r.titles) {
+		return v
+	}
+
+	row := make([]interface{}, len(r.titles))
+	for i, title := range r.titles {
+		if str, ok := obj[title].(string); ok {
+			row[i] = castString(r.types[i], str)
+		} else {
+			row[i] = obj[title]
+		}
+	}
+	return row
+}
+
+// tabularTitles checks that a list of column titles is non-empty
+// and that every column has a title
+func tabularTitles(titles []string) bool {
+	if len(titles) == 0 {
+		return false
+	}
+	for _, t := range titles {
+		if t == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// XMLWriter implements the EntryWriter interface for
+// XML-formatted data
+type XMLWriter struct {
+	rowsWritten int
+	st          *dataset.Structure
+	opts        *dataset.XMLOptions
+	sm          scanMode
+	titles      []string
+	enc         *xml.Encoder
+	keysWritten map[string]bool
+}
+
+// NewXMLWriter creates a Writer from a structure and write destination
+func NewXMLWriter(st *dataset.Structure, w io.Writer) (*XMLWriter, error) {
+	if st.Schema == nil {
+		err := fmt.Errorf("schema required for XML writer")
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	sm, err := schemaScanMode(st.Schema)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+
+	opts := xmlOptions(st)
+	if opts.RootElement == "" {
+		opts.RootElement = defaultXMLRootElement
+	}
+	if opts.EntryElement == "" {
+		opts.EntryElement = defaultXMLEntryElement
+	}
+
+	// error is ignored b/c not all XML datasets are tabular
+	titles, _, _ := terribleHackToGetHeaderRowAndTypes(st)
+
+	xw := &XMLWriter{
+		st:     st,
+		opts:   opts,
+		sm:     sm,
+		titles: titles,
+		enc:    xml.NewEncoder(w),
+	}
+	if sm == smObject {
+		xw.keysWritten = map[string]bool{}
+	}
+	return xw, nil
+}
+
+// Structure gives this writer's structure
+func (w *XMLWriter) Structure() *dataset.Structure {
+	return w.st
+}
+
+// WriteEntry writes one XML element to the writer
+func (w *XMLWriter) WriteEntry(ent Entry) error {
+	if w.rowsWritten == 0 {
+		if err := w.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: w.opts.RootElement}}); err != nil {
+			log.Debug(err.Error())
+			return fmt.Errorf("error writing root element: %s", err.Error())
+		}
+	}
+
+	name := w.opts.EntryElement
+	if w.sm == smObject {
+		if ent.Key == "" {
+			log.Debug("write empty key")
+			return fmt.Errorf("entry key cannot be empty")
+		} else if w.keysWritten[ent.Key] {
+			log.Debugf(`key already written: "%s"`, ent.Key)
+			return fmt.Errorf(`key already written: "%s"`, ent.Key)
+		}
+		w.keysWritten[ent.Key] = true
+		name = ent.Key
+	}
+
+	var err error
+	if row, ok := ent.Value.([]interface{}); ok && tabularTitles(w.titles) && len(row) == len(w.titles) {
+		err = w.writeRow(name, row)
+	} else {
+		err = w.writeElement(name, ent.Value)
+	}
+	if err != nil {
+		log.Debug(err.Error())
+		return err
+	}
+
+	w.rowsWritten++
+	return nil
+}
+
+// writeRow writes an array of values as child elements named by column title
+func (w *XMLWriter) writeRow(name string, row []interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := w.enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for i, cell := range row {
+		if err := w.writeElement(w.titles[i], cell); err != nil {
+			return err
+		}
+	}
+	return w.enc.EncodeToken(start.End())
+}
+
+// writeElement writes a single value as an element, mapping object
+// keys to attributes & child elements
+func (w *XMLWriter) writeElement(name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		children := []string{}
+		for _, key := range keys {
+			if key == xmlTextKey {
+				continue
+			}
+			if strings.HasPrefix(key, w.opts.AttributePrefix) {
+				str, err := xmlString(t[key])
+				if err != nil {
+					return err
+				}
+				start.Attr = append(start.Attr, xml.Attr{
+					Name:  xml.Name{Local: strings.TrimPrefix(key, w.opts.AttributePrefix)},
+					Value: str,
+				})
+				continue
+			}
+			children = append(children, key)
+		}
+
+		if err := w.enc.EncodeToken(start); err != nil {
+			return err
+		}
+		if text, ok := t[xmlTextKey]; ok {
+			str, err := xmlString(text)
+			if err != nil {
+				return err
+			}
+			if err := w.enc.EncodeToken(xml.CharData(str)); err != nil {
+				return err
+			}
+		}
+		for _, key := range children {
+			// arrays within objects are written as repeated elements
+			if arr, ok := t[key].([]interface{}); ok {
+				for _, item := range arr {
+					if err := w.writeElement(key, item); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := w.writeElement(key, t[key]); err != nil {
+				return err
+			}
+		}
+		return w.enc.EncodeToken(start.End())
+
+	case []interface{}:
+		if err := w.enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range t {
+			if err := w.writeElement("item", item); err != nil {
+				return err
+			}
+		}
+		return w.enc.EncodeToken(start.End())
+
+	default:
+		str, err := xmlString(t)
+		if err != nil {
+			return err
+		}
+		if err := w.enc.EncodeToken(start); err != nil {
+			return err
+		}
+		if str != "" {
+			if err := w.enc.EncodeToken(xml.CharData(str)); err != nil {
+				return err
+			}
+		}
+		return w.enc.EncodeToken(start.End())
+	}
+}
+
+// xmlString converts a scalar value to it's string representation
+func xmlString(v interface{}) (string, error) {
+	strs, err := encode([]interface{}{v})
+	if err != nil {
+		return "", err
+	}
+	return strs[0], nil
+}
+
+// Close finalizes the writer, indicating no more records
+// will be written
+func (w *XMLWriter) Close() error {
+	root := xml.StartElement{Name: xml.Name{Local: w.opts.RootElement}}
+	if w.rowsWritten == 0 {
+		if err := w.enc.EncodeToken(root); err != nil {
+			log.Debug(err.Error())
+			return fmt.Errorf("error writing root element: %s", err.Error())
+		}
+	}
+	if err := w.enc.EncodeToken(root.End()); err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error closing writer: %s", err.Error())
+	}
+	return w.enc.Flush()
+}
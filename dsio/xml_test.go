@@ -0,0 +1,244 @@
+package dsio
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+)
+
+const xmlData = `<?xml version="1.0"?>
+<cities>
+	<city><name>toronto</name><pop>40000000</pop><avg_age>55.5</avg_age><in_usa>false</in_usa></city>
+	<city><name>new york</name><pop>8500000</pop><avg_age>44.4</avg_age><in_usa>true</in_usa></city>
+	<note>not a city</note>
+	<city><name>chicago</name><pop>300000</pop><avg_age>44.4</avg_age><in_usa>true</in_usa></city>
+</cities>`
+
+var xmlStruct = &dataset.Structure{
+	Format: dataset.XMLDataFormat,
+	FormatConfig: &dataset.XMLOptions{
+		RootElement:  "cities",
+		EntryElement: "city",
+	},
+	Schema: jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type":"array",
+			"items": [
+				{"title":"name","type":"string"},
+				{"title":"pop","type":"integer"},
+				{"title":"avg_age","type":"number"},
+				{"title":"in_usa","type":"boolean"}
+			]
+		}
+	}`),
+}
+
+func TestXMLReader(t *testing.T) {
+	cases := []struct {
+		structure *dataset.Structure
+		data      string
+		entries   []Entry
+		err       string
+	}{
+		{&dataset.Structure{}, "", nil, "schema required for XML reader"},
+		{&dataset.Structure{Schema: jsonschema.Must(`false`)}, "", nil, "invalid schema. root must be either an array or object type"},
+		{xmlStruct, xmlData, []Entry{
+			{Value: []interface{}{"toronto", int64(40000000), float64(55.5), false}},
+			{Value: []interface{}{"new york", int64(8500000), float64(44.4), true}},
+			{Value: []interface{}{"chicago", int64(300000), float64(44.4), true}},
+		}, ""},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray}, `<data><a id="1">foo</a><b><c>bar</c><c>baz</c></b></data>`, []Entry{
+			{Value: map[string]interface{}{"@id": "1", "#text": "foo"}},
+			{Value: map[string]interface{}{"c": []interface{}{"bar", "baz"}}},
+		}, ""},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray, FormatConfig: &dataset.XMLOptions{IgnoreAttributes: true}}, `<data><a id="1">foo</a></data>`, []Entry{
+			{Value: "foo"},
+		}, ""},
+		{&dataset.Structure{Schema: dataset.BaseSchemaObject, FormatConfig: &dataset.XMLOptions{AttributePrefix: "_"}}, `<data><a id="1"/><b>bar</b></data>`, []Entry{
+			{Key: "a", Value: map[string]interface{}{"_id": "1"}},
+			{Key: "b", Value: "bar"},
+		}, ""},
+	}
+
+	for i, c := range cases {
+		r, err := NewXMLReader(c.structure, bytes.NewBufferString(c.data))
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s. got: %s", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		got := []Entry{}
+		for {
+			ent, err := r.ReadEntry()
+			if err != nil {
+				if err.Error() == "EOF" {
+					break
+				}
+				t.Errorf("case %d error reading entry %d: %s", i, len(got), err.Error())
+				break
+			}
+			got = append(got, ent)
+		}
+
+		if !reflect.DeepEqual(c.entries, got) {
+			t.Errorf("case %d entries mismatch. expected:\n%v\ngot:\n%v", i, c.entries, got)
+		}
+	}
+}
+
+func TestXMLWriter(t *testing.T) {
+	objst := &dataset.Structure{Schema: dataset.BaseSchemaObject}
+	arrst := &dataset.Structure{Schema: dataset.BaseSchemaArray}
+
+	cases := []struct {
+		structure *dataset.Structure
+		entries   []Entry
+		out       string
+		err       string
+	}{
+		{&dataset.Structure{}, []Entry{}, "", "schema required for XML writer"},
+		{&dataset.Structure{Schema: jsonschema.Must(`true`)}, []Entry{}, "", "invalid schema. root must be either an array or object type"},
+
+		{arrst, []Entry{}, "<data></data>", ""},
+		{objst, []Entry{}, "<data></data>", ""},
+		{arrst, []Entry{{Value: "a"}, {Value: float64(1.5)}, {Value: nil}}, "<data><entry>a</entry><entry>1.5</entry><entry></entry></data>", ""},
+		{arrst, []Entry{{Value: map[string]interface{}{"@id": "1", "#text": "foo", "b": []interface{}{"x", "y"}}}}, `<data><entry id="1">foo<b>x</b><b>y</b></entry></data>`, ""},
+		{objst, []Entry{{Key: "a", Value: "hello"}, {Key: "b", Value: "world"}}, `<data><a>hello</a><b>world</b></data>`, ""},
+		{xmlStruct, []Entry{{Value: []interface{}{"toronto", int64(40000000), float64(55.5), false}}}, `<cities><city><name>toronto</name><pop>40000000</pop><avg_age>55.5</avg_age><in_usa>false</in_usa></city></cities>`, ""},
+	}
+
+	for i, c := range cases {
+		buf := &bytes.Buffer{}
+		w, err := NewXMLWriter(c.structure, buf)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: %s. got: %s", i, c.err, err)
+			continue
+		} else if c.err != "" {
+			continue
+		}
+
+		for _, ent := range c.entries {
+			if err := w.WriteEntry(ent); err != nil {
+				t.Errorf("case %d WriteEntry error: %s", i, err.Error())
+				break
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("case %d Close error: %s", i, err.Error())
+		}
+
+		if buf.String() != c.out {
+			t.Errorf("case %d result mismatch. expected:\n%s\ngot:\n%s", i, c.out, buf.String())
+		}
+	}
+}
+
+func TestXMLWriterDoubleKey(t *testing.T) {
+	st := &dataset.Structure{Format: dataset.XMLDataFormat, Schema: dataset.BaseSchemaObject}
+	w, err := NewXMLWriter(st, &bytes.Buffer{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	if err := w.WriteEntry(Entry{Key: "a", Value: "foo"}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	err = w.WriteEntry(Entry{Key: "a", Value: "bar"})
+	if err == nil {
+		t.Errorf("expected an error on second write with duplicate key")
+		return
+	}
+
+	expect := `key already written: "a"`
+	if err.Error() != expect {
+		t.Errorf("error mismatch. expected: %s, got: %s", expect, err.Error())
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	r, err := NewXMLReader(xmlStruct, bytes.NewBufferString(xmlData))
+	if err != nil {
+		t.Errorf("error allocating reader: %s", err.Error())
+		return
+	}
+	buf := &bytes.Buffer{}
+	w, err := NewXMLWriter(xmlStruct, buf)
+	if err != nil {
+		t.Errorf("error allocating writer: %s", err.Error())
+		return
+	}
+	if err := EachEntry(r, func(i int, ent Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		return w.WriteEntry(ent)
+	}); err != nil {
+		t.Errorf("error copying entries: %s", err.Error())
+		return
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("error closing writer: %s", err.Error())
+		return
+	}
+
+	r, err = NewXMLReader(xmlStruct, buf)
+	if err != nil {
+		t.Errorf("error allocating reader: %s", err.Error())
+		return
+	}
+	count := 0
+	if err := EachEntry(r, func(i int, ent Entry, err error) error {
+		count++
+		return err
+	}); err != nil {
+		t.Errorf("error reading written data: %s", err.Error())
+	}
+	if count != 3 {
+		t.Errorf("expected: %d entries, got: %d", 3, count)
+	}
+}
+
+func TestXMLReaderDeclaredEncoding(t *testing.T) {
+	data := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<cities><city><name>montr\xe9al</name></city></cities>"
+	schema := jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type": "array",
+			"items": [{"title":"name","type":"string"}]
+		}
+	}`)
+
+	cases := []struct {
+		structure *dataset.Structure
+	}{
+		// encoding declared in the prolog
+		{&dataset.Structure{Format: dataset.XMLDataFormat, Schema: schema}},
+		// structure encoding takes precedence over the prolog
+		{&dataset.Structure{Format: dataset.XMLDataFormat, Schema: schema, Encoding: "latin1"}},
+	}
+
+	for i, c := range cases {
+		r, err := NewEntryReader(c.structure, bytes.NewBufferString(data))
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		ent, err := r.ReadEntry()
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		expect := []interface{}{"montréal"}
+		if !reflect.DeepEqual(expect, ent.Value) {
+			t.Errorf("case %d value mismatch. expected: %v, got: %v", i, expect, ent.Value)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package dsql executes sql select queries against datasets. Queries read
+// resources bound by name to dsio.EntryReaders, matching the way
+// dataset.Transform rewrites queries to refer to its Resources as a, b, c...
+//
+// Supported syntax:
+// * SELECT [DISTINCT] with *, table.*, expressions & aliases
+// * FROM with table aliases, [INNER] JOIN, LEFT [OUTER] JOIN, CROSS JOIN
+// and comma separated tables
+// * WHERE, GROUP BY, HAVING, ORDER BY [ASC|DESC] & LIMIT [OFFSET]
+// * operators: and, or, not, =, != (or <>), <, <=, >, >=, +, -, *, /, %,
+// || (concatenation), IS [NOT] NULL, [NOT] IN, [NOT] LIKE, [NOT] BETWEEN
+// * aggregates: count, sum, avg, min & max, with optional DISTINCT
+// * functions: lower, upper, length, abs, round & coalesce
+// Strings are quoted with single quotes, names that aren't plain identifiers
+// are quoted with double quotes or backticks.
+//
+// Resources are read into memory, queries are meant for the modest size
+// of data a transform works with
+package dsql
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	logger "github.com/ipfs/go-log"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+var log = logger.Logger("dsql")
+
+// ExecOpts configures the results of Exec
+type ExecOpts struct {
+	// Format of the result structure, defaults to JSON
+	Format dataset.DataFormat
+	// FormatConfig of the result structure. CSV results default to
+	// including a header row
+	FormatConfig dataset.FormatConfig
+}
+
+// Exec runs a select query against named resources, returning a reader of
+// the results & the structure that describes them. Result entries are
+// arrays, with a schema titling each column. Only resources the query
+// refers to are read
+func Exec(query string, resources map[string]dsio.EntryReader, opts ...func(*ExecOpts)) (dsio.EntryReader, *dataset.Structure, error) {
+	opt := &ExecOpts{Format: dataset.JSONDataFormat}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	stmt, err := parse(query)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil, fmt.Errorf("error parsing query: %s", err.Error())
+	}
+
+	tables := map[string]*table{}
+	for _, ref := range stmt.from {
+		if _, ok := tables[ref.name]; ok {
+			continue
+		}
+		r, ok := resources[ref.name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown table %q", ref.name)
+		}
+		t, err := loadTable(r)
+		if err != nil {
+			log.Debug(err.Error())
+			return nil, nil, fmt.Errorf("error reading table %q: %s", ref.name, err.Error())
+		}
+		tables[ref.name] = t
+	}
+
+	res, err := execute(stmt, tables)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil, fmt.Errorf("error executing query: %s", err.Error())
+	}
+
+	st, err := res.structure(opt)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil, fmt.Errorf("error creating result structure: %s", err.Error())
+	}
+	return &resultReader{structure: st, rows: res.rows}, st, nil
+}
+
+// structure describes query results
+func (res *result) structure(opt *ExecOpts) (*dataset.Structure, error) {
+	items := make([]interface{}, len(res.titles))
+	for i, title := range res.titles {
+		field := map[string]interface{}{"title": title}
+		if res.types[i] != nil {
+			field["type"] = res.types[i]
+		}
+		items[i] = field
+	}
+	sch := map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":  "array",
+			"items": items,
+		},
+	}
+	data, err := json.Marshal(sch)
+	if err != nil {
+		return nil, err
+	}
+	rs := &jsonschema.RootSchema{}
+	if err := rs.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+
+	st := &dataset.Structure{
+		Qri:          dataset.KindStructure,
+		Format:       opt.Format,
+		FormatConfig: opt.FormatConfig,
+		Schema:       rs,
+		Entries:      len(res.rows),
+	}
+	if st.Format == dataset.CSVDataFormat && st.FormatConfig == nil {
+		st.FormatConfig = &dataset.CSVOptions{HeaderRow: true}
+	}
+	return st, nil
+}
+
+// resultReader reads query results
+type resultReader struct {
+	structure *dataset.Structure
+	rows      [][]interface{}
+	i         int
+}
+
+// Structure gives the structure of results
+func (r *resultReader) Structure() *dataset.Structure {
+	return r.structure
+}
+
+// ReadEntry reads the next result row
+func (r *resultReader) ReadEntry() (dsio.Entry, error) {
+	if r.i >= len(r.rows) {
+		return dsio.Entry{}, io.EOF
+	}
+	ent := dsio.Entry{Index: r.i, Value: r.rows[r.i]}
+	r.i++
+	return ent, nil
+}
+
+// schemaMap decodes a schema into generic values
+func schemaMap(rs *jsonschema.RootSchema) (map[string]interface{}, error) {
+	data, err := rs.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	sch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &sch); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
@@ -0,0 +1,250 @@
+package dsql
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+var citiesStructure = &dataset.Structure{
+	Format:       dataset.CSVDataFormat,
+	FormatConfig: &dataset.CSVOptions{HeaderRow: true},
+	Schema: jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type": "array",
+			"items": [
+				{"title": "city", "type": "string"},
+				{"title": "pop", "type": "integer"},
+				{"title": "avg_age", "type": "number"},
+				{"title": "in_usa", "type": "boolean"}
+			]
+		}
+	}`),
+}
+
+var statesStructure = &dataset.Structure{
+	Format:       dataset.CSVDataFormat,
+	FormatConfig: &dataset.CSVOptions{HeaderRow: true},
+	Schema: jsonschema.Must(`{
+		"type": "array",
+		"items": {
+			"type": "array",
+			"items": [
+				{"title": "city", "type": "string"},
+				{"title": "state", "type": "string"}
+			]
+		}
+	}`),
+}
+
+func testResources(t *testing.T) map[string]dsio.EntryReader {
+	resources := map[string]dsio.EntryReader{}
+	for name, st := range map[string]*dataset.Structure{"a": citiesStructure, "b": statesStructure} {
+		path := "testdata/cities.csv"
+		if name == "b" {
+			path = "testdata/states.csv"
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("error opening %s: %s", path, err.Error())
+		}
+		r, err := dsio.NewEntryReader(st, f)
+		if err != nil {
+			t.Fatalf("error creating reader: %s", err.Error())
+		}
+		resources[name] = r
+	}
+	return resources
+}
+
+func TestExec(t *testing.T) {
+	cases := []struct {
+		query  string
+		titles string
+		expect string
+		err    string
+	}{
+		{"select * from a limit 2",
+			"city,pop,avg_age,in_usa",
+			`[["toronto",40000000,55.5,false],["new york",8500000,44.4,true]]`, ""},
+		{"select city, pop / 1000 as k from a where in_usa and pop > 250000",
+			"city,k",
+			`[["new york",8500],["chicago",300]]`, ""},
+		{"SELECT city FROM a WHERE city LIKE 'ch%' ORDER BY city DESC",
+			"city",
+			`[["chicago"],["chatham"]]`, ""},
+		{"select upper(city), round(avg_age) from a where pop between 35000 and 300000 order by 2",
+			"upper(city),round(avg_age)",
+			`[["CHICAGO",44],["RALEIGH",51],["CHATHAM",65]]`, ""},
+		{"select a.city, b.state from a join b on a.city = b.city order by state limit 2 offset 1",
+			"city,state",
+			`[["chatham","MA"],["raleigh","NC"]]`, ""},
+		{"select a.city, state from a left join b on a.city = b.city where state is null",
+			"city,state",
+			`[["toronto",null]]`, ""},
+		{"select x.city, count(y.state) n from a x left outer join b y on x.city = y.city group by x.city having n > 0 order by n desc, x.city",
+			"city,n",
+			`[["chatham",2],["chicago",1],["new york",1],["raleigh",1]]`, ""},
+		{"select in_usa, count(*), sum(pop), min(city), max(avg_age), avg(pop) from a group by in_usa order by in_usa",
+			"in_usa,count(*),sum(pop),min(city),max(avg_age),avg(pop)",
+			`[[false,1,40000000,"toronto",55.5,40000000],[true,4,9085000,"chatham",65.25,2271250]]`, ""},
+		{"select count(*), count(distinct avg_age), sum(pop) from a where pop < 0",
+			"count(*),count(distinct avg_age),sum(pop)",
+			`[[0,0,null]]`, ""},
+		{"select distinct avg_age from a where avg_age < 50",
+			"avg_age",
+			`[[44.4]]`, ""},
+		{"select a.city || ', ' || state as place from a, b where a.city = b.city and state in ('NY', 'IL')",
+			"place",
+			`[["new york, NY"],["chicago, IL"]]`, ""},
+		{"select count(*) as pairs from a cross join b",
+			"pairs",
+			`[[25]]`, ""},
+		{"select `city` from a where not in_usa;",
+			"city",
+			`[["toronto"]]`, ""},
+
+		{"select * from c", "", "", `unknown table "c"`},
+		{"select country from a", "", "", `error executing query: unknown column "country"`},
+		{"select city from a, b", "", "", `error executing query: column "city" is ambiguous`},
+		{"select a.city from a join a on a.city = a.city", "", "", `error executing query: table "a" is named more than once, use an alias`},
+		{"select city from a where count(*) > 1", "", "", "error executing query: aggregates aren't allowed in WHERE"},
+		{"select nope(city) from a", "", "", `error executing query: unknown function "nope"`},
+		{"select city from a where pop", "", "", "error executing query: condition pop: expected a boolean, got integer"},
+		{"select city from a order by 3", "", "", "error executing query: ORDER BY position 3 is out of range"},
+		{"select city from", "", "", "error parsing query: position 16: expected a name, got end of query"},
+		{"select city from a where", "", "", "error parsing query: position 24: unexpected end of query"},
+		{"select city from a limit -1", "", "", `error parsing query: position 25: expected a non-negative integer, got "-"`},
+		{"select 'city from a", "", "", "error parsing query: position 7: unterminated '"},
+	}
+
+	for i, c := range cases {
+		r, st, err := Exec(c.query, testResources(t))
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d %q error mismatch. expected: '%s', got: '%v'", i, c.query, c.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		var rows []interface{}
+		err = dsio.EachEntry(r, func(_ int, ent dsio.Entry, err error) error {
+			rows = append(rows, ent.Value)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("case %d error reading results: %s", i, err.Error())
+			continue
+		}
+		data, err := json.Marshal(rows)
+		if err != nil {
+			t.Errorf("case %d error encoding results: %s", i, err.Error())
+			continue
+		}
+		if string(data) != c.expect {
+			t.Errorf("case %d %q result mismatch.\nexpected: %s\ngot:      %s", i, c.query, c.expect, string(data))
+		}
+		if st.Entries != len(rows) {
+			t.Errorf("case %d expected structure to count %d entries. got: %d", i, len(rows), st.Entries)
+		}
+
+		titles, err := resultTitles(st)
+		if err != nil {
+			t.Errorf("case %d error reading result schema: %s", i, err.Error())
+			continue
+		}
+		if titles != c.titles {
+			t.Errorf("case %d titles mismatch. expected: %s, got: %s", i, c.titles, titles)
+		}
+	}
+}
+
+func resultTitles(st *dataset.Structure) (string, error) {
+	columns, _, _ := schemaColumns(st)
+	return strings.Join(columns, ","), nil
+}
+
+func TestExecResultStructure(t *testing.T) {
+	r, st, err := Exec("select a.city, pop, null as nothing, coalesce(state, 'none') as state from a left join b on a.city = b.city", testResources(t), func(o *ExecOpts) {
+		o.Format = dataset.CSVDataFormat
+	})
+	if err != nil {
+		t.Fatalf("error executing query: %s", err.Error())
+	}
+
+	sch, err := schemaMap(st.Schema)
+	if err != nil {
+		t.Fatalf("error reading schema: %s", err.Error())
+	}
+	items := sch["items"].(map[string]interface{})["items"].([]interface{})
+	expect := `[{"title":"city","type":"string"},{"title":"pop","type":"integer"},{"title":"nothing"},{"title":"state","type":"string"}]`
+	if data, _ := json.Marshal(items); string(data) != expect {
+		t.Errorf("schema items mismatch.\nexpected: %s\ngot:      %s", expect, string(data))
+	}
+
+	buf, err := dsio.NewEntryBuffer(st)
+	if err != nil {
+		t.Fatalf("error creating buffer: %s", err.Error())
+	}
+	if err := dsio.EachEntry(r, func(_ int, ent dsio.Entry, err error) error {
+		return buf.WriteEntry(ent)
+	}); err != nil {
+		t.Fatalf("error writing results: %s", err.Error())
+	}
+	if err := buf.Close(); err != nil {
+		t.Fatalf("error closing buffer: %s", err.Error())
+	}
+	expectCSV := "city,pop,nothing,state\ntoronto,40000000,,none\nnew york,8500000,,NY\nchicago,300000,,IL\nchatham,35000,,NJ\nchatham,35000,,MA\nraleigh,250000,,NC\n"
+	if string(buf.Bytes()) != expectCSV {
+		t.Errorf("csv mismatch.\nexpected: %s\ngot:      %s", expectCSV, string(buf.Bytes()))
+	}
+}
+
+func TestExecObjects(t *testing.T) {
+	st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: dataset.BaseSchemaArray}
+	r, err := dsio.NewEntryReader(st, strings.NewReader(`[{"name":"a","n":1},{"name":"b","tags":["x"]},{"name":"c","n":2.5}]`))
+	if err != nil {
+		t.Fatalf("error creating reader: %s", err.Error())
+	}
+	res, _, err := Exec("select name, n * 2 as doubled from t where tags is null order by n desc", map[string]dsio.EntryReader{"t": r})
+	if err != nil {
+		t.Fatalf("error executing query: %s", err.Error())
+	}
+	var rows []interface{}
+	dsio.EachEntry(res, func(_ int, ent dsio.Entry, err error) error {
+		rows = append(rows, ent.Value)
+		return nil
+	})
+	expect := `[["c",5],["a",2]]`
+	if data, _ := json.Marshal(rows); string(data) != expect {
+		t.Errorf("result mismatch. expected: %s, got: %s", expect, string(data))
+	}
+}
+
+func TestLike(t *testing.T) {
+	cases := []struct {
+		str, pattern string
+		expect       bool
+	}{
+		{"chicago", "ch%", true},
+		{"chicago", "%go", true},
+		{"chicago", "c_icago", true},
+		{"chicago", "%ica%", true},
+		{"chicago", "chi", false},
+		{"", "%", true},
+		{"abcabd", "%ab_", true},
+		{"abcabe", "%abd", false},
+	}
+	for i, c := range cases {
+		if got := like(c.str, c.pattern); got != c.expect {
+			t.Errorf("case %d like(%q, %q) expected %t", i, c.str, c.pattern, c.expect)
+		}
+	}
+}
@@ -0,0 +1,673 @@
+package dsql
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+)
+
+// table is a resource loaded into memory
+type table struct {
+	columns []string
+	// types are the declared jsonschema types of columns, empty if unknown
+	types []string
+	rows  [][]interface{}
+}
+
+// loadTable reads all entries of a resource. Array entries are rows,
+// columns are named by schema item titles, or by position if untitled.
+// Object entries are rows with a column per key
+func loadTable(r dsio.EntryReader) (*table, error) {
+	t := &table{}
+	objects := false
+	if st := r.Structure(); st != nil {
+		t.columns, t.types, objects = schemaColumns(st)
+	}
+
+	var objs []map[string]interface{}
+	err := dsio.EachEntry(r, func(i int, ent dsio.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch v := ent.Value.(type) {
+		case []interface{}:
+			row := make([]interface{}, len(v))
+			for j, val := range v {
+				row[j] = normalize(val)
+			}
+			t.rows = append(t.rows, row)
+		case map[string]interface{}:
+			objects = true
+			objs = append(objs, v)
+		default:
+			return fmt.Errorf("entry %d: expected an array or object, got %T", i, ent.Value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if objects {
+		if len(t.rows) > 0 {
+			return nil, fmt.Errorf("can't mix array & object entries")
+		}
+		t.objectRows(objs)
+		return t, nil
+	}
+
+	// rows may be wider than the schema declares
+	for _, row := range t.rows {
+		for len(t.columns) < len(row) {
+			t.columns = append(t.columns, "")
+			t.types = append(t.types, "")
+		}
+	}
+	for i, col := range t.columns {
+		if col == "" {
+			t.columns[i] = fmt.Sprintf("field_%d", i+1)
+		}
+	}
+	for i, row := range t.rows {
+		for len(row) < len(t.columns) {
+			row = append(row, nil)
+		}
+		t.rows[i] = row
+	}
+	return t, nil
+}
+
+// objectRows converts object entries to rows. Keys the schema doesn't
+// declare become columns in sorted order
+func (t *table) objectRows(objs []map[string]interface{}) {
+	declared := map[string]bool{}
+	for _, col := range t.columns {
+		declared[col] = true
+	}
+	var extra []string
+	for _, obj := range objs {
+		for key := range obj {
+			if !declared[key] {
+				declared[key] = true
+				extra = append(extra, key)
+			}
+		}
+	}
+	sort.Strings(extra)
+	for _, key := range extra {
+		t.columns = append(t.columns, key)
+		t.types = append(t.types, "")
+	}
+
+	t.rows = make([][]interface{}, len(objs))
+	for i, obj := range objs {
+		row := make([]interface{}, len(t.columns))
+		for j, col := range t.columns {
+			row[j] = normalize(obj[col])
+		}
+		t.rows[i] = row
+	}
+}
+
+// schemaColumns reads column titles & types from a structure's schema,
+// reporting whether entries are objects
+func schemaColumns(st *dataset.Structure) (columns, types []string, objects bool) {
+	if st.Schema == nil {
+		return nil, nil, false
+	}
+	sch, err := schemaMap(st.Schema)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil, false
+	}
+	items, _ := sch["items"].(map[string]interface{})
+	if fields, ok := items["items"].([]interface{}); ok {
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+			title, _ := field["title"].(string)
+			columns = append(columns, title)
+			types = append(types, schemaType(field))
+		}
+		return columns, types, false
+	}
+	if props, ok := items["properties"].(map[string]interface{}); ok {
+		for key := range props {
+			columns = append(columns, key)
+		}
+		sort.Strings(columns)
+		for _, key := range columns {
+			prop, _ := props[key].(map[string]interface{})
+			types = append(types, schemaType(prop))
+		}
+		return columns, types, true
+	}
+	return nil, nil, items["type"] == "object"
+}
+
+// schemaType reads the type of a schema, picking the first non-null type
+// from lists of types
+func schemaType(sch map[string]interface{}) string {
+	switch t := sch["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, ti := range t {
+			if str, ok := ti.(string); ok && str != "null" {
+				return str
+			}
+		}
+	}
+	return ""
+}
+
+// scope resolves column references against the tables of a query. Rows
+// of a query are the columns of each table, concatenated in order
+type scope struct {
+	tables []scopeTable
+	width  int
+}
+
+type scopeTable struct {
+	name   string
+	offset int
+	*table
+}
+
+func newScope(refs []tableRef, tables map[string]*table) (*scope, error) {
+	s := &scope{}
+	for _, ref := range refs {
+		t, ok := tables[ref.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown table %q", ref.name)
+		}
+		for _, st := range s.tables {
+			if st.name == ref.binding() {
+				return nil, fmt.Errorf("table %q is named more than once, use an alias", st.name)
+			}
+		}
+		s.tables = append(s.tables, scopeTable{name: ref.binding(), offset: s.width, table: t})
+		s.width += len(t.columns)
+	}
+	return s, nil
+}
+
+// lookup finds the position of a column in a row
+func (s *scope) lookup(tableName, name string) (int, error) {
+	idx := -1
+	for _, t := range s.tables {
+		if tableName != "" && t.name != tableName {
+			continue
+		}
+		for i, col := range t.columns {
+			if col == name {
+				if idx >= 0 {
+					return 0, fmt.Errorf("column %q is ambiguous", name)
+				}
+				idx = t.offset + i
+			}
+		}
+		if tableName != "" {
+			if idx < 0 {
+				return 0, fmt.Errorf("unknown column %q in table %q", name, tableName)
+			}
+			return idx, nil
+		}
+	}
+	if tableName != "" {
+		return 0, fmt.Errorf("unknown table %q", tableName)
+	}
+	if idx < 0 {
+		return 0, fmt.Errorf("unknown column %q", name)
+	}
+	return idx, nil
+}
+
+// declaredType gives the schema type of a column position
+func (s *scope) declaredType(idx int) string {
+	for _, t := range s.tables {
+		if idx >= t.offset && idx < t.offset+len(t.columns) {
+			return t.types[idx-t.offset]
+		}
+	}
+	return ""
+}
+
+// output is a column of query results
+type output struct {
+	title string
+	expr  expr
+}
+
+// result is the output of a query
+type result struct {
+	titles []string
+	// types are jsonschema type values, nil for unconstrained columns
+	types []interface{}
+	rows  [][]interface{}
+}
+
+// execute runs a parsed query against loaded tables
+func execute(stmt *selectStmt, tables map[string]*table) (*result, error) {
+	s, err := newScope(stmt.from, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := stmt.outputs(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := stmt.bind(s, outputs); err != nil {
+		return nil, err
+	}
+	order, err := stmt.orderExprs(s, outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.join(s)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.where != nil {
+		if rows, err = filter(rows, stmt.where); err != nil {
+			return nil, err
+		}
+	}
+
+	ctxs, err := stmt.contexts(rows, outputs, order)
+	if err != nil {
+		return nil, err
+	}
+
+	type sortable struct {
+		values, keys []interface{}
+	}
+	res := make([]sortable, 0, len(ctxs))
+	seen := map[string]bool{}
+	for _, ctx := range ctxs {
+		values := make([]interface{}, len(outputs))
+		for i, o := range outputs {
+			if values[i], err = o.expr.eval(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if stmt.distinct {
+			k := key(values)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		keys := make([]interface{}, len(order))
+		for i, o := range order {
+			if o.output >= 0 {
+				keys[i] = values[o.output]
+			} else if keys[i], err = o.expr.eval(ctx); err != nil {
+				return nil, err
+			}
+		}
+		res = append(res, sortable{values, keys})
+	}
+
+	if len(order) > 0 {
+		sort.SliceStable(res, func(i, j int) bool {
+			for k, o := range order {
+				if c := compare(res[i].keys[k], res[j].keys[k]); c != 0 {
+					return c < 0 != o.desc
+				}
+			}
+			return false
+		})
+	}
+
+	if stmt.offset > len(res) {
+		res = res[:0]
+	} else {
+		res = res[stmt.offset:]
+	}
+	if stmt.limit >= 0 && stmt.limit < len(res) {
+		res = res[:stmt.limit]
+	}
+
+	out := &result{rows: make([][]interface{}, len(res))}
+	for i, r := range res {
+		out.rows[i] = r.values
+	}
+	for i, o := range outputs {
+		out.titles = append(out.titles, o.title)
+		declared := ""
+		if c, ok := o.expr.(*colRef); ok {
+			declared = s.declaredType(c.idx)
+		}
+		out.types = append(out.types, columnType(out.rows, i, declared))
+	}
+	return out, nil
+}
+
+// outputs expands the select list into result columns, binding each
+func (stmt *selectStmt) outputs(s *scope) ([]output, error) {
+	var outputs []output
+	for _, f := range stmt.fields {
+		if f.star {
+			matched := false
+			for _, t := range s.tables {
+				if f.table != "" && t.name != f.table {
+					continue
+				}
+				matched = true
+				for i, col := range t.columns {
+					outputs = append(outputs, output{title: col, expr: &colRef{table: t.name, name: col, idx: t.offset + i}})
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unknown table %q", f.table)
+			}
+			continue
+		}
+
+		if err := f.expr.bind(s); err != nil {
+			return nil, err
+		}
+		title := f.alias
+		if title == "" {
+			if c, ok := f.expr.(*colRef); ok {
+				title = c.name
+			} else {
+				title = f.expr.String()
+			}
+		}
+		outputs = append(outputs, output{title: title, expr: f.expr})
+	}
+	return outputs, nil
+}
+
+// bind resolves column references of every clause except select & order
+// by, checking aggregates only appear where they're allowed. HAVING can
+// refer to result columns by title
+func (stmt *selectStmt) bind(s *scope, outputs []output) error {
+	for _, t := range stmt.from {
+		if t.on == nil {
+			continue
+		}
+		if hasAggregate(t.on) {
+			return fmt.Errorf("aggregates aren't allowed in ON")
+		}
+		if err := t.on.bind(s); err != nil {
+			return err
+		}
+	}
+	if stmt.where != nil {
+		if hasAggregate(stmt.where) {
+			return fmt.Errorf("aggregates aren't allowed in WHERE")
+		}
+		if err := stmt.where.bind(s); err != nil {
+			return err
+		}
+	}
+	for _, e := range stmt.groupBy {
+		if hasAggregate(e) {
+			return fmt.Errorf("aggregates aren't allowed in GROUP BY")
+		}
+		if err := e.bind(s); err != nil {
+			return err
+		}
+	}
+	if stmt.having != nil {
+		stmt.having = resolveAliases(stmt.having, s, outputs)
+		if err := stmt.having.bind(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveAliases replaces unqualified references to result column titles
+// that don't name a table column with the result column's expression
+func resolveAliases(e expr, s *scope, outputs []output) expr {
+	switch x := e.(type) {
+	case *colRef:
+		if x.table != "" {
+			return x
+		}
+		if _, err := s.lookup("", x.name); err == nil {
+			return x
+		}
+		for _, o := range outputs {
+			if o.title == x.name {
+				return o.expr
+			}
+		}
+	case *unaryExpr:
+		x.x = resolveAliases(x.x, s, outputs)
+	case *binaryExpr:
+		x.l = resolveAliases(x.l, s, outputs)
+		x.r = resolveAliases(x.r, s, outputs)
+	case *isNullExpr:
+		x.x = resolveAliases(x.x, s, outputs)
+	case *inExpr:
+		x.x = resolveAliases(x.x, s, outputs)
+		for i, item := range x.list {
+			x.list[i] = resolveAliases(item, s, outputs)
+		}
+	case *betweenExpr:
+		x.x = resolveAliases(x.x, s, outputs)
+		x.lo = resolveAliases(x.lo, s, outputs)
+		x.hi = resolveAliases(x.hi, s, outputs)
+	case *funcCall:
+		for i, a := range x.args {
+			x.args[i] = resolveAliases(a, s, outputs)
+		}
+	}
+	return e
+}
+
+// orderExpr is a bound order by term. output is the index of the result
+// column it sorts by, or -1 to sort by expr
+type orderExpr struct {
+	expr   expr
+	output int
+	desc   bool
+}
+
+// orderExprs binds order by terms. Terms can name result columns by title
+// or by 1-based position
+func (stmt *selectStmt) orderExprs(s *scope, outputs []output) ([]orderExpr, error) {
+	order := make([]orderExpr, len(stmt.orderBy))
+	for i, o := range stmt.orderBy {
+		order[i] = orderExpr{expr: o.expr, output: -1, desc: o.desc}
+		switch x := o.expr.(type) {
+		case *literal:
+			n, ok := x.val.(int64)
+			if !ok || n < 1 || int(n) > len(outputs) {
+				return nil, fmt.Errorf("ORDER BY position %s is out of range", x.String())
+			}
+			order[i].output = int(n) - 1
+			continue
+		case *colRef:
+			if x.table == "" {
+				for j, out := range outputs {
+					if out.title == x.name {
+						order[i].output = j
+						break
+					}
+				}
+				if order[i].output >= 0 {
+					continue
+				}
+			}
+		}
+		if err := o.expr.bind(s); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// join builds the rows of a query from its tables
+func (stmt *selectStmt) join(s *scope) ([][]interface{}, error) {
+	first := s.tables[0]
+	rows := make([][]interface{}, len(first.rows))
+	for i, r := range first.rows {
+		row := make([]interface{}, s.width)
+		copy(row, r)
+		rows[i] = row
+	}
+
+	for ti, ref := range stmt.from[1:] {
+		t := s.tables[ti+1]
+		var joined [][]interface{}
+		for _, row := range rows {
+			matched := false
+			for _, r := range t.rows {
+				next := make([]interface{}, s.width)
+				copy(next, row)
+				copy(next[t.offset:], r)
+				if ref.on != nil {
+					ok, err := truthy(ref.on, &evalCtx{row: next})
+					if err != nil {
+						return nil, err
+					}
+					if !ok {
+						continue
+					}
+				}
+				matched = true
+				joined = append(joined, next)
+			}
+			if !matched && ref.join == "left" {
+				joined = append(joined, row)
+			}
+		}
+		rows = joined
+	}
+	return rows, nil
+}
+
+// contexts gives the contexts results are evaluated in: one per row, or
+// one per group for grouped queries
+func (stmt *selectStmt) contexts(rows [][]interface{}, outputs []output, order []orderExpr) ([]*evalCtx, error) {
+	grouped := len(stmt.groupBy) > 0 || stmt.having != nil
+	for _, o := range outputs {
+		grouped = grouped || hasAggregate(o.expr)
+	}
+	for _, o := range order {
+		grouped = grouped || o.output < 0 && hasAggregate(o.expr)
+	}
+
+	if !grouped {
+		ctxs := make([]*evalCtx, len(rows))
+		for i, row := range rows {
+			ctxs[i] = &evalCtx{row: row}
+		}
+		return ctxs, nil
+	}
+
+	var ctxs []*evalCtx
+	if len(stmt.groupBy) == 0 {
+		// aggregates without grouping give a single row, even for no input
+		ctx := &evalCtx{group: rows, grouped: true}
+		if len(rows) > 0 {
+			ctx.row = rows[0]
+		}
+		ctxs = append(ctxs, ctx)
+	} else {
+		groups := map[string]*evalCtx{}
+		for _, row := range rows {
+			values := make([]interface{}, len(stmt.groupBy))
+			for i, e := range stmt.groupBy {
+				v, err := e.eval(&evalCtx{row: row})
+				if err != nil {
+					return nil, err
+				}
+				values[i] = v
+			}
+			k := key(values)
+			ctx, ok := groups[k]
+			if !ok {
+				ctx = &evalCtx{row: row, grouped: true}
+				groups[k] = ctx
+				ctxs = append(ctxs, ctx)
+			}
+			ctx.group = append(ctx.group, row)
+		}
+	}
+
+	if stmt.having == nil {
+		return ctxs, nil
+	}
+	var having []*evalCtx
+	for _, ctx := range ctxs {
+		ok, err := truthy(stmt.having, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			having = append(having, ctx)
+		}
+	}
+	return having, nil
+}
+
+// filter keeps rows a condition is true for
+func filter(rows [][]interface{}, cond expr) ([][]interface{}, error) {
+	var kept [][]interface{}
+	for _, row := range rows {
+		ok, err := truthy(cond, &evalCtx{row: row})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, row)
+		}
+	}
+	return kept, nil
+}
+
+// truthy evaluates a condition, null counts as false
+func truthy(cond expr, ctx *evalCtx) (bool, error) {
+	v, err := cond.eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	switch b := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return b, nil
+	}
+	return false, fmt.Errorf("condition %s: expected a boolean, got %s", cond.String(), typeName(v))
+}
+
+// columnType gives the jsonschema type of a result column from its
+// values, falling back to a declared type. Columns with nulls also accept
+// null
+func columnType(rows [][]interface{}, col int, declared string) interface{} {
+	t, nulls := "", false
+	for _, row := range rows {
+		vt := typeName(row[col])
+		switch {
+		case vt == "null":
+			nulls = true
+		case t == "" || t == vt:
+			t = vt
+		case t == "integer" && vt == "number" || t == "number" && vt == "integer":
+			t = "number"
+		default:
+			// mixed types aren't constrained
+			return nil
+		}
+	}
+	if t == "" {
+		t = declared
+	}
+	if t == "" {
+		return nil
+	}
+	if nulls {
+		return []interface{}{t, "null"}
+	}
+	return t
+}
@@ -0,0 +1,314 @@
+package dsql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// expr is a node of a parsed expression
+type expr interface {
+	// String gives the expression as sql, used to title unaliased columns
+	String() string
+	// bind resolves column references against the tables a query reads
+	bind(s *scope) error
+	// eval computes the value of an expression
+	eval(ctx *evalCtx) (interface{}, error)
+}
+
+// evalCtx is the data an expression is evaluated against. group is set
+// when evaluating the results of a grouped query, row is the first row of
+// the group, or nil for an empty group
+type evalCtx struct {
+	row     []interface{}
+	group   [][]interface{}
+	grouped bool
+}
+
+// colRef refers to a column, optionally qualified by table name or alias
+type colRef struct {
+	table, name string
+	// idx is the position of the column in a joined row, set by bind
+	idx int
+}
+
+func (c *colRef) String() string {
+	if c.table != "" {
+		return c.table + "." + c.name
+	}
+	return c.name
+}
+
+func (c *colRef) bind(s *scope) (err error) {
+	c.idx, err = s.lookup(c.table, c.name)
+	return err
+}
+
+func (c *colRef) eval(ctx *evalCtx) (interface{}, error) {
+	if ctx.row == nil {
+		return nil, nil
+	}
+	return ctx.row[c.idx], nil
+}
+
+// literal is a constant value
+type literal struct {
+	val interface{}
+}
+
+func (l *literal) String() string {
+	switch v := l.val.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprintf("%v", l.val)
+}
+
+func (l *literal) bind(s *scope) error                    { return nil }
+func (l *literal) eval(ctx *evalCtx) (interface{}, error) { return l.val, nil }
+
+// unaryExpr is negation or logical not
+type unaryExpr struct {
+	op string
+	x  expr
+}
+
+func (u *unaryExpr) String() string {
+	if u.op == "not" {
+		return "not " + u.x.String()
+	}
+	return u.op + u.x.String()
+}
+
+func (u *unaryExpr) bind(s *scope) error { return u.x.bind(s) }
+
+func (u *unaryExpr) eval(ctx *evalCtx) (interface{}, error) {
+	v, err := u.x.eval(ctx)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if u.op == "not" {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("not: expected a boolean, got %s", typeName(v))
+		}
+		return !b, nil
+	}
+	return arith("-", int64(0), v)
+}
+
+// binaryExpr is an operator with two operands
+type binaryExpr struct {
+	op   string
+	l, r expr
+}
+
+func (b *binaryExpr) String() string {
+	return b.l.String() + " " + b.op + " " + b.r.String()
+}
+
+func (b *binaryExpr) bind(s *scope) error {
+	if err := b.l.bind(s); err != nil {
+		return err
+	}
+	return b.r.bind(s)
+}
+
+func (b *binaryExpr) eval(ctx *evalCtx) (interface{}, error) {
+	l, err := b.l.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// and & or short circuit, treating null as unknown
+	switch b.op {
+	case "and", "or":
+		lb, err := logical(b.op, l)
+		if err != nil {
+			return nil, err
+		}
+		if lb != nil && *lb == (b.op == "or") {
+			return *lb, nil
+		}
+		r, err := b.r.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rb, err := logical(b.op, r)
+		if err != nil {
+			return nil, err
+		}
+		if rb != nil && *rb == (b.op == "or") {
+			return *rb, nil
+		}
+		if lb == nil || rb == nil {
+			return nil, nil
+		}
+		return *lb, nil
+	}
+
+	r, err := b.r.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+
+	switch b.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		c := compare(l, r)
+		switch b.op {
+		case "=":
+			return c == 0, nil
+		case "!=":
+			return c != 0, nil
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "||":
+		return toString(l) + toString(r), nil
+	case "like":
+		str, ok := l.(string)
+		pattern, pok := r.(string)
+		if !ok || !pok {
+			return nil, fmt.Errorf("like: expected strings, got %s & %s", typeName(l), typeName(r))
+		}
+		return like(str, pattern), nil
+	}
+	return arith(b.op, l, r)
+}
+
+// logical reads an operand of and / or, nil means null
+func logical(op string, v interface{}) (*bool, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a boolean, got %s", op, typeName(v))
+	}
+	return &b, nil
+}
+
+// isNullExpr checks if a value is null
+type isNullExpr struct {
+	x   expr
+	not bool
+}
+
+func (n *isNullExpr) String() string {
+	if n.not {
+		return n.x.String() + " is not null"
+	}
+	return n.x.String() + " is null"
+}
+
+func (n *isNullExpr) bind(s *scope) error { return n.x.bind(s) }
+
+func (n *isNullExpr) eval(ctx *evalCtx) (interface{}, error) {
+	v, err := n.x.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != n.not, nil
+}
+
+// inExpr checks if a value equals any of a list of values
+type inExpr struct {
+	x    expr
+	list []expr
+	not  bool
+}
+
+func (in *inExpr) String() string {
+	strs := make([]string, len(in.list))
+	for i, e := range in.list {
+		strs[i] = e.String()
+	}
+	op := " in ("
+	if in.not {
+		op = " not in ("
+	}
+	return in.x.String() + op + strings.Join(strs, ", ") + ")"
+}
+
+func (in *inExpr) bind(s *scope) error {
+	if err := in.x.bind(s); err != nil {
+		return err
+	}
+	for _, e := range in.list {
+		if err := e.bind(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *inExpr) eval(ctx *evalCtx) (interface{}, error) {
+	v, err := in.x.eval(ctx)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	sawNull := false
+	for _, e := range in.list {
+		item, err := e.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			sawNull = true
+		} else if compare(v, item) == 0 {
+			return !in.not, nil
+		}
+	}
+	if sawNull {
+		return nil, nil
+	}
+	return in.not, nil
+}
+
+// betweenExpr checks if a value falls in an inclusive range
+type betweenExpr struct {
+	x, lo, hi expr
+	not       bool
+}
+
+func (b *betweenExpr) String() string {
+	op := " between "
+	if b.not {
+		op = " not between "
+	}
+	return b.x.String() + op + b.lo.String() + " and " + b.hi.String()
+}
+
+func (b *betweenExpr) bind(s *scope) error {
+	for _, e := range []expr{b.x, b.lo, b.hi} {
+		if err := e.bind(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *betweenExpr) eval(ctx *evalCtx) (interface{}, error) {
+	vs := make([]interface{}, 3)
+	for i, e := range []expr{b.x, b.lo, b.hi} {
+		v, err := e.eval(ctx)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		vs[i] = v
+	}
+	in := compare(vs[0], vs[1]) >= 0 && compare(vs[0], vs[2]) <= 0
+	return in != b.not, nil
+}
@@ -0,0 +1,259 @@
+package dsql
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// aggregates are functions computed over the rows of a group
+var aggregates = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true,
+}
+
+// scalarFuncs are functions computed from a single row, given evaluated
+// arguments
+var scalarFuncs = map[string]struct {
+	minArgs, maxArgs int
+	fn               func(args []interface{}) (interface{}, error)
+}{
+	"lower": {1, 1, func(args []interface{}) (interface{}, error) {
+		return mapString("lower", args[0], strings.ToLower)
+	}},
+	"upper": {1, 1, func(args []interface{}) (interface{}, error) {
+		return mapString("upper", args[0], strings.ToUpper)
+	}},
+	"length": {1, 1, func(args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		str, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("length: expected a string, got %s", typeName(args[0]))
+		}
+		return int64(utf8.RuneCountInString(str)), nil
+	}},
+	"abs": {1, 1, func(args []interface{}) (interface{}, error) {
+		switch n := args[0].(type) {
+		case nil:
+			return nil, nil
+		case int64:
+			if n < 0 {
+				return -n, nil
+			}
+			return n, nil
+		case float64:
+			return math.Abs(n), nil
+		}
+		return nil, fmt.Errorf("abs: expected a number, got %s", typeName(args[0]))
+	}},
+	"round": {1, 2, func(args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		f, ok := toFloat(args[0])
+		if !ok {
+			return nil, fmt.Errorf("round: expected a number, got %s", typeName(args[0]))
+		}
+		places := int64(0)
+		if len(args) == 2 {
+			if places, ok = args[1].(int64); !ok {
+				return nil, fmt.Errorf("round: expected integer places, got %s", typeName(args[1]))
+			}
+		}
+		scale := math.Pow(10, float64(places))
+		return math.Floor(f*scale+0.5) / scale, nil
+	}},
+	"coalesce": {1, -1, func(args []interface{}) (interface{}, error) {
+		for _, v := range args {
+			if v != nil {
+				return v, nil
+			}
+		}
+		return nil, nil
+	}},
+}
+
+func mapString(name string, v interface{}, fn func(string) string) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	str, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a string, got %s", name, typeName(v))
+	}
+	return fn(str), nil
+}
+
+// funcCall is a call to a scalar or aggregate function
+type funcCall struct {
+	name string
+	args []expr
+	// star is set for count(*)
+	star bool
+	// distinct is set for aggregates over distinct values
+	distinct bool
+}
+
+func (f *funcCall) String() string {
+	if f.star {
+		return f.name + "(*)"
+	}
+	strs := make([]string, len(f.args))
+	for i, a := range f.args {
+		strs[i] = a.String()
+	}
+	prefix := ""
+	if f.distinct {
+		prefix = "distinct "
+	}
+	return f.name + "(" + prefix + strings.Join(strs, ", ") + ")"
+}
+
+func (f *funcCall) bind(s *scope) error {
+	if aggregates[f.name] {
+		if !f.star && len(f.args) != 1 {
+			return fmt.Errorf("%s expects 1 argument, got %d", f.name, len(f.args))
+		}
+	} else if sf, ok := scalarFuncs[f.name]; ok {
+		if f.star || f.distinct {
+			return fmt.Errorf("%s isn't an aggregate function", f.name)
+		}
+		if len(f.args) < sf.minArgs || sf.maxArgs >= 0 && len(f.args) > sf.maxArgs {
+			return fmt.Errorf("%s expects %s, got %d", f.name, argCount(sf.minArgs, sf.maxArgs), len(f.args))
+		}
+	} else {
+		return fmt.Errorf("unknown function %q", f.name)
+	}
+
+	for _, a := range f.args {
+		if err := a.bind(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func argCount(min, max int) string {
+	switch {
+	case min == max && min == 1:
+		return "1 argument"
+	case min == max:
+		return fmt.Sprintf("%d arguments", min)
+	case max < 0:
+		return fmt.Sprintf("at least %d arguments", min)
+	}
+	return fmt.Sprintf("%d to %d arguments", min, max)
+}
+
+func (f *funcCall) eval(ctx *evalCtx) (interface{}, error) {
+	if aggregates[f.name] {
+		return f.aggregate(ctx)
+	}
+
+	args := make([]interface{}, len(f.args))
+	for i, a := range f.args {
+		v, err := a.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return scalarFuncs[f.name].fn(args)
+}
+
+// aggregate computes an aggregate function over the rows of a group. Nulls
+// are skipped, aggregates of no values are null, except count which is 0
+func (f *funcCall) aggregate(ctx *evalCtx) (interface{}, error) {
+	if !ctx.grouped {
+		return nil, fmt.Errorf("aggregate %s isn't allowed here", f.String())
+	}
+	if f.star {
+		return int64(len(ctx.group)), nil
+	}
+
+	var values []interface{}
+	seen := map[string]bool{}
+	for _, row := range ctx.group {
+		v, err := f.args[0].eval(&evalCtx{row: row})
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		if f.distinct {
+			k := key([]interface{}{v})
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		values = append(values, v)
+	}
+
+	if f.name == "count" {
+		return int64(len(values)), nil
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	switch f.name {
+	case "min", "max":
+		best := values[0]
+		for _, v := range values[1:] {
+			if c := compare(v, best); f.name == "min" && c < 0 || f.name == "max" && c > 0 {
+				best = v
+			}
+		}
+		return best, nil
+	}
+
+	var sum interface{} = int64(0)
+	for _, v := range values {
+		var err error
+		if sum, err = arith("+", sum, v); err != nil {
+			return nil, fmt.Errorf("%s: expected numbers, got %s", f.name, typeName(v))
+		}
+	}
+	if f.name == "avg" {
+		total, _ := toFloat(sum)
+		return total / float64(len(values)), nil
+	}
+	return sum, nil
+}
+
+// hasAggregate checks if an expression calls an aggregate function
+func hasAggregate(e expr) bool {
+	switch x := e.(type) {
+	case *funcCall:
+		if aggregates[x.name] {
+			return true
+		}
+		for _, a := range x.args {
+			if hasAggregate(a) {
+				return true
+			}
+		}
+	case *unaryExpr:
+		return hasAggregate(x.x)
+	case *binaryExpr:
+		return hasAggregate(x.l) || hasAggregate(x.r)
+	case *isNullExpr:
+		return hasAggregate(x.x)
+	case *inExpr:
+		if hasAggregate(x.x) {
+			return true
+		}
+		for _, item := range x.list {
+			if hasAggregate(item) {
+				return true
+			}
+		}
+	case *betweenExpr:
+		return hasAggregate(x.x) || hasAggregate(x.lo) || hasAggregate(x.hi)
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package dsql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// token kinds
+const (
+	tokEOF = iota
+	tokIdent
+	tokKeyword
+	tokString
+	tokNumber
+	tokOp
+)
+
+// keywords are reserved words, which can't be used as unquoted identifiers
+var keywords = map[string]bool{
+	"select": true, "distinct": true, "from": true, "as": true, "join": true,
+	"inner": true, "left": true, "outer": true, "cross": true, "on": true,
+	"where": true, "group": true, "by": true, "having": true, "order": true,
+	"asc": true, "desc": true, "limit": true, "offset": true, "and": true,
+	"or": true, "not": true, "is": true, "null": true, "true": true,
+	"false": true, "in": true, "like": true, "between": true,
+}
+
+// operators are the symbols a query can contain
+var operators = map[string]bool{
+	"=": true, "==": true, "!=": true, "<>": true, "<": true, "<=": true,
+	">": true, ">=": true, "+": true, "-": true, "*": true, "/": true,
+	"%": true, "||": true, ",": true, ".": true, "(": true, ")": true,
+	";": true,
+}
+
+type token struct {
+	kind int
+	// text is the token as written, lowercased for keywords & unquoted for
+	// strings & quoted identifiers
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits a query into tokens
+func lex(query string) ([]token, error) {
+	var toks []token
+	rs := []rune(query)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-':
+			// comments run to the end of the line
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '\'' || r == '"' || r == '`':
+			str, n, err := lexQuoted(rs[i:])
+			if err != nil {
+				return nil, fmt.Errorf("position %d: %s", i, err.Error())
+			}
+			kind := tokIdent
+			if r == '\'' {
+				kind = tokString
+			}
+			toks = append(toks, token{kind, str, i})
+			i += n
+		case unicode.IsDigit(r) || r == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1]):
+			start := i
+			for i++; i < len(rs); i++ {
+				c := rs[i]
+				if !(unicode.IsDigit(c) || c == '.' || c == 'e' || c == 'E' ||
+					(c == '-' || c == '+') && (rs[i-1] == 'e' || rs[i-1] == 'E')) {
+					break
+				}
+			}
+			toks = append(toks, token{tokNumber, string(rs[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			word := string(rs[start:i])
+			if keywords[strings.ToLower(word)] {
+				toks = append(toks, token{tokKeyword, strings.ToLower(word), start})
+			} else {
+				toks = append(toks, token{tokIdent, word, start})
+			}
+		default:
+			op := string(r)
+			if i+1 < len(rs) {
+				switch two := string(rs[i : i+2]); two {
+				case "==", "!=", "<>", "<=", ">=", "||":
+					op = two
+				}
+			}
+			if !operators[op] {
+				return nil, fmt.Errorf("position %d: unexpected %q", i, r)
+			}
+			toks = append(toks, token{tokOp, normalizeOp(op), i})
+			i += len([]rune(op))
+		}
+	}
+	return append(toks, token{tokEOF, "", len(rs)}), nil
+}
+
+// normalizeOp maps operator aliases to a single spelling
+func normalizeOp(op string) string {
+	switch op {
+	case "==":
+		return "="
+	case "<>":
+		return "!="
+	}
+	return op
+}
+
+// lexQuoted reads a quoted string, returning its contents & the number of
+// runes consumed. Doubling the quote character escapes it
+func lexQuoted(rs []rune) (string, int, error) {
+	quote := rs[0]
+	str := []rune{}
+	for i := 1; i < len(rs); i++ {
+		if rs[i] == quote {
+			if i+1 < len(rs) && rs[i+1] == quote {
+				str = append(str, quote)
+				i++
+				continue
+			}
+			return string(str), i + 1, nil
+		}
+		str = append(str, rs[i])
+	}
+	return "", 0, fmt.Errorf("unterminated %c", quote)
+}
@@ -0,0 +1,572 @@
+package dsql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// selectStmt is a parsed select query
+type selectStmt struct {
+	distinct bool
+	fields   []selectField
+	from     []tableRef
+	where    expr
+	groupBy  []expr
+	having   expr
+	orderBy  []orderTerm
+	// limit is -1 for queries without a limit
+	limit, offset int
+}
+
+// selectField is a column of query results. star fields select every
+// column of a table, or of all tables if table is empty
+type selectField struct {
+	expr  expr
+	alias string
+	star  bool
+	table string
+}
+
+// tableRef is a table a query reads from. join is empty for the first
+// table, otherwise one of "inner", "left" or "cross"
+type tableRef struct {
+	name, alias string
+	join        string
+	on          expr
+}
+
+// binding gives the name a table is referred to by within a query
+func (t tableRef) binding() string {
+	if t.alias != "" {
+		return t.alias
+	}
+	return t.name
+}
+
+type orderTerm struct {
+	expr expr
+	desc bool
+}
+
+// parser is a recursive descent parser for select queries
+type parser struct {
+	toks []token
+	i    int
+}
+
+// parse parses a single select query
+func parse(query string) (*selectStmt, error) {
+	toks, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	stmt, err := p.selectStmt()
+	if err != nil {
+		return nil, err
+	}
+	p.op(";")
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+	return stmt, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	tok := p.toks[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return fmt.Errorf("position %d: %s", tok.pos, fmt.Sprintf(format, args...))
+}
+
+// keyword consumes the next token if it's one of the given keywords,
+// returning the keyword consumed
+func (p *parser) keyword(kws ...string) string {
+	tok := p.peek()
+	if tok.kind == tokKeyword {
+		for _, kw := range kws {
+			if tok.text == kw {
+				p.i++
+				return kw
+			}
+		}
+	}
+	return ""
+}
+
+// expectKeyword consumes a keyword, erroring if it's missing
+func (p *parser) expectKeyword(kw string) error {
+	if p.keyword(kw) == "" {
+		tok := p.peek()
+		return p.errorf(tok, "expected %s, got %s", strings.ToUpper(kw), tok)
+	}
+	return nil
+}
+
+// op consumes the next token if it's the given operator
+func (p *parser) op(op string) bool {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.op(op) {
+		tok := p.peek()
+		return p.errorf(tok, "expected %q, got %s", op, tok)
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	tok := p.next()
+	if tok.kind != tokIdent {
+		return "", p.errorf(tok, "expected a name, got %s", tok)
+	}
+	return tok.text, nil
+}
+
+func (p *parser) selectStmt() (*selectStmt, error) {
+	if err := p.expectKeyword("select"); err != nil {
+		return nil, err
+	}
+	stmt := &selectStmt{limit: -1}
+	stmt.distinct = p.keyword("distinct") != ""
+
+	for {
+		f, err := p.selectField()
+		if err != nil {
+			return nil, err
+		}
+		stmt.fields = append(stmt.fields, f)
+		if !p.op(",") {
+			break
+		}
+	}
+
+	if err := p.expectKeyword("from"); err != nil {
+		return nil, err
+	}
+	t, err := p.tableRef()
+	if err != nil {
+		return nil, err
+	}
+	stmt.from = append(stmt.from, t)
+	for {
+		if p.op(",") {
+			t, err = p.tableRef()
+			t.join = "cross"
+		} else if kw := p.keyword("join", "inner", "left", "cross"); kw != "" {
+			t, err = p.join(kw)
+		} else {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		stmt.from = append(stmt.from, t)
+	}
+
+	if p.keyword("where") != "" {
+		if stmt.where, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("group") != "" {
+		if err := p.expectKeyword("by"); err != nil {
+			return nil, err
+		}
+		if stmt.groupBy, err = p.exprList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("having") != "" {
+		if stmt.having, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("order") != "" {
+		if err := p.expectKeyword("by"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			stmt.orderBy = append(stmt.orderBy, orderTerm{expr: e, desc: p.keyword("asc", "desc") == "desc"})
+			if !p.op(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("limit") != "" {
+		if stmt.limit, err = p.count(); err != nil {
+			return nil, err
+		}
+		if p.keyword("offset") != "" {
+			if stmt.offset, err = p.count(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stmt, nil
+}
+
+// count reads a non-negative integer
+func (p *parser) count() (int, error) {
+	tok := p.next()
+	n, err := strconv.Atoi(tok.text)
+	if tok.kind != tokNumber || err != nil || n < 0 {
+		return 0, p.errorf(tok, "expected a non-negative integer, got %s", tok)
+	}
+	return n, nil
+}
+
+func (p *parser) selectField() (selectField, error) {
+	if p.op("*") {
+		return selectField{star: true}, nil
+	}
+	// table.*
+	if p.peek().kind == tokIdent && p.i+2 < len(p.toks) && p.toks[p.i+1].text == "." && p.toks[p.i+2].text == "*" {
+		table := p.next().text
+		p.i += 2
+		return selectField{star: true, table: table}, nil
+	}
+
+	e, err := p.expr()
+	if err != nil {
+		return selectField{}, err
+	}
+	f := selectField{expr: e}
+	if p.keyword("as") != "" {
+		if f.alias, err = p.ident(); err != nil {
+			return f, err
+		}
+	} else if p.peek().kind == tokIdent {
+		f.alias = p.next().text
+	}
+	return f, nil
+}
+
+func (p *parser) tableRef() (tableRef, error) {
+	name, err := p.ident()
+	if err != nil {
+		return tableRef{}, err
+	}
+	t := tableRef{name: name}
+	if p.keyword("as") != "" {
+		if t.alias, err = p.ident(); err != nil {
+			return t, err
+		}
+	} else if p.peek().kind == tokIdent {
+		t.alias = p.next().text
+	}
+	return t, nil
+}
+
+// join parses a join clause, after its first keyword
+func (p *parser) join(kw string) (tableRef, error) {
+	kind := "inner"
+	switch kw {
+	case "left":
+		kind = "left"
+		p.keyword("outer")
+	case "cross":
+		kind = "cross"
+	}
+	if kw != "join" {
+		if err := p.expectKeyword("join"); err != nil {
+			return tableRef{}, err
+		}
+	}
+
+	t, err := p.tableRef()
+	if err != nil {
+		return t, err
+	}
+	t.join = kind
+	if kind != "cross" {
+		if err := p.expectKeyword("on"); err != nil {
+			return t, err
+		}
+		if t.on, err = p.expr(); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+func (p *parser) exprList() ([]expr, error) {
+	var list []expr
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if !p.op(",") {
+			return list, nil
+		}
+	}
+}
+
+// expr parses an expression. Precedence from loosest to tightest is:
+// or, and, not, comparisons, + - ||, * / %, unary minus
+func (p *parser) expr() (expr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") != "" {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "or", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (expr, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") != "" {
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "and", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if p.keyword("not") != "" {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "not", x: x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+	l, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind == tokOp {
+		switch tok.text {
+		case "=", "!=", "<", "<=", ">", ">=":
+			p.next()
+			r, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			return &binaryExpr{op: tok.text, l: l, r: r}, nil
+		}
+	}
+
+	if p.keyword("is") != "" {
+		not := p.keyword("not") != ""
+		if err := p.expectKeyword("null"); err != nil {
+			return nil, err
+		}
+		return &isNullExpr{x: l, not: not}, nil
+	}
+
+	not := p.keyword("not") != ""
+	switch p.keyword("in", "like", "between") {
+	case "in":
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		list, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return &inExpr{x: l, list: list, not: not}, nil
+	case "like":
+		r, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		var e expr = &binaryExpr{op: "like", l: l, r: r}
+		if not {
+			e = &unaryExpr{op: "not", x: e}
+		}
+		return e, nil
+	case "between":
+		lo, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("and"); err != nil {
+			return nil, err
+		}
+		hi, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenExpr{x: l, lo: lo, hi: hi, not: not}, nil
+	}
+	if not {
+		tok := p.peek()
+		return nil, p.errorf(tok, "expected IN, LIKE or BETWEEN, got %s", tok)
+	}
+	return l, nil
+}
+
+func (p *parser) additive() (expr, error) {
+	l, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokOp || tok.text != "+" && tok.text != "-" && tok.text != "||" {
+			return l, nil
+		}
+		p.next()
+		r, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: tok.text, l: l, r: r}
+	}
+}
+
+func (p *parser) multiplicative() (expr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokOp || tok.text != "*" && tok.text != "/" && tok.text != "%" {
+			return l, nil
+		}
+		p.next()
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: tok.text, l: l, r: r}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.op("-") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		// fold negative number literals
+		if lit, ok := x.(*literal); ok {
+			switch n := lit.val.(type) {
+			case int64:
+				return &literal{-n}, nil
+			case float64:
+				return &literal{-n}, nil
+			}
+		}
+		return &unaryExpr{op: "-", x: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		if n, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return &literal{n}, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid number %s", tok)
+		}
+		return &literal{f}, nil
+	case tokString:
+		return &literal{tok.text}, nil
+	case tokKeyword:
+		switch tok.text {
+		case "null":
+			return &literal{nil}, nil
+		case "true":
+			return &literal{true}, nil
+		case "false":
+			return &literal{false}, nil
+		}
+	case tokOp:
+		if tok.text == "(" {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+	case tokIdent:
+		if p.op("(") {
+			return p.funcCall(tok)
+		}
+		if p.op(".") {
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			return &colRef{table: tok.text, name: name}, nil
+		}
+		return &colRef{name: tok.text}, nil
+	case tokEOF:
+		return nil, p.errorf(tok, "unexpected end of query")
+	}
+	return nil, p.errorf(tok, "unexpected %s", tok)
+}
+
+// funcCall parses function arguments, after the opening parenthesis
+func (p *parser) funcCall(name token) (expr, error) {
+	f := &funcCall{name: strings.ToLower(name.text)}
+	if p.op("*") {
+		if f.name != "count" {
+			return nil, p.errorf(name, "only count accepts *")
+		}
+		f.star = true
+	} else if !p.op(")") {
+		f.distinct = p.keyword("distinct") != ""
+		args, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		f.args = args
+	} else {
+		return f, nil
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,259 @@
+package dsql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// normalize converts decoded entry values to the types queries work with:
+// nil, bool, int64, float64 & string. Arrays & objects are kept as-is
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case int:
+		return int64(x)
+	case int8:
+		return int64(x)
+	case int16:
+		return int64(x)
+	case int32:
+		return int64(x)
+	case uint8:
+		return int64(x)
+	case uint16:
+		return int64(x)
+	case uint32:
+		return int64(x)
+	case uint64:
+		if x <= math.MaxInt64 {
+			return int64(x)
+		}
+		return float64(x)
+	case uint:
+		return normalize(uint64(x))
+	case float32:
+		return float64(x)
+	case []byte:
+		return string(x)
+	}
+	return v
+}
+
+// typeName gives the sql-ish name of a value's type
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// typeRank orders values of different types: null, booleans, numbers,
+// strings, then everything else
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int64, float64:
+		return 2
+	case string:
+		return 3
+	}
+	return 4
+}
+
+// compare orders two values. Values of different types are ordered by
+// typeRank, integers & numbers compare by value
+func compare(a, b interface{}) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return cmpInt(int64(ra), int64(rb))
+	}
+	switch x := a.(type) {
+	case bool:
+		y := b.(bool)
+		if x == y {
+			return 0
+		} else if y {
+			return -1
+		}
+		return 1
+	case int64:
+		if y, ok := b.(int64); ok {
+			return cmpInt(x, y)
+		}
+		return cmpFloat(float64(x), b.(float64))
+	case float64:
+		if y, ok := b.(int64); ok {
+			return cmpFloat(x, float64(y))
+		}
+		return cmpFloat(x, b.(float64))
+	case string:
+		return strings.Compare(x, b.(string))
+	case nil:
+		return 0
+	}
+	return strings.Compare(toString(a), toString(b))
+}
+
+func cmpInt(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func cmpFloat(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// arith applies an arithmetic operator. Operations on two integers give an
+// integer, dividing by zero gives null
+func arith(op string, a, b interface{}) (interface{}, error) {
+	x, xok := a.(int64)
+	y, yok := b.(int64)
+	if xok && yok {
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/":
+			if y == 0 {
+				return nil, nil
+			}
+			return x / y, nil
+		case "%":
+			if y == 0 {
+				return nil, nil
+			}
+			return x % y, nil
+		}
+	}
+
+	f, fok := toFloat(a)
+	g, gok := toFloat(b)
+	if !fok || !gok {
+		return nil, fmt.Errorf("%s: expected numbers, got %s & %s", op, typeName(a), typeName(b))
+	}
+	switch op {
+	case "+":
+		return f + g, nil
+	case "-":
+		return f - g, nil
+	case "*":
+		return f * g, nil
+	case "/":
+		if g == 0 {
+			return nil, nil
+		}
+		return f / g, nil
+	case "%":
+		if g == 0 {
+			return nil, nil
+		}
+		return math.Mod(f, g), nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// toString formats a value as text
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// like matches a string against a pattern, where % matches any run of
+// characters & _ matches a single character
+func like(str, pattern string) bool {
+	s, p := []rune(str), []rune(pattern)
+	// star & mark record the last % for backtracking
+	si, pi, star, mark := 0, 0, -1, 0
+	for si < len(s) {
+		switch {
+		case pi < len(p) && (p[pi] == '_' || p[pi] == s[si]):
+			si++
+			pi++
+		case pi < len(p) && p[pi] == '%':
+			star, mark = pi, si
+			pi++
+		case star >= 0:
+			mark++
+			si, pi = mark, star+1
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '%' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// key encodes values for grouping & distinct checks. Integers & numbers
+// with the same value share a key
+func key(vs []interface{}) string {
+	strs := make([]string, len(vs))
+	for i, v := range vs {
+		switch x := v.(type) {
+		case int64:
+			strs[i] = "n" + strconv.FormatFloat(float64(x), 'g', -1, 64)
+		case float64:
+			strs[i] = "n" + strconv.FormatFloat(x, 'g', -1, 64)
+		default:
+			data, _ := json.Marshal(v)
+			strs[i] = typeName(v)[:1] + string(data)
+		}
+	}
+	return strings.Join(strs, "\x00")
+}
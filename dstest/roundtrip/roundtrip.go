@@ -0,0 +1,322 @@
+// Package roundtrip checks that entries survive being written & read back
+// in every supported data format. It lives apart from package dstest so
+// dsio tests can keep using dstest without an import cycle
+package roundtrip
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+
+	logger "github.com/ipfs/go-log"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/dataset/vals"
+)
+
+var log = logger.Logger("roundtrip")
+
+// Format describes the data a format can round trip
+type Format struct {
+	// Tabular formats can only represent entries that are arrays of
+	// scalar values
+	Tabular bool
+	// ArrayOnly formats can't represent keyed entries
+	ArrayOnly bool
+	// Prepare adjusts a structure before writing, eg: to set format
+	// options. Prepare is given a copy of the structure
+	Prepare func(st *dataset.Structure)
+}
+
+// Formats configures round trips for data formats. Formats listed by
+// dataset.SupportedDataFormats without an entry here are expected to round
+// trip any data, so new formats are covered automatically. Add an entry to
+// declare the limits of a format
+var Formats = map[dataset.DataFormat]Format{
+	dataset.CSVDataFormat: {
+		Tabular:   true,
+		ArrayOnly: true,
+		Prepare: func(st *dataset.Structure) {
+			st.FormatConfig = &dataset.CSVOptions{HeaderRow: true}
+		},
+	},
+	dataset.XMLDataFormat:     {Tabular: true, ArrayOnly: true},
+	dataset.NDJSONDataFormat:  {ArrayOnly: true},
+	dataset.ParquetDataFormat: {Tabular: true, ArrayOnly: true},
+}
+
+// Test runs Check, reporting each error to t
+func Test(t testing.TB, st *dataset.Structure, entries []dsio.Entry) {
+	for _, err := range Check(st, entries) {
+		t.Error(err.Error())
+	}
+}
+
+// Check round trips entries through every supported data format that can
+// represent them, and checks CBOR output is deterministic. Check returns
+// at most one error per format
+func Check(st *dataset.Structure, entries []dsio.Entry) (errs []error) {
+	for _, df := range dataset.SupportedDataFormats() {
+		if !Supports(df, entries) {
+			continue
+		}
+		if err := CheckFormat(df, st, entries); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := CheckCanonicalCBOR(st, entries); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Supports reports whether a data format can represent a set of entries
+func Supports(df dataset.DataFormat, entries []dsio.Entry) bool {
+	f := Formats[df]
+	for _, ent := range entries {
+		if f.ArrayOnly && ent.Key != "" {
+			return false
+		}
+		if f.Tabular && !isRow(ent.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// isRow checks if a value is an array of scalars
+func isRow(v interface{}) bool {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, cell := range arr {
+		switch cell.(type) {
+		case []interface{}, map[string]interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+// CheckFormat writes entries in a data format, reads them back & writes
+// them again, checking that both writes produce identical bytes and that
+// read values equal written values
+func CheckFormat(df dataset.DataFormat, st *dataset.Structure, entries []dsio.Entry) error {
+	fst := &dataset.Structure{}
+	fst.Assign(st)
+	fst.Format = df
+	fst.FormatConfig = nil
+	if f, ok := Formats[df]; ok && f.Prepare != nil {
+		f.Prepare(fst)
+	}
+
+	first, err := write(fst, entries)
+	if err != nil {
+		return fmt.Errorf("%s: %s", df, err.Error())
+	}
+	read, err := read(fst, first)
+	if err != nil {
+		return fmt.Errorf("%s: %s", df, err.Error())
+	}
+	if len(read) != len(entries) {
+		return fmt.Errorf("%s: entry count mismatch. wrote: %d, read: %d", df, len(entries), len(read))
+	}
+	// keyed entries are unordered, formats are free to reorder them
+	keyed := map[string]dsio.Entry{}
+	for _, ent := range read {
+		if ent.Key != "" {
+			keyed[ent.Key] = ent
+		}
+	}
+	for i, ent := range entries {
+		got := read[i]
+		if ent.Key != "" {
+			var ok bool
+			if got, ok = keyed[ent.Key]; !ok {
+				return fmt.Errorf("%s: entry %d key %q is missing", df, i, ent.Key)
+			}
+		} else if got.Key != "" {
+			return fmt.Errorf("%s: entry %d key mismatch. wrote no key, read: %q", df, i, got.Key)
+		}
+		if err := compare(ent.Value, got.Value); err != nil {
+			return fmt.Errorf("%s: entry %d value mismatch: %s", df, i, err.Error())
+		}
+	}
+
+	second, err := write(fst, read)
+	if err != nil {
+		return fmt.Errorf("%s: rewriting: %s", df, err.Error())
+	}
+	if !bytes.Equal(first, second) {
+		return fmt.Errorf("%s: rewritten bytes don't match. first: %q, second: %q", df, first, second)
+	}
+	return nil
+}
+
+// CheckCanonicalCBOR checks that CBOR output doesn't depend on the order
+// object keys are set in
+func CheckCanonicalCBOR(st *dataset.Structure, entries []dsio.Entry) error {
+	cst := &dataset.Structure{}
+	cst.Assign(st)
+	cst.Format = dataset.CBORDataFormat
+	cst.FormatConfig = nil
+
+	first, err := write(cst, entries)
+	if err != nil {
+		return fmt.Errorf("cbor: %s", err.Error())
+	}
+
+	// rebuilding objects & writing keyed entries in reverse gives go maps
+	// a different insertion order
+	rebuilt := make([]dsio.Entry, len(entries))
+	for i, ent := range entries {
+		rebuilt[i] = dsio.Entry{Index: ent.Index, Key: ent.Key, Value: rebuild(ent.Value)}
+	}
+	if len(rebuilt) > 0 && rebuilt[0].Key != "" {
+		for i, j := 0, len(rebuilt)-1; i < j; i, j = i+1, j-1 {
+			rebuilt[i], rebuilt[j] = rebuilt[j], rebuilt[i]
+		}
+	}
+
+	second, err := write(cst, rebuilt)
+	if err != nil {
+		return fmt.Errorf("cbor: %s", err.Error())
+	}
+	if !bytes.Equal(first, second) {
+		return fmt.Errorf("cbor: output isn't canonical. first: %x, second: %x", first, second)
+	}
+	return nil
+}
+
+// rebuild deep-copies a value, inserting object keys in reverse order
+func rebuild(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		arr := make([]interface{}, len(x))
+		for i, val := range x {
+			arr[i] = rebuild(val)
+		}
+		return arr
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+		obj := make(map[string]interface{}, len(x))
+		for _, key := range keys {
+			obj[key] = rebuild(x[key])
+		}
+		return obj
+	}
+	return v
+}
+
+// write encodes entries with a structure
+func write(st *dataset.Structure, entries []dsio.Entry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := dsio.NewEntryWriter(st, buf)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error creating writer: %s", err.Error())
+	}
+	for i, ent := range entries {
+		if err := w.WriteEntry(ent); err != nil {
+			log.Debug(err.Error())
+			return nil, fmt.Errorf("error writing entry %d: %s", i, err.Error())
+		}
+	}
+	if err := w.Close(); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error closing writer: %s", err.Error())
+	}
+	return buf.Bytes(), nil
+}
+
+// read decodes all entries from data
+func read(st *dataset.Structure, data []byte) ([]dsio.Entry, error) {
+	r, err := dsio.NewEntryReader(st, bytes.NewReader(data))
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error creating reader: %s", err.Error())
+	}
+	var entries []dsio.Entry
+	err = dsio.EachEntry(r, func(i int, ent dsio.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ent)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading entries: %s", err.Error())
+	}
+	return entries, nil
+}
+
+// compare checks two decoded values are equal under vals.Equal, recursing
+// into arrays & objects. Integers & numbers with the same value are equal,
+// as many formats can't tell them apart
+func compare(a, b interface{}) error {
+	av, err := vals.ConvertDecoded(a)
+	if err != nil {
+		return err
+	}
+	bv, err := vals.ConvertDecoded(b)
+	if err != nil {
+		return err
+	}
+	return compareValues("", av, bv)
+}
+
+func compareValues(path string, a, b vals.Value) error {
+	at, bt := a.Type(), b.Type()
+	switch {
+	case at == vals.TypeArray && bt == vals.TypeArray:
+		if a.Len() != b.Len() {
+			return fmt.Errorf("%s: length %d != %d", pathString(path), a.Len(), b.Len())
+		}
+		for i := 0; i < a.Len(); i++ {
+			if err := compareValues(fmt.Sprintf("%s/%d", path, i), a.Index(i), b.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case at == vals.TypeObject && bt == vals.TypeObject:
+		ak, bk := a.Keys(), b.Keys()
+		sort.Strings(ak)
+		sort.Strings(bk)
+		if fmt.Sprint(ak) != fmt.Sprint(bk) {
+			return fmt.Errorf("%s: keys %v != %v", pathString(path), ak, bk)
+		}
+		for _, key := range ak {
+			if err := compareValues(path+"/"+key, a.MapIndex(key), b.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case isNumeric(at) && isNumeric(bt) && at != bt:
+		if a.Number() != b.Number() {
+			return fmt.Errorf("%s: %v != %v", pathString(path), a.Number(), b.Number())
+		}
+		return nil
+	}
+
+	if !vals.Equal(a, b) {
+		return fmt.Errorf("%s: %#v != %#v", pathString(path), a, b)
+	}
+	return nil
+}
+
+func isNumeric(t vals.Type) bool {
+	return t == vals.TypeInteger || t == vals.TypeNumber
+}
+
+func pathString(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
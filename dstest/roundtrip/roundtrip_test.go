@@ -0,0 +1,88 @@
+package roundtrip
+
+import (
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestSupports(t *testing.T) {
+	rows := []dsio.Entry{{Value: []interface{}{"a", int64(1)}}}
+	nested := []dsio.Entry{{Value: []interface{}{"a", []interface{}{int64(1)}}}}
+	keyed := []dsio.Entry{{Key: "a", Value: []interface{}{"a"}}}
+
+	cases := []struct {
+		df      dataset.DataFormat
+		entries []dsio.Entry
+		expect  bool
+	}{
+		{dataset.CSVDataFormat, rows, true},
+		{dataset.CSVDataFormat, nested, false},
+		{dataset.CSVDataFormat, keyed, false},
+		{dataset.NDJSONDataFormat, nested, true},
+		{dataset.NDJSONDataFormat, keyed, false},
+		{dataset.JSONDataFormat, keyed, true},
+		{dataset.CBORDataFormat, nested, true},
+	}
+
+	for i, c := range cases {
+		if got := Supports(c.df, c.entries); got != c.expect {
+			t.Errorf("case %d %s mismatch. expected: %t, got: %t", i, c.df, c.expect, got)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b interface{}
+		err  string
+	}{
+		{int64(1), float64(1), ""},
+		{int64(1), float64(1.5), "/: 1 != 1.5"},
+		{"a", "a", ""},
+		{"1", int64(1), `/: "1" != 1`},
+		{[]interface{}{"a", nil}, []interface{}{"a", nil}, ""},
+		{[]interface{}{"a"}, []interface{}{"a", "b"}, "/: length 1 != 2"},
+		{map[string]interface{}{"a": []interface{}{true}}, map[string]interface{}{"a": []interface{}{false}}, "/a/0: true != false"},
+		{map[string]interface{}{"a": 1.0}, map[string]interface{}{"b": 1.0}, "/: keys [a] != [b]"},
+	}
+
+	for i, c := range cases {
+		err := compare(c.a, c.b)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	st := &dataset.Structure{
+		Format: dataset.JSONDataFormat,
+		Schema: jsonschema.Must(`{
+			"type": "array",
+			"items": {
+				"type": "array",
+				"items": [
+					{ "title": "name", "type": "string" },
+					{ "title": "count", "type": "integer" },
+					{ "title": "ratio", "type": "number" },
+					{ "title": "ok", "type": "boolean" }
+				]
+			}
+		}`),
+	}
+	entries := []dsio.Entry{
+		{Value: []interface{}{"a", int64(1), 0.5, true}},
+		{Value: []interface{}{"b, with a comma", int64(-20), 1e-7, false}},
+	}
+	Test(t, st, entries)
+
+	if err := CheckCanonicalCBOR(&dataset.Structure{Schema: dataset.BaseSchemaObject}, []dsio.Entry{
+		{Key: "b", Value: map[string]interface{}{"y": int64(1), "x": "z"}},
+		{Key: "a", Value: int64(2)},
+	}); err != nil {
+		t.Errorf("expected canonical cbor output: %s", err.Error())
+	}
+}
@@ -1,62 +1,92 @@
 package generate
 
-// import (
-// 	"bytes"
-// 	"encoding/csv"
-// 	"github.com/qri-io/dataset"
-// )
-
-// // RandomDataOpts configures RandomData output
-// type RandomDataOpts struct {
-// 	Data           []byte
-// 	NumRandRecords int
-// 	Format         dataset.DataFormat
-// }
-
-// // RandomData generates data based on a given resource definition
-// func RandomData(st *dataset.Structure, opts ...func(o *RandomDataOpts)) []byte {
-// 	opt := &RandomDataOpts{
-// 		Data:           nil,
-// 		NumRandRecords: 500,
-// 		Format:         dataset.CSVDataFormat,
-// 	}
-// 	for _, option := range opts {
-// 		option(opt)
-// 	}
-// 	if opt.NumRandRecords == 0 || opt.Format != dataset.CSVDataFormat {
-// 		return nil
-// 	}
-
-// 	buf := bytes.NewBuffer(opt.Data)
-// 	if err := csv.NewWriter(buf).WriteAll(RandomStringRows(st.Schema.Fields, opt.NumRandRecords)); err != nil {
-// 		panic(err)
-// 	}
-
-// 	return buf.Bytes()
-// }
-
-// // RandomRows generates random row data
-// func RandomRows(fields []*dataset.Field, numRows int) (rows [][]interface{}) {
-// 	rows = make([][]interface{}, numRows)
-// 	for i := 0; i < numRows; i++ {
-// 		row := make([]interface{}, len(fields))
-// 		for j, field := range fields {
-// 			row[j] = RandomValue(field.Type)
-// 		}
-// 		rows[i] = row
-// 	}
-// 	return
-// }
-
-// // RandomStringRows generates random row data as strings
-// func RandomStringRows(fields []*dataset.Field, numRows int) (rows [][]string) {
-// 	rows = make([][]string, numRows)
-// 	for i := 0; i < numRows; i++ {
-// 		row := make([]string, len(fields))
-// 		for j, field := range fields {
-// 			row[j] = RandomStringValue(field.Type)
-// 		}
-// 		rows[i] = row
-// 	}
-// 	return
-// }
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+)
+
+// WriteEntries writes n random entries that satisfy the schema of the
+// writer's structure to w. Entries of an object schema are keyed by the
+// schema's properties, followed by random keys. WriteEntries doesn't close
+// the writer
+func (g *Generator) WriteEntries(w dsio.EntryWriter, n int) error {
+	sch, err := decodeSchema(w.Structure())
+	if err != nil {
+		return err
+	}
+
+	if sch["type"] == "object" {
+		props, _ := sch["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(props))
+		for key := range props {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		additional, _ := sch["additionalProperties"].(map[string]interface{})
+
+		for i := 0; i < n; i++ {
+			ent := dsio.Entry{Index: i}
+			if i < len(keys) {
+				ent.Key = keys[i]
+				prop, _ := props[ent.Key].(map[string]interface{})
+				ent.Value = g.Value(prop)
+			} else {
+				ent.Key = fmt.Sprintf("%s_%d", g.randString(8), i)
+				ent.Value = g.Value(additional)
+			}
+			if err := w.WriteEntry(ent); err != nil {
+				log.Debug(err.Error())
+				return fmt.Errorf("error writing entry %d: %s", i, err.Error())
+			}
+		}
+		return nil
+	}
+
+	items, _ := sch["items"].(map[string]interface{})
+	for i := 0; i < n; i++ {
+		if err := w.WriteEntry(dsio.Entry{Index: i, Value: g.Value(items)}); err != nil {
+			log.Debug(err.Error())
+			return fmt.Errorf("error writing entry %d: %s", i, err.Error())
+		}
+	}
+	return nil
+}
+
+// RandomData generates n random entries encoded with a given structure
+func (g *Generator) RandomData(st *dataset.Structure, n int) ([]byte, error) {
+	buf, err := dsio.NewEntryBuffer(st)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error allocating data buffer: %s", err.Error())
+	}
+	if err := g.WriteEntries(buf, n); err != nil {
+		return nil, err
+	}
+	if err := buf.Close(); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error closing buffer: %s", err.Error())
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeSchema gives the decoded form of a structure's schema
+func decodeSchema(st *dataset.Structure) (map[string]interface{}, error) {
+	if st == nil || st.Schema == nil {
+		return nil, fmt.Errorf("schema is required")
+	}
+	data, err := st.Schema.MarshalJSON()
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error marshaling schema: %s", err.Error())
+	}
+	sch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &sch); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error reading schema: %s", err.Error())
+	}
+	return sch, nil
+}
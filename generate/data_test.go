@@ -0,0 +1,60 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestWriteEntries(t *testing.T) {
+	sch := jsonschema.Must(`{
+		"type": "object",
+		"properties": {
+			"a": { "type": "integer" },
+			"b": { "type": "string" }
+		},
+		"additionalProperties": { "type": "boolean" }
+	}`)
+	st := &dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch}
+	buf, err := dsio.NewEntryBuffer(st)
+	if err != nil {
+		t.Fatalf("error allocating buffer: %s", err.Error())
+	}
+
+	g := NewGenerator(func(o *GeneratorOpts) { o.Seed = 1 })
+	if err := g.WriteEntries(buf, 4); err != nil {
+		t.Fatalf("error writing entries: %s", err.Error())
+	}
+	if err := buf.Close(); err != nil {
+		t.Fatalf("error closing buffer: %s", err.Error())
+	}
+
+	ents := []dsio.Entry{}
+	dsio.EachEntry(buf, func(i int, ent dsio.Entry, err error) error {
+		ents = append(ents, ent)
+		return nil
+	})
+	if len(ents) != 4 {
+		t.Fatalf("expected 4 entries. got: %d", len(ents))
+	}
+	if ents[0].Key != "a" || ents[1].Key != "b" {
+		t.Errorf("expected property keys first. got: %s, %s", ents[0].Key, ents[1].Key)
+	}
+	if _, ok := ents[1].Value.(string); !ok {
+		t.Errorf("expected string value for b. got: %#v", ents[1].Value)
+	}
+	for _, ent := range ents[2:] {
+		if _, ok := ent.Value.(bool); !ok {
+			t.Errorf("expected boolean value for additional key %s. got: %#v", ent.Key, ent.Value)
+		}
+	}
+
+	if err := g.WriteEntries(buf, 1); err == nil {
+		t.Errorf("expected writing to a closed buffer to error")
+	}
+	if err := g.WriteEntries(&dsio.EntryBuffer{}, 1); err == nil || err.Error() != "schema is required" {
+		t.Errorf("expected missing schema error. got: %v", err)
+	}
+}
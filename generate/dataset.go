@@ -1,49 +1,69 @@
 package generate
 
-// import (
-//   "github.com/ipfs/go-datastore"
-//   "math/rand"
-
-//   "github.com/qri-io/dataset"
-//   "github.com/qri-io/dataset/datatypes"
-// )
-
-// type RandomDatasetOpts struct {
-//   Path      datastore.Key
-//   Datatypes []datatypes.Type
-//   Format    dataset.DataFormat
-//   NumFields int
-//   Fields    []*dataset.Field
-//   // Data           []byte
-//   // NumRandRecords int
-// }
-
-// // RandomDataset generates a randomized resource definition
-// func RandomDataset(options ...func(*RandomDatasetOpts)) *dataset.Dataset {
-//   opt := &RandomDatasetOpts{
-//     NumFields: rand.Intn(9) + 1,
-//     Datatypes: nil,
-//     Format:    dataset.CsvDataFormat,
-//   }
-
-//   for _, option := range options {
-//     option(opt)
-//   }
-
-//   if opt.Fields == nil && opt.NumFields > 0 {
-//     opt.Fields = RandomFields(func(o *RandomFieldsOpt) {
-//       o.NumFields = opt.NumFields
-//       o.Datatypes = opt.Datatypes
-//     })
-//   }
-
-//   ds := &dataset.Dataset{
-//     Format: opt.Format,
-//     Schema: &dataset.Schema{
-//       Fields: opt.Fields,
-//     },
-//     // Data: opt.Data,
-//   }
-
-//   return ds
-// }
+import (
+	"fmt"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+)
+
+// RandomDatasetOpts configures Dataset
+type RandomDatasetOpts struct {
+	// Seed seeds the random number generator, a zero seed uses the current
+	// time
+	Seed int64
+	// Structure of the dataset, a random tabular structure if nil
+	Structure *dataset.Structure
+	// Format of a random structure, default CSV
+	Format dataset.DataFormat
+	// NumEntries is the number of entries in the body, default 100
+	NumEntries int
+}
+
+// Dataset generates a complete random dataset with meta, structure &
+// commit, and a body file with random entries that satisfy the
+// structure's schema
+func Dataset(options ...func(*RandomDatasetOpts)) (*dataset.Dataset, cafs.File, error) {
+	opt := &RandomDatasetOpts{
+		Format:     dataset.CSVDataFormat,
+		NumEntries: 100,
+	}
+	for _, option := range options {
+		option(opt)
+	}
+
+	g := NewGenerator(func(o *GeneratorOpts) { o.Seed = opt.Seed })
+	st := opt.Structure
+	if st == nil {
+		var err error
+		if st, err = g.Structure(func(o *RandomStructureOpts) { o.Format = opt.Format }); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	data, err := g.RandomData(st, opt.NumEntries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ds := &dataset.Dataset{
+		Qri: dataset.KindDataset,
+		Meta: &dataset.Meta{
+			Qri:         dataset.KindMeta,
+			Title:       fmt.Sprintf("random dataset %s", g.randString(8)),
+			Description: g.randString(g.between(0, 140)),
+		},
+		Structure: &dataset.Structure{},
+		Commit: &dataset.Commit{
+			Qri:       dataset.KindCommit,
+			Title:     "generated random dataset",
+			Timestamp: g.time(),
+		},
+	}
+	ds.Structure.Assign(st)
+	ds.Structure.Qri = dataset.KindStructure
+	ds.Structure.Entries = opt.NumEntries
+	ds.Structure.Length = len(data)
+
+	return ds, cafs.NewMemfileBytes(fmt.Sprintf("body.%s", st.Format.String()), data), nil
+}
@@ -0,0 +1,64 @@
+package generate
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/dataset/validate"
+)
+
+func TestDataset(t *testing.T) {
+	for _, format := range []dataset.DataFormat{dataset.CSVDataFormat, dataset.JSONDataFormat, dataset.CBORDataFormat} {
+		ds, body, err := Dataset(func(o *RandomDatasetOpts) {
+			o.Seed = 7
+			o.Format = format
+			o.NumEntries = 25
+		})
+		if err != nil {
+			t.Errorf("%s: error generating dataset: %s", format, err.Error())
+			continue
+		}
+		if err := validate.Dataset(ds); err != nil {
+			t.Errorf("%s: invalid dataset: %s", format, err.Error())
+		}
+		if ds.Meta == nil || ds.Meta.Title == "" || ds.Commit == nil || ds.Commit.Title == "" {
+			t.Errorf("%s: expected meta & commit titles", format)
+		}
+
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Fatalf("%s: error reading body: %s", format, err.Error())
+		}
+		r, err := dsio.NewEntryReader(ds.Structure, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: error creating entry reader: %s", format, err.Error())
+		}
+		errs, err := validate.ValidateEntries(r, 0)
+		if err != nil {
+			t.Errorf("%s: error validating body: %s", format, err.Error())
+			continue
+		}
+		if len(errs) != 0 {
+			t.Errorf("%s: expected valid body. got: %v", format, errs)
+		}
+
+		r, _ = dsio.NewEntryReader(ds.Structure, bytes.NewReader(data))
+		count := 0
+		dsio.EachEntry(r, func(i int, ent dsio.Entry, err error) error {
+			count++
+			return nil
+		})
+		if count != 25 || ds.Structure.Entries != 25 {
+			t.Errorf("%s: expected 25 entries. got: %d, structure entries: %d", format, count, ds.Structure.Entries)
+		}
+	}
+
+	a, _, _ := Dataset(func(o *RandomDatasetOpts) { o.Seed = 3 })
+	b, _, _ := Dataset(func(o *RandomDatasetOpts) { o.Seed = 3 })
+	if err := dataset.CompareDatasets(a, b); err != nil {
+		t.Errorf("seeded datasets should match: %s", err.Error())
+	}
+}
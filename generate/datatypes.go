@@ -1,74 +1,321 @@
 package generate
 
-// import (
-// 	"math/rand"
-// 	"net/url"
-// 	"time"
-
-// 	"github.com/qri-io/dataset/vals"
-// )
-
-// // RandomValue generates a randomized value for a given datatype
-// func RandomValue(t vals.Type) interface{} {
-// 	switch t {
-// 	// case vals.Unknown:
-// 	// return RandomValue(vals.Type((rand.Intn(vals.NumDatatypes) + 1) - 2))
-// 	case vals.TypeString:
-// 		return randString(rand.Intn(100))
-// 	case vals.TypeNumber:
-// 		return rand.Float32()
-// 	case vals.Integer:
-// 		return rand.Int()
-// 	case vals.Boolean:
-// 		return rand.Intn(10) > 4
-// 	case vals.JSON:
-// 		if rand.Intn(2) == 1 {
-// 			return map[string]interface{}{}
-// 		}
-// 		return []interface{}{}
-// 	case vals.Date:
-// 		return time.Now().Add(time.Hour * 24 * time.Duration(rand.Intn(30)+1))
-// 	case vals.URL:
-// 		return &url.URL{
-// 			Scheme: "http",
-// 			Host:   "bit.ly",
-// 			Path:   randString(6),
-// 		}
-// 	}
-
-// 	return nil
-// }
-
-// // RandomStringValue is RandomValue that always returns a string
-// func RandomStringValue(t vals.Type) string {
-// 	switch t {
-// 	case vals.Unknown:
-// 		return ""
-// 	case vals.Any:
-// 		return RandomStringValue(vals.Type((rand.Intn(vals.NumDatatypes) + 1) - 2))
-// 	case vals.TypeString:
-// 		return randString(rand.Intn(100))
-// 	case vals.TypeNumber:
-// 		str, _ := vals.TypeNumber.ValueToString(rand.Float32())
-// 		return str
-// 	case vals.Integer:
-// 		str, _ := vals.Integer.ValueToString(rand.Int())
-// 		return str
-// 	case vals.Boolean:
-// 		if rand.Intn(10) > 4 {
-// 			return "true"
-// 		}
-// 		return "false"
-// 	case vals.JSON:
-// 		if rand.Intn(2) > 1 {
-// 			return "{}"
-// 		}
-// 		return "[]"
-// 	case vals.Date:
-// 		return time.Now().Add(time.Hour * 24 * time.Duration(rand.Intn(30)+1)).Format(time.ANSIC)
-// 	case vals.URL:
-// 		return "http://bit.ly/" + randString(6)
-// 	}
-
-// 	return ""
-// }
+import (
+	"fmt"
+	"math"
+	"regexp/syntax"
+	"sort"
+	"time"
+)
+
+// scalarTypes are the types picked for schemas that don't specify a type
+var scalarTypes = []string{"string", "integer", "number", "boolean"}
+
+// defaults for numbers without bounds
+const (
+	defaultMinimum = -1000
+	defaultMaximum = 1000
+)
+
+// Value generates a random value that satisfies a decoded jsonschema.
+// Supported keywords are type, enum, const, string minLength, maxLength,
+// pattern & format, numeric minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum & multipleOf, object properties & required, and array
+// items, minItems & maxItems. Integers are generated as int64, numbers as
+// float64
+func (g *Generator) Value(sch map[string]interface{}) interface{} {
+	if sch == nil {
+		sch = map[string]interface{}{}
+	}
+	if enum, ok := sch["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[g.rnd.Intn(len(enum))]
+	}
+	if c, ok := sch["const"]; ok {
+		return c
+	}
+
+	switch g.schemaType(sch) {
+	case "string":
+		return g.stringValue(sch)
+	case "integer":
+		return g.integerValue(sch)
+	case "number":
+		return g.numberValue(sch)
+	case "boolean":
+		return g.rnd.Intn(2) == 1
+	case "object":
+		return g.objectValue(sch)
+	case "array":
+		return g.arrayValue(sch)
+	}
+	return nil
+}
+
+// schemaType picks the type of value to generate for a schema
+func (g *Generator) schemaType(sch map[string]interface{}) string {
+	switch t := sch["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		if len(t) > 0 {
+			if str, ok := t[g.rnd.Intn(len(t))].(string); ok {
+				return str
+			}
+		}
+	}
+
+	if _, ok := sch["properties"]; ok {
+		return "object"
+	}
+	if _, ok := sch["items"]; ok {
+		return "array"
+	}
+	return scalarTypes[g.rnd.Intn(len(scalarTypes))]
+}
+
+// stringValue generates a string that satisfies a string schema
+func (g *Generator) stringValue(sch map[string]interface{}) string {
+	min, max := 0, g.maxStringLength
+	if n, ok := number(sch["minLength"]); ok {
+		min = int(n)
+	}
+	if n, ok := number(sch["maxLength"]); ok {
+		max = int(n)
+	} else if max < min {
+		max = min
+	}
+
+	if pattern, ok := sch["pattern"].(string); ok {
+		re, err := syntax.Parse(pattern, syntax.Perl)
+		if err != nil {
+			log.Debug(err.Error())
+		} else {
+			re = re.Simplify()
+			// patterns don't always agree with length limits, try a few
+			// times before giving up
+			var str string
+			for i := 0; i < 10; i++ {
+				str = g.matchString(re)
+				if l := len([]rune(str)); l >= min && l <= max {
+					break
+				}
+			}
+			return str
+		}
+	}
+
+	if format, ok := sch["format"].(string); ok {
+		if str, ok := g.formatString(format); ok {
+			return str
+		}
+	}
+
+	return g.randString(g.between(min, max))
+}
+
+// formatString generates a string in a named format. Returns false for
+// unsupported formats
+func (g *Generator) formatString(format string) (string, bool) {
+	switch format {
+	case "date-time":
+		return g.time().Format(time.RFC3339), true
+	case "date":
+		return g.time().Format("2006-01-02"), true
+	case "time":
+		return g.time().Format("15:04:05"), true
+	case "email":
+		return fmt.Sprintf("%s@%s.com", g.randString(g.between(1, 12)), g.randString(g.between(1, 12))), true
+	case "uri", "url":
+		return fmt.Sprintf("https://%s.com/%s", g.randString(g.between(1, 12)), g.randString(g.between(0, 12))), true
+	}
+	return "", false
+}
+
+// time gives a random time within roughly 30 years of 2000
+func (g *Generator) time() time.Time {
+	base := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	return base.Add(time.Duration(g.rnd.Int63n(int64(time.Hour)*24*365*30)) - time.Hour*24*365*15).Truncate(time.Second)
+}
+
+// matchString generates a string matching a parsed regular expression
+func (g *Generator) matchString(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return string(re.Rune)
+	case syntax.OpCharClass:
+		if len(re.Rune) < 2 {
+			return ""
+		}
+		i := g.rnd.Intn(len(re.Rune)/2) * 2
+		lo, hi := re.Rune[i], re.Rune[i+1]
+		if hi-lo > 0xff {
+			hi = lo + 0xff
+		}
+		return string(lo + rune(g.rnd.Intn(int(hi-lo)+1)))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return string(alphaNumericRunes[g.rnd.Intn(len(alphaNumericRunes))])
+	case syntax.OpCapture:
+		return g.matchString(re.Sub[0])
+	case syntax.OpConcat:
+		str := ""
+		for _, sub := range re.Sub {
+			str += g.matchString(sub)
+		}
+		return str
+	case syntax.OpAlternate:
+		return g.matchString(re.Sub[g.rnd.Intn(len(re.Sub))])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		min, max := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			min, max = 0, -1
+		case syntax.OpPlus:
+			min, max = 1, -1
+		case syntax.OpQuest:
+			min, max = 0, 1
+		}
+		if max < 0 {
+			max = min + 4
+		}
+		str := ""
+		for n := g.between(min, max); n > 0; n-- {
+			str += g.matchString(re.Sub[0])
+		}
+		return str
+	}
+	// anchors, word boundaries & empty matches consume no characters
+	return ""
+}
+
+// integerValue generates an integer that satisfies a numeric schema
+func (g *Generator) integerValue(sch map[string]interface{}) int64 {
+	min, max := bounds(sch)
+	lo, hi := math.Ceil(min), math.Floor(max)
+	if n, ok := number(sch["exclusiveMinimum"]); ok && lo <= n {
+		lo = math.Floor(n) + 1
+	}
+	if n, ok := number(sch["exclusiveMaximum"]); ok && hi >= n {
+		hi = math.Ceil(n) - 1
+	}
+
+	if m, ok := number(sch["multipleOf"]); ok && m >= 1 && m == math.Trunc(m) {
+		lo, hi = math.Ceil(lo/m), math.Floor(hi/m)
+		return int64(g.between(int(lo), int(hi))) * int64(m)
+	}
+	return int64(g.between(int(lo), int(hi)))
+}
+
+// numberValue generates a number that satisfies a numeric schema
+func (g *Generator) numberValue(sch map[string]interface{}) float64 {
+	min, max := bounds(sch)
+	if n, ok := number(sch["exclusiveMinimum"]); ok && min <= n {
+		min = math.Nextafter(n, math.Inf(1))
+	}
+	if n, ok := number(sch["exclusiveMaximum"]); ok && max >= n {
+		max = math.Nextafter(n, math.Inf(-1))
+	}
+
+	if m, ok := number(sch["multipleOf"]); ok && m > 0 {
+		lo, hi := math.Ceil(min/m), math.Floor(max/m)
+		return float64(g.between(int(lo), int(hi))) * m
+	}
+	return min + g.rnd.Float64()*(max-min)
+}
+
+// bounds gives the inclusive range of a numeric schema, defaulting
+// unbounded sides
+func bounds(sch map[string]interface{}) (min, max float64) {
+	min, max = defaultMinimum, defaultMaximum
+	minimum, hasMin := number(sch["minimum"])
+	maximum, hasMax := number(sch["maximum"])
+	if hasMin {
+		min = minimum
+		if !hasMax {
+			max = minimum + (defaultMaximum - defaultMinimum)
+		}
+	}
+	if hasMax {
+		max = maximum
+		if !hasMin {
+			min = maximum - (defaultMaximum - defaultMinimum)
+		}
+	}
+	return
+}
+
+// objectValue generates an object that satisfies an object schema.
+// Required properties are always present, others are present three
+// quarters of the time
+func (g *Generator) objectValue(sch map[string]interface{}) map[string]interface{} {
+	obj := map[string]interface{}{}
+	required := map[string]bool{}
+	if req, ok := sch["required"].([]interface{}); ok {
+		for _, r := range req {
+			if key, ok := r.(string); ok {
+				required[key] = true
+			}
+		}
+	}
+
+	props, _ := sch["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	// iterate in sorted order so output is reproducible
+	sort.Strings(keys)
+	for _, key := range keys {
+		if required[key] || g.rnd.Intn(4) > 0 {
+			prop, _ := props[key].(map[string]interface{})
+			obj[key] = g.Value(prop)
+		}
+	}
+	for key := range required {
+		if _, ok := obj[key]; !ok {
+			obj[key] = g.Value(nil)
+		}
+	}
+	return obj
+}
+
+// arrayValue generates an array that satisfies an array schema
+func (g *Generator) arrayValue(sch map[string]interface{}) []interface{} {
+	switch items := sch["items"].(type) {
+	case []interface{}:
+		arr := make([]interface{}, len(items))
+		for i, item := range items {
+			s, _ := item.(map[string]interface{})
+			arr[i] = g.Value(s)
+		}
+		return arr
+	default:
+		s, _ := items.(map[string]interface{})
+		min, max := 0, g.maxArrayLength
+		if n, ok := number(sch["minItems"]); ok {
+			min = int(n)
+		}
+		if n, ok := number(sch["maxItems"]); ok {
+			max = int(n)
+		} else if max < min {
+			max = min
+		}
+		arr := make([]interface{}, g.between(min, max))
+		for i := range arr {
+			arr[i] = g.Value(s)
+		}
+		return arr
+	}
+}
+
+// number reads a decoded json number
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
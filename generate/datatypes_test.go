@@ -0,0 +1,124 @@
+package generate
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestValue(t *testing.T) {
+	cases := []struct {
+		schema string
+		check  func(v interface{}) bool
+	}{
+		{`{"enum": ["a", "b", 3]}`, func(v interface{}) bool {
+			return v == "a" || v == "b" || v == float64(3)
+		}},
+		{`{"const": "x"}`, func(v interface{}) bool { return v == "x" }},
+		{`{"type": "string", "minLength": 3, "maxLength": 5}`, func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && len(s) >= 3 && len(s) <= 5
+		}},
+		{`{"type": "string", "pattern": "^[A-Z]{3}-\\d{2,4}(x|yz)?$"}`, func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && regexp.MustCompile(`^[A-Z]{3}-\d{2,4}(x|yz)?$`).MatchString(s)
+		}},
+		{`{"type": "string", "format": "date-time"}`, func(v interface{}) bool {
+			_, err := time.Parse(time.RFC3339, v.(string))
+			return err == nil
+		}},
+		{`{"type": "integer", "minimum": 5, "maximum": 7}`, func(v interface{}) bool {
+			i, ok := v.(int64)
+			return ok && i >= 5 && i <= 7
+		}},
+		{`{"type": "integer", "exclusiveMinimum": 5, "exclusiveMaximum": 7}`, func(v interface{}) bool {
+			return v == int64(6)
+		}},
+		{`{"type": "integer", "minimum": 0, "multipleOf": 5}`, func(v interface{}) bool {
+			i, ok := v.(int64)
+			return ok && i >= 0 && i%5 == 0
+		}},
+		{`{"type": "number", "minimum": -1.5, "maximum": 1.5}`, func(v interface{}) bool {
+			n, ok := v.(float64)
+			return ok && n >= -1.5 && n <= 1.5
+		}},
+		{`{"type": "number", "maximum": -100}`, func(v interface{}) bool {
+			n, ok := v.(float64)
+			return ok && n <= -100
+		}},
+		{`{"type": "number", "multipleOf": 0.25}`, func(v interface{}) bool {
+			n, ok := v.(float64)
+			return ok && math.Mod(n, 0.25) == 0
+		}},
+		{`{"type": ["boolean", "null"]}`, func(v interface{}) bool {
+			_, ok := v.(bool)
+			return ok || v == nil
+		}},
+		{`{"type": "array", "items": {"type": "boolean"}, "minItems": 2, "maxItems": 3}`, func(v interface{}) bool {
+			arr, ok := v.([]interface{})
+			if !ok || len(arr) < 2 || len(arr) > 3 {
+				return false
+			}
+			for _, b := range arr {
+				if _, ok := b.(bool); !ok {
+					return false
+				}
+			}
+			return true
+		}},
+		{`{"type": "array", "items": [{"type": "string"}, {"type": "integer"}]}`, func(v interface{}) bool {
+			arr, ok := v.([]interface{})
+			if !ok || len(arr) != 2 {
+				return false
+			}
+			_, s := arr[0].(string)
+			_, i := arr[1].(int64)
+			return s && i
+		}},
+		{`{"type": "object", "required": ["a"], "properties": {"a": {"type": "integer"}, "b": {"type": "string"}}}`, func(v interface{}) bool {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			if _, ok := obj["a"].(int64); !ok {
+				return false
+			}
+			if b, ok := obj["b"]; ok {
+				_, ok = b.(string)
+				return ok
+			}
+			return true
+		}},
+	}
+
+	g := NewGenerator(func(o *GeneratorOpts) { o.Seed = 1 })
+	for i, c := range cases {
+		sch := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(c.schema), &sch); err != nil {
+			t.Fatalf("case %d error parsing schema: %s", i, err.Error())
+		}
+		for j := 0; j < 50; j++ {
+			if v := g.Value(sch); !c.check(v) {
+				t.Errorf("case %d invalid value: %#v", i, v)
+				break
+			}
+		}
+	}
+}
+
+func TestGeneratorSeed(t *testing.T) {
+	sch := map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"type": "string"},
+	}
+	a := NewGenerator(func(o *GeneratorOpts) { o.Seed = 42 })
+	b := NewGenerator(func(o *GeneratorOpts) { o.Seed = 42 })
+	for i := 0; i < 10; i++ {
+		if av, bv := a.Value(sch), b.Value(sch); !reflect.DeepEqual(av, bv) {
+			t.Fatalf("seeded generators should match. %d: %v != %v", i, av, bv)
+		}
+	}
+}
@@ -1,79 +1,62 @@
 package generate
 
-// import (
-// 	"math/rand"
-
-// 	"github.com/qri-io/dataset"
-// 	"github.com/qri-io/dataset/datatypes"
-// 	// "github.com/qri-io/datatypes/datatypes_generate"
-// )
-
-// // RandomFieldsOpt specifies the options for GenerateRandomFields
-// type RandomFieldsOpt struct {
-// 	// use a provided name instead of a random one
-// 	Name string
-// 	// number of random fields to generate, default between 1 & 10
-// 	NumFields int
-// 	// constrict creation to provided types, blank means any valid datatypes
-// 	Datatypes []datatypes.Type
-// 	// set fields to get a specific set of fields back
-// 	// overrides numfields, datatypess
-// 	Fields []*dataset.Field
-// }
-
-// // RandomFields creates a random valid table. Provide option func(s) to customize
-// func RandomFields(options ...func(*RandomFieldsOpt)) []*dataset.Field {
-// 	opt := &RandomFieldsOpt{
-// 		Name:      randString(16),
-// 		NumFields: rand.Intn(9) + 1,
-// 		Fields:    nil,
-// 	}
-// 	for _, option := range options {
-// 		option(opt)
-// 	}
-
-// 	if opt.Fields == nil {
-// 		opt.Fields = make([]*dataset.Field, opt.NumFields)
-// 		for i := 0; i < opt.NumFields; i++ {
-// 			opt.Fields[i] = RandomField(func(o *RandomFieldOpt) {
-// 				o.Datatypes = opt.Datatypes
-// 			})
-// 		}
-// 	}
-
-// 	return opt.Fields
-// }
-
-// // RandomFieldOpt are the options for RandomField
-// type RandomFieldOpt struct {
-// 	// use a provided name instead of a random one
-// 	Name string
-// 	// use a provided type instead of a random one
-// 	Type datatypes.Type
-// 	// constrict random types to a provided set, blank means any valid datatypes
-// 	Datatypes []datatypes.Type
-// }
-
-// // RandomField generates a random field, optionally configured
-// func RandomField(options ...func(*RandomFieldOpt)) *dataset.Field {
-// 	opt := &RandomFieldOpt{
-// 		Name:      randString(16),
-// 		Datatypes: nil,
-// 	}
-// 	for _, option := range options {
-// 		option(opt)
-// 	}
-
-// 	if opt.Type == datatypes.Unknown {
-// 		if opt.Datatypes != nil {
-// 			opt.Type = opt.Datatypes[rand.Intn((len(opt.Datatypes)-1))+1]
-// 		} else {
-// 			opt.Type = datatypes.Type(rand.Intn(datatypes.NumDatatypes) + 1)
-// 		}
-// 	}
-
-// 	return &dataset.Field{
-// 		Name: opt.Name,
-// 		Type: opt.Type,
-// 	}
-// }
+// RandomFieldsOpt specifies the options for Fields
+type RandomFieldsOpt struct {
+	// number of random fields to generate, default between 1 & 10
+	NumFields int
+	// constrict creation to provided types, blank means any scalar type
+	Types []string
+}
+
+// Fields creates random column schemas for the items of a tabular
+// schema. Provide option func(s) to customize
+func (g *Generator) Fields(options ...func(*RandomFieldsOpt)) []interface{} {
+	opt := &RandomFieldsOpt{
+		NumFields: g.between(1, 10),
+	}
+	for _, option := range options {
+		option(opt)
+	}
+
+	fields := make([]interface{}, opt.NumFields)
+	for i := range fields {
+		fields[i] = g.Field(func(o *RandomFieldOpt) {
+			o.Types = opt.Types
+		})
+	}
+	return fields
+}
+
+// RandomFieldOpt are the options for Field
+type RandomFieldOpt struct {
+	// use a provided title instead of a random one
+	Title string
+	// use a provided type instead of a random one
+	Type string
+	// constrict random types to a provided set, blank means any scalar type
+	Types []string
+}
+
+// Field generates a random column schema, optionally configured
+func (g *Generator) Field(options ...func(*RandomFieldOpt)) map[string]interface{} {
+	opt := &RandomFieldOpt{}
+	for _, option := range options {
+		option(opt)
+	}
+
+	if opt.Title == "" {
+		opt.Title = "field_" + g.randString(8)
+	}
+	if opt.Type == "" {
+		types := opt.Types
+		if len(types) == 0 {
+			types = scalarTypes
+		}
+		opt.Type = types[g.rnd.Intn(len(types))]
+	}
+
+	return map[string]interface{}{
+		"title": opt.Title,
+		"type":  opt.Type,
+	}
+}
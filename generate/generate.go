@@ -2,21 +2,73 @@
 // structures and data
 package generate
 
-// import (
-// 	"math/rand"
-// 	"time"
-// )
-
-// func init() {
-// 	rand.Seed(time.Now().UnixNano())
-// }
-
-// var alphaNumericRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
-
-// func randString(n int) string {
-// 	b := make([]rune, n)
-// 	for i := range b {
-// 		b[i] = alphaNumericRunes[rand.Intn(len(alphaNumericRunes))]
-// 	}
-// 	return string(b)
-// }
+import (
+	"math/rand"
+	"time"
+
+	logger "github.com/ipfs/go-log"
+)
+
+var (
+	log               = logger.Logger("generate")
+	alphaNumericRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+)
+
+// Generator creates random structures and data. Generators created with
+// the same seed produce the same output. Generators are not safe for
+// concurrent use
+type Generator struct {
+	rnd             *rand.Rand
+	maxStringLength int
+	maxArrayLength  int
+}
+
+// GeneratorOpts configures a Generator
+type GeneratorOpts struct {
+	// Seed seeds the random number generator, a zero seed uses the current
+	// time
+	Seed int64
+	// MaxStringLength is the longest string generated for schemas that
+	// don't specify a maxLength, default 32
+	MaxStringLength int
+	// MaxArrayLength is the longest array generated for schemas that don't
+	// specify maxItems, default 8
+	MaxArrayLength int
+}
+
+// NewGenerator creates a Generator, optionally configured
+func NewGenerator(opts ...func(*GeneratorOpts)) *Generator {
+	opt := &GeneratorOpts{
+		MaxStringLength: 32,
+		MaxArrayLength:  8,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.Seed == 0 {
+		opt.Seed = time.Now().UnixNano()
+	}
+
+	return &Generator{
+		rnd:             rand.New(rand.NewSource(opt.Seed)),
+		maxStringLength: opt.MaxStringLength,
+		maxArrayLength:  opt.MaxArrayLength,
+	}
+}
+
+// randString creates a random alphanumeric string of length n
+func (g *Generator) randString(n int) string {
+	b := make([]rune, n)
+	for i := range b {
+		b[i] = alphaNumericRunes[g.rnd.Intn(len(alphaNumericRunes))]
+	}
+	return string(b)
+}
+
+// between gives a random integer in the range [min, max]
+func (g *Generator) between(min, max int) int {
+	if max <= min {
+		return min
+	}
+	return min + g.rnd.Intn(max-min+1)
+}
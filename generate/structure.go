@@ -1,50 +1,67 @@
 package generate
 
-// import (
-// 	"github.com/ipfs/go-datastore"
-// 	"math/rand"
+import (
+	"encoding/json"
+	"fmt"
 
-// 	"github.com/qri-io/dataset"
-// 	"github.com/qri-io/dataset/datatypes"
-// )
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/jsonschema"
+)
 
-// // RandomStructureOpts configures the RandomStructure func
-// type RandomStructureOpts struct {
-// 	Path      datastore.Key
-// 	Datatypes []datatypes.Type
-// 	Format    dataset.DataFormat
-// 	NumFields int
-// 	Fields    []*dataset.Field
-// 	// Data           []byte
-// 	// NumRandRecords int
-// }
+// RandomStructureOpts configures the Structure func
+type RandomStructureOpts struct {
+	// Format of the structure, default CSV
+	Format dataset.DataFormat
+	// number of fields (columns) to generate, default between 1 & 10
+	NumFields int
+	// constrict fields to provided types, blank means any scalar type
+	Types []string
+	// set fields to get a specific set of column schemas back,
+	// overrides NumFields & Types
+	Fields []interface{}
+}
 
-// // RandomStructure generates a randomized resource definition
-// func RandomStructure(options ...func(*RandomStructureOpts)) *dataset.Structure {
-// 	opt := &RandomStructureOpts{
-// 		NumFields: rand.Intn(9) + 1,
-// 		Datatypes: nil,
-// 		Format:    dataset.CSVDataFormat,
-// 	}
+// Structure generates a random structure for tabular data, where entries
+// are arrays of columns
+func (g *Generator) Structure(options ...func(*RandomStructureOpts)) (*dataset.Structure, error) {
+	opt := &RandomStructureOpts{
+		Format:    dataset.CSVDataFormat,
+		NumFields: g.between(1, 10),
+	}
+	for _, option := range options {
+		option(opt)
+	}
 
-// 	for _, option := range options {
-// 		option(opt)
-// 	}
+	if opt.Fields == nil {
+		opt.Fields = g.Fields(func(o *RandomFieldsOpt) {
+			o.NumFields = opt.NumFields
+			o.Types = opt.Types
+		})
+	}
 
-// 	if opt.Fields == nil && opt.NumFields > 0 {
-// 		opt.Fields = RandomFields(func(o *RandomFieldsOpt) {
-// 			o.NumFields = opt.NumFields
-// 			o.Datatypes = opt.Datatypes
-// 		})
-// 	}
+	data, err := json.Marshal(map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":  "array",
+			"items": opt.Fields,
+		},
+	})
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error marshaling schema: %s", err.Error())
+	}
+	sch := &jsonschema.RootSchema{}
+	if err := sch.UnmarshalJSON(data); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error parsing schema: %s", err.Error())
+	}
 
-// 	ds := &dataset.Structure{
-// 		Format: opt.Format,
-// 		Schema: &dataset.Schema{
-// 			Fields: opt.Fields,
-// 		},
-// 		// Data: opt.Data,
-// 	}
-
-// 	return ds
-// }
+	st := &dataset.Structure{
+		Format: opt.Format,
+		Schema: sch,
+	}
+	if opt.Format == dataset.CSVDataFormat {
+		st.FormatConfig = &dataset.CSVOptions{HeaderRow: true}
+	}
+	return st, nil
+}
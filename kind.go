@@ -21,6 +21,8 @@ const (
 	KindCommit = Kind("cm:" + CurrentSpecVersion)
 	// KindVisConfig is the current kind for dataset transforms
 	KindVisConfig = Kind("vc:" + CurrentSpecVersion)
+	// KindStats is the current kind for dataset stats
+	KindStats = Kind("sa:" + CurrentSpecVersion)
 )
 
 // Kind is a short identifier for all types of qri dataset objects
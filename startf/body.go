@@ -0,0 +1,134 @@
+package startf
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsfs"
+	"github.com/qri-io/dataset/dsio"
+	"go.starlark.net/starlark"
+)
+
+// readBody loads the body of a dataset as a list, or a dict if entries
+// are keyed
+func readBody(store cafs.Filestore, ds *dataset.Dataset) (starlark.Value, error) {
+	f, err := dsfs.LoadData(store, ds)
+	if err != nil {
+		return nil, fmt.Errorf("error loading body: %s", err.Error())
+	}
+	defer f.Close()
+
+	r, err := dsio.NewEntryReader(ds.Structure, f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %s", err.Error())
+	}
+
+	var (
+		list  []starlark.Value
+		dict  = &starlark.Dict{}
+		keyed bool
+	)
+	err = dsio.EachEntry(r, func(i int, ent dsio.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		v, err := toStarlark(ent.Value)
+		if err != nil {
+			return fmt.Errorf("entry %d: %s", i, err.Error())
+		}
+		if ent.Key != "" {
+			keyed = true
+			return dict.SetKey(starlark.String(ent.Key), v)
+		}
+		list = append(list, v)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %s", err.Error())
+	}
+	if keyed {
+		return dict, nil
+	}
+	return starlark.NewList(list), nil
+}
+
+// writeBody encodes a body with a structure
+func writeBody(st *dataset.Structure, body interface{}) (cafs.File, error) {
+	buf, err := dsio.NewEntryBuffer(st)
+	if err != nil {
+		return nil, fmt.Errorf("error allocating body buffer: %s", err.Error())
+	}
+
+	switch b := body.(type) {
+	case []interface{}:
+		for i, v := range b {
+			if err := buf.WriteEntry(dsio.Entry{Index: i, Value: v}); err != nil {
+				return nil, fmt.Errorf("error writing body entry %d: %s", i, err.Error())
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(b))
+		for key := range b {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			if err := buf.WriteEntry(dsio.Entry{Index: i, Key: key, Value: b[key]}); err != nil {
+				return nil, fmt.Errorf("error writing body entry %q: %s", key, err.Error())
+			}
+		}
+	}
+
+	if err := buf.Close(); err != nil {
+		return nil, fmt.Errorf("error writing body: %s", err.Error())
+	}
+	return cafs.NewMemfileBytes(fmt.Sprintf("data.%s", st.Format.String()), buf.Bytes()), nil
+}
+
+// metaValue converts dataset metadata to a dict, or None for no metadata
+func metaValue(md *dataset.Meta) (starlark.Value, error) {
+	if md == nil {
+		return starlark.None, nil
+	}
+	data, err := json.Marshal(md)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding meta: %s", err.Error())
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("error decoding meta: %s", err.Error())
+	}
+	// scripts don't need the kind of metadata
+	delete(fields, "qri")
+	return toStarlark(fields)
+}
+
+// metaFromValue converts a dict to dataset metadata
+func metaFromValue(d *starlark.Dict) (*dataset.Meta, error) {
+	fields, err := fromStarlark(d)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	md := &dataset.Meta{}
+	if err := md.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("invalid meta: %s", err.Error())
+	}
+	md.Qri = dataset.KindMeta
+	return md, nil
+}
+
+func sortedKeys(resources map[string]*dataset.Dataset) []string {
+	keys := make([]string, 0, len(resources))
+	for key := range resources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
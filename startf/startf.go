@@ -0,0 +1,361 @@
+// Package startf runs transforms written in starlark, a python dialect
+// designed for sandboxed execution. Transform scripts read the previous
+// version of a dataset & any resources the transform names, and emit the
+// body & metadata of a new version.
+//
+// Scripts are given these globals:
+// * prev: the previous version as a struct with body & meta fields,
+// None for the first version
+// * resources: a dict of transform resources, each a struct with body &
+// meta fields
+// * config: a dict of the transform's Config
+// * set_body(body): sets the body of the new version, a list or dict
+// * set_meta(meta): sets the metadata of the new version, a dict
+// Inputs are frozen, scripts copy values to change them.
+//
+// Scripts have no access to the network or filesystem. load statements
+// can only load modules callers provide with ExecOpts.Modules
+package startf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	logger "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
+	"github.com/qri-io/dataset/dsfs"
+	"github.com/qri-io/dataset/dsio"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+var log = logger.Logger("startf")
+
+const (
+	// DefaultMaxSteps is the default limit on starlark computation steps a
+	// transform can execute
+	DefaultMaxSteps = 10000000
+)
+
+// Syntax is the Transform.Syntax value of starlark transforms
+const Syntax = "starlark"
+
+// ExecOpts configures transform execution
+type ExecOpts struct {
+	// MaxSteps limits the starlark computation steps a transform can
+	// execute, zero means no limit. Defaults to DefaultMaxSteps
+	MaxSteps uint64
+	// MaxProcessHeapGrowth cancels execution if the heap of the whole
+	// process grows more than MaxProcessHeapGrowth bytes while the
+	// transform runs. Zero, the default, disables the guard.
+	// This doesn't measure the transform's own memory use: allocations by
+	// every other goroutine count against it, and the heap is sampled
+	// periodically, so short bursts of allocation can go unnoticed. Only
+	// enable it in processes that run little else alongside transforms
+	MaxProcessHeapGrowth uint64
+	// Modules are made available to load statements by name. Modules give
+	// scripts capabilities beyond the sandbox, eg: network access
+	Modules map[string]starlark.StringDict
+	// Print is called with the output of print statements, output is
+	// logged by default
+	Print func(msg string)
+}
+
+// ExecTransform runs a starlark transform, loading the script at the store
+// path in t.Data. prev is the previous version of the dataset, or nil for
+// the first version. ExecTransform returns a dataset & data file ready to
+// pass to dsfs.CreateDataset. The new version keeps the structure of prev
+// unless t.Structure specifies one, & keeps the metadata of prev if the
+// script doesn't set it
+func ExecTransform(store cafs.Filestore, t *dataset.Transform, prev *dataset.Dataset, opts ...func(*ExecOpts)) (*dataset.Dataset, cafs.File, error) {
+	opt := &ExecOpts{
+		MaxSteps: DefaultMaxSteps,
+		Print: func(msg string) {
+			log.Info(msg)
+		},
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	if t.Syntax != Syntax {
+		return nil, nil, fmt.Errorf("can't execute transform with syntax '%s'", t.Syntax)
+	}
+	script, err := loadScript(store, t.Data)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil, err
+	}
+
+	globals, err := predeclared(store, t, prev)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil, err
+	}
+	out := &output{}
+	globals["set_body"] = starlark.NewBuiltin("set_body", out.setBody)
+	globals["set_meta"] = starlark.NewBuiltin("set_meta", out.setMeta)
+
+	thread := &starlark.Thread{
+		Name: "transform",
+		Print: func(_ *starlark.Thread, msg string) {
+			opt.Print(msg)
+		},
+		Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			if mod, ok := opt.Modules[module]; ok {
+				return mod, nil
+			}
+			return nil, fmt.Errorf("module %q isn't available", module)
+		},
+	}
+	if opt.MaxSteps > 0 {
+		thread.SetMaxExecutionSteps(opt.MaxSteps)
+		thread.OnMaxSteps = func(th *starlark.Thread) {
+			th.Cancel(fmt.Sprintf("exceeded step limit of %d", opt.MaxSteps))
+		}
+	}
+	if opt.MaxProcessHeapGrowth > 0 {
+		stop := guardHeap(thread, opt.MaxProcessHeapGrowth)
+		defer stop()
+	}
+
+	if _, err := starlark.ExecFile(thread, t.Data, script, globals); err != nil {
+		log.Debug(err.Error())
+		return nil, nil, fmt.Errorf("error executing transform: %s", err.Error())
+	}
+	if out.body == nil {
+		return nil, nil, fmt.Errorf("transform didn't set a body")
+	}
+
+	ds := &dataset.Dataset{
+		Qri:       dataset.KindDataset,
+		Commit:    &dataset.Commit{Qri: dataset.KindCommit},
+		Transform: t,
+	}
+	if prev != nil {
+		ds.PreviousPath = prev.Path().String()
+		ds.Meta = prev.Meta
+	}
+	if out.meta != nil {
+		ds.Meta = out.meta
+	}
+	ds.Structure = outputStructure(t, prev, out.body)
+
+	df, err := writeBody(ds.Structure, out.body)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil, err
+	}
+	return ds, df, nil
+}
+
+// CreateDataset runs a starlark transform & saves the result as a new
+// version of prev, which may be nil for the first version
+func CreateDataset(store cafs.Filestore, t *dataset.Transform, prev *dataset.Dataset, pk crypto.PrivKey, pin bool, opts ...func(*ExecOpts)) (datastore.Key, error) {
+	ds, df, err := ExecTransform(store, t, prev, opts...)
+	if err != nil {
+		return datastore.NewKey(""), err
+	}
+	return dsfs.CreateDataset(store, ds, df, pk, pin)
+}
+
+// Runner gives a dsfs.TransformRunner that executes starlark transforms,
+// for checking transforms with dsfs.VerifyTransform
+func Runner(opts ...func(*ExecOpts)) dsfs.TransformRunner {
+	return dsfs.TransformRunnerFunc(func(store cafs.Filestore, t *dataset.Transform, prev *dataset.Dataset) (dsio.EntryReader, *dataset.Structure, error) {
+		ds, df, err := ExecTransform(store, t, prev, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, err := dsio.NewEntryReader(ds.Structure, df)
+		if err != nil {
+			log.Debug(err.Error())
+			return nil, nil, err
+		}
+		return r, ds.Structure, nil
+	})
+}
+
+// loadScript reads a transform script from the store
+func loadScript(store cafs.Filestore, path string) ([]byte, error) {
+	if path == "" {
+		return nil, fmt.Errorf("transform has no script to execute")
+	}
+	f, err := store.Get(datastore.NewKey(path))
+	if err != nil {
+		return nil, fmt.Errorf("error loading transform script: %s", err.Error())
+	}
+	defer f.Close()
+	script, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading transform script: %s", err.Error())
+	}
+	return script, nil
+}
+
+// predeclared builds the frozen input globals of a script
+func predeclared(store cafs.Filestore, t *dataset.Transform, prev *dataset.Dataset) (starlark.StringDict, error) {
+	globals := starlark.StringDict{"prev": starlark.None}
+	if prev != nil {
+		v, err := datasetValue(store, prev)
+		if err != nil {
+			return nil, fmt.Errorf("error loading previous version: %s", err.Error())
+		}
+		globals["prev"] = v
+	}
+
+	resources := &starlark.Dict{}
+	for _, name := range sortedKeys(t.Resources) {
+		ref := t.Resources[name]
+		if ref == nil {
+			return nil, fmt.Errorf("resource '%s' is empty", name)
+		}
+		v, err := datasetValue(store, ref)
+		if err != nil {
+			return nil, fmt.Errorf("error loading resource '%s': %s", name, err.Error())
+		}
+		if err := resources.SetKey(starlark.String(name), v); err != nil {
+			return nil, err
+		}
+	}
+	globals["resources"] = resources
+
+	config, err := toStarlark(t.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %s", err.Error())
+	}
+	if config == starlark.None {
+		config = &starlark.Dict{}
+	}
+	globals["config"] = config
+
+	globals.Freeze()
+	return globals, nil
+}
+
+// datasetValue loads the body & metadata of a dataset as a struct.
+// Dataset references are loaded from the store
+func datasetValue(store cafs.Filestore, ds *dataset.Dataset) (starlark.Value, error) {
+	if ds.IsEmpty() || ds.Structure == nil || ds.DataPath == "" {
+		loaded, err := dsfs.LoadDataset(store, ds.Path())
+		if err != nil {
+			return nil, err
+		}
+		ds = loaded
+	}
+
+	body, err := readBody(store, ds)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := metaValue(ds.Meta)
+	if err != nil {
+		return nil, err
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"body": body,
+		"meta": meta,
+	}), nil
+}
+
+// outputStructure picks the structure of a transform's output
+func outputStructure(t *dataset.Transform, prev *dataset.Dataset, body interface{}) *dataset.Structure {
+	src := t.Structure
+	if (src == nil || src.Schema == nil) && prev != nil && prev.Structure != nil {
+		src = prev.Structure
+	}
+
+	st := &dataset.Structure{Qri: dataset.KindStructure, Format: dataset.JSONDataFormat}
+	if src != nil {
+		if src.Format != dataset.UnknownDataFormat {
+			st.Format = src.Format
+			st.FormatConfig = src.FormatConfig
+		}
+		st.Schema = src.Schema
+	}
+	if st.Schema == nil {
+		if _, ok := body.(map[string]interface{}); ok {
+			st.Schema = dataset.BaseSchemaObject
+		} else {
+			st.Schema = dataset.BaseSchemaArray
+		}
+	}
+	// data files are written uncompressed
+	st.Compression = compression.None
+	return st
+}
+
+// output collects the values a script emits
+type output struct {
+	body interface{}
+	meta *dataset.Meta
+}
+
+func (out *output) setBody(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var v starlark.Value
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &v); err != nil {
+		return nil, err
+	}
+	switch v.(type) {
+	case *starlark.List, starlark.Tuple, *starlark.Dict:
+	default:
+		return nil, fmt.Errorf("%s: body must be a list or dict, got %s", fn.Name(), v.Type())
+	}
+	body, err := fromStarlark(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fn.Name(), err.Error())
+	}
+	out.body = body
+	return starlark.None, nil
+}
+
+func (out *output) setMeta(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var d *starlark.Dict
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &d); err != nil {
+		return nil, err
+	}
+	meta, err := metaFromValue(d)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fn.Name(), err.Error())
+	}
+	out.meta = meta
+	return starlark.None, nil
+}
+
+// heapGuardInterval is how often guardHeap checks the size of the heap
+const heapGuardInterval = 50 * time.Millisecond
+
+// guardHeap cancels a thread if the process heap grows more than limit bytes
+// while it runs, returning a function that stops guarding. Reading memory
+// stats briefly stops the world, so the heap is only checked every
+// heapGuardInterval
+func guardHeap(thread *starlark.Thread, limit uint64) (stop func()) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	base := ms.HeapAlloc
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heapGuardInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				runtime.ReadMemStats(&ms)
+				if ms.HeapAlloc > base && ms.HeapAlloc-base > limit {
+					thread.Cancel(fmt.Sprintf("process heap grew more than %d bytes", limit))
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,253 @@
+package startf
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-crypto"
+	"github.com/qri-io/cafs"
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsfs"
+	"github.com/qri-io/jsonschema"
+	"go.starlark.net/starlark"
+)
+
+const citiesCSV = `city,pop,in_usa
+toronto,40000000,false
+new york,8500000,true
+chicago,300000,true
+`
+
+var citiesSchema = jsonschema.Must(`{
+	"type": "array",
+	"items": {
+		"type": "array",
+		"items": [
+			{"title": "city", "type": "string"},
+			{"title": "pop", "type": "integer"},
+			{"title": "in_usa", "type": "boolean"}
+		]
+	}
+}`)
+
+// testStore creates a store holding a cities dataset
+func testStore(t *testing.T) (cafs.Filestore, crypto.PrivKey, *dataset.Dataset) {
+	store := cafs.NewMapstore()
+	pk, _, err := crypto.GenerateKeyPair(crypto.RSA, 1024)
+	if err != nil {
+		t.Fatalf("error generating private key: %s", err.Error())
+	}
+	ds := &dataset.Dataset{
+		Meta:   &dataset.Meta{Title: "cities"},
+		Commit: &dataset.Commit{Title: "initial commit"},
+		Structure: &dataset.Structure{
+			Format:       dataset.CSVDataFormat,
+			FormatConfig: &dataset.CSVOptions{HeaderRow: true},
+			Schema:       citiesSchema,
+		},
+	}
+	key, err := dsfs.CreateDataset(store, ds, cafs.NewMemfileBytes("cities.csv", []byte(citiesCSV)), pk, false)
+	if err != nil {
+		t.Fatalf("error creating cities dataset: %s", err.Error())
+	}
+	prev, err := dsfs.LoadDataset(store, key)
+	if err != nil {
+		t.Fatalf("error loading cities dataset: %s", err.Error())
+	}
+	return store, pk, prev
+}
+
+// putScript adds a transform script to a store, returning its path
+func putScript(t *testing.T, store cafs.Filestore, script string) string {
+	key, err := store.Put(cafs.NewMemfileBytes("transform.star", []byte(script)), false)
+	if err != nil {
+		t.Fatalf("error putting script: %s", err.Error())
+	}
+	return key.String()
+}
+
+func TestExecTransform(t *testing.T) {
+	store, _, prev := testStore(t)
+
+	cases := []struct {
+		script string
+		prev   *dataset.Dataset
+		expect string
+		err    string
+	}{
+		{`
+def us_cities(rows):
+  return [r for r in rows if r[2]]
+set_body(us_cities(prev.body))
+`, prev, "city,pop,in_usa\nnew york,8500000,true\nchicago,300000,true\n", ""},
+		{`set_body({"a": 1, "b": [config["n"]]})`, nil, `{"a":1,"b":[2]}`, ""},
+		{`set_body({"n": len(resources["cities"].body), "title": resources["cities"].meta["title"]})`, nil, `{"n":3,"title":"cities"}`, ""},
+		{`x = 1`, nil, "", "transform didn't set a body"},
+		{`set_body("nope")`, nil, "", "error executing transform: set_body: body must be a list or dict, got string"},
+		{`set_body({1: 2})`, nil, "", "error executing transform: set_body: dict keys must be strings, got int"},
+		{`prev.body.append(1)`, prev, "", "error executing transform: append: cannot append to frozen list"},
+	}
+
+	for i, c := range cases {
+		tf := &dataset.Transform{
+			Syntax:    Syntax,
+			Data:      putScript(t, store, c.script),
+			Config:    map[string]interface{}{"n": 2},
+			Resources: map[string]*dataset.Dataset{"cities": dataset.NewDatasetRef(prev.Path())},
+		}
+		ds, df, err := ExecTransform(store, tf, c.prev)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(df)
+		if err != nil {
+			t.Errorf("case %d error reading data file: %s", i, err.Error())
+			continue
+		}
+		if string(data) != c.expect {
+			t.Errorf("case %d body mismatch. expected: %s, got: %s", i, c.expect, string(data))
+		}
+		if ds.Transform != tf {
+			t.Errorf("case %d expected dataset transform to be set", i)
+		}
+	}
+}
+
+func TestCreateDataset(t *testing.T) {
+	store, pk, prev := testStore(t)
+	tf := &dataset.Transform{
+		Syntax: Syntax,
+		Data: putScript(t, store, `
+def big(rows):
+  return [r for r in rows if r[1] > 1000000]
+set_body(big(prev.body))
+set_meta({"title": prev.meta["title"] + ", big ones", "keywords": ["cities"]})
+`),
+	}
+
+	key, err := CreateDataset(store, tf, prev, pk, false)
+	if err != nil {
+		t.Fatalf("error creating dataset: %s", err.Error())
+	}
+	ds, err := dsfs.LoadDataset(store, key)
+	if err != nil {
+		t.Fatalf("error loading dataset: %s", err.Error())
+	}
+	if ds.PreviousPath != prev.Path().String() {
+		t.Errorf("previous path mismatch. expected: %s, got: %s", prev.Path().String(), ds.PreviousPath)
+	}
+	if ds.Meta == nil || ds.Meta.Title != "cities, big ones" || len(ds.Meta.Keywords) != 1 {
+		t.Errorf("meta mismatch. got: %#v", ds.Meta)
+	}
+	if ds.Structure.Format != dataset.CSVDataFormat {
+		t.Errorf("expected format to carry over from previous version, got: %s", ds.Structure.Format)
+	}
+	if ds.Structure.Entries != 2 {
+		t.Errorf("entries mismatch. expected: 2, got: %d", ds.Structure.Entries)
+	}
+
+	f, err := dsfs.LoadData(store, ds)
+	if err != nil {
+		t.Fatalf("error loading data: %s", err.Error())
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("error reading data: %s", err.Error())
+	}
+	expect := "city,pop,in_usa\ntoronto,40000000,false\nnew york,8500000,true\n"
+	if string(data) != expect {
+		t.Errorf("data mismatch. expected: %s, got: %s", expect, string(data))
+	}
+
+	report, err := dsfs.VerifyTransform(store, key, func(o *dsfs.VerifyTransformOpts) {
+		o.Runners[Syntax] = Runner()
+	})
+	if err != nil {
+		t.Fatalf("error verifying transform: %s", err.Error())
+	}
+	if !report.Match {
+		t.Errorf("expected transform to be reproducible. diffs: %v", report.Diffs)
+	}
+}
+
+func TestExecTransformSandbox(t *testing.T) {
+	store, _, _ := testStore(t)
+
+	cases := []struct {
+		script string
+		opt    func(*ExecOpts)
+		err    string
+	}{
+		{`load("http.star", "get")`, nil, `cannot load http.star: module "http.star" isn't available`},
+		{`
+load("math.star", "double")
+set_body([double(2)])
+`, func(o *ExecOpts) {
+			o.Modules = map[string]starlark.StringDict{
+				"math.star": {"double": starlark.NewBuiltin("double", func(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+					n, _ := starlark.AsInt32(args[0])
+					return starlark.MakeInt(n * 2), nil
+				})},
+			}
+		}, ""},
+		{`
+def spin():
+  n = 0
+  for i in range(1000000):
+    n += i
+  return n
+spin()
+`, func(o *ExecOpts) { o.MaxSteps = 1000 }, "Starlark computation cancelled: exceeded step limit of 1000"},
+		{`
+def grow():
+  rows = []
+  for i in range(100000000):
+    rows.append([i, "a row with enough text to take up some space"])
+  return rows
+grow()
+`, func(o *ExecOpts) { o.MaxSteps, o.MaxProcessHeapGrowth = 0, 32<<20 }, "Starlark computation cancelled: process heap grew more than 33554432 bytes"},
+	}
+
+	for i, c := range cases {
+		tf := &dataset.Transform{Syntax: Syntax, Data: putScript(t, store, c.script)}
+		var opts []func(*ExecOpts)
+		if c.opt != nil {
+			opts = append(opts, c.opt)
+		}
+		_, _, err := ExecTransform(store, tf, nil, opts...)
+		if c.err == "" {
+			if err != nil {
+				t.Errorf("case %d unexpected error: %s", i, err.Error())
+			}
+			continue
+		}
+		if err == nil || !strings.HasSuffix(err.Error(), c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+		}
+	}
+}
+
+func TestExecTransformErrors(t *testing.T) {
+	store := cafs.NewMapstore()
+	cases := []struct {
+		transform *dataset.Transform
+		err       string
+	}{
+		{&dataset.Transform{Syntax: "sql", Data: "/map/foo"}, "can't execute transform with syntax 'sql'"},
+		{&dataset.Transform{Syntax: Syntax}, "transform has no script to execute"},
+		{&dataset.Transform{Syntax: Syntax, Data: datastore.NewKey("/map/missing").String()}, "error loading transform script: datastore: key not found"},
+	}
+	for i, c := range cases {
+		_, _, err := ExecTransform(store, c.transform, nil)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%v'", i, c.err, err)
+		}
+	}
+}
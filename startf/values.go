@@ -0,0 +1,141 @@
+package startf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"go.starlark.net/starlark"
+)
+
+// toStarlark converts a decoded go value to a starlark value. Arrays become
+// lists & objects become dicts with sorted keys
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch x := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(x), nil
+	case int:
+		return starlark.MakeInt(x), nil
+	case int8:
+		return starlark.MakeInt64(int64(x)), nil
+	case int16:
+		return starlark.MakeInt64(int64(x)), nil
+	case int32:
+		return starlark.MakeInt64(int64(x)), nil
+	case int64:
+		return starlark.MakeInt64(x), nil
+	case uint8:
+		return starlark.MakeUint64(uint64(x)), nil
+	case uint16:
+		return starlark.MakeUint64(uint64(x)), nil
+	case uint32:
+		return starlark.MakeUint64(uint64(x)), nil
+	case uint64:
+		return starlark.MakeUint64(x), nil
+	case float32:
+		return starlark.Float(x), nil
+	case float64:
+		return starlark.Float(x), nil
+	case string:
+		return starlark.String(x), nil
+	case []byte:
+		return starlark.Bytes(x), nil
+	case []interface{}:
+		elems := make([]starlark.Value, len(x))
+		for i, el := range x {
+			sv, err := toStarlark(el)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = sv
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		d := starlark.NewDict(len(keys))
+		for _, key := range keys {
+			sv, err := toStarlark(x[key])
+			if err != nil {
+				return nil, err
+			}
+			if err := d.SetKey(starlark.String(key), sv); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	case map[interface{}]interface{}:
+		obj := make(map[string]interface{}, len(x))
+		for key, val := range x {
+			obj[fmt.Sprintf("%v", key)] = val
+		}
+		return toStarlark(obj)
+	}
+	return nil, fmt.Errorf("can't convert %T to a starlark value", v)
+}
+
+// fromStarlark converts a starlark value to a go value. Integers become
+// int64 when they fit, float64 otherwise. Lists & tuples become arrays,
+// dicts become objects & must have string keys
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch x := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(x), nil
+	case starlark.Int:
+		if i, ok := x.Int64(); ok {
+			return i, nil
+		}
+		f := x.Float()
+		if math.IsInf(float64(f), 0) {
+			return nil, fmt.Errorf("integer %s is too large", x.String())
+		}
+		return float64(f), nil
+	case starlark.Float:
+		return float64(x), nil
+	case starlark.String:
+		return string(x), nil
+	case starlark.Bytes:
+		return string(x), nil
+	case *starlark.List:
+		return iterableValues(x, x.Len())
+	case starlark.Tuple:
+		return iterableValues(x, x.Len())
+	case *starlark.Dict:
+		obj := make(map[string]interface{}, x.Len())
+		for _, item := range x.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dict keys must be strings, got %s", item[0].Type())
+			}
+			val, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			obj[string(key)] = val
+		}
+		return obj, nil
+	}
+	return nil, fmt.Errorf("can't convert %s value", v.Type())
+}
+
+func iterableValues(it starlark.Iterable, n int) ([]interface{}, error) {
+	arr := make([]interface{}, 0, n)
+	iter := it.Iterate()
+	defer iter.Done()
+	var el starlark.Value
+	for iter.Next(&el) {
+		v, err := fromStarlark(el)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
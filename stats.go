@@ -0,0 +1,160 @@
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+)
+
+// Stats summarizes the values of a dataset's data, calculated when the
+// dataset is created so consumers can inspect data without reading it
+type Stats struct {
+	// private storage for reference to this object
+	path datastore.Key
+	// Qri should always be KindStats
+	Qri Kind `json:"qri"`
+	// Columns holds statistics for each column of tabular data, or each key
+	// of object entries. Data with scalar entries has a single, untitled column
+	Columns []*ColumnStats `json:"columns,omitempty"`
+}
+
+// ColumnStats summarizes the values of a single column
+type ColumnStats struct {
+	// Title of the column
+	Title string `json:"title,omitempty"`
+	// Type is the schema type of the column, if known
+	Type string `json:"type,omitempty"`
+	// Count is the number of non-null values
+	Count int `json:"count"`
+	// NullCount is the number of null or missing values
+	NullCount int `json:"nullCount"`
+	// Distinct estimates the number of unique non-null values. Estimates are
+	// exact for small numbers of values
+	Distinct int `json:"distinct"`
+	// Min & Max are the smallest & largest number or string values
+	Min interface{} `json:"min,omitempty"`
+	Max interface{} `json:"max,omitempty"`
+	// Mean & StdDev describe number values. StdDev is the population
+	// standard deviation
+	Mean   *float64 `json:"mean,omitempty"`
+	StdDev *float64 `json:"stdDev,omitempty"`
+	// TopValues lists the most frequent string values, most frequent first.
+	// Counts may be overestimates for data with many unique strings
+	TopValues []*ValueCount `json:"topValues,omitempty"`
+	// Histogram buckets number values
+	Histogram *Histogram `json:"histogram,omitempty"`
+}
+
+// ValueCount is the number of times a value occurs
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Histogram counts values in equal-width bins. Bins has one more element
+// than Counts, bin i holds values from Bins[i] up to, but not including,
+// Bins[i+1]. The last bin includes it's upper bound
+type Histogram struct {
+	Bins   []float64 `json:"bins"`
+	Counts []int     `json:"counts"`
+}
+
+// NewStatsRef creates an empty struct with it's internal path set
+func NewStatsRef(path datastore.Key) *Stats {
+	return &Stats{path: path}
+}
+
+// Path gives the internal path reference for this stats
+func (s *Stats) Path() datastore.Key {
+	return s.path
+}
+
+// SetPath sets the internal path property of a Stats
+// Use with caution. most callers should never need to call SetPath
+func (s *Stats) SetPath(path string) {
+	if path == "" {
+		s.path = datastore.Key{}
+	} else {
+		s.path = datastore.NewKey(path)
+	}
+}
+
+// IsEmpty checks to see if stats has any fields other than the internal path
+func (s *Stats) IsEmpty() bool {
+	return s.Columns == nil
+}
+
+// Assign collapses all properties of a group of stats on to one
+// this is directly inspired by Javascript's Object.assign
+func (s *Stats) Assign(stats ...*Stats) {
+	for _, st := range stats {
+		if st == nil {
+			continue
+		}
+		if st.path.String() != "" {
+			s.path = st.path
+		}
+		if st.Qri != "" {
+			s.Qri = st.Qri
+		}
+		if st.Columns != nil {
+			s.Columns = st.Columns
+		}
+	}
+}
+
+// _stats is a private struct for marshaling into & out of
+type _stats Stats
+
+// MarshalJSON satisfies the json.Marshaler interface
+func (s *Stats) MarshalJSON() ([]byte, error) {
+	// if we're dealing with an empty object that has a path specified, marshal to a string instead
+	if s.path.String() != "" && s.IsEmpty() {
+		return s.path.MarshalJSON()
+	}
+	return s.MarshalJSONObject()
+}
+
+// MarshalJSONObject always marshals to a json Object, even if Stats is empty or a reference
+func (s *Stats) MarshalJSONObject() ([]byte, error) {
+	st := _stats(*s)
+	st.Qri = KindStats
+	return json.Marshal(st)
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface
+func (s *Stats) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*s = Stats{path: datastore.NewKey(path)}
+		return nil
+	}
+
+	st := _stats{}
+	if err := json.Unmarshal(data, &st); err != nil {
+		log.Debug(err.Error())
+		return fmt.Errorf("error unmarshaling stats: %s", err.Error())
+	}
+	*s = Stats(st)
+	return nil
+}
+
+// UnmarshalStats tries to extract a stats type from an empty
+// interface. Pairs nicely with datastore.Get() from github.com/ipfs/go-datastore
+func UnmarshalStats(v interface{}) (*Stats, error) {
+	switch r := v.(type) {
+	case *Stats:
+		return r, nil
+	case Stats:
+		return &r, nil
+	case []byte:
+		stats := &Stats{}
+		err := json.Unmarshal(r, stats)
+		return stats, err
+	default:
+		err := fmt.Errorf("couldn't parse stats, value is invalid type")
+		log.Debug(err.Error())
+		return nil, err
+	}
+}
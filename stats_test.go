@@ -0,0 +1,113 @@
+package dataset
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+)
+
+func TestStatsSetPath(t *testing.T) {
+	cases := []struct {
+		path   string
+		expect datastore.Key
+	}{
+		{"", datastore.Key{}},
+		{"path", datastore.NewKey("path")},
+	}
+
+	for i, c := range cases {
+		got := &Stats{}
+		got.SetPath(c.path)
+		if !got.Path().Equal(c.expect) {
+			t.Errorf("case %d path mismatch. expected: %s, got: %s", i, c.expect, got.Path())
+		}
+	}
+}
+
+func TestStatsAssign(t *testing.T) {
+	cols := []*ColumnStats{{Title: "a", Count: 2}}
+	cases := []struct {
+		got    *Stats
+		assign *Stats
+		expect *Stats
+	}{
+		{&Stats{}, nil, &Stats{}},
+		{&Stats{}, &Stats{Qri: KindStats, Columns: cols}, &Stats{Qri: KindStats, Columns: cols}},
+		{&Stats{Qri: KindStats, Columns: cols}, &Stats{}, &Stats{Qri: KindStats, Columns: cols}},
+	}
+
+	for i, c := range cases {
+		c.got.Assign(c.assign)
+		if err := CompareStats(c.expect, c.got); err != nil {
+			t.Errorf("case %d error: %s", i, err)
+		}
+	}
+}
+
+func TestStatsMarshalJSON(t *testing.T) {
+	mean := 1.5
+	cases := []struct {
+		in  *Stats
+		out string
+		err string
+	}{
+		{&Stats{}, `{"qri":"sa:0"}`, ""},
+		{&Stats{path: datastore.NewKey("/map/QmStats")}, `"/map/QmStats"`, ""},
+		{&Stats{Columns: []*ColumnStats{{Title: "a", Count: 2, Distinct: 2, Min: float64(1), Max: float64(2), Mean: &mean}}},
+			`{"qri":"sa:0","columns":[{"title":"a","count":2,"nullCount":0,"distinct":2,"min":1,"max":2,"mean":1.5}]}`, ""},
+	}
+
+	for i, c := range cases {
+		got, err := json.Marshal(c.in)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+			continue
+		}
+		if string(got) != c.out {
+			t.Errorf("case %d, %s != %s", i, c.out, string(got))
+			continue
+		}
+
+		st, err := UnmarshalStats(got)
+		if err != nil {
+			t.Errorf("case %d unexpected unmarshal error: %s", i, err.Error())
+			continue
+		}
+		if c.in.path.String() != "" {
+			if !st.Path().Equal(c.in.path) {
+				t.Errorf("case %d path mismatch. expected: %s, got: %s", i, c.in.path, st.Path())
+			}
+			continue
+		}
+		c.in.Qri = KindStats
+		if err := CompareStats(c.in, st); err != nil {
+			t.Errorf("case %d round trip error: %s", i, err)
+		}
+	}
+}
+
+func TestUnmarshalStats(t *testing.T) {
+	st := Stats{Qri: KindStats}
+	cases := []struct {
+		value interface{}
+		out   *Stats
+		err   string
+	}{
+		{st, &st, ""},
+		{&st, &st, ""},
+		{[]byte(`{"qri":"sa:0"}`), &Stats{Qri: KindStats}, ""},
+		{5, nil, "couldn't parse stats, value is invalid type"},
+	}
+
+	for i, c := range cases {
+		got, err := UnmarshalStats(c.value)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+			continue
+		}
+		if err := CompareStats(c.out, got); err != nil {
+			t.Errorf("case %d stats mismatch: %s", i, err)
+		}
+	}
+}
@@ -29,12 +29,15 @@ type Structure struct {
 	path datastore.Key
 	// Checksum is a bas58-encoded multihash checksum of the entire data
 	// file this structure points to. This is different from IPFS
-	// hashes, which are calculated after breaking the file into blocks
+	// hashes, which are calculated after breaking the file into blocks.
+	// For compressed data the checksum is of the compressed bytes
 	Checksum string `json:"checksum,omitempty"`
 	// Compression specifies any compression on the source data,
-	// if empty assume no compression
+	// if empty assume no compression. Stored data must use gzip or zstd,
+	// bzip2 data can be read but not stored
 	Compression compression.Type `json:"compression,omitempty"`
-	// Encoding specifics character encoding
+	// Encoding specifics character encoding of text data formats,
+	// eg: "utf-8", "utf-16le", "iso-8859-1", "windows-1252".
 	// should assume utf-8 if not specified
 	Encoding string `json:"encoding,omitempty"`
 	// ErrCount is the number of errors returned by validating data
@@ -50,7 +53,8 @@ type Structure struct {
 	// to interpret the speficied format.
 	FormatConfig FormatConfig `json:"formatConfig,omitempty"`
 	// Length is the length of the data object in bytes.
-	// must always match & be present
+	// must always match & be present. For compressed data this is the
+	// compressed length
 	Length int `json:"length,omitempty"`
 	// Qri should always be KindStructure
 	Qri Kind `json:"qri"`
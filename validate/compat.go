@@ -0,0 +1,410 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/qri-io/jsonschema"
+)
+
+// Compatibility classifies how a schema change affects consumers of data.
+// Compatibility is a set of flags, FullyCompatible is both backward &
+// forward compatible
+type Compatibility int
+
+const (
+	// CompatibilityNone means consumers of either schema may break
+	CompatibilityNone Compatibility = 0
+	// BackwardCompatible changes let consumers using the new schema read
+	// data written with the previous schema
+	BackwardCompatible Compatibility = 1 << iota
+	// ForwardCompatible changes let consumers using the previous schema read
+	// data written with the new schema
+	ForwardCompatible
+	// FullyCompatible changes are both backward & forward compatible
+	FullyCompatible = BackwardCompatible | ForwardCompatible
+)
+
+// String implements the stringer interface for Compatibility
+func (c Compatibility) String() string {
+	switch c {
+	case BackwardCompatible:
+		return "backward"
+	case ForwardCompatible:
+		return "forward"
+	case FullyCompatible:
+		return "full"
+	}
+	return "none"
+}
+
+// Satisfies checks if a compatibility meets a required compatibility.
+// Every compatibility satisfies CompatibilityNone
+func (c Compatibility) Satisfies(required Compatibility) bool {
+	return c&required == required
+}
+
+// SchemaChange describes a single difference between two schemas
+type SchemaChange struct {
+	// Kind of change, one of "column added", "column removed",
+	// "column renamed", "column moved", "property added",
+	// "property removed", "type widened", "type narrowed", "type changed",
+	// "required added" or "required removed"
+	Kind string
+	// Path locates the changed column or property, dot-separated, with
+	// array items written as "[]", eg: "address.city" or "tags[]"
+	Path string
+	// From & To describe the previous & new state, if applicable
+	From, To string
+	// Compatibility of this change on it's own
+	Compatibility Compatibility
+}
+
+// String describes a change, eg: `type narrowed "pop": number -> integer`
+func (c SchemaChange) String() string {
+	str := fmt.Sprintf("%s %q", c.Kind, c.Path)
+	if c.From != "" || c.To != "" {
+		str = fmt.Sprintf("%s: %s -> %s", str, c.From, c.To)
+	}
+	return str
+}
+
+// SchemaCompatibility is the result of comparing two schemas
+type SchemaCompatibility struct {
+	// Compatibility of all changes taken together
+	Compatibility Compatibility
+	// Changes lists differences between schemas
+	Changes []SchemaChange
+}
+
+// Error describes changes that don't satisfy a required compatibility,
+// returning nil if the required compatibility is met
+func (sc *SchemaCompatibility) Error(required Compatibility) error {
+	if sc.Compatibility.Satisfies(required) {
+		return nil
+	}
+	var breaking []string
+	for _, c := range sc.Changes {
+		if !c.Compatibility.Satisfies(required) {
+			breaking = append(breaking, c.String())
+		}
+	}
+	return fmt.Errorf("schema change doesn't meet %s compatibility: %s", required, strings.Join(breaking, ", "))
+}
+
+// CompareSchemas checks the compatibility of changing from schema prev to
+// schema next. Tabular columns are matched by title, object properties by
+// key. Changes follow these rules:
+// * adding a column or property is forward compatible, and backward
+// compatible if it's optional: nullable for columns, not required for
+// properties
+// * removing a column or property is backward compatible, and forward
+// compatible if it was optional
+// * renaming or moving a column is incompatible
+// * widening a type, eg: integer to number, or adding "null", is backward
+// compatible. narrowing a type is forward compatible
+// * making a property required is forward compatible, making a property
+// optional is backward compatible
+func CompareSchemas(prev, next *jsonschema.RootSchema) (*SchemaCompatibility, error) {
+	p, err := decodeSchema(prev)
+	if err != nil {
+		return nil, err
+	}
+	n, err := decodeSchema(next)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &SchemaCompatibility{Compatibility: FullyCompatible}
+	sc.Changes = compareSchemaNodes("", p, n)
+	for _, c := range sc.Changes {
+		sc.Compatibility &= c.Compatibility
+	}
+	return sc, nil
+}
+
+// decodeSchema gives the decoded form of a schema
+func decodeSchema(sch *jsonschema.RootSchema) (map[string]interface{}, error) {
+	if sch == nil {
+		return nil, fmt.Errorf("schema is required")
+	}
+	data, err := sch.MarshalJSON()
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error marshaling schema: %s", err.Error())
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error reading schema: %s", err.Error())
+	}
+	return m, nil
+}
+
+// compareSchemaNodes lists changes between two decoded schemas at path
+func compareSchemaNodes(path string, prev, next map[string]interface{}) []SchemaChange {
+	var changes []SchemaChange
+	if c, ok := compareTypes(path, prev, next); ok {
+		changes = append(changes, c)
+	}
+
+	switch pi := prev["items"].(type) {
+	case []interface{}:
+		if ni, ok := next["items"].([]interface{}); ok {
+			changes = append(changes, compareColumns(path, pi, ni)...)
+		}
+	case map[string]interface{}:
+		if ni, ok := next["items"].(map[string]interface{}); ok {
+			// the items of a top-level array are dataset entries, which
+			// don't need a path
+			itemsPath := path + "[]"
+			if path == "" {
+				itemsPath = ""
+			}
+			changes = append(changes, compareSchemaNodes(itemsPath, pi, ni)...)
+		}
+	}
+
+	pp, pok := prev["properties"].(map[string]interface{})
+	np, nok := next["properties"].(map[string]interface{})
+	if pok || nok {
+		changes = append(changes, compareProperties(path, pp, np, requiredSet(prev), requiredSet(next))...)
+	}
+	return changes
+}
+
+// compareColumns lists changes between two lists of tabular columns
+func compareColumns(path string, prev, next []interface{}) []SchemaChange {
+	var changes []SchemaChange
+	pcols, ncols := columnIndex(prev), columnIndex(next)
+
+	for i, col := range prev {
+		pc, _ := col.(map[string]interface{})
+		title := columnTitle(pc, i)
+		colPath := joinPath(path, title)
+
+		j, ok := ncols[title]
+		if !ok {
+			// a column with a new title in the same position & of the same
+			// type is a rename
+			if i < len(next) {
+				nc, _ := next[i].(map[string]interface{})
+				ntitle := columnTitle(nc, i)
+				if _, existed := pcols[ntitle]; !existed && sameTypes(pc, nc) {
+					changes = append(changes, SchemaChange{Kind: "column renamed", Path: colPath, From: title, To: ntitle})
+					continue
+				}
+			}
+			c := SchemaChange{Kind: "column removed", Path: colPath, Compatibility: BackwardCompatible}
+			if nullable(pc) {
+				c.Compatibility = FullyCompatible
+			}
+			changes = append(changes, c)
+			continue
+		}
+
+		if i != j {
+			changes = append(changes, SchemaChange{Kind: "column moved", Path: colPath, From: fmt.Sprintf("%d", i), To: fmt.Sprintf("%d", j)})
+		}
+		nc, _ := next[j].(map[string]interface{})
+		changes = append(changes, compareSchemaNodes(colPath, pc, nc)...)
+	}
+
+	for j, col := range next {
+		nc, _ := col.(map[string]interface{})
+		title := columnTitle(nc, j)
+		if _, ok := pcols[title]; ok {
+			continue
+		}
+		if j < len(prev) {
+			pc, _ := prev[j].(map[string]interface{})
+			if _, kept := ncols[columnTitle(pc, j)]; !kept && sameTypes(pc, nc) {
+				// reported as a rename
+				continue
+			}
+		}
+		c := SchemaChange{Kind: "column added", Path: joinPath(path, title), Compatibility: ForwardCompatible}
+		if nullable(nc) {
+			c.Compatibility = FullyCompatible
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// compareProperties lists changes between two sets of object properties
+func compareProperties(path string, prev, next map[string]interface{}, prevReq, nextReq map[string]bool) []SchemaChange {
+	keys := map[string]bool{}
+	for key := range prev {
+		keys[key] = true
+	}
+	for key := range next {
+		keys[key] = true
+	}
+	for key := range prevReq {
+		keys[key] = true
+	}
+	for key := range nextReq {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []SchemaChange
+	for _, key := range sorted {
+		propPath := joinPath(path, key)
+		ps, pok := prev[key].(map[string]interface{})
+		ns, nok := next[key].(map[string]interface{})
+
+		switch {
+		case pok && !nok:
+			c := SchemaChange{Kind: "property removed", Path: propPath, Compatibility: BackwardCompatible}
+			if !prevReq[key] {
+				c.Compatibility = FullyCompatible
+			}
+			changes = append(changes, c)
+			continue
+		case !pok && nok:
+			c := SchemaChange{Kind: "property added", Path: propPath, Compatibility: ForwardCompatible}
+			if !nextReq[key] {
+				c.Compatibility = FullyCompatible
+			}
+			changes = append(changes, c)
+			continue
+		case pok && nok:
+			changes = append(changes, compareSchemaNodes(propPath, ps, ns)...)
+		}
+
+		if prevReq[key] && !nextReq[key] {
+			changes = append(changes, SchemaChange{Kind: "required removed", Path: propPath, Compatibility: BackwardCompatible})
+		} else if !prevReq[key] && nextReq[key] {
+			changes = append(changes, SchemaChange{Kind: "required added", Path: propPath, Compatibility: ForwardCompatible})
+		}
+	}
+	return changes
+}
+
+// compareTypes checks for a change in the types a schema allows
+func compareTypes(path string, prev, next map[string]interface{}) (SchemaChange, bool) {
+	pt, nt := schemaTypes(prev), schemaTypes(next)
+	wider, narrower := typesCover(nt, pt), typesCover(pt, nt)
+	c := SchemaChange{Path: path, From: typesString(pt), To: typesString(nt)}
+	switch {
+	case wider && narrower:
+		return c, false
+	case wider:
+		c.Kind = "type widened"
+		c.Compatibility = BackwardCompatible
+	case narrower:
+		c.Kind = "type narrowed"
+		c.Compatibility = ForwardCompatible
+	default:
+		c.Kind = "type changed"
+	}
+	return c, true
+}
+
+// schemaTypes lists the types a schema allows, nil allows any type
+func schemaTypes(sch map[string]interface{}) []string {
+	switch t := sch["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if str, ok := v.(string); ok {
+				types = append(types, str)
+			}
+		}
+		sort.Strings(types)
+		return types
+	}
+	return nil
+}
+
+// typesCover checks if every value of types b is a valid value of types a
+func typesCover(a, b []string) bool {
+	if a == nil {
+		return true
+	}
+	if b == nil {
+		return false
+	}
+	for _, bt := range b {
+		if !hasType(a, bt) && !(bt == "integer" && hasType(a, "number")) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasType(types []string, t string) bool {
+	for _, str := range types {
+		if str == t {
+			return true
+		}
+	}
+	return false
+}
+
+func typesString(types []string) string {
+	if types == nil {
+		return "any"
+	}
+	return strings.Join(types, "|")
+}
+
+// sameTypes checks if two schemas allow the same types
+func sameTypes(a, b map[string]interface{}) bool {
+	at, bt := schemaTypes(a), schemaTypes(b)
+	return typesCover(at, bt) && typesCover(bt, at)
+}
+
+// nullable checks if a schema allows null values
+func nullable(sch map[string]interface{}) bool {
+	types := schemaTypes(sch)
+	return types == nil || hasType(types, "null")
+}
+
+// requiredSet gives the required properties of an object schema
+func requiredSet(sch map[string]interface{}) map[string]bool {
+	req := map[string]bool{}
+	if arr, ok := sch["required"].([]interface{}); ok {
+		for _, r := range arr {
+			if key, ok := r.(string); ok {
+				req[key] = true
+			}
+		}
+	}
+	return req
+}
+
+// columnIndex maps column titles to positions
+func columnIndex(cols []interface{}) map[string]int {
+	idx := make(map[string]int, len(cols))
+	for i, col := range cols {
+		c, _ := col.(map[string]interface{})
+		idx[columnTitle(c, i)] = i
+	}
+	return idx
+}
+
+// columnTitle gives the title of a column, defaulting to it's position
+func columnTitle(col map[string]interface{}, i int) string {
+	if title, ok := col["title"].(string); ok && title != "" {
+		return title
+	}
+	return fmt.Sprintf("%d", i)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,125 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/qri-io/jsonschema"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	tabular := func(cols string) *jsonschema.RootSchema {
+		return jsonschema.Must(`{"type":"array","items":{"type":"array","items":[` + cols + `]}}`)
+	}
+	object := func(props, required string) *jsonschema.RootSchema {
+		return jsonschema.Must(`{"type":"array","items":{"type":"object","properties":{` + props + `},"required":[` + required + `]}}`)
+	}
+
+	cases := []struct {
+		description string
+		prev, next  *jsonschema.RootSchema
+		expect      Compatibility
+		changes     []string
+	}{
+		{"no change",
+			tabular(`{"title":"a","type":"string"},{"title":"b","type":"integer"}`),
+			tabular(`{"title":"a","type":"string"},{"title":"b","type":"integer"}`),
+			FullyCompatible, nil},
+		{"column added",
+			tabular(`{"title":"a","type":"string"}`),
+			tabular(`{"title":"a","type":"string"},{"title":"b","type":"integer"}`),
+			ForwardCompatible, []string{`column added "b"`}},
+		{"nullable column added",
+			tabular(`{"title":"a","type":"string"}`),
+			tabular(`{"title":"a","type":"string"},{"title":"b","type":["integer","null"]}`),
+			FullyCompatible, []string{`column added "b"`}},
+		{"column removed",
+			tabular(`{"title":"a","type":"string"},{"title":"b","type":"integer"}`),
+			tabular(`{"title":"a","type":"string"}`),
+			BackwardCompatible, []string{`column removed "b"`}},
+		{"column renamed",
+			tabular(`{"title":"a","type":"string"},{"title":"b","type":"integer"}`),
+			tabular(`{"title":"a","type":"string"},{"title":"c","type":"integer"}`),
+			CompatibilityNone, []string{`column renamed "b": b -> c`}},
+		{"column moved",
+			tabular(`{"title":"a","type":"string"},{"title":"b","type":"integer"}`),
+			tabular(`{"title":"b","type":"integer"},{"title":"a","type":"string"}`),
+			CompatibilityNone, []string{`column moved "a": 0 -> 1`, `column moved "b": 1 -> 0`}},
+		{"type widened",
+			tabular(`{"title":"a","type":"integer"}`),
+			tabular(`{"title":"a","type":"number"}`),
+			BackwardCompatible, []string{`type widened "a": integer -> number`}},
+		{"type narrowed",
+			tabular(`{"title":"a","type":["null","string"]}`),
+			tabular(`{"title":"a","type":"string"}`),
+			ForwardCompatible, []string{`type narrowed "a": null|string -> string`}},
+		{"type changed",
+			tabular(`{"title":"a","type":"integer"}`),
+			tabular(`{"title":"a","type":"boolean"}`),
+			CompatibilityNone, []string{`type changed "a": integer -> boolean`}},
+		{"required added",
+			object(`"a":{"type":"string"}`, ``),
+			object(`"a":{"type":"string"}`, `"a"`),
+			ForwardCompatible, []string{`required added "a"`}},
+		{"required removed",
+			object(`"a":{"type":"string"}`, `"a"`),
+			object(`"a":{"type":"string"}`, ``),
+			BackwardCompatible, []string{`required removed "a"`}},
+		{"properties added & removed",
+			object(`"a":{"type":"string"},"b":{"type":"string"}`, `"a"`),
+			object(`"b":{"type":"string"},"c":{"type":"object","properties":{"d":{"type":"integer"}}}`, ``),
+			BackwardCompatible, []string{`property removed "a"`, `property added "c"`}},
+		{"nested change",
+			object(`"a":{"type":"object","properties":{"b":{"type":"array","items":{"type":"integer"}}}}`, ``),
+			object(`"a":{"type":"object","properties":{"b":{"type":"array","items":{"type":"number"}}}}`, ``),
+			BackwardCompatible, []string{`type widened "a.b[]": integer -> number`}},
+	}
+
+	for _, c := range cases {
+		sc, err := CompareSchemas(c.prev, c.next)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.description, err.Error())
+			continue
+		}
+		if sc.Compatibility != c.expect {
+			t.Errorf("%s: compatibility mismatch. expected: %s, got: %s", c.description, c.expect, sc.Compatibility)
+		}
+		if len(sc.Changes) != len(c.changes) {
+			t.Errorf("%s: change count mismatch. expected: %d, got: %d: %v", c.description, len(c.changes), len(sc.Changes), sc.Changes)
+			continue
+		}
+		for i, ch := range sc.Changes {
+			if ch.String() != c.changes[i] {
+				t.Errorf("%s: change %d mismatch. expected: %s, got: %s", c.description, i, c.changes[i], ch.String())
+			}
+		}
+	}
+
+	if _, err := CompareSchemas(nil, tabular(``)); err == nil || err.Error() != "schema is required" {
+		t.Errorf("expected missing schema error. got: %v", err)
+	}
+}
+
+func TestSchemaCompatibilityError(t *testing.T) {
+	sc := &SchemaCompatibility{
+		Compatibility: ForwardCompatible,
+		Changes: []SchemaChange{
+			{Kind: "column added", Path: "b", Compatibility: ForwardCompatible},
+			{Kind: "column added", Path: "c", Compatibility: FullyCompatible},
+		},
+	}
+	cases := []struct {
+		required Compatibility
+		err      string
+	}{
+		{CompatibilityNone, ""},
+		{ForwardCompatible, ""},
+		{BackwardCompatible, `schema change doesn't meet backward compatibility: column added "b"`},
+		{FullyCompatible, `schema change doesn't meet full compatibility: column added "b"`},
+	}
+	for i, c := range cases {
+		err := sc.Error(c.required)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+		}
+	}
+}
@@ -1,16 +1,26 @@
 package validate
 
 import (
-	"encoding/csv"
 	"fmt"
 	"io"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
 )
 
 // CheckCsvRowLengths ensures that csv input has
 // the same number of columns in every row and otherwise
 // returns an error
 func CheckCsvRowLengths(r io.Reader) error {
-	csvReader := csv.NewReader(r)
+	return CheckCsvRowLengthsOptions(r, nil)
+}
+
+// CheckCsvRowLengthsOptions is CheckCsvRowLengths for csv input read with
+// a set of csv options, which may be nil. If opts specifies
+// VariadicFields, rows are only checked for well-formed csv
+func CheckCsvRowLengthsOptions(r io.Reader, opts *dataset.CSVOptions) error {
+	variadic := opts != nil && opts.VariadicFields
+	csvReader := dsio.NewCSVRecordReader(r, opts)
 	csvReader.FieldsPerRecord = -1
 	csvReader.TrimLeadingSpace = true
 	//csvReader.LazyQuotes = true
@@ -27,7 +37,7 @@ func CheckCsvRowLengths(r io.Reader) error {
 		if err != nil {
 			return err
 		}
-		if len(record) != rowLen {
+		if !variadic && len(record) != rowLen {
 			return fmt.Errorf("error: inconsistent column length on line %d of length %d (rather than %d). ensure all csv columns same length", i, len(record), rowLen)
 		}
 	}
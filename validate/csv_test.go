@@ -3,6 +3,8 @@ package validate
 import (
 	"strings"
 	"testing"
+
+	"github.com/qri-io/dataset"
 )
 
 func TestCheckCsvRowLengths(t *testing.T) {
@@ -25,3 +27,25 @@ func TestCheckCsvRowLengths(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckCsvRowLengthsOptions(t *testing.T) {
+	cases := []struct {
+		input string
+		opts  *dataset.CSVOptions
+		err   string
+	}{
+		{rawText4, nil, "error: inconsistent column length on line 4 of length 2 (rather than 1). ensure all csv columns same length"},
+		{rawText4, &dataset.CSVOptions{VariadicFields: true}, ""},
+		{"a\tb\n1\t2\n3,4\t5\n", &dataset.CSVOptions{Delimiter: '\t'}, ""},
+		{"a\tb\n1\t2\n3\n", &dataset.CSVOptions{Delimiter: '\t'}, "error: inconsistent column length on line 2 of length 1 (rather than 2). ensure all csv columns same length"},
+		{"a,b\n# comment\n1,2\n", &dataset.CSVOptions{Comment: '#'}, ""},
+	}
+
+	for i, c := range cases {
+		r := strings.NewReader(c.input)
+		err := CheckCsvRowLengthsOptions(r, c.opts)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case [%d] error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+		}
+	}
+}
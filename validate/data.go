@@ -2,15 +2,16 @@ package validate
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/qri-io/dataset"
 	"github.com/qri-io/dataset/dsio"
 	"github.com/qri-io/jsonschema"
 )
 
-// EntryReader consumes a reader & returns any validation errors present
-// TODO - refactor this to wrap a reader & return a struct that gives an
-// error or nil on each entry read.
+// EntryReader consumes a reader & returns any validation errors present.
+// EntryReader buffers the entire dataset in memory, for large datasets use
+// a ValidatingReader instead
 func EntryReader(r dsio.EntryReader) ([]jsonschema.ValError, error) {
 	st := r.Structure()
 
@@ -46,3 +47,94 @@ func EntryReader(r dsio.EntryReader) ([]jsonschema.ValError, error) {
 
 	return st.Schema.ValidateBytes(data)
 }
+
+// ValidatingReader wraps an EntryReader, checking each entry against the
+// reader's schema as it's read. ValidatingReader is itself an EntryReader,
+// passing entries through unchanged. Errors of the dataset as a whole are
+// added once the underlying reader reaches the end of it's entries
+type ValidatingReader struct {
+	r         dsio.EntryReader
+	v         *EntryValidator
+	maxErrors int
+	i         int
+	errs      []EntryError
+	done      bool
+}
+
+// NewValidatingReader wraps an EntryReader. Once maxErrors validation errors
+// are found the reader stops, returning io.EOF. A maxErrors of 0 or less
+// never stops early
+func NewValidatingReader(r dsio.EntryReader, maxErrors int) (*ValidatingReader, error) {
+	v, err := NewEntryValidator(r.Structure())
+	if err != nil {
+		return nil, err
+	}
+	return &ValidatingReader{r: r, v: v, maxErrors: maxErrors}, nil
+}
+
+// Structure gives the structure being read
+func (r *ValidatingReader) Structure() *dataset.Structure {
+	return r.r.Structure()
+}
+
+// ReadEntry reads & validates one entry from the underlying reader
+func (r *ValidatingReader) ReadEntry() (dsio.Entry, error) {
+	if r.Stopped() {
+		return dsio.Entry{}, io.EOF
+	}
+
+	ent, err := r.r.ReadEntry()
+	if err == io.EOF && !r.done {
+		r.done = true
+		r.addErrors(r.v.DatasetErrors())
+	}
+	if err != nil {
+		return ent, err
+	}
+
+	errs, err := r.v.EntryErrors(r.i, ent)
+	if err != nil {
+		return ent, err
+	}
+	r.addErrors(errs)
+	r.i++
+	return ent, nil
+}
+
+// addErrors records errors, up to the maximum error count
+func (r *ValidatingReader) addErrors(errs []EntryError) {
+	r.errs = append(r.errs, errs...)
+	if r.Stopped() {
+		r.errs = r.errs[:r.maxErrors]
+	}
+}
+
+// Errors gives validation errors found so far, in the order entries were read
+func (r *ValidatingReader) Errors() []EntryError {
+	return r.errs
+}
+
+// Stopped reports whether the reader has reached its maximum error count
+func (r *ValidatingReader) Stopped() bool {
+	return r.maxErrors > 0 && len(r.errs) >= r.maxErrors
+}
+
+// ValidateEntries reads all entries from a reader, returning up to maxErrors
+// validation errors. A maxErrors of 0 or less returns all errors
+func ValidateEntries(r dsio.EntryReader, maxErrors int) ([]EntryError, error) {
+	vr, err := NewValidatingReader(r, maxErrors)
+	if err != nil {
+		return nil, err
+	}
+	err = dsio.EachEntry(vr, func(i int, ent dsio.Entry, err error) error {
+		if err != nil {
+			return fmt.Errorf("error reading entry %d: %s", i, err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, err
+	}
+	return vr.Errors(), nil
+}
@@ -2,6 +2,7 @@ package validate
 
 import (
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/qri-io/dataset/dsio"
@@ -53,3 +54,85 @@ func TestEntryReader(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateEntries(t *testing.T) {
+	cases := []struct {
+		name      string
+		maxErrors int
+		errors    []EntryError
+	}{
+		{"craigslist", 0, nil},
+		{"movies", 0, []EntryError{
+			{Index: 0, Column: "duration", Path: "/0/1", Value: "", Rule: "type", Message: "type should be integer"},
+			{Index: 1, Column: "duration", Path: "/1/1", Value: "", Rule: "type", Message: "type should be integer"},
+		}},
+		{"movies", 1, []EntryError{
+			{Index: 0, Column: "duration", Path: "/0/1", Value: "", Rule: "type", Message: "type should be integer"},
+		}},
+	}
+
+	for _, c := range cases {
+		tc, err := dstest.NewTestCaseFromDir(fmt.Sprintf("testdata/%s", c.name))
+		if err != nil {
+			t.Errorf("%s: error loading %s", c.name, err.Error())
+			continue
+		}
+
+		r, err := dsio.NewEntryReader(tc.Input.Structure, tc.DataFile())
+		if err != nil {
+			t.Errorf("%s: error creating entry reader: %s", c.name, err.Error())
+			continue
+		}
+
+		errs, err := ValidateEntries(r, c.maxErrors)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.name, err.Error())
+			continue
+		}
+
+		if len(errs) != len(c.errors) {
+			t.Errorf("%s: error length mismatch. expected: %d, got: %d", c.name, len(c.errors), len(errs))
+			continue
+		}
+		for j, e := range errs {
+			if e != c.errors[j] {
+				t.Errorf("%s: validation error %d mismatch. expected: %#v, got: %#v", c.name, j, c.errors[j], e)
+			}
+		}
+	}
+}
+
+func TestValidatingReaderStop(t *testing.T) {
+	tc, err := dstest.NewTestCaseFromDir("testdata/movies")
+	if err != nil {
+		t.Fatalf("error loading test case: %s", err.Error())
+	}
+	r, err := dsio.NewEntryReader(tc.Input.Structure, tc.DataFile())
+	if err != nil {
+		t.Fatalf("error creating entry reader: %s", err.Error())
+	}
+	vr, err := NewValidatingReader(r, 2)
+	if err != nil {
+		t.Fatalf("error creating validating reader: %s", err.Error())
+	}
+
+	read := 0
+	for {
+		if _, err := vr.ReadEntry(); err != nil {
+			if err != io.EOF {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			break
+		}
+		read++
+	}
+	if read != 2 {
+		t.Errorf("expected reader to stop after 2 entries. read: %d", read)
+	}
+	if !vr.Stopped() {
+		t.Errorf("expected reader to be stopped")
+	}
+	if expect := `entry 1 column "duration": type should be integer`; vr.Errors()[1].Error() != expect {
+		t.Errorf("error string mismatch. expected: %s, got: %s", expect, vr.Errors()[1].Error())
+	}
+}
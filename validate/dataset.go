@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
 	"github.com/qri-io/jsonschema"
 )
 
@@ -72,6 +73,16 @@ func Structure(s *dataset.Structure) error {
 		}
 	}
 
+	// stored bodies must be re-writable by merges, migrations & transform
+	// verification, so only compression types with a writer are accepted
+	switch s.Compression {
+	case compression.None, compression.Gzip, compression.Zstd:
+	case compression.Bzip2:
+		return fmt.Errorf("bzip2 compression is read-only, decompress data or use gzip or zstd compression")
+	default:
+		return fmt.Errorf("unsupported compression type: %s", s.Compression)
+	}
+
 	if err := Schema(s.Schema); err != nil {
 		return fmt.Errorf("schema: %s", err.Error())
 	}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/compression"
 	"github.com/qri-io/jsonschema"
 )
 
@@ -65,6 +66,9 @@ func TestStructure(t *testing.T) {
 		{&dataset.Structure{Format: dataset.CSVDataFormat}, "csv data format requires a schema"},
 		// {&dataset.Structure{Format: dataset.CSVDataFormat, Schema: jsonschema.Must(`true`)}, "schema: fields are required"},
 		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: jsonschema.Must(`{ "type" : "array" }`)}, ""},
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: jsonschema.Must(`{ "type" : "array" }`), Compression: compression.Zstd}, ""},
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: jsonschema.Must(`{ "type" : "array" }`), Compression: compression.Bzip2}, "bzip2 compression is read-only, decompress data or use gzip or zstd compression"},
+		{&dataset.Structure{Format: dataset.JSONDataFormat, Schema: jsonschema.Must(`{ "type" : "array" }`), Compression: compression.Tar}, "unsupported compression type: tar"},
 	}
 
 	for i, c := range cases {
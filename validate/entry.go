@@ -0,0 +1,537 @@
+package validate
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/jsonschema"
+)
+
+// EntryValidator checks entries one at a time against the schema of a
+// dataset structure. Unlike EntryReader, EntryValidator never needs to hold
+// the entire dataset in memory, which makes it suitable for validating
+// streams of entries as they're read. Each entry is checked against the
+// root schema, so $ref pointers into the schema resolve. Rules that apply to
+// the dataset as a whole, like minItems or required, are checked by
+// DatasetErrors once all entries are validated, so entries must be validated
+// once each, in order
+type EntryValidator struct {
+	// raw is the decoded root schema
+	raw map[string]interface{}
+	// entries is the root schema without dataset rules, used to validate
+	// entries wrapped in a single-entry array or object
+	entries *jsonschema.RootSchema
+	// tuple caches entry schemas of array datasets with positional items
+	tuple map[int]*jsonschema.RootSchema
+	// contains is the root contains rule applied to wrapped entries
+	contains *jsonschema.RootSchema
+
+	// count is the number of entries validated
+	count int
+	// contained is true once an entry matches contains
+	contained bool
+	// hashes of validated entries, kept when items must be unique
+	hashes map[[sha256.Size]byte]bool
+	// dupes are positions of entries that duplicate an earlier entry
+	dupes []int
+	// keys are object keys validated that dataset rules name
+	keys map[string]bool
+}
+
+// datasetRules are root schema keywords that apply to the dataset as a whole
+// rather than to single entries
+var datasetRules = map[string]bool{
+	"contains":      true,
+	"dependencies":  true,
+	"maxItems":      true,
+	"maxProperties": true,
+	"minItems":      true,
+	"minProperties": true,
+	"required":      true,
+	"uniqueItems":   true,
+}
+
+// EntryError describes a single invalid value within a dataset entry
+type EntryError struct {
+	// Index is the position of the entry in the dataset, -1 for errors of
+	// the dataset as a whole
+	Index int
+	// Key is the key of the entry for object-type datasets
+	Key string
+	// Column is the title of the invalid column, or the property of an
+	// object entry. Empty if the entry as a whole is invalid
+	Column string
+	// Path is the property path of the invalid value from the top level
+	// of the dataset
+	Path string
+	// Value is the offending value
+	Value interface{}
+	// Rule is the schema keyword the value breaks, eg: "type" or "minimum".
+	// Empty if the error can't be attributed to a single keyword
+	Rule string
+	// Message is a human-readable description of the error
+	Message string
+}
+
+// Error implements the error interface
+func (e EntryError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("dataset: %s", e.Message)
+	}
+	if e.Column != "" {
+		return fmt.Sprintf("entry %d column %q: %s", e.Index, e.Column, e.Message)
+	}
+	return fmt.Sprintf("entry %d: %s", e.Index, e.Message)
+}
+
+// NewEntryValidator creates an EntryValidator from a structure's schema
+func NewEntryValidator(st *dataset.Structure) (*EntryValidator, error) {
+	if st == nil || st.Schema == nil {
+		return nil, fmt.Errorf("schema is required")
+	}
+
+	data, err := st.Schema.MarshalJSON()
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error marshaling schema: %s", err.Error())
+	}
+	sch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &sch); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error reading schema: %s", err.Error())
+	}
+
+	v := &EntryValidator{
+		raw:   sch,
+		tuple: map[int]*jsonschema.RootSchema{},
+		keys:  map[string]bool{},
+	}
+	if v.entries, err = v.entrySchema(nil); err != nil {
+		return nil, err
+	}
+	if contains, ok := sch["contains"]; ok {
+		if v.contains, err = v.entrySchema(map[string]interface{}{"items": contains}); err != nil {
+			return nil, err
+		}
+	}
+	if unique, ok := sch["uniqueItems"].(bool); ok && unique {
+		v.hashes = map[[sha256.Size]byte]bool{}
+	}
+
+	return v, nil
+}
+
+// entrySchema creates a schema for validating wrapped entries from the root
+// schema, without dataset rules & with any keywords in replace swapped in
+func (v *EntryValidator) entrySchema(replace map[string]interface{}) (*jsonschema.RootSchema, error) {
+	sch := map[string]interface{}{}
+	for key, val := range v.raw {
+		if !datasetRules[key] {
+			sch[key] = val
+		}
+	}
+	for key, val := range replace {
+		sch[key] = val
+	}
+	return subschema(sch)
+}
+
+// subschema creates a standalone schema from a decoded portion of a parent schema
+func subschema(sch map[string]interface{}) (*jsonschema.RootSchema, error) {
+	data, err := json.Marshal(sch)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error marshaling subschema: %s", err.Error())
+	}
+	rs := &jsonschema.RootSchema{}
+	if err := rs.UnmarshalJSON(data); err != nil {
+		log.Debug(err.Error())
+		return nil, fmt.Errorf("error parsing subschema: %s", err.Error())
+	}
+	return rs, nil
+}
+
+// ValidateEntry checks a single entry at position i, returning any validation
+// errors. Error property paths are relative to the top level of the dataset
+func (v *EntryValidator) ValidateEntry(i int, ent dsio.Entry) ([]jsonschema.ValError, error) {
+	errs, _, err := v.validateEntry(i, ent)
+	if err != nil {
+		return nil, err
+	}
+	prefix := entryPath(i, ent)
+	for j, e := range errs {
+		errs[j].PropertyPath = prefix + e.PropertyPath
+	}
+	return errs, nil
+}
+
+// EntryErrors checks a single entry at position i, describing each invalid
+// value with its position in the dataset & the schema rule it breaks
+func (v *EntryValidator) EntryErrors(i int, ent dsio.Entry) ([]EntryError, error) {
+	errs, sch, err := v.validateEntry(i, ent)
+	if err != nil || len(errs) == 0 {
+		return nil, err
+	}
+
+	prefix := entryPath(i, ent)
+	entErrs := make([]EntryError, len(errs))
+	for j, e := range errs {
+		ee := EntryError{
+			Index:   i,
+			Key:     ent.Key,
+			Path:    prefix + e.PropertyPath,
+			Value:   e.InvalidValue,
+			Message: e.Message,
+		}
+
+		colSchema := sch
+		if segs := strings.Split(strings.TrimPrefix(e.PropertyPath, "/"), "/"); e.PropertyPath != "" {
+			ee.Column, colSchema = columnSchema(sch, segs[0])
+			colSchema = v.resolve(colSchema)
+			val, ok := valueAt(ent.Value, segs)
+			if ok {
+				ee.Value = val
+			}
+			// errors nested within a column are described by the column only
+			if len(segs) > 1 {
+				colSchema = nil
+			}
+		} else if ent.Value != nil {
+			ee.Value = ent.Value
+		}
+		ee.Rule = v.failingRule(colSchema, ee.Value)
+		entErrs[j] = ee
+	}
+	return entErrs, nil
+}
+
+// DatasetErrors checks rules of the root schema that apply to the dataset as
+// a whole against all entries validated so far: minItems, maxItems,
+// uniqueItems, contains, required, minProperties, maxProperties &
+// dependencies. Dependencies given as schemas aren't checked
+func (v *EntryValidator) DatasetErrors() []EntryError {
+	var errs []EntryError
+	add := func(rule, msg string, args ...interface{}) {
+		errs = append(errs, EntryError{Index: -1, Rule: rule, Message: fmt.Sprintf(msg, args...)})
+	}
+
+	if n, ok := v.raw["minItems"].(float64); ok && v.count < int(n) {
+		add("minItems", "%d entries is below the minimum of %d", v.count, int(n))
+	}
+	if n, ok := v.raw["maxItems"].(float64); ok && v.count > int(n) {
+		add("maxItems", "%d entries exceeds the maximum of %d", v.count, int(n))
+	}
+	for _, i := range v.dupes {
+		errs = append(errs, EntryError{Index: i, Rule: "uniqueItems", Message: "entries must be unique, entry duplicates an earlier entry"})
+	}
+	if v.contains != nil && !v.contained {
+		add("contains", "no entry matches the contains schema")
+	}
+	if n, ok := v.raw["minProperties"].(float64); ok && v.count < int(n) {
+		add("minProperties", "%d entries is below the minimum of %d", v.count, int(n))
+	}
+	if n, ok := v.raw["maxProperties"].(float64); ok && v.count > int(n) {
+		add("maxProperties", "%d entries exceeds the maximum of %d", v.count, int(n))
+	}
+	if required, ok := v.raw["required"].([]interface{}); ok {
+		for _, r := range required {
+			if key, ok := r.(string); ok && !v.keys[key] {
+				add("required", "%q value is required", key)
+			}
+		}
+	}
+	if deps, ok := v.raw["dependencies"].(map[string]interface{}); ok {
+		keys := make([]string, 0, len(deps))
+		for key := range deps {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			names, ok := deps[key].([]interface{})
+			if !ok || !v.keys[key] {
+				continue
+			}
+			for _, n := range names {
+				if name, ok := n.(string); ok && !v.keys[name] {
+					add("dependencies", "%q value is required by %q", name, key)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// validateEntry checks a single entry, returning errors with paths relative
+// to the entry, and the decoded schema of the entry. The entry is wrapped
+// in a single-entry array or object & checked against the root schema
+func (v *EntryValidator) validateEntry(i int, ent dsio.Entry) ([]jsonschema.ValError, map[string]interface{}, error) {
+	var (
+		wrapped interface{}
+		prefix  string
+		sch     = v.entries
+		raw     map[string]interface{}
+		err     error
+	)
+	if ent.Key != "" {
+		wrapped = map[string]interface{}{ent.Key: ent.Value}
+		prefix = "/" + ent.Key
+		raw = v.propertySchema(ent.Key)
+	} else {
+		wrapped = []interface{}{ent.Value}
+		prefix = "/0"
+		if sch, raw, err = v.itemSchema(i); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	data, err := json.Marshal(wrapped)
+	if err != nil {
+		log.Debug(err.Error())
+		return nil, nil, fmt.Errorf("error marshaling entry %d: %s", i, err.Error())
+	}
+	v.track(ent, data)
+
+	errs, err := sch.ValidateBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v.contains != nil && !v.contained {
+		if cerrs, err := v.contains.ValidateBytes(data); err == nil && len(cerrs) == 0 {
+			v.contained = true
+		}
+	}
+
+	for j, e := range errs {
+		errs[j].PropertyPath = strings.TrimPrefix(e.PropertyPath, prefix)
+	}
+	return errs, raw, nil
+}
+
+// track records what dataset rules need to know of a validated entry
+func (v *EntryValidator) track(ent dsio.Entry, wrapped []byte) {
+	if v.hashes != nil && ent.Key == "" {
+		sum := sha256.Sum256(wrapped)
+		if v.hashes[sum] {
+			v.dupes = append(v.dupes, v.count)
+		}
+		v.hashes[sum] = true
+	}
+	if ent.Key != "" && v.named(ent.Key) {
+		v.keys[ent.Key] = true
+	}
+	v.count++
+}
+
+// named reports whether a dataset rule names an object key
+func (v *EntryValidator) named(key string) bool {
+	if required, ok := v.raw["required"].([]interface{}); ok {
+		for _, r := range required {
+			if r == key {
+				return true
+			}
+		}
+	}
+	deps, _ := v.raw["dependencies"].(map[string]interface{})
+	for k, d := range deps {
+		if k == key {
+			return true
+		}
+		names, _ := d.([]interface{})
+		for _, n := range names {
+			if n == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// itemSchema gives the schema for validating entry i of an array dataset,
+// and the decoded schema of the entry
+func (v *EntryValidator) itemSchema(i int) (*jsonschema.RootSchema, map[string]interface{}, error) {
+	list, ok := v.raw["items"].([]interface{})
+	if !ok {
+		items, _ := v.raw["items"].(map[string]interface{})
+		return v.entries, v.resolve(items), nil
+	}
+
+	// positional items give each index it's own schema, entries past the
+	// listed items share additionalItems
+	idx := i
+	var item interface{}
+	if i < len(list) {
+		item = list[i]
+	} else {
+		idx = len(list)
+		item = v.raw["additionalItems"]
+	}
+	raw, _ := item.(map[string]interface{})
+	if sch, ok := v.tuple[idx]; ok {
+		return sch, v.resolve(raw), nil
+	}
+	replace := map[string]interface{}{"items": []interface{}{}, "additionalItems": true}
+	if item != nil {
+		replace["items"] = []interface{}{item}
+	}
+	sch, err := v.entrySchema(replace)
+	if err != nil {
+		return nil, nil, err
+	}
+	v.tuple[idx] = sch
+	return sch, v.resolve(raw), nil
+}
+
+// propertySchema gives the decoded schema of an object dataset entry
+func (v *EntryValidator) propertySchema(key string) map[string]interface{} {
+	if props, ok := v.raw["properties"].(map[string]interface{}); ok {
+		if prop, ok := props[key].(map[string]interface{}); ok {
+			return v.resolve(prop)
+		}
+	}
+	add, _ := v.raw["additionalProperties"].(map[string]interface{})
+	return v.resolve(add)
+}
+
+// resolve follows $ref pointers into the root schema
+func (v *EntryValidator) resolve(sch map[string]interface{}) map[string]interface{} {
+	// limit the number of hops to stop circular references
+	for i := 0; i < 32 && sch != nil; i++ {
+		ref, ok := sch["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#") {
+			return sch
+		}
+		var cur interface{} = v.raw
+		for _, seg := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+			if seg == "" {
+				continue
+			}
+			seg = strings.Replace(strings.Replace(seg, "~1", "/", -1), "~0", "~", -1)
+			switch c := cur.(type) {
+			case map[string]interface{}:
+				cur = c[seg]
+			case []interface{}:
+				idx, err := strconv.Atoi(seg)
+				if err != nil || idx < 0 || idx >= len(c) {
+					return nil
+				}
+				cur = c[idx]
+			default:
+				return nil
+			}
+		}
+		sch, _ = cur.(map[string]interface{})
+	}
+	return sch
+}
+
+// entryPath gives the property path of an entry
+func entryPath(i int, ent dsio.Entry) string {
+	if ent.Key != "" {
+		return "/" + ent.Key
+	}
+	return fmt.Sprintf("/%d", i)
+}
+
+// columnSchema gives the title & decoded schema of a column of an entry
+// schema, where seg is a column index or object key
+func columnSchema(sch map[string]interface{}, seg string) (string, map[string]interface{}) {
+	if idx, err := strconv.Atoi(seg); err == nil {
+		switch items := sch["items"].(type) {
+		case []interface{}:
+			if idx < len(items) {
+				col, _ := items[idx].(map[string]interface{})
+				if title, ok := col["title"].(string); ok && title != "" {
+					return title, col
+				}
+				return seg, col
+			}
+			add, _ := sch["additionalItems"].(map[string]interface{})
+			return seg, add
+		case map[string]interface{}:
+			return seg, items
+		}
+	}
+	if props, ok := sch["properties"].(map[string]interface{}); ok {
+		if prop, ok := props[seg].(map[string]interface{}); ok {
+			return seg, prop
+		}
+	}
+	add, _ := sch["additionalProperties"].(map[string]interface{})
+	return seg, add
+}
+
+// valueAt finds the value at a property path within a value
+func valueAt(v interface{}, path []string) (interface{}, bool) {
+	for _, seg := range path {
+		switch val := v.(type) {
+		case []interface{}:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(val) {
+				return nil, false
+			}
+			v = val[idx]
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = val[seg]; !ok {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// schema keywords that don't validate values on their own
+var nonRuleKeywords = map[string]bool{
+	"$comment":    true,
+	"$id":         true,
+	"$schema":     true,
+	"default":     true,
+	"description": true,
+	"examples":    true,
+	"title":       true,
+}
+
+// failingRule finds the first keyword of a schema that rejects a value by
+// checking each keyword in isolation, alongside root definitions so $ref
+// pointers to them resolve. Returns an empty string if no single keyword
+// rejects the value
+func (v *EntryValidator) failingRule(sch map[string]interface{}, value interface{}) string {
+	if sch == nil {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+
+	keys := make([]string, 0, len(sch))
+	for key := range sch {
+		if !nonRuleKeywords[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		rule := map[string]interface{}{key: sch[key]}
+		if defs, ok := v.raw["definitions"]; ok && key != "definitions" {
+			rule["definitions"] = defs
+		}
+		rs, err := subschema(rule)
+		if err != nil {
+			continue
+		}
+		if errs, err := rs.ValidateBytes(data); err == nil && len(errs) > 0 {
+			return key
+		}
+	}
+	return ""
+}
@@ -0,0 +1,177 @@
+package validate
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/qri-io/dataset"
+	"github.com/qri-io/dataset/dsio"
+	"github.com/qri-io/dataset/dstest"
+	"github.com/qri-io/jsonschema"
+)
+
+func TestNewEntryValidator(t *testing.T) {
+	cases := []struct {
+		st  *dataset.Structure
+		err string
+	}{
+		{nil, "schema is required"},
+		{&dataset.Structure{}, "schema is required"},
+		{&dataset.Structure{Schema: dataset.BaseSchemaArray}, ""},
+		{namesStructure, ""},
+	}
+
+	for i, c := range cases {
+		_, err := NewEntryValidator(c.st)
+		if !(err == nil && c.err == "" || err != nil && err.Error() == c.err) {
+			t.Errorf("case %d error mismatch. expected: '%s', got: '%s'", i, c.err, err)
+			continue
+		}
+	}
+}
+
+func TestEntryValidator(t *testing.T) {
+	cases := []struct {
+		name   string
+		errors []string
+	}{
+		{"craigslist", nil},
+		{"movies", []string{
+			`/0/1: "" type should be integer`,
+			`/1/1: "" type should be integer`,
+		}},
+	}
+
+	for _, c := range cases {
+		tc, err := dstest.NewTestCaseFromDir(fmt.Sprintf("testdata/%s", c.name))
+		if err != nil {
+			t.Errorf("%s: error loading %s", c.name, err.Error())
+			continue
+		}
+
+		v, err := NewEntryValidator(tc.Input.Structure)
+		if err != nil {
+			t.Errorf("%s: error creating validator: %s", c.name, err.Error())
+			continue
+		}
+
+		r, err := dsio.NewEntryReader(tc.Input.Structure, tc.DataFile())
+		if err != nil {
+			t.Errorf("%s: error creating entry reader: %s", c.name, err.Error())
+			continue
+		}
+
+		errs := []string{}
+		err = dsio.EachEntry(r, func(i int, ent dsio.Entry, err error) error {
+			if err != nil {
+				return err
+			}
+			valErrs, err := v.ValidateEntry(i, ent)
+			for _, e := range valErrs {
+				errs = append(errs, e.Error())
+			}
+			return err
+		})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.name, err.Error())
+			continue
+		}
+
+		if len(errs) != len(c.errors) {
+			t.Errorf("%s: error length mismatch. expected: %d, got: %d", c.name, len(c.errors), len(errs))
+			continue
+		}
+		for j, e := range errs {
+			if e != c.errors[j] {
+				t.Errorf("%s: validation error %d mismatch. expected: %s, got: %s", c.name, j, c.errors[j], e)
+			}
+		}
+	}
+}
+
+func TestEntryErrors(t *testing.T) {
+	sch := &jsonschema.RootSchema{}
+	if err := sch.UnmarshalJSON([]byte(`{
+		"type": "object",
+		"additionalProperties": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {
+				"name": { "type": "string" },
+				"tags": { "type": "array", "items": { "type": "string" } }
+			}
+		}
+	}`)); err != nil {
+		t.Fatalf("error parsing schema: %s", err.Error())
+	}
+	v, err := NewEntryValidator(&dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch})
+	if err != nil {
+		t.Fatalf("error creating validator: %s", err.Error())
+	}
+
+	cases := []struct {
+		ent    dsio.Entry
+		expect []EntryError
+	}{
+		{dsio.Entry{Key: "a", Value: map[string]interface{}{"name": "a"}}, nil},
+		{dsio.Entry{Key: "b", Value: map[string]interface{}{"name": float64(1)}}, []EntryError{
+			{Index: 1, Key: "b", Column: "name", Path: "/b/name", Value: float64(1), Rule: "type"},
+		}},
+		{dsio.Entry{Key: "c", Value: map[string]interface{}{"name": "c", "tags": []interface{}{"x", false}}}, []EntryError{
+			{Index: 2, Key: "c", Column: "tags", Path: "/c/tags/1", Value: false},
+		}},
+	}
+
+	for i, c := range cases {
+		errs, err := v.EntryErrors(i, c.ent)
+		if err != nil {
+			t.Errorf("case %d unexpected error: %s", i, err.Error())
+			continue
+		}
+		if len(errs) != len(c.expect) {
+			t.Errorf("case %d error length mismatch. expected: %d, got: %d: %v", i, len(c.expect), len(errs), errs)
+			continue
+		}
+		for j, e := range errs {
+			e.Message = ""
+			if e != c.expect[j] {
+				t.Errorf("case %d error %d mismatch. expected: %#v, got: %#v", i, j, c.expect[j], e)
+			}
+		}
+	}
+}
+
+func TestEntryValidatorDatasetErrors(t *testing.T) {
+	sch := &jsonschema.RootSchema{}
+	if err := sch.UnmarshalJSON([]byte(`{
+		"type": "object",
+		"definitions": {
+			"city": {"type": "object", "properties": {"pop": {"type": "integer"}}}
+		},
+		"required": ["toronto", "chicago"],
+		"additionalProperties": {"$ref": "#/definitions/city"}
+	}`)); err != nil {
+		t.Fatalf("error parsing schema: %s", err.Error())
+	}
+	v, err := NewEntryValidator(&dataset.Structure{Format: dataset.JSONDataFormat, Schema: sch})
+	if err != nil {
+		t.Fatalf("error creating validator: %s", err.Error())
+	}
+
+	errs, err := v.EntryErrors(0, dsio.Entry{Key: "toronto", Value: map[string]interface{}{"pop": "lots"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if len(errs) != 1 || errs[0].Path != "/toronto/pop" {
+		t.Errorf("expected $ref to resolve to a single error at /toronto/pop, got: %v", errs)
+	}
+
+	dsErrs := v.DatasetErrors()
+	if len(dsErrs) != 1 {
+		t.Fatalf("dataset error length mismatch. expected: 1, got: %d: %v", len(dsErrs), dsErrs)
+	}
+	expect := EntryError{Index: -1, Rule: "required", Message: `"chicago" value is required`}
+	if dsErrs[0] != expect {
+		t.Errorf("dataset error mismatch. expected: %#v, got: %#v", expect, dsErrs[0])
+	}
+}